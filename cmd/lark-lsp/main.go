@@ -0,0 +1,17 @@
+// Lark-lsp is a Language Server Protocol server for Lark. It communicates
+// with the editor over standard input and output.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"larklang.io/lark/pkg/lsp"
+)
+
+func main() {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
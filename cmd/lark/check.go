@@ -0,0 +1,25 @@
+package main
+
+import "larklang.io/lark/pkg/schema"
+
+// runCheck loads files with the modules they import, checks them and
+// reports all diagnostics.
+func runCheck(cmd *command, args []string) int {
+	var lf loadFlags
+	flags := cmd.flagSet()
+	format := flags.String("format", "text", "diagnostics output `format`: text, json or sarif")
+	lf.register(flags)
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+	if !validFormat(*format, "text", "json", "sarif") {
+		return exitError
+	}
+
+	prog, code := load(&lf, flags.Args())
+	if prog == nil {
+		return code
+	}
+	schema.Check(prog)
+	return report(*format, prog.Diagnostics())
+}
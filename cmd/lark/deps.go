@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"larklang.io/lark/pkg/loader"
+)
+
+type depsFile struct {
+	Path    string       `json:"path"`
+	Imports []depsImport `json:"imports"`
+}
+
+type depsImport struct {
+	Name string `json:"name"`
+	Path string `json:"path"`           // import path
+	File string `json:"file,omitempty"` // resolved file, empty if not found
+}
+
+// runDeps prints the files of a program, each after the files it imports,
+// together with their imports.
+func runDeps(cmd *command, args []string) int {
+	var lf loadFlags
+	flags := cmd.flagSet()
+	format := flags.String("format", "text", "output `format`: text, dot or json")
+	lf.register(flags)
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+	if !validFormat(*format, "text", "dot", "json") {
+		return exitError
+	}
+
+	prog, code := load(&lf, flags.Args())
+	if prog == nil {
+		return code
+	}
+
+	var err error
+	switch *format {
+	case "text":
+		writeDepsText(os.Stdout, prog)
+	case "dot":
+		writeDepsDot(os.Stdout, prog)
+	case "json":
+		err = writeDepsJSON(os.Stdout, prog)
+	}
+	if err != nil {
+		return errorf("%v", err)
+	}
+	return report("text", prog.Diagnostics())
+}
+
+func writeDepsText(w io.Writer, prog *loader.Program) {
+	for _, file := range prog.Files {
+		fmt.Fprintln(w, file.Path)
+		for _, imp := range file.Imports {
+			if imp.File != nil {
+				fmt.Fprintf(w, "\t%s\n", imp.File.Path)
+			} else {
+				fmt.Fprintf(w, "\t%q (not found)\n", imp.Path)
+			}
+		}
+	}
+}
+
+func writeDepsDot(w io.Writer, prog *loader.Program) {
+	fmt.Fprintln(w, "digraph deps {")
+	for _, file := range prog.Files {
+		fmt.Fprintf(w, "\t%s;\n", strconv.Quote(file.Path))
+		for _, imp := range file.Imports {
+			if imp.File != nil {
+				fmt.Fprintf(w, "\t%s -> %s;\n", strconv.Quote(file.Path), strconv.Quote(imp.File.Path))
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeDepsJSON(w io.Writer, prog *loader.Program) error {
+	files := []depsFile{}
+	for _, file := range prog.Files {
+		f := depsFile{Path: file.Path, Imports: []depsImport{}}
+		for _, imp := range file.Imports {
+			dep := depsImport{Name: imp.Name, Path: imp.Path}
+			if imp.File != nil {
+				dep.File = imp.File.Path
+			}
+			f.Imports = append(f.Imports, dep)
+		}
+		files = append(files, f)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(files)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/format"
+	"larklang.io/lark/pkg/loader"
+)
+
+// runDoc prints a summary of the declarations of a file or the
+// documentation of one declaration. The name may be qualified by the name
+// of an imported module and followed by the name of a field or method.
+func runDoc(cmd *command, args []string) int {
+	var lf loadFlags
+	flags := cmd.flagSet()
+	lf.register(flags)
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	prog, code := load(&lf, flags.Args()[:1])
+	if prog == nil {
+		return code
+	}
+	if prog.HasErrors() {
+		return report("text", prog.Diagnostics())
+	}
+
+	file := prog.Roots[0]
+	if flags.NArg() == 1 {
+		summary(os.Stdout, file)
+		return exitSuccess
+	}
+
+	parts := strings.Split(flags.Arg(1), ".")
+	if imp := file.Lookup(parts[0]); imp != nil && imp.File != nil && len(parts) > 1 {
+		file, parts = imp.File, parts[1:]
+	}
+	if len(parts) > 2 {
+		return errorf("invalid name %q", flags.Arg(1))
+	}
+
+	decl := lookup(file, parts[0])
+	if decl != nil && len(parts) == 2 {
+		decl = member(decl, parts[1])
+	}
+	if decl == nil {
+		return errorf("no declaration %s in %s", flags.Arg(1), file.Path)
+	}
+	document(os.Stdout, decl)
+	return exitSuccess
+}
+
+func lookup(file *loader.File, name string) ast.Node {
+	for _, sym := range file.Symtab {
+		if sym.Name.Name == name {
+			return sym.Decl
+		}
+	}
+	return nil
+}
+
+func member(decl ast.Node, name string) ast.Node {
+	switch n := decl.(type) {
+	case *ast.Struct:
+		for _, field := range n.Fields {
+			if field.Name.Name == name {
+				return field
+			}
+		}
+	case *ast.Interface:
+		for _, method := range n.Methods {
+			if method.Name.Name == name {
+				return method
+			}
+		}
+	case *ast.Enum:
+		for _, m := range n.Members {
+			if m.Name.Name == name {
+				return m
+			}
+		}
+	case *ast.Union:
+		for _, v := range n.Variants {
+			if v.Name.Name == name {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func docOf(decl ast.Node) *ast.CommentGroup {
+	switch n := decl.(type) {
+	case *ast.ConstSpec:
+		return n.Doc
+	case *ast.TypeAlias:
+		return n.Doc
+	case *ast.Struct:
+		return n.Doc
+	case *ast.Interface:
+		return n.Doc
+	case *ast.Enum:
+		return n.Doc
+	case *ast.EnumMember:
+		return n.Doc
+	case *ast.Union:
+		return n.Doc
+	case *ast.Variant:
+		return n.Doc
+	case *ast.Field:
+		return n.Doc
+	case *ast.Method:
+		return n.Doc
+	}
+	return nil
+}
+
+// document writes the source of decl followed by its indented doc comment.
+func document(w io.Writer, decl ast.Node) {
+	var buf bytes.Buffer
+	format.Node(&buf, decl)
+	w.Write(buf.Bytes())
+
+	if text := docOf(decl).Text(); text != "" {
+		fmt.Fprintln(w)
+		for _, line := range strings.Split(text, "\n") {
+			if line == "" {
+				fmt.Fprintln(w)
+			} else {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
+}
+
+// summary writes one line for each declaration of file.
+func summary(w io.Writer, file *loader.File) {
+	for _, sym := range file.Symtab {
+		switch decl := sym.Decl.(type) {
+		case *ast.Struct:
+			fmt.Fprintf(w, "struct %s%s\n", decl.Name.Name, elided(len(decl.Fields)))
+		case *ast.Interface:
+			fmt.Fprintf(w, "interface %s%s\n", decl.Name.Name, elided(len(decl.Methods)))
+		case *ast.Enum:
+			fmt.Fprintf(w, "enum %s%s\n", decl.Name.Name, elided(len(decl.Members)))
+		case *ast.Union:
+			fmt.Fprintf(w, "union %s%s\n", decl.Name.Name, elided(len(decl.Variants)))
+		default:
+			var buf bytes.Buffer
+			format.Node(&buf, decl)
+			w.Write(buf.Bytes())
+		}
+	}
+}
+
+func elided(members int) string {
+	if members == 0 {
+		return " {}"
+	}
+	return " { ... }"
+}
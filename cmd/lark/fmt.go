@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+
+	"larklang.io/lark/internal/fmtfile"
+)
+
+// runFmt formats files like larkfmt. With -l or -d, unformatted files
+// result in exit status 1.
+func runFmt(cmd *command, args []string) int {
+	flags := cmd.flagSet()
+	list := flags.Bool("l", false, "list files whose formatting differs from the canonical style")
+	diffs := flags.Bool("d", false, "display diffs instead of rewriting files")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+
+	srcs, err := sources(flags.Args())
+	if err != nil {
+		return errorf("%v", err)
+	}
+
+	opts := fmtfile.Options{List: *list, Diff: *diffs, Write: *write}
+	code := exitSuccess
+	for _, src := range srcs {
+		text := src.Src
+		if text == nil {
+			if text, err = os.ReadFile(src.Path); err != nil {
+				code = errorf("%v", err)
+				continue
+			}
+		} else if *write {
+			code = errorf("cannot use -w with standard input")
+			continue
+		}
+
+		changed, err := opts.Process(src.Path, text, os.Stdout)
+		if err != nil {
+			code = errorf("%v", err)
+			continue
+		}
+		if changed && (*list || *diffs) {
+			code = max(code, exitWarning)
+		}
+	}
+	return code
+}
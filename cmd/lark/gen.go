@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+	_ "larklang.io/lark/pkg/gen/avro"
+	_ "larklang.io/lark/pkg/gen/c"
+	_ "larklang.io/lark/pkg/gen/doc"
+	_ "larklang.io/lark/pkg/gen/golang"
+	_ "larklang.io/lark/pkg/gen/graphql"
+	_ "larklang.io/lark/pkg/gen/jsonschema"
+	_ "larklang.io/lark/pkg/gen/openapi"
+	_ "larklang.io/lark/pkg/gen/proto"
+	_ "larklang.io/lark/pkg/gen/python"
+	_ "larklang.io/lark/pkg/gen/rust"
+	_ "larklang.io/lark/pkg/gen/sql"
+	_ "larklang.io/lark/pkg/gen/typescript"
+	"larklang.io/lark/pkg/plugin"
+	"larklang.io/lark/pkg/schema"
+)
+
+// paramList is a flag that collects generator parameters.
+type paramList gen.Params
+
+func (p paramList) String() string { return "" }
+
+func (p paramList) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("parameter %q is not of the form name=value", value)
+	}
+	p[name] = val
+	return nil
+}
+
+// runGen checks files and, if there are no errors, runs a builtin
+// generator or a plugin and writes the files it produces below the output
+// directory.
+func runGen(cmd *command, args []string) int {
+	var lf loadFlags
+	params := gen.Params{}
+	flags := cmd.flagSet()
+	lang := flags.String("lang", "", "builtin generator `name`")
+	pluginName := flags.String("plugin", "", "run the plugin lark-gen-`name` from the PATH")
+	out := flags.String("out", ".", "output `dir`ectory")
+	flags.Var(paramList(params), "param", "pass `name=value` to the generator (may be repeated)")
+	lf.register(flags)
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+
+	var g gen.Generator
+	name := *lang
+	switch {
+	case *lang != "" && *pluginName != "":
+		return errorf("gen: -lang and -plugin are mutually exclusive")
+	case *pluginName != "":
+		bin, err := plugin.Lookup(*pluginName)
+		if err != nil {
+			return errorf("%v", err)
+		}
+		g, name = bin, *pluginName
+	default:
+		var ok bool
+		if g, ok = gen.Lookup(*lang); !ok {
+			names := strings.Join(gen.Names(), ", ")
+			if names == "" {
+				names = "none"
+			}
+			return errorf("unknown generator %q (available: %s)", *lang, names)
+		}
+	}
+
+	prog, code := load(&lf, flags.Args())
+	if prog == nil {
+		return code
+	}
+	s := schema.Check(prog)
+	if code = report("text", prog.Diagnostics()); code == exitError {
+		return code
+	}
+
+	files, err := g.Generate(s, params)
+	if err != nil {
+		return errorf("%s: %v", name, err)
+	}
+	for _, file := range files {
+		if err := writeFile(*out, file); err != nil {
+			return errorf("%v", err)
+		}
+	}
+	return code
+}
+
+func writeFile(dir string, file gen.File) error {
+	name := filepath.FromSlash(file.Name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("generated file name %q is not a relative path", file.Name)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, file.Content, 0o644)
+}
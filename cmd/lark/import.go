@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+
+	"larklang.io/lark/pkg/convert/avro"
+	"larklang.io/lark/pkg/convert/jsonschema"
+	"larklang.io/lark/pkg/convert/protobuf"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/gen"
+)
+
+// importExts maps the input languages of import to the extensions of
+// their files.
+var importExts = map[string]string{
+	"proto":      ".proto",
+	"jsonschema": ".json",
+	"avro":       ".avsc",
+}
+
+// runImport converts files of another schema language to Lark. The
+// converted files are written below the output directory, or to standard
+// output without one; what cannot be converted is reported as diagnostics.
+func runImport(cmd *command, args []string) int {
+	var path pathList
+	flags := cmd.flagSet()
+	from := flags.String("from", "proto", "input `language`: proto, jsonschema or avro")
+	namespace := flags.String("namespace", "", "Avro namespace `prefix` left out of module paths")
+	out := flags.String("out", "", "output `dir`ectory; standard output if empty")
+	flags.Var(&path, "I", "resolve imports of the input relative to `dir` (may be repeated)")
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+
+	ext, ok := importExts[*from]
+	if !ok {
+		return errorf("import: unknown input language %q (available: proto, jsonschema, avro)", *from)
+	}
+	srcs, err := sourcesOf(flags.Args(), ext)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	for _, src := range srcs {
+		if src.Path == stdinName && *out != "" {
+			return errorf("import: -out needs file paths, not standard input")
+		}
+	}
+
+	var files []gen.File
+	var diags []diag.File
+	add := func(source, name string, content []byte, lines []string, diagnostics []diag.Diagnostic) {
+		diags = append(diags, diag.File{Name: source, Lines: lines, Diagnostics: diagnostics})
+		if content != nil {
+			files = append(files, gen.File{Name: name, Content: content})
+		}
+	}
+	switch *from {
+	case "proto":
+		var protos []protobuf.Source
+		for _, src := range srcs {
+			protos = append(protos, protobuf.Source{Path: src.Path, Src: src.Src})
+		}
+		c := &protobuf.Config{Path: path}
+		converted, err := c.Convert(protos...)
+		if err != nil {
+			return errorf("%v", err)
+		}
+		for _, file := range converted {
+			add(file.Source, file.Path, file.Content, file.Lines, file.Diagnostics)
+		}
+	case "jsonschema":
+		var docs []jsonschema.Source
+		for _, src := range srcs {
+			docs = append(docs, jsonschema.Source{Path: src.Path, Src: src.Src})
+		}
+		converted, err := new(jsonschema.Config).Convert(docs...)
+		if err != nil {
+			return errorf("%v", err)
+		}
+		for _, file := range converted {
+			add(file.Source, file.Path, file.Content, file.Lines, file.Diagnostics)
+		}
+	case "avro":
+		var schemas []avro.Source
+		for _, src := range srcs {
+			schemas = append(schemas, avro.Source{Path: src.Path, Src: src.Src})
+		}
+		c := &avro.Config{Namespace: *namespace}
+		modules, converted, err := c.Convert(schemas...)
+		if err != nil {
+			return errorf("%v", err)
+		}
+		for _, file := range converted {
+			add(file.Path, "", nil, file.Lines, file.Diagnostics)
+		}
+		for _, m := range modules {
+			files = append(files, gen.File{Name: m.Path, Content: m.Content})
+		}
+	}
+
+	code := report("text", diags)
+	for _, file := range files {
+		if *out == "" {
+			header(os.Stdout, len(files), file.Name)
+			os.Stdout.Write(file.Content)
+			continue
+		}
+		if err := writeFile(*out, file); err != nil {
+			return errorf("%v", err)
+		}
+	}
+	return code
+}
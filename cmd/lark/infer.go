@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+
+	"larklang.io/lark/pkg/convert/infer"
+	"larklang.io/lark/pkg/diag"
+)
+
+// runInfer infers Lark structs from sample JSON and NDJSON documents and
+// writes them to the output file, or to standard output without one.
+// Nothing is written if a sample is malformed.
+func runInfer(cmd *command, args []string) int {
+	flags := cmd.flagSet()
+	name := flags.String("name", "Sample", "`name` of the root struct")
+	out := flags.String("out", "", "output `file`; standard output if empty")
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+
+	srcs, err := sourcesOf(flags.Args(), ".json", ".ndjson", ".jsonl")
+	if err != nil {
+		return errorf("%v", err)
+	}
+	var samples []infer.Source
+	for _, src := range srcs {
+		samples = append(samples, infer.Source{Path: src.Path, Src: src.Src})
+	}
+	c := &infer.Config{Name: *name}
+	content, files, err := c.Infer(samples...)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	var diags []diag.File
+	for _, file := range files {
+		diags = append(diags, diag.File{Name: file.Path, Lines: file.Lines, Diagnostics: file.Diagnostics})
+	}
+	code := report("text", diags)
+	if code == exitError {
+		return code
+	}
+	if content == nil {
+		return errorf("infer: no samples")
+	}
+	if *out == "" {
+		os.Stdout.Write(content)
+		return code
+	}
+	if err := os.WriteFile(*out, content, 0o644); err != nil {
+		return errorf("%v", err)
+	}
+	return code
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/parser"
+)
+
+const stdinName = "<standard input>"
+
+// pathList is a flag that may be repeated to collect directories.
+type pathList []string
+
+func (p *pathList) String() string       { return strings.Join(*p, string(filepath.ListSeparator)) }
+func (p *pathList) Set(dir string) error { *p = append(*p, dir); return nil }
+
+// loadFlags are the flags of commands that load files and their imports.
+type loadFlags struct {
+	maxErrors int
+	path      pathList
+}
+
+func (f *loadFlags) register(flags *flag.FlagSet) {
+	flags.IntVar(&f.maxErrors, "max-errors", parser.DefaultMaxErrors, "stop after `n` errors per file, 0 means no limit")
+	flags.Var(&f.path, "I", "search `dir` for imported modules (may be repeated)")
+}
+
+func (f *loadFlags) config() *loader.Config {
+	return &loader.Config{Path: f.path, MaxErrors: f.maxErrors}
+}
+
+// sources expands the path arguments into the Lark files to process.
+// Without arguments, or for the argument "-", standard input is read.
+func sources(args []string) ([]loader.Source, error) {
+	return sourcesOf(args, ".lark")
+}
+
+// sourcesOf expands the path arguments into the files to process;
+// directories are searched for files with one of the extensions exts.
+func sourcesOf(args []string, exts ...string) ([]loader.Source, error) {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	var srcs []loader.Source
+	for _, arg := range args {
+		if arg == "-" {
+			src, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			srcs = append(srcs, loader.Source{Path: stdinName, Src: src})
+			continue
+		}
+
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			srcs = append(srcs, loader.Source{Path: arg})
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && slices.Contains(exts, filepath.Ext(path)) {
+				srcs = append(srcs, loader.Source{Path: path})
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return srcs, nil
+}
+
+// status returns the exit code for the diagnostics of files.
+func status(files []diag.File) int {
+	code := exitSuccess
+	for _, file := range files {
+		for _, d := range file.Diagnostics {
+			switch d.Severity {
+			case diag.Error:
+				return exitError
+			case diag.Warning:
+				code = exitWarning
+			}
+		}
+	}
+	return code
+}
+
+// report writes the diagnostics of files in the given format and returns
+// the exit code for them. Text goes to standard error, the machine
+// readable formats to standard output.
+func report(format string, files []diag.File) int {
+	var err error
+	switch format {
+	case "text":
+		diag.NewRenderer(os.Stderr).Render(files)
+	case "json":
+		err = diag.WriteJSON(os.Stdout, files)
+	case "sarif":
+		err = diag.WriteSARIF(os.Stdout, diag.Tool{Name: "lark"}, files)
+	default:
+		return errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return errorf("%v", err)
+	}
+	return status(files)
+}
+
+// validFormat reports an error unless format is one of formats.
+func validFormat(format string, formats ...string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	errorf("unknown format %q, want one of %s", format, strings.Join(formats, ", "))
+	return false
+}
+
+// load loads the files named by args and their imports.
+func load(f *loadFlags, args []string) (*loader.Program, int) {
+	srcs, err := sources(args)
+	if err != nil {
+		return nil, errorf("%v", err)
+	}
+	prog, err := f.config().Load(srcs...)
+	if err != nil {
+		return nil, errorf("%v", err)
+	}
+	return prog, exitSuccess
+}
+
+// header prints the name of a file when output for several files is
+// written to w.
+func header(w io.Writer, n int, name string) {
+	if n > 1 {
+		fmt.Fprintf(w, "# %s\n", name)
+	}
+}
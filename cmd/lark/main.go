@@ -0,0 +1,142 @@
+// Lark is a tool for managing Lark source files.
+//
+// Usage:
+//
+//	lark <command> [flags] [path ...]
+//
+// The commands are:
+//
+//	parse    parse files and print their syntax trees
+//	check    check files and the modules they import
+//	fmt      format files in the canonical style
+//	gen      generate code from files
+//	deps     print the import graph of files
+//	doc      show the documentation of declarations
+//	import   convert files of another schema language to Lark
+//	infer    infer structs from sample JSON documents
+//	migrate  write the SQL that migrates tables between schema versions
+//
+// Paths may name files or directories; directories are searched
+// recursively for files with the .lark extension. Without paths, or with
+// the path "-", standard input is read.
+//
+// Gen runs either a generator built into lark, selected with -lang, or an
+// external plugin, selected with -plugin name, which runs the executable
+// lark-gen-name from the PATH. See package
+// larklang.io/lark/pkg/plugin for the protocol.
+//
+// Import converts files of another schema language, selected with -from,
+// to Lark: proto converts protobuf definitions, jsonschema JSON Schema
+// documents and avro Avro schemas, whose namespaces become module paths
+// after the prefix given with -namespace. Constructs without a Lark
+// equivalent are reported as warnings.
+//
+// Infer reads sample JSON documents, one per file or several per file as
+// in NDJSON, and prints the structs that hold all of them, for refining by
+// hand. Directories are searched for .json, .ndjson and .jsonl files.
+//
+// Migrate compares the tables that the sql generator creates for two
+// versions of a schema, each given as a file or a directory, and prints
+// the ALTER TABLE statements that turn the old tables into the new ones.
+//
+// Lark exits with status 0 on success, 1 if there were warnings (or, for
+// fmt -l and fmt -d, unformatted files) and 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Exit codes shared by all commands.
+const (
+	exitSuccess = 0
+	exitWarning = 1
+	exitError   = 2
+)
+
+type command struct {
+	name  string
+	usage string
+	short string
+	run   func(cmd *command, args []string) int
+	flags *flag.FlagSet
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "parse", usage: "[-format text|json|sarif] [-json] [-max-errors n] [path ...]", short: "parse files and print their syntax trees", run: runParse},
+		{name: "check", usage: "[-format text|json|sarif] [-max-errors n] [-I dir] [path ...]", short: "check files and the modules they import", run: runCheck},
+		{name: "fmt", usage: "[-l] [-d] [-w] [path ...]", short: "format files in the canonical style", run: runFmt},
+		{name: "gen", usage: "-lang name | -plugin name [-out dir] [-param name=value] [-I dir] [path ...]", short: "generate code from files", run: runGen},
+		{name: "deps", usage: "[-format text|dot|json] [-I dir] [path ...]", short: "print the import graph of files", run: runDeps},
+		{name: "doc", usage: "[-I dir] path [name[.member]]", short: "show the documentation of declarations", run: runDoc},
+		{name: "import", usage: "[-from proto|jsonschema|avro] [-namespace prefix] [-out dir] [-I dir] [path ...]", short: "convert files of another schema language to Lark", run: runImport},
+		{name: "infer", usage: "[-name name] [-out file] [path ...]", short: "infer structs from sample JSON documents", run: runInfer},
+		{name: "migrate", usage: "[-dialect postgres|sqlite] [-out file] [-I dir] old new", short: "write the SQL that migrates tables between schema versions", run: runMigrate},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lark <command> [flags] [path ...]")
+	fmt.Fprintln(os.Stderr, "\nThe commands are:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-7s  %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(os.Stderr, "\nUse \"lark <command> -h\" for more information about a command.")
+}
+
+// flagSet returns the flag set of cmd, which reports usage errors
+// instead of exiting.
+func (cmd *command) flagSet() *flag.FlagSet {
+	if cmd.flags == nil {
+		cmd.flags = flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.flags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "usage: lark %s %s\n", cmd.name, cmd.usage)
+			cmd.flags.PrintDefaults()
+		}
+	}
+	return cmd.flags
+}
+
+// parseFlags parses the arguments of cmd. If it returns false, cmd stops
+// with the exit code: exitSuccess after -h, exitError after a usage error.
+func (cmd *command) parseFlags(args []string) (int, bool) {
+	switch err := cmd.flagSet().Parse(args); {
+	case err == flag.ErrHelp:
+		return exitSuccess, false
+	case err != nil:
+		return exitError, false
+	}
+	return exitSuccess, true
+}
+
+func errorf(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, "lark: "+format+"\n", args...)
+	return exitError
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		os.Exit(exitSuccess)
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(cmd, os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "lark: unknown command %q\n", name)
+	usage()
+	os.Exit(exitError)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/gen/sql"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// runMigrate checks two versions of a schema and, if there are no errors,
+// writes the SQL script that migrates the tables of the old version to
+// the new one to the output file, or to standard output without one.
+// Changes that fail for tables with rows are reported as warnings on the
+// fields of the new version.
+func runMigrate(cmd *command, args []string) int {
+	var lf loadFlags
+	flags := cmd.flagSet()
+	dialect := flags.String("dialect", "postgres", "SQL `dialect`: postgres or sqlite")
+	out := flags.String("out", "", "output `file`; standard output if empty")
+	lf.register(flags)
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	var progs []*loader.Program
+	var versions []*schema.Schema
+	hasErrors := false
+	for _, arg := range flags.Args() {
+		prog, c := load(&lf, []string{arg})
+		if prog == nil {
+			return c
+		}
+		progs = append(progs, prog)
+		versions = append(versions, schema.Check(prog))
+		hasErrors = hasErrors || prog.HasErrors()
+	}
+	diagnostics := func() []diag.File {
+		return append(progs[0].Diagnostics(), progs[1].Diagnostics()...)
+	}
+	if hasErrors {
+		return report("text", diagnostics())
+	}
+
+	content, warnings, err := sql.Migrate(versions[0], versions[1], *dialect)
+	if err != nil {
+		report("text", diagnostics())
+		return errorf("migrate: %v", err)
+	}
+	for _, w := range warnings {
+		addWarning(progs[1], w)
+	}
+	code := report("text", diagnostics())
+	if *out == "" {
+		os.Stdout.Write(content)
+		return code
+	}
+	if err := os.WriteFile(*out, content, 0o644); err != nil {
+		return errorf("%v", err)
+	}
+	return code
+}
+
+// addWarning adds a warning of a migration to the file of prog that
+// declares its field.
+func addWarning(prog *loader.Program, w sql.Warning) {
+	for _, file := range prog.Files {
+		if file.Path == w.Field.File.Path {
+			file.Diagnostics = append(file.Diagnostics, diag.Diagnostic{
+				Severity: diag.Warning,
+				Code:     diag.UnsafeMigration,
+				Range:    diag.Span(w.Field.Pos, utf8.RuneCountInString(w.Field.Name)),
+				Message:  w.Message,
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/parser"
+)
+
+// runParse parses each file on its own, without loading imports, and
+// prints the syntax trees of the files without errors. With -json, the
+// trees of all files are written as JSON lines of the form
+//
+//	{"path": "file.lark", "ast": {"node": "File", ...}}
+//
+// and diagnostics go to standard error as text.
+func runParse(cmd *command, args []string) int {
+	flags := cmd.flagSet()
+	format := flags.String("format", "text", "diagnostics output `format`: text, json or sarif")
+	maxErrors := flags.Int("max-errors", parser.DefaultMaxErrors, "stop after `n` errors per file, 0 means no limit")
+	asJSON := flags.Bool("json", false, "print syntax trees as JSON, including those with errors")
+	if code, ok := cmd.parseFlags(args); !ok {
+		return code
+	}
+	if !validFormat(*format, "text", "json", "sarif") {
+		return exitError
+	}
+	if *asJSON && *format != "text" {
+		return errorf("cannot use -json with -format %s", *format)
+	}
+
+	srcs, err := sources(flags.Args())
+	if err != nil {
+		return errorf("%v", err)
+	}
+
+	var files []diag.File
+	for _, src := range srcs {
+		text := src.Src
+		if text == nil {
+			if text, err = os.ReadFile(src.Path); err != nil {
+				return errorf("%v", err)
+			}
+		}
+
+		parsed := parser.ParseWithOptions(text, parser.Options{MaxErrors: *maxErrors})
+		files = append(files, diag.File{Name: src.Path, Lines: parsed.Lines, Diagnostics: parsed.Diagnostics})
+
+		if *asJSON {
+			if err := writeJSONTree(os.Stdout, src.Path, parsed.File); err != nil {
+				return errorf("%v", err)
+			}
+		} else if *format == "text" && !diag.HasErrors(parsed.Diagnostics) {
+			header(os.Stdout, len(srcs), src.Path)
+			ast.Fprint(os.Stdout, parsed.File)
+		}
+	}
+	return report(*format, files)
+}
+
+func writeJSONTree(w io.Writer, path string, file *ast.File) error {
+	tree, err := ast.MarshalJSON(file)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(struct {
+		Path string          `json:"path"`
+		AST  json.RawMessage `json:"ast"`
+	}{path, tree})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", line)
+	return err
+}
@@ -0,0 +1,116 @@
+// Larkfmt formats Lark source files in the canonical style.
+//
+// Usage:
+//
+//	larkfmt [flags] [path ...]
+//
+// Without paths, larkfmt formats standard input. Directories are processed
+// recursively; only files with the .lark extension are formatted.
+//
+// With -l or -d, larkfmt exits with status 1 if the formatting of a file
+// differs, and with status 2 after an error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"larklang.io/lark/internal/fmtfile"
+)
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from larkfmt's")
+	diffs = flag.Bool("d", false, "display diffs instead of rewriting files")
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+)
+
+var exitCode = 0
+
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	exitCode = 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: larkfmt [flags] [path ...]")
+	flag.PrintDefaults()
+}
+
+func processFile(filename string, in io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	opts := fmtfile.Options{List: *list, Diff: *diffs, Write: *write}
+	changed, err := opts.Process(filename, src, out)
+	if changed && (*list || *diffs) {
+		exitCode = max(exitCode, 1)
+	}
+	return err
+}
+
+func walkDir(path string) {
+	err := filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".lark" {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			report(err)
+			return nil
+		}
+		defer file.Close()
+
+		if err := processFile(path, file, os.Stdout); err != nil {
+			report(err)
+		}
+		return nil
+	})
+	if err != nil {
+		report(err)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			report(fmt.Errorf("larkfmt: cannot use -w with standard input"))
+		} else if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			report(err)
+		case info.IsDir():
+			walkDir(path)
+		default:
+			file, err := os.Open(path)
+			if err != nil {
+				report(err)
+				continue
+			}
+			if err := processFile(path, file, os.Stdout); err != nil {
+				report(err)
+			}
+			file.Close()
+		}
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,27 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     string
+	}{
+		{"a\n", "a\n", ""},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"", "a\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			"--- old\n+++ new\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{"a", "b", "--- old\n+++ new\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n"},
+	}
+
+	for _, test := range tests {
+		got := string(Unified("old", "new", []byte(test.old), []byte(test.new)))
+		if got != test.want {
+			t.Errorf("%q -> %q: got\n%s\nwant\n%s", test.old, test.new, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Package fmtfile formats Lark files for the larkfmt command and lark fmt,
+// which take the same flags and behave alike.
+package fmtfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/pkg/format"
+)
+
+// Options select what Process does with a file. Without any of them, the
+// formatted file is written to the output. With List or Diff, the commands
+// exit with status 1 if the formatting of a file differs.
+type Options struct {
+	List  bool // list the file if its formatting differs
+	Diff  bool // write a diff if the formatting differs
+	Write bool // rewrite the file if the formatting differs
+}
+
+// Process formats src, the content of the file filename, and writes the
+// output selected by opts to out. It reports whether the formatting of src
+// differs from the canonical style.
+func (opts Options) Process(filename string, src []byte, out io.Writer) (bool, error) {
+	res, err := format.Source(src)
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", filename, err)
+	}
+
+	if bytes.Equal(src, res) {
+		if !opts.List && !opts.Diff && !opts.Write {
+			_, err = out.Write(res)
+		}
+		return false, err
+	}
+
+	if opts.List {
+		fmt.Fprintln(out, filename)
+	}
+	if opts.Write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return true, err
+		}
+		if err := os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return true, err
+		}
+	}
+	if opts.Diff {
+		out.Write(diff.Unified(filename+".orig", filename, src, res))
+	}
+	if !opts.List && !opts.Write && !opts.Diff {
+		_, err = out.Write(res)
+	}
+	return true, err
+}
@@ -0,0 +1,50 @@
+package fmtfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	const src, res = "const  x=1\n", "const x = 1\n"
+	filename := filepath.Join(t.TempDir(), "x.lark")
+
+	tests := []struct {
+		opts Options
+		src  string
+		want string // output, or prefix of the output for Diff
+	}{
+		{Options{}, src, res},
+		{Options{}, res, res},
+		{Options{List: true}, src, filename + "\n"},
+		{Options{List: true}, res, ""},
+		{Options{Diff: true}, src, "--- " + filename + ".orig\n"},
+		{Options{Write: true}, src, ""},
+	}
+	for _, test := range tests {
+		if err := os.WriteFile(filename, []byte(test.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		changed, err := test.opts.Process(filename, []byte(test.src), &out)
+		if err != nil {
+			t.Errorf("%+v %q: %v", test.opts, test.src, err)
+			continue
+		}
+		if want := test.src != res; changed != want {
+			t.Errorf("%+v %q: got changed %v; want %v", test.opts, test.src, changed, want)
+		}
+		if got := out.String(); got != test.want && !(test.opts.Diff && strings.HasPrefix(got, test.want)) {
+			t.Errorf("%+v %q: got output %q; want %q", test.opts, test.src, got, test.want)
+		}
+		if text, _ := os.ReadFile(filename); test.opts.Write && string(text) != res {
+			t.Errorf("%+v %q: got file %q; want %q", test.opts, test.src, text, res)
+		}
+	}
+
+	if _, err := (Options{}).Process("bad.lark", []byte("const = 1\n"), &strings.Builder{}); err == nil || !strings.HasPrefix(err.Error(), "bad.lark:") {
+		t.Errorf("got error %v for bad.lark", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Package gentest holds the fixtures shared by the tests of code
+// generators. Test files are read from the testdata directory of the
+// package under test, which is also on the import path.
+package gentest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// Check loads and checks the given sources. It fails the test if they
+// have errors.
+func Check(t testing.TB, sources ...loader.Source) *schema.Schema {
+	t.Helper()
+	c := &loader.Config{Path: []string{"testdata"}}
+	prog, err := c.Load(sources...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := schema.Check(prog)
+	if prog.HasErrors() {
+		t.Fatalf("unexpected diagnostics %v", prog.Diagnostics())
+	}
+	return s
+}
+
+// Load loads and checks the given files of testdata.
+func Load(t testing.TB, names ...string) *schema.Schema {
+	t.Helper()
+	var sources []loader.Source
+	for _, name := range names {
+		src, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, loader.Source{Path: name, Src: src})
+	}
+	return Check(t, sources...)
+}
+
+// Generate runs g on the given files of testdata.
+func Generate(t testing.TB, g gen.Generator, params gen.Params, names ...string) []gen.File {
+	t.Helper()
+	files, err := g.Generate(Load(t, names...), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// Compare reports an error if got, the output named name, differs from
+// the golden file.
+func Compare(t testing.TB, golden, name string, got []byte) {
+	t.Helper()
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s:\n%s", name, golden, diff.Unified(golden, name, want, got))
+	}
+}
@@ -0,0 +1,49 @@
+// Package jsonobj provides JSON objects whose members keep their order,
+// for generators whose output is read by people.
+package jsonobj
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// An Object is a JSON object whose members keep their order.
+type Object []Member
+
+// A Member is a member of an object.
+type Member struct {
+	Key   string
+	Value any
+}
+
+// Set sets the value of a member, adding it at the end if it is new.
+func (o *Object) Set(key string, value any) {
+	for i := range *o {
+		if (*o)[i].Key == key {
+			(*o)[i].Value = value
+			return
+		}
+	}
+	*o = append(*o, Member{key, value})
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(m.Key); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := enc.Encode(m.Value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
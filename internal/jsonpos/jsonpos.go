@@ -0,0 +1,349 @@
+// Package jsonpos parses JSON into values that keep the order of object
+// members and the positions of values, for converters that report
+// diagnostics about their JSON input.
+package jsonpos
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+// A Value is a JSON value with its position. Unlike encoding/json, the
+// parser keeps the order of object members and the literals of numbers.
+type Value struct {
+	Kind    Kind
+	Pos     diag.Pos
+	Text    string // value of strings; literal of numbers
+	Bool    bool
+	Elems   []*Value
+	Members []*Member
+}
+
+// A Kind is the kind of a JSON value.
+type Kind int
+
+const (
+	Null Kind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+var kinds = [...]string{
+	Null:   "null",
+	Bool:   "boolean",
+	Number: "number",
+	String: "string",
+	Array:  "array",
+	Object: "object",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+// A Member is a member of an object.
+type Member struct {
+	Key   string
+	Pos   diag.Pos // position of the key
+	Value *Value
+}
+
+// Get returns the member of an object with the given key, or nil.
+func (v *Value) Get(key string) *Member {
+	if v == nil || v.Kind != Object {
+		return nil
+	}
+	for _, m := range v.Members {
+		if m.Key == key {
+			return m
+		}
+	}
+	return nil
+}
+
+// Lookup returns the value of the member of an object with the given key,
+// or nil.
+func (v *Value) Lookup(key string) *Value {
+	if m := v.Get(key); m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+// Str returns the value of a string.
+func (v *Value) Str() (string, bool) {
+	if v == nil || v.Kind != String {
+		return "", false
+	}
+	return v.Text, true
+}
+
+// A SyntaxError reports invalid JSON.
+type SyntaxError struct {
+	Pos diag.Pos
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line+1, e.Pos.Column+1, e.Msg)
+}
+
+type parser struct {
+	src    []byte
+	offset int
+	pos    diag.Pos
+}
+
+// Parse parses a JSON document.
+func Parse(src []byte) (*Value, *SyntaxError) {
+	values, err := parse(src, false)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// ParseStream parses a sequence of JSON values separated by white space,
+// such as NDJSON. After a syntax error, it returns the values before the
+// one with the error.
+func ParseStream(src []byte) ([]*Value, *SyntaxError) {
+	return parse(src, true)
+}
+
+func parse(src []byte, stream bool) (values []*Value, err *SyntaxError) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	p.space()
+	for !stream || p.offset < len(p.src) {
+		values = append(values, p.value())
+		p.space()
+		if !stream {
+			if p.offset < len(p.src) {
+				p.errorf("unexpected %s after the end of the document", p.describe())
+			}
+			break
+		}
+	}
+	return values, nil
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	panic(&SyntaxError{p.pos, fmt.Sprintf(format, args...)})
+}
+
+// describe returns a description of the current character for errors.
+func (p *parser) describe() string {
+	if p.offset >= len(p.src) {
+		return "end of file"
+	}
+	r, _ := utf8.DecodeRune(p.src[p.offset:])
+	return strconv.QuoteRune(r)
+}
+
+func (p *parser) peek() byte {
+	if p.offset < len(p.src) {
+		return p.src[p.offset]
+	}
+	return 0
+}
+
+// advance moves past one character.
+func (p *parser) advance() {
+	r, size := utf8.DecodeRune(p.src[p.offset:])
+	p.offset += size
+	if r == '\n' {
+		p.pos.Line++
+		p.pos.Column = 0
+	} else {
+		p.pos.Column++
+	}
+}
+
+func (p *parser) space() {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.advance()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) expect(c byte) {
+	if p.peek() != c || p.offset >= len(p.src) {
+		p.errorf("expected %q, found %s", c, p.describe())
+	}
+	p.advance()
+}
+
+func (p *parser) value() *Value {
+	v := &Value{Pos: p.pos}
+	switch c := p.peek(); {
+	case c == '{':
+		v.Kind = Object
+		p.advance()
+		p.space()
+		if p.peek() == '}' {
+			p.advance()
+			return v
+		}
+		for {
+			p.space()
+			m := &Member{Pos: p.pos}
+			if p.peek() != '"' {
+				p.errorf("expected a string, found %s", p.describe())
+			}
+			m.Key = p.str()
+			p.space()
+			p.expect(':')
+			p.space()
+			m.Value = p.value()
+			v.Members = append(v.Members, m)
+			p.space()
+			if p.peek() != ',' {
+				break
+			}
+			p.advance()
+		}
+		p.expect('}')
+	case c == '[':
+		v.Kind = Array
+		p.advance()
+		p.space()
+		if p.peek() == ']' {
+			p.advance()
+			return v
+		}
+		for {
+			p.space()
+			v.Elems = append(v.Elems, p.value())
+			p.space()
+			if p.peek() != ',' {
+				break
+			}
+			p.advance()
+		}
+		p.expect(']')
+	case c == '"':
+		v.Kind, v.Text = String, p.str()
+	case c == '-' || c >= '0' && c <= '9':
+		v.Kind, v.Text = Number, p.number()
+	case p.literal("true"):
+		v.Kind, v.Bool = Bool, true
+	case p.literal("false"):
+		v.Kind = Bool
+	case p.literal("null"):
+		v.Kind = Null
+	default:
+		p.errorf("unexpected %s", p.describe())
+	}
+	return v
+}
+
+// literal consumes the literal word if it is next.
+func (p *parser) literal(word string) bool {
+	if !strings.HasPrefix(string(p.src[p.offset:]), word) {
+		return false
+	}
+	for range word {
+		p.advance()
+	}
+	return true
+}
+
+func (p *parser) number() string {
+	begin, pos := p.offset, p.pos
+	for {
+		c := p.peek()
+		if !(c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E') {
+			break
+		}
+		p.advance()
+	}
+	text := string(p.src[begin:p.offset])
+	if !numberSyntax.MatchString(text) {
+		p.pos = pos
+		p.errorf("invalid number %s", text)
+	}
+	return text
+}
+
+// numberSyntax is the grammar of JSON numbers, which is stricter than that
+// of strconv.
+var numberSyntax = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+func (p *parser) str() string {
+	p.advance()
+	var b strings.Builder
+	for {
+		c := p.peek()
+		switch {
+		case p.offset >= len(p.src) || c == '\n':
+			p.errorf("unterminated string")
+		case c == '"':
+			p.advance()
+			return b.String()
+		case c == '\\':
+			p.advance()
+			p.escape(&b)
+		case c < ' ':
+			p.errorf("control character in string")
+		default:
+			r, _ := utf8.DecodeRune(p.src[p.offset:])
+			b.WriteRune(r)
+			p.advance()
+		}
+	}
+}
+
+func (p *parser) escape(b *strings.Builder) {
+	c := p.peek()
+	if i := strings.IndexByte(`"\/bfnrt`, c); i >= 0 && p.offset < len(p.src) {
+		b.WriteByte("\"\\/\b\f\n\r\t"[i])
+		p.advance()
+		return
+	}
+	if c != 'u' {
+		p.errorf("unknown escape sequence \\%c", c)
+	}
+	p.advance()
+	r := p.hex()
+	if utf16.IsSurrogate(r) && strings.HasPrefix(string(p.src[p.offset:]), `\u`) {
+		p.advance()
+		p.advance()
+		r = utf16.DecodeRune(r, p.hex())
+	}
+	b.WriteRune(r)
+}
+
+func (p *parser) hex() rune {
+	if p.offset+4 > len(p.src) {
+		p.errorf("invalid escape sequence")
+	}
+	n, err := strconv.ParseUint(string(p.src[p.offset:p.offset+4]), 16, 32)
+	if err != nil {
+		p.errorf("invalid escape sequence")
+	}
+	for range 4 {
+		p.advance()
+	}
+	return rune(n)
+}
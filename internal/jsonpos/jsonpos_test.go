@@ -0,0 +1,47 @@
+package jsonpos
+
+import (
+	"testing"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+func TestParse(t *testing.T) {
+	src := "{\n  \"a\": [1, -2.5e3, \"\\u00e9\\ud83d\\ude00\"],\n  \"b\": {\"c\": null, \"d\": true}\n}"
+	v, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Members[0].Key + v.Members[1].Key; got != "ab" {
+		t.Errorf("keys %q; want \"ab\"", got)
+	}
+	a := v.Lookup("a")
+	if a.Kind != Array || len(a.Elems) != 3 {
+		t.Fatalf("a is %v with %d elements", a.Kind, len(a.Elems))
+	}
+	if got := a.Elems[1].Text; got != "-2.5e3" {
+		t.Errorf("number literal %q; want \"-2.5e3\"", got)
+	}
+	if got, _ := a.Elems[2].Str(); got != "é😀" {
+		t.Errorf("string %q; want \"é😀\"", got)
+	}
+	if got, want := a.Elems[2].Pos, (diag.Pos{Line: 1, Column: 19}); got != want {
+		t.Errorf("position %v; want %v", got, want)
+	}
+	if m := v.Lookup("b").Get("d"); m == nil || !m.Value.Bool || m.Pos != (diag.Pos{Line: 2, Column: 19}) {
+		t.Errorf("member d is %+v", m)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	values, err := ParseStream([]byte("{\"a\": 1}\n{\"a\": 2}\n{\"a\": }\n{}"))
+	if len(values) != 2 {
+		t.Errorf("got %d values; want 2", len(values))
+	}
+	if err == nil || err.Error() != "3:7: unexpected '}'" {
+		t.Errorf("got error %v; want 3:7: unexpected '}'", err)
+	}
+	if values, err := ParseStream([]byte(" \n")); len(values) != 0 || err != nil {
+		t.Errorf("empty stream: got %d values, error %v", len(values), err)
+	}
+}
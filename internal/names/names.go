@@ -0,0 +1,107 @@
+// Package names turns the names of other schema languages into Lark
+// identifiers, for the converters that import them.
+package names
+
+import (
+	"strings"
+	"unicode"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/scanner"
+	"larklang.io/lark/pkg/schema"
+)
+
+// IsKeyword reports whether name is a Lark keyword, which cannot name a
+// field.
+func IsKeyword(name string) bool {
+	return scanner.New([]byte(name), nil).Scan().Kind.IsKeyword()
+}
+
+// IsBuiltinType reports whether name is the name of a built-in type, which
+// a declaration would shadow.
+func IsBuiltinType(name string) bool {
+	_, ok := schema.LookupPrimitive(name)
+	return ok || name == "list" || name == "map"
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// IsIdent reports whether name is a Lark identifier.
+func IsIdent(name string) bool {
+	if name == "" || IsKeyword(name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) && (i == 0 || !isDigit(name[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// words splits name into words at the characters that cannot appear in
+// identifiers and at changes of case.
+func words(name string) []string {
+	var list []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return r > unicode.MaxASCII || !isLetter(byte(r)) && !isDigit(byte(r)) || r == '_'
+	}) {
+		list = append(list, gen.Words(part)...)
+	}
+	return list
+}
+
+// Type returns the name of a declaration for name in PascalCase:
+// "user-address" becomes "UserAddress".
+func Type(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	if b.Len() == 0 || isDigit(b.String()[0]) {
+		return "T" + b.String()
+	}
+	return b.String()
+}
+
+// Snake returns name in snake case, turned into an identifier: "userId"
+// becomes "user_id" and "2fa" becomes "_2fa".
+func Snake(name string) string {
+	list := words(name)
+	for i, word := range list {
+		list[i] = strings.ToLower(word)
+	}
+	s := strings.Join(list, "_")
+	if s == "" || isDigit(s[0]) {
+		s = "_" + s
+	}
+	if IsKeyword(s) {
+		s += "_"
+	}
+	return s
+}
+
+// Field returns the name of the field for a key, and the key for @json if
+// the field is named differently. Keys that are identifiers keep their
+// name.
+func Field(key string) (name, json string) {
+	if IsIdent(key) {
+		return key, ""
+	}
+	return Snake(key), key
+}
+
+// Ident returns name if it is an identifier, and its snake case otherwise.
+// It names enum members, variants and imports.
+func Ident(name string) string {
+	if IsIdent(name) {
+		return name
+	}
+	return Snake(name)
+}
@@ -0,0 +1,28 @@
+package names
+
+import "testing"
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		key, typ, field, json string
+	}{
+		{"user", "User", "user", ""},
+		{"user-address", "UserAddress", "user_address", "user-address"},
+		{"userId", "UserId", "userId", ""},
+		{"HTTPServer", "HTTPServer", "HTTPServer", ""},
+		{"2fa", "T2fa", "_2fa", "2fa"},
+		{"type", "Type", "type_", "type"},
+		{"$id", "Id", "id", "$id"},
+	}
+	for _, test := range tests {
+		if got := Type(test.key); got != test.typ {
+			t.Errorf("Type(%q) = %q; want %q", test.key, got, test.typ)
+		}
+		if name, json := Field(test.key); name != test.field || json != test.json {
+			t.Errorf("Field(%q) = %q, %q; want %q, %q", test.key, name, json, test.field, test.json)
+		}
+	}
+	if got, want := Ident("in-progress"), "in_progress"; got != want {
+		t.Errorf("Ident = %q; want %q", got, want)
+	}
+}
@@ -1,11 +1,54 @@
 package ast
 
-import "larklang.io/lark/pkg/scanner"
+import (
+	"strings"
+
+	"larklang.io/lark/pkg/scanner"
+)
 
 type Node interface {
 	Pos() scanner.Pos
 }
 
+// A Comment is a single // comment.
+type Comment struct {
+	Slash scanner.Pos // position of the leading "//"
+	Text  string      // comment text including the "//"
+}
+
+// A CommentGroup is a sequence of comments on consecutive lines without
+// other tokens in between, or a single comment that follows other tokens
+// on its line.
+type CommentGroup struct {
+	List     []*Comment
+	Trailing bool // the group follows other tokens on its line
+}
+
+func (c *Comment) Pos() scanner.Pos      { return c.Slash }
+func (g *CommentGroup) Pos() scanner.Pos { return g.List[0].Pos() }
+
+// EndLine returns the line of the last comment of the group.
+func (g *CommentGroup) EndLine() int {
+	return g.List[len(g.List)-1].Slash.Line
+}
+
+// Text returns the text of the comment group without the comment markers
+// and the single space that usually follows them. Lines are separated by
+// newlines; the result has no trailing newline.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+
+	lines := make([]string, 0, len(g.List))
+	for _, c := range g.List {
+		text := strings.TrimPrefix(c.Text, "//")
+		text = strings.TrimPrefix(text, " ")
+		lines = append(lines, strings.TrimRight(text, " \t\r"))
+	}
+	return strings.Join(lines, "\n")
+}
+
 type (
 	BadNode struct {
 		From scanner.Pos
@@ -41,40 +84,125 @@ type (
 		Rhs Node
 	}
 
+	// An Annotation attaches metadata to a declaration, a field, a method
+	// or a parameter: @name or @name(args).
+	Annotation struct {
+		At   scanner.Pos // position of "@"
+		Name *Name
+		Args []Node // nil if there are no parentheses
+	}
+
 	ImportSpec struct {
+		Doc   *CommentGroup
 		Path  *BasicLit
 		Alias *Name
 	}
 
 	ConstSpec struct {
-		Name *Name
-		Expr Node
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		Name        *Name
+		Type        *Type // or nil
+		Expr        Node
 	}
 
+	// A Type is a named type with optional type arguments, or an array
+	// type [Elem; Len], which has no name.
 	Type struct {
-		Name *QualName
-		Args []Node
+		Name   *QualName // nil for array types
+		Args   []Node
+		Lbrack scanner.Pos // position of "[" of an array type
+		Elem   *Type       // element type of an array type
+		Len    Node        // length of an array type
 	}
 
 	TypeAlias struct {
-		TypePos scanner.Pos
-		Name    *Name
-		Type    *Type
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		TypePos     scanner.Pos
+		Name        *Name
+		Type        *Type
 	}
 
 	Field struct {
-		Name *Name
-		Type *Type
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		Name        *Name
+		Optional    bool
+		Type        *Type
 	}
 
 	Struct struct {
-		StructPos scanner.Pos
-		Name      *Name
-		Fields    []*Field
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		StructPos   scanner.Pos
+		Name        *Name
+		Fields      []*Field
+		Rbrace      scanner.Pos
+	}
+
+	// An EnumMember is a member of an enum with an optional integer
+	// value.
+	EnumMember struct {
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		Name        *Name
+		Value       Node // or nil
+	}
+
+	Enum struct {
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		EnumPos     scanner.Pos
+		Name        *Name
+		Members     []*EnumMember
+		Rbrace      scanner.Pos
+	}
+
+	// A Variant is a variant of a union: a name and a struct type.
+	Variant struct {
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		Name        *Name
+		Type        *Type
+	}
+
+	Union struct {
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		UnionPos    scanner.Pos
+		Name        *Name
+		Variants    []*Variant
+		Rbrace      scanner.Pos
+	}
+
+	Param struct {
+		Annotations []*Annotation
+		Name        *Name
+		Type        *Type
+	}
+
+	Method struct {
+		Doc         *CommentGroup
+		Annotations []*Annotation
+		FuncPos     scanner.Pos
+		Name        *Name
+		Params      []*Param
+		Result      *Type // or nil
+	}
+
+	Interface struct {
+		Doc          *CommentGroup
+		Annotations  []*Annotation
+		InterfacePos scanner.Pos
+		Name         *Name
+		Methods      []*Method
+		Rbrace       scanner.Pos
 	}
 
 	File struct {
-		Nodes []Node
+		Nodes    []Node
+		Comments []*CommentGroup // all comments of the file in source order
 	}
 )
 
@@ -84,10 +212,24 @@ func (x *QualName) Pos() scanner.Pos   { return x.NodePos }
 func (x *BadNode) Pos() scanner.Pos    { return x.From }
 func (x *UnaryExpr) Pos() scanner.Pos  { return x.OpPos }
 func (x *BinaryExpr) Pos() scanner.Pos { return x.Lhs.Pos() }
+func (x *Annotation) Pos() scanner.Pos { return x.At }
 func (x *ImportSpec) Pos() scanner.Pos { return x.Path.Pos() }
 func (x *ConstSpec) Pos() scanner.Pos  { return x.Name.Pos() }
-func (x *Type) Pos() scanner.Pos       { return x.Name.Pos() }
 func (x *TypeAlias) Pos() scanner.Pos  { return x.TypePos }
 func (x *Field) Pos() scanner.Pos      { return x.Name.Pos() }
 func (x *Struct) Pos() scanner.Pos     { return x.StructPos }
-func (x *File) Pos() scanner.Pos     { return scanner.Pos{Line: 0, Column: 0} }
+func (x *EnumMember) Pos() scanner.Pos { return x.Name.Pos() }
+func (x *Enum) Pos() scanner.Pos       { return x.EnumPos }
+func (x *Variant) Pos() scanner.Pos    { return x.Name.Pos() }
+func (x *Union) Pos() scanner.Pos      { return x.UnionPos }
+func (x *Param) Pos() scanner.Pos      { return x.Name.Pos() }
+func (x *Method) Pos() scanner.Pos     { return x.FuncPos }
+func (x *Interface) Pos() scanner.Pos  { return x.InterfacePos }
+func (x *File) Pos() scanner.Pos       { return scanner.Pos{Line: 0, Column: 0} }
+
+func (x *Type) Pos() scanner.Pos {
+	if x.Name == nil {
+		return x.Lbrack
+	}
+	return x.Name.Pos()
+}
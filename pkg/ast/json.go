@@ -0,0 +1,250 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/scanner"
+)
+
+// The JSON encoding of a node is an object whose "node" member names the
+// node type, followed by one member per field of the node. Member names
+// are the field names with a lower-case first letter. Positions are
+// objects with zero-based "line" and "column" members, token kinds are
+// their string representation and absent nodes are null:
+//
+//	{"node":"BasicLit","valuePos":{"line":0,"column":10},"kind":"INTEGER","value":"42"}
+//
+// Doc comments are encoded in place and also as part of File.Comments;
+// decoding a file links them again.
+
+// nodeTypes maps the type discriminators of the JSON encoding to node types.
+var nodeTypes = map[string]reflect.Type{}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func init() {
+	for _, node := range []Node{
+		&BadNode{}, &BasicLit{}, &Name{}, &QualName{}, &UnaryExpr{},
+		&BinaryExpr{}, &Annotation{}, &ImportSpec{}, &ConstSpec{}, &Type{}, &TypeAlias{},
+		&Field{}, &Struct{}, &EnumMember{}, &Enum{}, &Variant{}, &Union{}, &Param{}, &Method{}, &Interface{}, &File{},
+		&Comment{}, &CommentGroup{},
+	} {
+		typ := reflect.TypeOf(node).Elem()
+		nodeTypes[typ.Name()] = typ
+	}
+}
+
+// MarshalJSON returns the JSON encoding of node.
+func MarshalJSON(node Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, reflect.ValueOf(&node).Elem()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a node from its JSON encoding.
+func UnmarshalJSON(data []byte) (Node, error) {
+	var node Node
+	if err := decode(data, reflect.ValueOf(&node).Elem()); err != nil {
+		return nil, err
+	}
+	if file, ok := node.(*File); ok {
+		linkComments(file)
+	}
+	return node, nil
+}
+
+func isNode(typ reflect.Type) bool {
+	return nodeTypes[typ.Name()] == typ
+}
+
+func memberName(field string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[size:]
+}
+
+func encode(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encode(buf, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case reflect.Struct:
+		buf.WriteByte('{')
+		typ := v.Type()
+		sep := ""
+		if isNode(typ) {
+			fmt.Fprintf(buf, `"node":%q`, typ.Name())
+			sep = ","
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			buf.WriteString(sep)
+			sep = ","
+			fmt.Fprintf(buf, "%q:", memberName(typ.Field(i).Name))
+			if err := encode(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("ast: %v", err)
+	}
+	buf.Write(data)
+	return nil
+}
+
+func decode(data json.RawMessage, v reflect.Value) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	switch {
+	case v.Type() == nodeType:
+		var header struct {
+			Node string `json:"node"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("ast: %v", err)
+		}
+		typ, ok := nodeTypes[header.Node]
+		if !ok {
+			return fmt.Errorf("ast: unknown node type %q", header.Node)
+		}
+		node := reflect.New(typ)
+		if err := decodeStruct(data, node.Elem()); err != nil {
+			return err
+		}
+		v.Set(node)
+		return nil
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.Struct:
+		node := reflect.New(v.Type().Elem())
+		if err := decodeStruct(data, node.Elem()); err != nil {
+			return err
+		}
+		v.Set(node)
+		return nil
+	case v.Kind() == reflect.Struct:
+		return decodeStruct(data, v)
+	case v.Kind() == reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return fmt.Errorf("ast: %v", err)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := decode(elem, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+		return fmt.Errorf("ast: %v", err)
+	}
+	return nil
+}
+
+func decodeStruct(data json.RawMessage, v reflect.Value) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return fmt.Errorf("ast: %v", err)
+	}
+
+	typ := v.Type()
+	if name, ok := members["node"]; ok && isNode(typ) {
+		if want, _ := json.Marshal(typ.Name()); !bytes.Equal(name, want) {
+			return fmt.Errorf("ast: got node type %s, want %s", name, want)
+		}
+		delete(members, "node")
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		name := memberName(typ.Field(i).Name)
+		if data, ok := members[name]; ok {
+			if err := decode(data, v.Field(i)); err != nil {
+				return err
+			}
+			delete(members, name)
+		}
+	}
+	for name := range members {
+		return fmt.Errorf("ast: unknown member %q of %s", name, typ.Name())
+	}
+	return nil
+}
+
+// linkComments replaces the doc comments of the declarations of file by
+// the equal comment groups of file.Comments, as the parser shares them.
+func linkComments(file *File) {
+	groups := map[scanner.Pos]*CommentGroup{}
+	for _, group := range file.Comments {
+		if len(group.List) > 0 {
+			groups[group.Pos()] = group
+		}
+	}
+
+	link := func(doc **CommentGroup) {
+		if *doc != nil && len((*doc).List) > 0 {
+			if group, ok := groups[(*doc).Pos()]; ok {
+				*doc = group
+			}
+		}
+	}
+	Inspect(file, func(node Node) bool {
+		switch n := node.(type) {
+		case *ImportSpec:
+			link(&n.Doc)
+		case *ConstSpec:
+			link(&n.Doc)
+		case *TypeAlias:
+			link(&n.Doc)
+		case *Field:
+			link(&n.Doc)
+		case *Struct:
+			link(&n.Doc)
+		case *EnumMember:
+			link(&n.Doc)
+		case *Enum:
+			link(&n.Doc)
+		case *Variant:
+			link(&n.Doc)
+		case *Union:
+			link(&n.Doc)
+		case *Method:
+			link(&n.Doc)
+		case *Interface:
+			link(&n.Doc)
+		}
+		return true
+	})
+}
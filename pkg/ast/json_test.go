@@ -0,0 +1,127 @@
+package ast_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/parser"
+)
+
+const source = `// Common types.
+import "common/types" as t
+
+// The answer.
+const Answer = -(40 + 2) * 1.5 // trailing
+
+type Ids = list[t.Uuid]
+
+struct User {
+    // Primary key.
+    id: t.Uuid
+    tags?: map[string, bool]
+    key: [uint8; 2 * 16]
+}
+
+// A role.
+@default(guest)
+enum Role {
+    guest
+    // Can do anything.
+    admin = 10
+}
+
+// A subject.
+@tag("type")
+union Subject {
+    // A user.
+    user: User
+}
+
+interface Users {
+    @http("GET")
+    func get(@path id: t.Uuid) -> User
+    func ping()
+}
+
+const broken = )
+`
+
+func TestJSONRoundTrip(t *testing.T) {
+	file := parser.ParseWithOptions([]byte(source), parser.Options{}).File
+
+	data, err := ast.MarshalJSON(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`{"node":"File","nodes":[{"node":"ImportSpec","doc":{"node":"CommentGroup","list":[{"node":"Comment","slash":{"line":0,"column":0},"text":"// Common types."}],"trailing":false}`,
+		`{"node":"BasicLit","valuePos":{"line":4,"column":17},"kind":"INTEGER","value":"40"}`,
+		`{"node":"UnaryExpr","opPos":{"line":4,"column":15},"op":"-","expr":`,
+		`"result":null}`,
+		`{"node":"BadNode","from":`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("encoding does not contain %s", want)
+		}
+	}
+
+	node, err := ast.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(node, file) {
+		t.Fatal("decoded tree differs from the parsed tree")
+	}
+
+	decoded := node.(*ast.File)
+	if decoded.Nodes[1].(*ast.ConstSpec).Doc != decoded.Comments[1] {
+		t.Error("doc comment is not shared with File.Comments")
+	}
+
+	again, err := ast.MarshalJSON(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Error("encoding of the decoded tree differs")
+	}
+}
+
+func TestJSONNode(t *testing.T) {
+	node, err := ast.UnmarshalJSON([]byte(`{"node":"Name","namePos":{"line":1,"column":2},"name":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := node.(*ast.Name); !ok || name.Name != "x" || name.NamePos.Column != 2 {
+		t.Errorf("got %#v", node)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		err  string
+	}{
+		{`{"node":"Unknown"}`, `ast: unknown node type "Unknown"`},
+		{`{"node":"Name","nam":"x"}`, `ast: unknown member "nam" of Name`},
+		{`{"node":"UnaryExpr","op":"~"}`, `ast: unknown token kind "~"`},
+		{`{"node":"Field","name":{"node":"BasicLit"}}`, `ast: got node type "BasicLit", want "Name"`},
+		{`[]`, `ast: json: cannot unmarshal array`},
+	} {
+		_, err := ast.UnmarshalJSON([]byte(tt.data))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("UnmarshalJSON(%s): got error %v; want %s", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	var buf bytes.Buffer
+	ast.Fprint(&buf, parser.Parse([]byte("const x = 1")).File)
+	if want := "Const: Pos=1:7\n  Name: Name=x, Pos=1:7\n  BasicLit: Kind=INTEGER, Value=1, Pos=1:11\n"; buf.String() != want {
+		t.Errorf("got %q; want %q", buf.String(), want)
+	}
+}
@@ -34,6 +34,9 @@ func (p *printer) Visit(node Node) Visitor {
 	case *BinaryExpr:
 		p.printf("BinaryExpr: Op=%s, Pos=%v", n.Op, n.Pos())
 		indent++
+	case *Annotation:
+		p.printf("Annotation: Name=%s, Pos=%v", n.Name.Name, n.Pos())
+		indent++
 	case *ImportSpec:
 		alias := ""
 		if n.Alias != nil {
@@ -51,11 +54,32 @@ func (p *printer) Visit(node Node) Visitor {
 		p.printf("TypeDef: Pos=%v", n.Pos())
 		indent++
 	case *Field:
-		p.printf("Field: Pos=%v", n.Pos())
+		p.printf("Field: Optional=%t, Pos=%v", n.Optional, n.Pos())
 		indent++
 	case *Struct:
 		p.printf("StructDef: Pos=%v", n.Pos())
 		indent++
+	case *EnumMember:
+		p.printf("EnumMember: Pos=%v", n.Pos())
+		indent++
+	case *Enum:
+		p.printf("EnumDef: Pos=%v", n.Pos())
+		indent++
+	case *Variant:
+		p.printf("Variant: Pos=%v", n.Pos())
+		indent++
+	case *Union:
+		p.printf("UnionDef: Pos=%v", n.Pos())
+		indent++
+	case *Param:
+		p.printf("Param: Pos=%v", n.Pos())
+		indent++
+	case *Method:
+		p.printf("Method: Pos=%v", n.Pos())
+		indent++
+	case *Interface:
+		p.printf("InterfaceDef: Pos=%v", n.Pos())
+		indent++
 	case *File:
 		// nothing to do
 	default:
@@ -69,7 +93,7 @@ func (p *printer) Exit(node Node) {
 }
 
 func (p *printer) printf(format string, args ...any) {
-	fmt.Println(strings.Repeat("  ", p.indent) + fmt.Sprintf(format, args...))
+	fmt.Fprintln(p.writer, strings.Repeat("  ", p.indent)+fmt.Sprintf(format, args...))
 }
 
 func Fprint(writer io.Writer, node Node) {
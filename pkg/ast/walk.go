@@ -28,30 +28,89 @@ func Walk(v Visitor, node Node) {
 	case *BinaryExpr:
 		Walk(v, n.Lhs)
 		Walk(v, n.Rhs)
+	case *Annotation:
+		Walk(v, n.Name)
+		for _, child := range n.Args {
+			Walk(v, child)
+		}
 	case *ImportSpec:
 		Walk(v, n.Path)
 		if n.Alias != nil {
 			Walk(v, n.Alias)
 		}
 	case *ConstSpec:
+		walkAnnotations(v, n.Annotations)
 		Walk(v, n.Name)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
 		Walk(v, n.Expr)
 	case *Type:
-		Walk(v, n.Name)
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
 		for _, child := range n.Args {
 			Walk(v, child)
 		}
+		if n.Elem != nil {
+			Walk(v, n.Elem)
+			Walk(v, n.Len)
+		}
 	case *TypeAlias:
+		walkAnnotations(v, n.Annotations)
 		Walk(v, n.Name)
 		Walk(v, n.Type)
 	case *Field:
+		walkAnnotations(v, n.Annotations)
 		Walk(v, n.Name)
 		Walk(v, n.Type)
 	case *Struct:
+		walkAnnotations(v, n.Annotations)
 		Walk(v, n.Name)
 		for _, child := range n.Fields {
 			Walk(v, child)
 		}
+	case *EnumMember:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *Enum:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		for _, child := range n.Members {
+			Walk(v, child)
+		}
+	case *Variant:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		Walk(v, n.Type)
+	case *Union:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		for _, child := range n.Variants {
+			Walk(v, child)
+		}
+	case *Param:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		Walk(v, n.Type)
+	case *Method:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		for _, child := range n.Params {
+			Walk(v, child)
+		}
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+	case *Interface:
+		walkAnnotations(v, n.Annotations)
+		Walk(v, n.Name)
+		for _, child := range n.Methods {
+			Walk(v, child)
+		}
 	case *File:
 		for _, child := range n.Nodes {
 			Walk(v, child)
@@ -60,3 +119,28 @@ func Walk(v Visitor, node Node) {
 		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
 	}
 }
+
+func walkAnnotations(v Visitor, annotations []*Annotation) {
+	for _, child := range annotations {
+		Walk(v, child)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+func (f inspector) Exit(node Node) {
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the children of node.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,326 @@
+// Package constant implements the values of Lark constant expressions and
+// the operations on them.
+//
+// Integer values are exact and unbounded; float values are IEEE 754
+// double-precision numbers. Operations that would produce an infinity or
+// NaN fail instead.
+package constant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/scanner"
+)
+
+// Kind is the kind of a value.
+type Kind int
+
+const (
+	// Unknown is the kind of the zero Value, which stands for a value
+	// that could not be computed.
+	Unknown Kind = iota
+	Null
+	Bool
+	Int
+	Float
+	String
+)
+
+var kinds = [...]string{
+	Unknown: "unknown",
+	Null:    "null",
+	Bool:    "bool",
+	Int:     "int",
+	Float:   "float",
+	String:  "string",
+}
+
+func (k Kind) String() string {
+	if 0 <= k && k < Kind(len(kinds)) {
+		return kinds[k]
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Value is the value of a constant expression. The zero Value is
+// unknown.
+type Value struct {
+	kind Kind
+	b    bool
+	i    *big.Int
+	f    float64
+	s    string
+}
+
+func MakeNull() Value             { return Value{kind: Null} }
+func MakeBool(b bool) Value       { return Value{kind: Bool, b: b} }
+func MakeInt64(i int64) Value     { return Value{kind: Int, i: big.NewInt(i)} }
+func MakeInt(i *big.Int) Value    { return Value{kind: Int, i: new(big.Int).Set(i)} }
+func MakeFloat64(f float64) Value { return Value{kind: Float, f: f} }
+func MakeString(s string) Value   { return Value{kind: String, s: s} }
+
+func (v Value) Kind() Kind        { return v.kind }
+func (v Value) IsKnown() bool     { return v.kind != Unknown }
+func (v Value) BoolVal() bool     { return v.b }
+func (v Value) StringVal() string { return v.s }
+func (v Value) Int() *big.Int     { return new(big.Int).Set(v.int()) }
+
+func (v Value) int() *big.Int {
+	if v.i == nil {
+		return new(big.Int)
+	}
+	return v.i
+}
+
+func (v Value) isNumeric() bool {
+	return v.kind == Int || v.kind == Float
+}
+
+// Int64 returns the value of an integer and whether it fits into an int64.
+func (v Value) Int64() (int64, bool) {
+	i := v.int()
+	return i.Int64(), v.kind == Int && i.IsInt64()
+}
+
+// Float64 returns the value of a number as a float64. Integers are
+// rounded to the nearest float64.
+func (v Value) Float64() float64 {
+	if v.kind == Int {
+		f, _ := new(big.Float).SetInt(v.int()).Float64()
+		return f
+	}
+	return v.f
+}
+
+// String returns the value in Lark syntax.
+func (v Value) String() string {
+	switch v.kind {
+	case Null:
+		return "null"
+	case Bool:
+		return strconv.FormatBool(v.b)
+	case Int:
+		return v.int().String()
+	case Float:
+		return formatFloat(v.f)
+	case String:
+		return strconv.Quote(v.s)
+	}
+	return "unknown"
+}
+
+// formatFloat formats f so that it reads back as a float.
+func formatFloat(f float64) string {
+	text := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEnN") {
+		text += ".0"
+	}
+	return text
+}
+
+// MakeFromLiteral returns the value of a literal.
+func MakeFromLiteral(lit *ast.BasicLit) (Value, error) {
+	switch lit.Kind {
+	case scanner.NULL:
+		return MakeNull(), nil
+	case scanner.TRUE:
+		return MakeBool(true), nil
+	case scanner.FALSE:
+		return MakeBool(false), nil
+	case scanner.INTEGER:
+		i, ok := new(big.Int).SetString(lit.Value, 0)
+		if !ok {
+			return Value{}, fmt.Errorf("invalid integer literal %s", lit.Value)
+		}
+		return Value{kind: Int, i: i}, nil
+	case scanner.FLOAT:
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("float literal %s overflows", lit.Value)
+		}
+		return MakeFloat64(f), nil
+	case scanner.STRING:
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid string literal %s", lit.Value)
+		}
+		return MakeString(s), nil
+	}
+	return Value{}, fmt.Errorf("%s is not a literal", lit.Kind)
+}
+
+// MarshalJSON encodes a value as the JSON literal of the same kind.
+// Floats always have a fraction or an exponent, so that they can be told
+// from integers.
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.kind {
+	case Unknown:
+		return nil, errors.New("constant: cannot encode unknown value")
+	case String:
+		return json.Marshal(v.s)
+	}
+	return []byte(v.String()), nil
+}
+
+// UnmarshalJSON decodes a value from a JSON literal. Numbers with a
+// fraction or an exponent are floats.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var x any
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
+		return err
+	}
+
+	switch x := x.(type) {
+	case nil:
+		*v = MakeNull()
+	case bool:
+		*v = MakeBool(x)
+	case string:
+		*v = MakeString(x)
+	case json.Number:
+		if !strings.ContainsAny(string(x), ".eE") {
+			i, ok := new(big.Int).SetString(string(x), 10)
+			if !ok {
+				return fmt.Errorf("constant: invalid integer %s", x)
+			}
+			*v = Value{kind: Int, i: i}
+			return nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return err
+		}
+		*v = MakeFloat64(f)
+	default:
+		return fmt.Errorf("constant: cannot decode %s", data)
+	}
+	return nil
+}
+
+// UnaryOp returns the result of the unary operation op x.
+func UnaryOp(op scanner.TokenKind, x Value) (Value, error) {
+	switch {
+	case op == scanner.MINUS && x.kind == Int:
+		return Value{kind: Int, i: new(big.Int).Neg(x.int())}, nil
+	case op == scanner.MINUS && x.kind == Float:
+		return MakeFloat64(-x.f), nil
+	case op == scanner.NOT && x.kind == Bool:
+		return MakeBool(!x.b), nil
+	}
+	return Value{}, fmt.Errorf("operator %s not defined on %s", op, x.kind)
+}
+
+// BinaryOp returns the result of the binary operation x op y. Mixing
+// integers and floats yields a float.
+func BinaryOp(x Value, op scanner.TokenKind, y Value) (Value, error) {
+	switch op {
+	case scanner.AND, scanner.OR:
+		if x.kind != Bool || y.kind != Bool {
+			break
+		}
+		if op == scanner.AND {
+			return MakeBool(x.b && y.b), nil
+		}
+		return MakeBool(x.b || y.b), nil
+
+	case scanner.EQ, scanner.NEQ, scanner.LT, scanner.LE, scanner.GT, scanner.GE:
+		return compare(x, op, y)
+
+	case scanner.PLUS, scanner.MINUS, scanner.MULT, scanner.DIV, scanner.MOD:
+		switch {
+		case x.kind == String && y.kind == String && op == scanner.PLUS:
+			return MakeString(x.s + y.s), nil
+		case x.kind == Int && y.kind == Int:
+			return intOp(x.int(), op, y.int())
+		case x.isNumeric() && y.isNumeric() && op != scanner.MOD:
+			return floatOp(x.Float64(), op, y.Float64())
+		}
+	}
+	return Value{}, fmt.Errorf("operator %s not defined on %s and %s", op, x.kind, y.kind)
+}
+
+func intOp(x *big.Int, op scanner.TokenKind, y *big.Int) (Value, error) {
+	z := new(big.Int)
+	switch op {
+	case scanner.PLUS:
+		z.Add(x, y)
+	case scanner.MINUS:
+		z.Sub(x, y)
+	case scanner.MULT:
+		z.Mul(x, y)
+	case scanner.DIV, scanner.MOD:
+		if y.Sign() == 0 {
+			return Value{}, errors.New("division by zero")
+		}
+		if op == scanner.DIV {
+			z.Quo(x, y)
+		} else {
+			z.Rem(x, y)
+		}
+	}
+	return Value{kind: Int, i: z}, nil
+}
+
+func floatOp(x float64, op scanner.TokenKind, y float64) (Value, error) {
+	var z float64
+	switch op {
+	case scanner.PLUS:
+		z = x + y
+	case scanner.MINUS:
+		z = x - y
+	case scanner.MULT:
+		z = x * y
+	case scanner.DIV:
+		if y == 0 {
+			return Value{}, errors.New("division by zero")
+		}
+		z = x / y
+	}
+	if math.IsInf(z, 0) || math.IsNaN(z) {
+		return Value{}, errors.New("constant overflow")
+	}
+	return MakeFloat64(z), nil
+}
+
+func compare(x Value, op scanner.TokenKind, y Value) (Value, error) {
+	var c int
+	switch {
+	case x.kind == Int && y.kind == Int:
+		c = x.int().Cmp(y.int())
+	case x.isNumeric() && y.isNumeric():
+		c = big.NewFloat(x.Float64()).Cmp(big.NewFloat(y.Float64()))
+	case x.kind == String && y.kind == String:
+		c = strings.Compare(x.s, y.s)
+	case x.kind == Null && y.kind == Null && (op == scanner.EQ || op == scanner.NEQ):
+		c = 0
+	case x.kind == Bool && y.kind == Bool && (op == scanner.EQ || op == scanner.NEQ):
+		if x.b != y.b {
+			c = 1
+		}
+	default:
+		return Value{}, fmt.Errorf("cannot compare %s and %s", x.kind, y.kind)
+	}
+
+	switch op {
+	case scanner.EQ:
+		return MakeBool(c == 0), nil
+	case scanner.NEQ:
+		return MakeBool(c != 0), nil
+	case scanner.LT:
+		return MakeBool(c < 0), nil
+	case scanner.LE:
+		return MakeBool(c <= 0), nil
+	case scanner.GT:
+		return MakeBool(c > 0), nil
+	}
+	return MakeBool(c >= 0), nil
+}
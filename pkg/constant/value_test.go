@@ -0,0 +1,135 @@
+package constant
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/scanner"
+)
+
+func lit(kind scanner.TokenKind, value string) Value {
+	v, err := MakeFromLiteral(&ast.BasicLit{Kind: kind, Value: value})
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestMakeFromLiteral(t *testing.T) {
+	tests := []struct {
+		kind  scanner.TokenKind
+		value string
+		want  string
+	}{
+		{scanner.INTEGER, "42", "42"},
+		{scanner.INTEGER, "0x10", "16"},
+		{scanner.INTEGER, "123456789012345678901234567890", "123456789012345678901234567890"},
+		{scanner.FLOAT, "1.5", "1.5"},
+		{scanner.FLOAT, "1e3", "1000.0"},
+		{scanner.STRING, `"a\tb"`, `"a\tb"`},
+		{scanner.TRUE, "true", "true"},
+		{scanner.NULL, "null", "null"},
+	}
+	for _, test := range tests {
+		if got := lit(test.kind, test.value).String(); got != test.want {
+			t.Errorf("%s: got %s; want %s", test.value, got, test.want)
+		}
+	}
+
+	if _, err := MakeFromLiteral(&ast.BasicLit{Kind: scanner.FLOAT, Value: "1e999"}); err == nil {
+		t.Error("1e999: got no error")
+	}
+}
+
+func TestOperations(t *testing.T) {
+	one, two := MakeInt64(1), MakeInt64(2)
+	tests := []struct {
+		x    Value
+		op   scanner.TokenKind
+		y    Value
+		want string
+	}{
+		{one, scanner.PLUS, two, "3"},
+		{one, scanner.DIV, two, "0"},
+		{MakeInt64(-7), scanner.MOD, two, "-1"},
+		{one, scanner.PLUS, MakeFloat64(0.5), "1.5"},
+		{MakeFloat64(1), scanner.DIV, two, "0.5"},
+		{MakeString("a"), scanner.PLUS, MakeString("b"), `"ab"`},
+		{one, scanner.LT, MakeFloat64(1.5), "true"},
+		{MakeString("a"), scanner.GE, MakeString("b"), "false"},
+		{MakeNull(), scanner.EQ, MakeNull(), "true"},
+		{MakeBool(true), scanner.AND, MakeBool(false), "false"},
+		{MakeInt(new(big.Int).Lsh(big.NewInt(1), 64)), scanner.MULT, two, "36893488147419103232"},
+	}
+	for _, test := range tests {
+		got, err := BinaryOp(test.x, test.op, test.y)
+		if err != nil {
+			t.Errorf("%s %s %s: %v", test.x, test.op, test.y, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("%s %s %s: got %s; want %s", test.x, test.op, test.y, got, test.want)
+		}
+	}
+
+	errors := []struct {
+		x  Value
+		op scanner.TokenKind
+		y  Value
+	}{
+		{one, scanner.DIV, MakeInt64(0)},
+		{MakeFloat64(1), scanner.MOD, two},
+		{MakeString("a"), scanner.MINUS, MakeString("b")},
+		{MakeBool(true), scanner.LT, MakeBool(false)},
+		{MakeFloat64(1e308), scanner.MULT, MakeFloat64(10)},
+	}
+	for _, test := range errors {
+		if v, err := BinaryOp(test.x, test.op, test.y); err == nil {
+			t.Errorf("%s %s %s: got %s; want error", test.x, test.op, test.y, v)
+		}
+	}
+
+	if v, err := UnaryOp(scanner.MINUS, one); err != nil || v.String() != "-1" {
+		t.Errorf("-1: got %s, %v", v, err)
+	}
+	if _, err := UnaryOp(scanner.NOT, one); err == nil {
+		t.Error("!1: got no error")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	values := []Value{
+		MakeNull(),
+		MakeBool(false),
+		MakeInt64(-3),
+		MakeInt(new(big.Int).Lsh(big.NewInt(1), 100)),
+		MakeFloat64(2),
+		MakeFloat64(0.25),
+		MakeString("ä\"\n"),
+	}
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("%s: %v", v, err)
+			continue
+		}
+		var got Value
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("%s: %v", data, err)
+			continue
+		}
+		if got.Kind() != v.Kind() || got.String() != v.String() {
+			t.Errorf("%s: got %s (%s); want %s (%s)", data, got, got.Kind(), v, v.Kind())
+		}
+	}
+
+	if _, err := json.Marshal(Value{}); err == nil {
+		t.Error("got no error for an unknown value")
+	}
+	var v Value
+	if err := json.Unmarshal([]byte(`[1]`), &v); err == nil {
+		t.Error("got no error for an array")
+	}
+}
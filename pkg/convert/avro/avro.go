@@ -0,0 +1,144 @@
+// Package avro converts Apache Avro schemas (.avsc) to Lark source.
+//
+// Every namespace becomes a Lark module: the named types of namespace
+// com.acme.users become declarations of module com/acme/users, or of
+// module users if the namespace prefix com.acme is configured. Types
+// without a namespace belong to the module of the file they are defined
+// in, which is its path without the .avsc extension. A file holds one
+// schema, which may define several named types; types may be defined in
+// several files as long as the definitions agree, as they do in the
+// self-contained files of the avro generator. Schemas are translated as
+// follows:
+//
+//   - Records and errors become structs. Fields whose names are not Lark
+//     identifiers are renamed and keep their name in @json("name").
+//   - Unions of null and one type become optional fields. Unions of
+//     records become Lark unions named after the enclosing record and
+//     the field, so that field owner of Group becomes GroupOwner, with
+//     Item appended for array items and Value for map values. The
+//     variants are named after the records in snake case, and the tag is
+//     kind, or the first of type, tag and variant that no record has as
+//     a field.
+//   - Enums become enums whose members are numbered in order; fixed types
+//     become aliases of byte arrays of their size.
+//   - Arrays become lists and maps become maps with string keys. int and
+//     long become int32 and int64, float and double float32 and float64.
+//   - The logical types timestamp-millis, timestamp-micros and
+//     timestamp-nanos become timestamp, uuid becomes uuid, and decimals of
+//     20 digits and scale 0 become uint64, which is how the avro generator
+//     writes these types. Other logical types are reported as warnings and
+//     their underlying types kept.
+//
+// References to named types of other namespaces become imports. Docs
+// become doc comments, and a final "Deprecated:" paragraph of them a
+// @deprecated annotation. Defaults become @default annotations: enum
+// defaults name the member and timestamp defaults are RFC 3339 strings.
+// Defaults of other composite types, aliases and field order are reported
+// as warnings and dropped; other attributes are metadata, which Avro
+// allows anywhere, and are ignored.
+package avro
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"larklang.io/lark/internal/jsonpos"
+	"larklang.io/lark/pkg/diag"
+)
+
+// A Config controls how schemas are converted.
+type Config struct {
+	// Namespace is a prefix of namespaces, such as "com.acme", that is
+	// left out of the module paths.
+	Namespace string
+
+	// ReadFile reads a file; nil means os.ReadFile.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// A Source names a schema file to convert. If Src is nil, the file is
+// read from Path.
+type Source struct {
+	Path string
+	Src  []byte
+}
+
+// A Module is a Lark file converted from the named types of a namespace.
+type Module struct {
+	Path    string // slash-separated path of the Lark file
+	Content []byte // formatted Lark source
+}
+
+// A File holds the diagnostics of a source.
+type File struct {
+	Path        string
+	Lines       []string
+	Diagnostics []diag.Diagnostic
+}
+
+// Convert converts the sources and returns the Lark modules, in the order
+// their first types are defined, and the diagnostics of the sources.
+// References between the sources are resolved by full name. Convert fails
+// only if a source cannot be read.
+func (c *Config) Convert(sources ...Source) ([]*Module, []*File, error) {
+	cv := &converter{
+		prefix:  strings.TrimSuffix(c.Namespace, "."),
+		named:   make(map[string]*decl),
+		byNode:  make(map[*jsonpos.Value]*decl),
+		modules: make(map[string]*module),
+	}
+	var files []*File
+	var srcs []*srcFile
+	for _, source := range sources {
+		src := source.Src
+		if src == nil {
+			var err error
+			if src, err = c.read(source.Path); err != nil {
+				return nil, nil, err
+			}
+		}
+		name := path.Clean(filepath.ToSlash(source.Path))
+		file := &File{
+			Path:  source.Path,
+			Lines: strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n"),
+		}
+		files = append(files, file)
+		root, err := jsonpos.Parse(src)
+		if err != nil {
+			file.Diagnostics = []diag.Diagnostic{{
+				Severity: diag.Error,
+				Code:     diag.InvalidInput,
+				Range:    diag.At(err.Pos),
+				Message:  err.Msg,
+			}}
+			continue
+		}
+		s := &srcFile{file: file, module: strings.TrimSuffix(name, ".avsc"), root: root}
+		srcs = append(srcs, s)
+		cv.declare(s, root, "")
+	}
+	for _, d := range cv.decls {
+		cv.define(d)
+	}
+	var modules []*Module
+	for _, m := range cv.order {
+		content, err := m.emit()
+		if err != nil {
+			return nil, nil, err
+		}
+		modules = append(modules, &Module{Path: m.path + ".lark", Content: content})
+	}
+	for _, s := range srcs {
+		s.file.Diagnostics = s.sortedDiags()
+	}
+	return modules, files, nil
+}
+
+func (c *Config) read(name string) ([]byte, error) {
+	if c.ReadFile != nil {
+		return c.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
@@ -0,0 +1,162 @@
+package avro
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// convert converts the given sources with the namespace prefix
+// com.example.
+func convert(t *testing.T, sources ...Source) ([]*Module, []*File) {
+	t.Helper()
+	c := &Config{Namespace: "com.example"}
+	modules, files, err := c.Convert(sources...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modules, files
+}
+
+// messages returns the diagnostics of a file as strings.
+func messages(file *File) []string {
+	var list []string
+	for _, d := range file.Diagnostics {
+		list = append(list, d.Error())
+	}
+	return list
+}
+
+// The testdata holds the output of the avro generator for users, and an
+// order as written by hand.
+func TestGolden(t *testing.T) {
+	modules, _ := convert(t,
+		Source{Path: "testdata/users/User.avsc"},
+		Source{Path: "testdata/users/Role.avsc"},
+		Source{Path: "testdata/orders.avsc"},
+	)
+	lark := make(map[string][]byte)
+	var paths []string
+	for _, m := range modules {
+		lark[m.Path] = m.Content
+		paths = append(paths, m.Path)
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(m.Path, ".lark")+".golden"))
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Content, want) {
+			t.Errorf("%s differs from %s:\n%s", m.Path, golden, diff.Unified(golden, m.Path, want, m.Content))
+		}
+	}
+	if got, want := strings.Join(paths, " "), "users.lark common/types.lark shop.lark"; got != want {
+		t.Errorf("got modules %s; want %s", got, want)
+	}
+
+	// The converted modules are valid Lark.
+	c := &loader.Config{
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := lark[filepath.ToSlash(name)]; ok {
+				return src, nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+	prog, err := c.Load(loader.Source{Path: "shop.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.Check(prog)
+	for _, file := range prog.Diagnostics() {
+		for _, d := range file.Diagnostics {
+			t.Errorf("%s: %v", file.Name, d)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	_, files := convert(t,
+		Source{Path: "testdata/users/User.avsc"},
+		Source{Path: "testdata/orders.avsc"},
+		Source{Path: "testdata/errors.avsc"},
+	)
+	tests := [][]string{
+		nil,
+		{
+			"6:3: warning[W0100]: aliases of com.example.shop.Order have no Lark equivalent and are dropped",
+			"16:9: warning[W0100]: default of enum com.example.shop.Status has no Lark equivalent and is dropped",
+			"22:45: warning[W0100]: logical type date of field due of com.example.shop.Order has no Lark equivalent; the int type is kept",
+			"23:49: warning[W0100]: logical type decimal of field total of com.example.shop.Order has no Lark equivalent; the bytes type is kept",
+			"32:47: warning[W0100]: order of field sku of com.example.shop.Line has no Lark equivalent and is dropped",
+			"34:67: warning[W0100]: null values of field options of com.example.shop.Line have no Lark equivalent and are dropped",
+			"34:88: warning[W0100]: default of field options of com.example.shop.Line is a object, which Lark annotations cannot express, and is dropped",
+			"49:30: warning[W0100]: union of field note of com.example.shop.Order has branches other than records, which have no Lark equivalent, and is dropped",
+			"50:33: warning[W0100]: type null of field nothing of com.example.shop.Order has no Lark equivalent and is dropped",
+			"51:32: error[E0300]: type Coupon of field coupon of com.example.shop.Order is not defined",
+		},
+		{
+			"5:30: error[E0306]: com.example.users.Role is already defined differently in testdata/users/User.avsc",
+			"6:72: error[E0400]: size 0 of fixed Tiny is not a positive integer",
+			"7:51: error[E0400]: default of field first of Bad is not null, the first branch of its union",
+		},
+	}
+	for i, file := range files {
+		got := messages(file)
+		if strings.Join(got, "\n") != strings.Join(tests[i], "\n") {
+			t.Errorf("%s: got diagnostics\n%s\nwant\n%s", file.Path, strings.Join(got, "\n"), strings.Join(tests[i], "\n"))
+		}
+	}
+}
+
+// Types defined in several files agree whether they define the types
+// they use or refer to them.
+func TestRedefinition(t *testing.T) {
+	const group = `{"type": "record", "name": "Group", "namespace": "a", "fields": [{"name": "tag", "type": "Tag"}]}`
+	modules, files := convert(t,
+		Source{Path: "tag.avsc", Src: []byte(`{"type": "enum", "name": "Tag", "namespace": "a", "symbols": ["x"]}`)},
+		Source{Path: "group.avsc", Src: []byte(group)},
+		Source{Path: "inline.avsc", Src: []byte(`{"type": "record", "name": "Group", "namespace": "a", "fields": [
+			{"name": "tag", "type": {"type": "enum", "name": "Tag", "symbols": ["x"]}}]}`)},
+	)
+	for _, file := range files {
+		if len(file.Diagnostics) > 0 {
+			t.Errorf("%s: unexpected diagnostics %v", file.Path, messages(file))
+		}
+	}
+	if len(modules) != 1 || modules[0].Path != "a.lark" {
+		t.Fatalf("got modules %v", modules)
+	}
+	const want = "enum Tag {\n    x\n}\n\nstruct Group {\n    tag: Tag\n}\n"
+	if got := string(modules[0].Content); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`{"type": "record",}`, `1:19: error[E0400]: expected a string, found '}'`},
+		{`{"type": "record", "fields": []}`, `1:1: error[E0400]: record has no name`},
+		{`{"type": "enum", "name": "E"}`, `1:1: error[E0400]: enum E has no symbols`},
+		{`{"type": "record", "name": "R", "fields": [{"name": "f"}]}`, `1:44: error[E0400]: field f of R has no type`},
+		{`{"type": "record", "name": "R", "fields": [{"name": "f", "type": 1}]}`, `1:66: error[E0400]: schema of field f of R is a number, not a name, a union or an object`},
+	}
+	for _, test := range tests {
+		_, files := convert(t, Source{Path: "errors.avsc", Src: []byte(test.src)})
+		if got := messages(files[0]); len(got) != 1 || got[0] != test.err {
+			t.Errorf("%q: got %v; want %s", test.src, got, test.err)
+		}
+		if !diag.HasErrors(files[0].Diagnostics) {
+			t.Errorf("%q: no errors", test.src)
+		}
+	}
+}
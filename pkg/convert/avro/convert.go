@@ -0,0 +1,739 @@
+package avro
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"larklang.io/lark/internal/jsonpos"
+	"larklang.io/lark/internal/names"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/schema"
+)
+
+type declKind int
+
+const (
+	dropped declKind = iota
+	structDecl
+	enumDecl
+	unionDecl
+	aliasDecl
+)
+
+// A decl is a Lark declaration under construction.
+type decl struct {
+	src         *srcFile
+	module      *module
+	node        *jsonpos.Value // definition the declaration is converted from
+	outer       string         // namespace enclosing the definition
+	fullName    string         // Avro name
+	namespace   string
+	name        string
+	kind        declKind
+	doc         string
+	annotations []string
+	typ         string // aliased type
+	fields      []*field
+	members     []string
+	variants    []*variant
+	nested      []*decl // unions of the fields, written after this one
+}
+
+type field struct {
+	name        string
+	typ         string
+	optional    bool
+	doc         string
+	annotations []string
+}
+
+type variant struct {
+	name, typ string
+}
+
+// A srcFile is a schema file being converted.
+type srcFile struct {
+	file   *File
+	module string // Lark module of the types without a namespace
+	root   *jsonpos.Value
+	diags  []diag.Diagnostic
+}
+
+func (s *srcFile) warnf(pos diag.Pos, format string, args ...any) {
+	s.diags = append(s.diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Code:     diag.Untranslatable,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *srcFile) errorf(pos diag.Pos, code diag.Code, format string, args ...any) {
+	s.diags = append(s.diags, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     code,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *srcFile) sortedDiags() []diag.Diagnostic {
+	sort.SliceStable(s.diags, func(i, j int) bool {
+		return s.diags[j].Pos().Greater(s.diags[i].Pos())
+	})
+	return s.diags
+}
+
+// A module is a Lark module under construction.
+type module struct {
+	path    string
+	decls   []*decl // named types in order
+	names   map[string]bool
+	imports map[*module]string // Lark names of the imported modules
+}
+
+// newName returns an unused declaration name based on an Avro name.
+func (m *module) newName(name string) string {
+	if !names.IsIdent(name) {
+		name = names.Type(name)
+	}
+	unique := name
+	for i := 2; m.names[unique] || m.importNameUsed(unique); i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	m.names[unique] = true
+	return unique
+}
+
+// qualify returns the name of a declaration, qualified with the name of
+// its import if it is declared in another module.
+func (m *module) qualify(d *decl) string {
+	if d.module == m {
+		return d.name
+	}
+	name, ok := m.imports[d.module]
+	if !ok {
+		name = names.Ident(path.Base(d.module.path))
+		base := name
+		for i := 2; m.names[name] || m.importNameUsed(name); i++ {
+			name = base + strconv.Itoa(i)
+		}
+		m.imports[d.module] = name
+	}
+	return name + "." + d.name
+}
+
+func (m *module) importNameUsed(name string) bool {
+	for _, used := range m.imports {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
+
+type converter struct {
+	prefix  string                   // namespace prefix left out of module paths
+	named   map[string]*decl         // by full name
+	byNode  map[*jsonpos.Value]*decl // definitions, including repeated ones
+	decls   []*decl                  // named types in order of definition
+	modules map[string]*module       // by path
+	order   []*module                // in order of their first type
+}
+
+// primitives maps the primitive Avro types to Lark types; null has none.
+var primitives = map[string]string{
+	"null":    "",
+	"boolean": "bool",
+	"int":     "int32",
+	"long":    "int64",
+	"float":   "float32",
+	"double":  "float64",
+	"string":  "string",
+	"bytes":   "bytes",
+}
+
+// isNamed reports whether kind is the type of a named type definition.
+func isNamed(kind string) bool {
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		return true
+	}
+	return false
+}
+
+// kindOf returns the type of a schema object.
+func kindOf(node *jsonpos.Value) string {
+	kind, _ := node.Lookup("type").Str()
+	return kind
+}
+
+// fullName returns the full name, the namespace and the simple name of a
+// named type defined by node, or referred to if node is nil, in the
+// enclosing namespace ns.
+func fullName(name string, node *jsonpos.Value, ns string) (full, namespace, simple string) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name, name[:i], name[i+1:]
+	}
+	if m := node.Get("namespace"); m != nil {
+		ns, _ = m.Value.Str()
+	}
+	if ns == "" {
+		return name, "", name
+	}
+	return ns + "." + name, ns, name
+}
+
+// module returns the Lark module of a namespace.
+func (cv *converter) module(ns string, s *srcFile) *module {
+	p := s.module
+	if ns != "" {
+		if rest, ok := strings.CutPrefix(ns, cv.prefix+"."); ok && cv.prefix != "" {
+			ns = rest
+		}
+		p = strings.ReplaceAll(ns, ".", "/")
+	}
+	m := cv.modules[p]
+	if m == nil {
+		m = &module{path: p, names: make(map[string]bool), imports: make(map[*module]string)}
+		cv.modules[p] = m
+		cv.order = append(cv.order, m)
+	}
+	return m
+}
+
+// resolve returns the named type that name refers to in namespace ns, or
+// nil.
+func (cv *converter) resolve(name, ns string) *decl {
+	full, _, _ := fullName(name, nil, ns)
+	if d := cv.named[full]; d != nil {
+		return d
+	}
+	return cv.named[name]
+}
+
+// declare declares the named types defined in a schema.
+func (cv *converter) declare(s *srcFile, node *jsonpos.Value, ns string) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case jsonpos.Array:
+		for _, elem := range node.Elems {
+			cv.declare(s, elem, ns)
+		}
+		return
+	case jsonpos.Object:
+	default:
+		return
+	}
+	t := node.Lookup("type")
+	kind, _ := t.Str()
+	switch {
+	case kind == "array":
+		cv.declare(s, node.Lookup("items"), ns)
+		return
+	case kind == "map":
+		cv.declare(s, node.Lookup("values"), ns)
+		return
+	case !isNamed(kind):
+		if t != nil && t.Kind != jsonpos.String {
+			cv.declare(s, t, ns)
+		}
+		return
+	}
+	name, ok := node.Lookup("name").Str()
+	if !ok || name == "" {
+		s.errorf(node.Pos, diag.InvalidInput, "%s has no name", kind)
+		return
+	}
+	full, namespace, simple := fullName(name, node, ns)
+	if d := cv.named[full]; d != nil {
+		if cv.canonical(d.node, d.outer, true) != cv.canonical(node, ns, true) {
+			s.errorf(node.Pos, diag.Redeclared, "%s is already defined differently in %s", full, d.src.file.Path)
+		}
+		cv.byNode[node] = d
+		return
+	}
+	m := cv.module(namespace, s)
+	d := &decl{src: s, module: m, node: node, outer: ns, fullName: full, namespace: namespace, name: m.newName(simple)}
+	cv.named[full] = d
+	cv.byNode[node] = d
+	cv.decls = append(cv.decls, d)
+	m.decls = append(m.decls, d)
+	if kind == "record" || kind == "error" {
+		for _, f := range elems(node.Lookup("fields")) {
+			cv.declare(s, f.Lookup("type"), namespace)
+		}
+	}
+}
+
+// canonical returns the JSON text of a schema in which the named types
+// other than the top one and the references to them are replaced by their
+// full names, so that the definitions of a type in several files compare
+// equal whether they define the types they use or refer to them.
+func (cv *converter) canonical(node *jsonpos.Value, ns string, top bool) string {
+	if node == nil {
+		return "null"
+	}
+	switch node.Kind {
+	case jsonpos.String:
+		if _, ok := primitives[node.Text]; ok {
+			return strconv.Quote(node.Text)
+		}
+		full, _, _ := fullName(node.Text, nil, ns)
+		return strconv.Quote(full)
+	case jsonpos.Array:
+		var elems []string
+		for _, elem := range node.Elems {
+			elems = append(elems, cv.canonical(elem, ns, false))
+		}
+		return "[" + strings.Join(elems, ",") + "]"
+	case jsonpos.Object:
+	default:
+		return text(node)
+	}
+	kind := kindOf(node)
+	if isNamed(kind) {
+		name, _ := node.Lookup("name").Str()
+		full, namespace, _ := fullName(name, node, ns)
+		if !top {
+			return strconv.Quote(full)
+		}
+		ns = namespace
+	}
+	var members []string
+	for _, m := range node.Members {
+		var value string
+		switch {
+		case m.Key == "type" && m.Value.Kind == jsonpos.String && (isNamed(m.Value.Text) || m.Value.Text == "array" || m.Value.Text == "map"):
+			value = text(m.Value)
+		case m.Key == "type" || m.Key == "items" || m.Key == "values":
+			value = cv.canonical(m.Value, ns, false)
+		case m.Key == "fields" && m.Value.Kind == jsonpos.Array:
+			var fields []string
+			for _, f := range m.Value.Elems {
+				var list []string
+				for _, fm := range f.Members {
+					v := text(fm.Value)
+					if fm.Key == "type" {
+						v = cv.canonical(fm.Value, ns, false)
+					}
+					list = append(list, strconv.Quote(fm.Key)+":"+v)
+				}
+				fields = append(fields, "{"+strings.Join(list, ",")+"}")
+			}
+			value = "[" + strings.Join(fields, ",") + "]"
+		default:
+			value = text(m.Value)
+		}
+		members = append(members, strconv.Quote(m.Key)+":"+value)
+	}
+	return "{" + strings.Join(members, ",") + "}"
+}
+
+// text returns the JSON text of a value.
+func text(v *jsonpos.Value) string {
+	switch v.Kind {
+	case jsonpos.Null:
+		return "null"
+	case jsonpos.Bool:
+		return strconv.FormatBool(v.Bool)
+	case jsonpos.Number:
+		return v.Text
+	case jsonpos.String:
+		return strconv.Quote(v.Text)
+	case jsonpos.Array:
+		var elems []string
+		for _, elem := range v.Elems {
+			elems = append(elems, text(elem))
+		}
+		return "[" + strings.Join(elems, ",") + "]"
+	}
+	var members []string
+	for _, m := range v.Members {
+		members = append(members, strconv.Quote(m.Key)+":"+text(m.Value))
+	}
+	return "{" + strings.Join(members, ",") + "}"
+}
+
+// define converts the definition of a named type.
+func (cv *converter) define(d *decl) {
+	s, node := d.src, d.node
+	d.doc, d.annotations = describe(node)
+	switch kind := kindOf(node); kind {
+	case "record", "error":
+		fields := node.Lookup("fields")
+		if fields == nil || fields.Kind != jsonpos.Array {
+			s.errorf(node.Pos, diag.InvalidInput, "%s %s has no fields", kind, d.fullName)
+			return
+		}
+		d.kind = structDecl
+		for _, f := range fields.Elems {
+			cv.field(d, f)
+		}
+	case "enum":
+		symbols := node.Lookup("symbols")
+		if symbols == nil || symbols.Kind != jsonpos.Array {
+			s.errorf(node.Pos, diag.InvalidInput, "enum %s has no symbols", d.fullName)
+			return
+		}
+		d.kind = enumDecl
+		for _, sym := range symbols.Elems {
+			name, ok := sym.Str()
+			if !ok {
+				s.errorf(sym.Pos, diag.InvalidInput, "symbol of enum %s is a %s, not a string", d.fullName, sym.Kind)
+				continue
+			}
+			d.members = append(d.members, names.Ident(name))
+		}
+		if m := node.Get("default"); m != nil {
+			s.warnf(m.Pos, "default of enum %s has no Lark equivalent and is dropped", d.fullName)
+		}
+	case "fixed":
+		size := node.Lookup("size")
+		if size == nil || size.Kind != jsonpos.Number {
+			s.errorf(node.Pos, diag.InvalidInput, "fixed %s has no size", d.fullName)
+			return
+		}
+		n, err := strconv.ParseInt(size.Text, 10, 64)
+		if err != nil || n <= 0 {
+			s.errorf(size.Pos, diag.InvalidInput, "size %s of fixed %s is not a positive integer", size.Text, d.fullName)
+			return
+		}
+		d.kind = aliasDecl
+		d.typ = fmt.Sprintf("[uint8; %d]", n)
+		if m := node.Get("logicalType"); m != nil {
+			lt, _ := m.Value.Str()
+			s.warnf(m.Pos, "logical type %s of %s has no Lark equivalent; the fixed type is kept", lt, d.fullName)
+		}
+	}
+	if m := node.Get("aliases"); m != nil {
+		s.warnf(m.Pos, "aliases of %s have no Lark equivalent and are dropped", d.fullName)
+	}
+}
+
+// elems returns the elements of an array, or nil.
+func elems(v *jsonpos.Value) []*jsonpos.Value {
+	if v == nil || v.Kind != jsonpos.Array {
+		return nil
+	}
+	return v.Elems
+}
+
+// describe returns the doc comment and the annotations of a definition or
+// a field. A final "Deprecated:" paragraph of the doc, as the avro
+// generator writes it, becomes @deprecated.
+func describe(node *jsonpos.Value) (string, []string) {
+	doc, _ := node.Lookup("doc").Str()
+	paras := strings.Split(strings.TrimSpace(doc), "\n\n")
+	last := paras[len(paras)-1]
+	var annotations []string
+	if last == "Deprecated." {
+		annotations = append(annotations, "@deprecated")
+	} else if msg, ok := strings.CutPrefix(last, "Deprecated: "); ok {
+		annotations = append(annotations, "@deprecated("+strconv.Quote(msg)+")")
+	} else {
+		return doc, nil
+	}
+	return strings.Join(paras[:len(paras)-1], "\n\n"), annotations
+}
+
+// A typeRef is a converted type.
+type typeRef struct {
+	typ       string
+	optional  bool   // whether the type is a union with null
+	nullFirst bool   // whether null is the first branch of the union
+	scalar    bool   // whether defaults are Lark literals of the same value
+	enum      *decl  // enum of the type, for defaults
+	unit      string // millis, micros or nanos of timestamps, for defaults
+}
+
+// field converts a field of record d.
+func (cv *converter) field(d *decl, node *jsonpos.Value) {
+	s := d.src
+	name, ok := node.Lookup("name").Str()
+	if !ok {
+		s.errorf(node.Pos, diag.InvalidInput, "field of %s has no name", d.fullName)
+		return
+	}
+	what := fmt.Sprintf("field %s of %s", name, d.fullName)
+	f := &field{}
+	var json string
+	f.name, json = names.Field(name)
+	f.doc, f.annotations = describe(node)
+	if json != "" {
+		f.annotations = append(f.annotations, "@json("+strconv.Quote(json)+")")
+	}
+	t := node.Lookup("type")
+	if t == nil {
+		s.errorf(node.Pos, diag.InvalidInput, "%s has no type", what)
+		return
+	}
+	ref, ok := cv.typ(d, t, d.namespace, d.name+names.Type(name), what)
+	if !ok {
+		return
+	}
+	f.typ, f.optional = ref.typ, ref.optional
+	if m := node.Get("default"); m != nil {
+		if a := cv.defaultOf(s, ref, m, what); a != "" {
+			f.annotations = append(f.annotations, a)
+		}
+	}
+	if m := node.Get("aliases"); m != nil {
+		s.warnf(m.Pos, "aliases of %s have no Lark equivalent and are dropped", what)
+	}
+	if m := node.Get("order"); m != nil {
+		if order, _ := m.Value.Str(); order != "ascending" {
+			s.warnf(m.Pos, "order of %s has no Lark equivalent and is dropped", what)
+		}
+	}
+	d.fields = append(d.fields, f)
+}
+
+// typ converts a schema in namespace ns used by record d. Unions are
+// declared under the name hint.
+func (cv *converter) typ(d *decl, node *jsonpos.Value, ns, hint, what string) (typeRef, bool) {
+	s := d.src
+	switch node.Kind {
+	case jsonpos.String:
+		if p, ok := primitives[node.Text]; ok {
+			if p == "" {
+				s.warnf(node.Pos, "type null of %s has no Lark equivalent and is dropped", what)
+				return typeRef{}, false
+			}
+			return typeRef{typ: p, scalar: true}, true
+		}
+		target := cv.resolve(node.Text, ns)
+		if target == nil {
+			s.errorf(node.Pos, diag.UndefinedName, "type %s of %s is not defined", node.Text, what)
+			return typeRef{}, false
+		}
+		return d.module.ref(target), true
+	case jsonpos.Array:
+		return cv.union(d, node, ns, hint, what)
+	case jsonpos.Object:
+	default:
+		s.errorf(node.Pos, diag.InvalidInput, "schema of %s is a %s, not a name, a union or an object", what, node.Kind)
+		return typeRef{}, false
+	}
+	t := node.Lookup("type")
+	if t == nil {
+		s.errorf(node.Pos, diag.InvalidInput, "schema of %s has no type", what)
+		return typeRef{}, false
+	}
+	if t.Kind != jsonpos.String {
+		return cv.typ(d, t, ns, hint, what)
+	}
+	switch kind := t.Text; {
+	case isNamed(kind):
+		target := cv.byNode[node]
+		if target == nil {
+			return typeRef{}, false // reported by declare
+		}
+		return d.module.ref(target), true
+	case kind == "array" || kind == "map":
+		key, suffix, list := "items", "Item", "list[%s]"
+		if kind == "map" {
+			key, suffix, list = "values", "Value", "map[string, %s]"
+		}
+		elem := node.Lookup(key)
+		if elem == nil {
+			s.errorf(node.Pos, diag.InvalidInput, "%s of %s has no %s", kind, what, key)
+			return typeRef{}, false
+		}
+		ref, ok := cv.typ(d, elem, ns, hint+suffix, key+" of "+what)
+		if !ok {
+			return typeRef{}, false
+		}
+		if ref.optional {
+			s.warnf(elem.Pos, "null %s of %s have no Lark equivalent and are dropped", key, what)
+		}
+		return typeRef{typ: fmt.Sprintf(list, ref.typ)}, true
+	}
+	ref, ok := cv.typ(d, t, ns, hint, what)
+	if !ok {
+		return typeRef{}, false
+	}
+	if m := node.Get("logicalType"); m != nil {
+		lt, _ := m.Value.Str()
+		return cv.logical(s, node, m, t.Text, lt, ref, what), true
+	}
+	return ref, true
+}
+
+// ref returns the type that refers to a named type from m.
+func (m *module) ref(target *decl) typeRef {
+	ref := typeRef{typ: m.qualify(target)}
+	if kindOf(target.node) == "enum" {
+		ref.enum = target
+	}
+	return ref
+}
+
+// logical converts the logical type lt of a primitive schema of type base,
+// which converts to ref.
+func (cv *converter) logical(s *srcFile, node *jsonpos.Value, m *jsonpos.Member, base, lt string, ref typeRef, what string) typeRef {
+	switch {
+	case base == "long" && (lt == "timestamp-millis" || lt == "timestamp-micros" || lt == "timestamp-nanos"):
+		return typeRef{typ: "timestamp", unit: strings.TrimPrefix(lt, "timestamp-")}
+	case base == "string" && lt == "uuid":
+		return typeRef{typ: "uuid", scalar: true}
+	case base == "bytes" && lt == "decimal":
+		precision, scale := node.Lookup("precision"), node.Lookup("scale")
+		if precision != nil && precision.Text == "20" && (scale == nil || scale.Text == "0") {
+			return typeRef{typ: "uint64"}
+		}
+	}
+	s.warnf(m.Pos, "logical type %s of %s has no Lark equivalent; the %s type is kept", lt, what, base)
+	return ref
+}
+
+// union converts a union in namespace ns used by record d.
+func (cv *converter) union(d *decl, node *jsonpos.Value, ns, hint, what string) (typeRef, bool) {
+	s := d.src
+	var branches []*jsonpos.Value
+	null := -1
+	for i, elem := range node.Elems {
+		if elem.Kind == jsonpos.String && elem.Text == "null" {
+			null = i
+			continue
+		}
+		branches = append(branches, elem)
+	}
+	var ref typeRef
+	switch len(branches) {
+	case 0:
+		s.warnf(node.Pos, "union of %s holds only null, which has no Lark type, and is dropped", what)
+		return typeRef{}, false
+	case 1:
+		var ok bool
+		if ref, ok = cv.typ(d, branches[0], ns, hint, what); !ok {
+			return typeRef{}, false
+		}
+	default:
+		var records []*decl
+		for _, b := range branches {
+			var r *decl
+			switch b.Kind {
+			case jsonpos.String:
+				r = cv.resolve(b.Text, ns)
+			case jsonpos.Object:
+				r = cv.byNode[b]
+			}
+			if r == nil || kindOf(r.node) != "record" && kindOf(r.node) != "error" {
+				s.warnf(node.Pos, "union of %s has branches other than records, which have no Lark equivalent, and is dropped", what)
+				return typeRef{}, false
+			}
+			records = append(records, r)
+		}
+		u := &decl{src: s, module: d.module, kind: unionDecl, name: d.module.newName(hint)}
+		if tag := tagFor(records); tag != schema.DefaultTag {
+			u.annotations = append(u.annotations, "@tag("+strconv.Quote(tag)+")")
+		}
+		used := make(map[string]bool) // variant names
+		for _, r := range records {
+			name := names.Snake(r.name)
+			unique := name
+			for i := 2; used[unique]; i++ {
+				unique = name + strconv.Itoa(i)
+			}
+			used[unique] = true
+			u.variants = append(u.variants, &variant{name: unique, typ: d.module.qualify(r)})
+		}
+		d.nested = append(d.nested, u)
+		ref = typeRef{typ: u.name}
+	}
+	if null >= 0 {
+		if ref.optional {
+			s.warnf(node.Pos, "union of %s nests unions, which have no Lark equivalent", what)
+		}
+		ref.optional, ref.nullFirst = true, null == 0
+	}
+	return ref, true
+}
+
+// tagFor returns the tag of a union of records: kind, or the first of
+// type, tag and variant that no record has as a field.
+func tagFor(records []*decl) string {
+	for _, tag := range []string{schema.DefaultTag, "type", "tag", "variant"} {
+		if !slices.ContainsFunc(records, func(r *decl) bool {
+			return slices.ContainsFunc(elems(r.node.Lookup("fields")), func(f *jsonpos.Value) bool {
+				name, _ := f.Lookup("name").Str()
+				return name == tag
+			})
+		}) {
+			return tag
+		}
+	}
+	return schema.DefaultTag
+}
+
+// defaultOf returns the @default annotation for the default m of a field
+// of type ref, or "" if there is none.
+func (cv *converter) defaultOf(s *srcFile, ref typeRef, m *jsonpos.Member, what string) string {
+	v := m.Value
+	if ref.optional && v.Kind == jsonpos.Null {
+		return ""
+	}
+	switch {
+	case ref.optional && ref.nullFirst:
+		s.errorf(m.Pos, diag.InvalidInput, "default of %s is not null, the first branch of its union", what)
+		return ""
+	case ref.enum != nil && v.Kind == jsonpos.String:
+		for _, sym := range elems(ref.enum.node.Lookup("symbols")) {
+			if sym.Kind == jsonpos.String && sym.Text == v.Text {
+				return "@default(" + strconv.Quote(names.Ident(v.Text)) + ")"
+			}
+		}
+		s.errorf(m.Pos, diag.InvalidInput, "default %q of %s is not a symbol of %s", v.Text, what, ref.enum.fullName)
+		return ""
+	case ref.unit != "" && v.Kind == jsonpos.Number:
+		if n, err := strconv.ParseInt(v.Text, 10, 64); err == nil {
+			var t time.Time
+			switch ref.unit {
+			case "millis":
+				t = time.UnixMilli(n)
+			case "micros":
+				t = time.UnixMicro(n)
+			default:
+				t = time.Unix(0, n)
+			}
+			return "@default(" + strconv.Quote(t.UTC().Format(time.RFC3339Nano)) + ")"
+		}
+	case ref.scalar:
+		if lit, ok := literal(v); ok {
+			return "@default(" + lit + ")"
+		}
+	}
+	s.warnf(m.Pos, "default of %s is a %s, which Lark annotations cannot express, and is dropped", what, v.Kind)
+	return ""
+}
+
+// literal returns the Lark literal for a scalar JSON value.
+func literal(v *jsonpos.Value) (string, bool) {
+	switch v.Kind {
+	case jsonpos.String:
+		return strconv.Quote(v.Text), true
+	case jsonpos.Bool:
+		return strconv.FormatBool(v.Bool), true
+	case jsonpos.Number:
+		if n, ok := new(big.Int).SetString(v.Text, 10); ok {
+			return constant.MakeInt(n).String(), true
+		}
+		f, _ := strconv.ParseFloat(v.Text, 64)
+		if math.IsInf(f, 0) {
+			return "", false
+		}
+		return constant.MakeFloat64(f).String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package avro
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"larklang.io/lark/pkg/format"
+)
+
+// emit returns the formatted Lark source of the module.
+func (m *module) emit() ([]byte, error) {
+	var b strings.Builder
+	m.importDecls(&b)
+	var write func(d *decl)
+	write = func(d *decl) {
+		m.decl(&b, d)
+		for _, n := range d.nested {
+			write(n)
+		}
+	}
+	for _, d := range m.decls {
+		write(d)
+	}
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("converting %s: %v", m.path, err)
+	}
+	return src, nil
+}
+
+// comment writes text as a comment.
+func comment(b *strings.Builder, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			b.WriteString("//\n")
+		} else {
+			fmt.Fprintf(b, "// %s\n", line)
+		}
+	}
+}
+
+// annotate writes the doc comment and the annotations of a declaration or
+// an entry.
+func annotate(b *strings.Builder, doc string, annotations []string) {
+	if doc != "" {
+		comment(b, doc)
+	}
+	for _, a := range annotations {
+		b.WriteString(a + "\n")
+	}
+}
+
+// importDecls writes the imports of the referenced modules in the order of
+// their paths.
+func (m *module) importDecls(b *strings.Builder) {
+	var used []*module
+	for other := range m.imports {
+		used = append(used, other)
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].path < used[j].path })
+	for _, other := range used {
+		p := other.path
+		// Modules below the directory of the module are imported relative
+		// to it.
+		if dir := path.Dir(m.path); dir != "." {
+			if rel, ok := strings.CutPrefix(p, dir+"/"); ok {
+				p = rel
+			}
+		}
+		if name := m.imports[other]; name == path.Base(p) {
+			fmt.Fprintf(b, "import %q\n", p)
+		} else {
+			fmt.Fprintf(b, "import %q as %s\n", p, name)
+		}
+	}
+	if len(used) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+// decl writes a declaration.
+func (m *module) decl(b *strings.Builder, d *decl) {
+	if d.kind == dropped {
+		return
+	}
+	annotate(b, d.doc, d.annotations)
+	switch d.kind {
+	case aliasDecl:
+		fmt.Fprintf(b, "type %s = %s\n\n", d.name, d.typ)
+	case structDecl:
+		fmt.Fprintf(b, "struct %s {\n", d.name)
+		for _, f := range d.fields {
+			annotate(b, f.doc, f.annotations)
+			mark := ""
+			if f.optional {
+				mark = "?"
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", f.name, mark, f.typ)
+		}
+		b.WriteString("}\n\n")
+	case enumDecl:
+		fmt.Fprintf(b, "enum %s {\n", d.name)
+		for _, name := range d.members {
+			fmt.Fprintf(b, "%s\n", name)
+		}
+		b.WriteString("}\n\n")
+	case unionDecl:
+		fmt.Fprintf(b, "union %s {\n", d.name)
+		for _, v := range d.variants {
+			fmt.Fprintf(b, "%s: %s\n", v.name, v.typ)
+		}
+		b.WriteString("}\n\n")
+	}
+}
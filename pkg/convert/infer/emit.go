@@ -0,0 +1,139 @@
+package infer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/internal/names"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/format"
+)
+
+// A decl is a struct to write.
+type decl struct {
+	name  string
+	shape *shape
+}
+
+func warnf(s *shape, format string, args ...any) {
+	s.file.Diagnostics = append(s.file.Diagnostics, diag.Diagnostic{
+		Severity: diag.Warning,
+		Code:     diag.Untranslatable,
+		Range:    diag.At(s.pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// emit returns the formatted Lark source of the structs, with the root
+// struct named name.
+func (in *inferrer) emit(name string) ([]byte, error) {
+	in.names = make(map[string]bool)
+	var b strings.Builder
+	in.object(&b, &decl{in.newName(name), in.root})
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("inferring %s: %v", name, err)
+	}
+	for _, file := range in.files {
+		sort.SliceStable(file.Diagnostics, func(i, j int) bool {
+			return file.Diagnostics[j].Pos().Greater(file.Diagnostics[i].Pos())
+		})
+	}
+	return src, nil
+}
+
+// newName returns an unused struct name based on name.
+func (in *inferrer) newName(name string) string {
+	name = names.Type(name)
+	unique := name
+	for i := 2; in.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	in.names[unique] = true
+	return unique
+}
+
+// object writes the struct for an object and then the structs of the
+// objects nested in it.
+func (in *inferrer) object(b *strings.Builder, d *decl) {
+	var nested []*decl
+	fmt.Fprintf(b, "struct %s {\n", d.name)
+	used := make(map[string]bool) // field names
+	for _, key := range d.shape.keys {
+		f := d.shape.fields[key]
+		name, json := names.Field(key)
+		what := fmt.Sprintf("field %s of %s", key, d.name)
+		if used[name] {
+			warnf(f, "%s clashes with another key as field %s and is dropped", what, name)
+			continue
+		}
+		typ, ok := in.typ(f, d.name+names.Type(key), what, &nested)
+		if !ok {
+			continue
+		}
+		used[name] = true
+		if json != "" {
+			fmt.Fprintf(b, "@json(%q)\n", json)
+		}
+		mark := ""
+		if f.null || d.shape.counts[key] < d.shape.count {
+			mark = "?"
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", name, mark, typ)
+	}
+	b.WriteString("}\n\n")
+	for _, n := range nested {
+		in.object(b, n)
+	}
+}
+
+// typ returns the Lark type of a shape, adding the structs it declares to
+// nested, or false after reporting why there is none.
+func (in *inferrer) typ(s *shape, hint, what string, nested *[]*decl) (string, bool) {
+	switch s.kinds {
+	case 0:
+		warnf(s, "%s is null in every sample, so its type cannot be inferred; it is dropped", what)
+		return "", false
+	case boolKind:
+		return "bool", true
+	case intKind:
+		switch {
+		case s.huge && s.negative:
+			return "float64", true
+		case s.huge:
+			return "uint64", true
+		}
+		return "int64", true
+	case intKind | floatKind, floatKind:
+		return "float64", true
+	case stringKind:
+		switch {
+		case !s.notTimestamp:
+			return "timestamp", true
+		case !s.notUUID:
+			return "uuid", true
+		}
+		return "string", true
+	case arrayKind:
+		if s.elem.count == 0 {
+			warnf(s, "%s is an empty array in every sample, so its element type cannot be inferred; it is dropped", what)
+			return "", false
+		}
+		if s.nullElems != nil {
+			warnf(s.nullElems, "null elements of %s have no Lark equivalent and are ignored", what)
+		}
+		elem, ok := in.typ(s.elem, singular(hint), "elements of "+what, nested)
+		if !ok {
+			return "", false
+		}
+		return "list[" + elem + "]", true
+	case objectKind:
+		d := &decl{in.newName(hint), s}
+		*nested = append(*nested, d)
+		return d.name, true
+	}
+	warnf(s, "%s has values of several kinds (%s), which have no common Lark type; it is dropped", what, s.kinds)
+	return "", false
+}
@@ -0,0 +1,283 @@
+// Package infer infers Lark declarations from sample JSON documents.
+//
+// Every sample is a value of the root struct. A source holds one JSON
+// value, or several separated by white space as in NDJSON; the elements
+// of a top-level array are samples too. The shapes of all samples are
+// merged:
+//
+//   - Objects become structs. Fields that are missing or null in some
+//     samples are optional. Keys that are not Lark identifiers become
+//     snake_case fields that keep their key in @json("key").
+//   - Numbers become int64 if all of them are integers, uint64 if some
+//     exceed int64, and float64 otherwise, so that integers widen to
+//     floats.
+//   - Strings become timestamp if all of them are RFC 3339 timestamps,
+//     uuid if all of them are UUIDs, and string otherwise.
+//   - Arrays become lists of the merged shape of their elements.
+//
+// Nested objects become structs named after the enclosing struct and the
+// key: the object under address in User becomes UserAddress, and the
+// elements of lines in Order become OrderLine. A number is appended to
+// names that are taken.
+//
+// Values whose type cannot be inferred, because the samples disagree on
+// it or hold only nulls and empty arrays, are dropped and reported as
+// warnings.
+package infer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"larklang.io/lark/internal/jsonpos"
+	"larklang.io/lark/pkg/diag"
+)
+
+// A Config controls how samples are read and declarations named.
+type Config struct {
+	// Name is the name of the root struct; empty means Sample.
+	Name string
+
+	// ReadFile reads a file; nil means os.ReadFile.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// A Source names a file of samples. If Src is nil, the file is read from
+// Path.
+type Source struct {
+	Path string
+	Src  []byte
+}
+
+// A File holds the diagnostics of a source.
+type File struct {
+	Path        string
+	Lines       []string
+	Diagnostics []diag.Diagnostic
+}
+
+// Infer returns the formatted Lark source of the declarations inferred
+// from the samples of the sources, and their diagnostics. The source is
+// nil if no source holds a sample. Infer fails only if a source cannot be
+// read.
+func (c *Config) Infer(sources ...Source) ([]byte, []*File, error) {
+	in := &inferrer{root: &shape{}}
+	for _, source := range sources {
+		src := source.Src
+		if src == nil {
+			var err error
+			if src, err = c.read(source.Path); err != nil {
+				return nil, nil, err
+			}
+		}
+		file := &File{
+			Path:  source.Path,
+			Lines: strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n"),
+		}
+		in.files = append(in.files, file)
+		in.samples(file, src)
+	}
+	if in.root.count == 0 {
+		return nil, in.files, nil
+	}
+	name := c.Name
+	if name == "" {
+		name = "Sample"
+	}
+	content, err := in.emit(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, in.files, nil
+}
+
+func (c *Config) read(name string) ([]byte, error) {
+	if c.ReadFile != nil {
+		return c.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// kinds are the kinds of JSON values, other than null.
+type kinds uint8
+
+const (
+	boolKind kinds = 1 << iota
+	intKind
+	floatKind
+	stringKind
+	arrayKind
+	objectKind
+)
+
+var kindNames = []string{"boolean", "integer", "number", "string", "array", "object"}
+
+func (k kinds) String() string {
+	var names []string
+	for i, name := range kindNames {
+		if k&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// A shape is the merged shape of the values at a position of the samples.
+type shape struct {
+	file  *File    // file of the first value
+	pos   diag.Pos // position of the first value
+	count int      // number of values that are not null
+	null  bool
+	kinds kinds
+
+	// Numbers.
+	negative bool // some integer is negative
+	huge     bool // some integer exceeds int64
+
+	// Strings.
+	notTimestamp, notUUID bool
+
+	// Arrays.
+	elem      *shape
+	nullElems *shape // first null element, for reporting
+
+	// Objects.
+	fields map[string]*shape
+	keys   []string // in the order of their first appearance
+	counts map[string]int
+}
+
+type inferrer struct {
+	files []*File
+	root  *shape
+	names map[string]bool
+}
+
+func errorf(file *File, pos diag.Pos, format string, args ...any) {
+	file.Diagnostics = append(file.Diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.InvalidInput,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// samples merges the samples of a source into the root shape. A syntax
+// error ends the source; the samples before it are kept.
+func (in *inferrer) samples(file *File, src []byte) {
+	values, err := jsonpos.ParseStream(src)
+	for _, v := range values {
+		if v.Kind != jsonpos.Array {
+			in.sample(file, v)
+			continue
+		}
+		for _, elem := range v.Elems {
+			in.sample(file, elem)
+		}
+	}
+	if err != nil {
+		errorf(file, err.Pos, "%s", err.Msg)
+	}
+}
+
+// sample merges a sample into the root shape.
+func (in *inferrer) sample(file *File, v *jsonpos.Value) {
+	if v.Kind != jsonpos.Object {
+		errorf(file, v.Pos, "sample is a %s, not an object", v.Kind)
+		return
+	}
+	in.root.merge(file, v)
+}
+
+// merge merges a value of a file into s.
+func (s *shape) merge(file *File, v *jsonpos.Value) {
+	if s.file == nil {
+		s.file, s.pos = file, v.Pos
+	}
+	if v.Kind != jsonpos.Null {
+		s.count++
+	}
+	switch v.Kind {
+	case jsonpos.Null:
+		s.null = true
+	case jsonpos.Bool:
+		s.kinds |= boolKind
+	case jsonpos.Number:
+		if i, err := strconv.ParseInt(v.Text, 10, 64); err == nil {
+			s.kinds |= intKind
+			s.negative = s.negative || i < 0
+		} else if _, err := strconv.ParseUint(v.Text, 10, 64); err == nil {
+			s.kinds |= intKind
+			s.huge = true
+		} else {
+			s.kinds |= floatKind
+		}
+	case jsonpos.String:
+		s.kinds |= stringKind
+		if _, err := time.Parse(time.RFC3339Nano, v.Text); err != nil {
+			s.notTimestamp = true
+		}
+		if !isUUID(v.Text) {
+			s.notUUID = true
+		}
+	case jsonpos.Array:
+		s.kinds |= arrayKind
+		if s.elem == nil {
+			s.elem = &shape{}
+		}
+		for _, elem := range v.Elems {
+			if elem.Kind == jsonpos.Null {
+				if s.nullElems == nil {
+					s.nullElems = &shape{file: file, pos: elem.Pos}
+				}
+				continue
+			}
+			s.elem.merge(file, elem)
+		}
+	case jsonpos.Object:
+		s.kinds |= objectKind
+		if s.fields == nil {
+			s.fields = make(map[string]*shape)
+			s.counts = make(map[string]int)
+		}
+		seen := make(map[string]bool)
+		for _, m := range v.Members {
+			if seen[m.Key] {
+				errorf(file, m.Pos, "duplicate key %q", m.Key)
+				continue
+			}
+			seen[m.Key] = true
+			f := s.fields[m.Key]
+			if f == nil {
+				f = &shape{}
+				s.fields[m.Key] = f
+				s.keys = append(s.keys, m.Key)
+			}
+			s.counts[m.Key]++
+			f.merge(file, m.Value)
+		}
+	}
+}
+
+// isUUID reports whether s is a UUID in the canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", rune(s[i])) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package infer
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// messages returns the diagnostics of a file as strings.
+func messages(file *File) []string {
+	var list []string
+	for _, d := range file.Diagnostics {
+		list = append(list, d.Error())
+	}
+	return list
+}
+
+func TestGolden(t *testing.T) {
+	c := &Config{Name: "Order"}
+	got, files, err := c.Infer(Source{Path: "testdata/orders.ndjson"}, Source{Path: "testdata/single.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const golden = "testdata/orders.golden"
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s", golden, diff.Unified(golden, "output", want, got))
+	}
+
+	tests := [][]string{
+		{
+			"2:251: warning[W0100]: null elements of field lines of Order have no Lark equivalent and are ignored",
+			"3:211: warning[W0100]: field retries of Order is an empty array in every sample, so its element type cannot be inferred; it is dropped",
+			"3:224: warning[W0100]: field extra of Order has values of several kinds (integer, string), which have no common Lark type; it is dropped",
+		},
+		nil,
+	}
+	for i, file := range files {
+		if got := messages(file); strings.Join(got, "\n") != strings.Join(tests[i], "\n") {
+			t.Errorf("%s: got diagnostics\n%s\nwant\n%s", file.Path, strings.Join(got, "\n"), strings.Join(tests[i], "\n"))
+		}
+	}
+
+	// The output is valid Lark.
+	prog, err := new(loader.Config).Load(loader.Source{Path: "order.lark", Src: got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.Check(prog)
+	for _, file := range prog.Diagnostics() {
+		for _, d := range file.Diagnostics {
+			t.Errorf("%s: %v", file.Name, d)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	tests := []struct {
+		samples, want string
+	}{
+		{`{"x": 1} {"x": -2}`, "x: int64"},
+		{`{"x": 1} {"x": 2.5}`, "x: float64"},
+		{`{"x": 18446744073709551615} {"x": 1}`, "x: uint64"},
+		{`{"x": 18446744073709551615} {"x": -1}`, "x: float64"},
+		{`{"x": 1e400}`, "x: float64"},
+		{`{"x": "2024-01-02T03:04:05Z"} {"x": "2024-01-02T03:04:05.123+02:00"}`, "x: timestamp"},
+		{`{"x": "2024-01-02T03:04:05Z"} {"x": "2024-01-02"}`, "x: string"},
+		{`{"x": "123e4567-e89b-12d3-a456-426614174000"}`, "x: uuid"},
+		{`{"x": true} {}`, "x?: bool"},
+		{`{"x": null} {"x": "a"}`, "x?: string"},
+		{`[{"x": [[1], []]}, {"x": []}]`, "x: list[list[int64]]"},
+		{`{"type": 1, "user-id": 2}`, "@json(\"type\")\n    type_: int64\n    @json(\"user-id\")\n    user_id: int64"},
+	}
+	for _, test := range tests {
+		got, files, err := new(Config).Infer(Source{Path: "sample.json", Src: []byte(test.samples)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := files[0].Diagnostics; len(d) > 0 {
+			t.Errorf("%s: unexpected diagnostics %v", test.samples, messages(files[0]))
+		}
+		want := "struct Sample {\n    " + test.want + "\n}\n"
+		if string(got) != want {
+			t.Errorf("%s: got\n%swant\n%s", test.samples, got, want)
+		}
+	}
+}
+
+func TestNested(t *testing.T) {
+	samples := `{"user": {"addresses": [{"zip": "1"}], "entries": [{"n": 1}], "data": [{"n": 2}]}, "sample": {}}`
+	got, _, err := new(Config).Infer(Source{Path: "sample.json", Src: []byte(samples)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(got), "\n") {
+		if name, ok := strings.CutPrefix(line, "struct "); ok {
+			names = append(names, strings.Fields(name)[0])
+		}
+	}
+	if got, want := strings.Join(names, " "), "Sample SampleUser SampleUserAddress SampleUserEntry SampleUserDataItem SampleSample"; got != want {
+		t.Errorf("got structs %s; want %s", got, want)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`{"a": 1,}`, `1:9: error[E0400]: expected a string, found '}'`},
+		{"{\"a\": 1}\n{\"a\": tru}", `2:7: error[E0400]: unexpected 't'`},
+		{`{"a": 1`, `1:8: error[E0400]: expected '}', found end of file`},
+		{`[1]`, `1:2: error[E0400]: sample is a number, not an object`},
+		{`{"a": 1, "a": 2}`, `1:10: error[E0400]: duplicate key "a"`},
+	}
+	for _, test := range tests {
+		_, files, err := new(Config).Infer(Source{Path: "errors.json", Src: []byte(test.src)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := messages(files[0]); len(got) != 1 || got[0] != test.err {
+			t.Errorf("%q: got %v; want %s", test.src, got, test.err)
+		}
+		if !diag.HasErrors(files[0].Diagnostics) {
+			t.Errorf("%q: no errors", test.src)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package infer
+
+import "strings"
+
+// singular returns the singular of a name in PascalCase for the elements
+// of an array: "OrderLines" becomes "OrderLine" and "UserEntries"
+// "UserEntry". Names that do not look plural get Item appended.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") &&
+		!strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
@@ -0,0 +1,1282 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/internal/jsonpos"
+	"larklang.io/lark/internal/names"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/schema"
+)
+
+type declKind int
+
+const (
+	dropped declKind = iota
+	structDecl
+	enumDecl
+	unionDecl
+	aliasDecl
+)
+
+// A decl is a Lark declaration under construction.
+type decl struct {
+	file        *fileConv
+	name        string
+	node        *jsonpos.Value // schema the declaration is converted from
+	doc         string
+	annotations []string
+	kind        declKind
+	defined     bool
+	active      bool   // being defined
+	typ         string // aliased type
+	fields      []*field
+	members     []*enumMember
+	tag         string
+	variants    []*variant
+	nested      []*decl // anonymous declarations, written after this one
+}
+
+type field struct {
+	name, json  string
+	node        *jsonpos.Value // schema of the property
+	typ         string
+	required    bool
+	nullable    bool
+	optional    bool
+	doc         string
+	annotations []string
+}
+
+type enumMember struct {
+	name  string
+	value int64
+	json  string // string value if it differs from the name, or ""
+}
+
+type variant struct {
+	name, doc string
+	typ       string
+	decl      *decl
+}
+
+// fileConv converts one document.
+type fileConv struct {
+	*converter
+	path    string // slash-separated path of the document
+	module  string // Lark module path
+	root    *jsonpos.Value
+	decls   []*decl // top-level declarations in order
+	names   map[string]bool
+	byNode  map[*jsonpos.Value]*decl
+	imports map[*fileConv]string // Lark names of the imported files
+	used    map[*jsonpos.Value]map[string]bool
+	active  map[*jsonpos.Value]bool // references being resolved
+	diags   []diag.Diagnostic
+}
+
+func newFileConv(cv *converter, name string, root *jsonpos.Value) *fileConv {
+	return &fileConv{
+		converter: cv,
+		path:      name,
+		module:    modulePath(name),
+		root:      root,
+		names:     make(map[string]bool),
+		byNode:    make(map[*jsonpos.Value]*decl),
+		imports:   make(map[*fileConv]string),
+		used:      make(map[*jsonpos.Value]map[string]bool),
+		active:    make(map[*jsonpos.Value]bool),
+	}
+}
+
+func (c *fileConv) warnf(pos diag.Pos, format string, args ...any) {
+	c.diags = append(c.diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Code:     diag.Untranslatable,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *fileConv) errorf(pos diag.Pos, code diag.Code, format string, args ...any) {
+	c.diags = append(c.diags, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     code,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *fileConv) sortedDiags() []diag.Diagnostic {
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[j].Pos().Greater(c.diags[i].Pos())
+	})
+	return c.diags
+}
+
+// take returns the member of a schema with the given key and marks it as
+// used.
+func (c *fileConv) take(node *jsonpos.Value, key string) *jsonpos.Member {
+	m := node.Get(key)
+	if m != nil {
+		if c.used[node] == nil {
+			c.used[node] = make(map[string]bool)
+		}
+		c.used[node][key] = true
+	}
+	return m
+}
+
+// takeValue is like take but returns the value of the member.
+func (c *fileConv) takeValue(node *jsonpos.Value, key string) *jsonpos.Value {
+	if m := c.take(node, key); m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+// ignored are the keywords that do not affect the validation of values
+// and have no Lark counterpart.
+var ignored = map[string]bool{
+	"$schema":  true,
+	"$id":      true,
+	"$comment": true,
+	"$anchor":  true,
+	"title":    true,
+	"examples": true,
+}
+
+// unused reports the keywords of a schema that were not translated.
+func (c *fileConv) unused(node *jsonpos.Value, what string) {
+	if node == nil || node.Kind != jsonpos.Object {
+		return
+	}
+	for _, m := range node.Members {
+		if c.used[node][m.Key] || ignored[m.Key] {
+			continue
+		}
+		c.take(node, m.Key)
+		c.warnf(m.Pos, "keyword %s of %s has no Lark equivalent and is dropped", m.Key, what)
+	}
+}
+
+// newName returns an unused declaration name based on name.
+func (c *fileConv) newName(name string) string {
+	name = names.Type(name)
+	unique := name
+	for i := 2; c.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	c.names[unique] = true
+	return unique
+}
+
+// isType reports whether the root of a document is a schema of its own and
+// not only a container of definitions.
+func isType(node *jsonpos.Value) bool {
+	if node.Kind != jsonpos.Object {
+		return false
+	}
+	for _, key := range []string{"type", "properties", "$ref", "oneOf", "anyOf", "allOf", "enum", "const", "items", "additionalProperties"} {
+		if node.Get(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// declare declares the root schema and the definitions of the document.
+func (c *fileConv) declare() {
+	if c.root.Kind == jsonpos.Bool {
+		c.warnf(c.root.Pos, "schema %s of %s has no Lark equivalent and is dropped", strconv.FormatBool(c.root.Bool), path.Base(c.module))
+		return
+	}
+	if c.root.Kind != jsonpos.Object {
+		c.errorf(c.root.Pos, diag.InvalidInput, "schema of %s is a %s, not an object", path.Base(c.module), c.root.Kind)
+		return
+	}
+	if isType(c.root) {
+		name := path.Base(c.module)
+		if title, ok := c.root.Lookup("title").Str(); ok && title != "" {
+			name = title
+		}
+		d := &decl{file: c, name: c.newName(name), node: c.root}
+		c.byNode[c.root] = d
+		c.decls = append(c.decls, d)
+	}
+	for _, key := range []string{"$defs", "definitions"} {
+		defs := c.takeValue(c.root, key)
+		if defs == nil {
+			continue
+		}
+		if defs.Kind != jsonpos.Object {
+			c.warnf(defs.Pos, "%s is not an object and is dropped", key)
+			continue
+		}
+		for _, m := range defs.Members {
+			d := &decl{file: c, name: c.newName(m.Key), node: m.Value}
+			c.byNode[m.Value] = d
+			c.decls = append(c.decls, d)
+		}
+	}
+}
+
+// defineAll defines the declarations of the document.
+func (c *fileConv) defineAll() {
+	for _, d := range c.decls {
+		c.define(d)
+	}
+}
+
+// define converts the schema of a declaration, unless it is converted
+// already.
+func (c *fileConv) define(d *decl) {
+	if d.defined {
+		return
+	}
+	d.defined = true
+	d.active = true
+	defer func() { d.active = false }()
+	node := d.node
+	what := d.name
+	if node.Kind == jsonpos.Bool {
+		c.warnf(node.Pos, "schema %s of %s has no Lark equivalent and is dropped", strconv.FormatBool(node.Bool), what)
+		return
+	}
+	if node.Kind != jsonpos.Object {
+		c.errorf(node.Pos, diag.InvalidInput, "schema of %s is a %s, not an object", what, node.Kind)
+		return
+	}
+	c.describe(node, &d.doc, &d.annotations, what)
+	switch c.shape(node) {
+	case structDecl:
+		d.kind = structDecl
+		required := make(map[string]bool)
+		c.structType(d, node, required)
+		for _, f := range d.fields {
+			f.required = f.required || required[f.json]
+			f.optional = f.nullable || !f.required
+		}
+	case enumDecl:
+		d.kind = enumDecl
+		c.enumType(d, node)
+	case unionDecl:
+		d.kind = unionDecl
+		c.unionType(d, node)
+	default:
+		f := &field{}
+		typ, nullable, ok := c.inline(node, d.name, d, f, what)
+		if nullable {
+			c.warnf(node.Pos, "null of %s has no Lark equivalent in a declaration and is dropped", what)
+		}
+		if ok {
+			d.kind = aliasDecl
+			d.typ = typ
+			d.annotations = append(d.annotations, f.annotations...)
+		}
+		return
+	}
+	c.unused(node, what)
+}
+
+// describe takes the doc comment and the annotations for the description,
+// deprecated and default keywords of a schema.
+func (c *fileConv) describe(node *jsonpos.Value, doc *string, annotations *[]string, what string) {
+	if s, ok := c.takeValue(node, "description").Str(); ok {
+		*doc = s
+	} else if s, ok := node.Lookup("title").Str(); ok && node != c.root {
+		*doc = s
+	}
+	if v := c.takeValue(node, "deprecated"); v != nil && v.Kind == jsonpos.Bool && v.Bool {
+		*annotations = append(*annotations, "@deprecated")
+	}
+	if m := c.take(node, "default"); m != nil {
+		if lit, ok := literal(m.Value); ok {
+			*annotations = append(*annotations, "@default("+lit+")")
+		} else {
+			c.warnf(m.Pos, "default of %s is a %s, which Lark annotations cannot express, and is dropped", what, m.Value.Kind)
+		}
+	}
+}
+
+// literal returns the Lark literal for a scalar JSON value.
+func literal(v *jsonpos.Value) (string, bool) {
+	switch v.Kind {
+	case jsonpos.String:
+		return strconv.Quote(v.Text), true
+	case jsonpos.Bool:
+		return strconv.FormatBool(v.Bool), true
+	case jsonpos.Number:
+		if n, ok := new(big.Int).SetString(v.Text, 10); ok {
+			return constant.MakeInt(n).String(), true
+		}
+		f, _ := strconv.ParseFloat(v.Text, 64)
+		if math.IsInf(f, 0) {
+			return "", false
+		}
+		return constant.MakeFloat64(f).String(), true
+	}
+	return "", false
+}
+
+// types returns the types named by the type keyword of a schema, without
+// null, and whether null is among them or the schema is nullable in the
+// OpenAPI sense.
+func (c *fileConv) types(node *jsonpos.Value) ([]string, bool) {
+	var types []string
+	nullable := false
+	if v := c.takeValue(node, "nullable"); v != nil && v.Kind == jsonpos.Bool {
+		nullable = v.Bool
+	}
+	t := c.takeValue(node, "type")
+	if t == nil {
+		return nil, nullable
+	}
+	var elems []*jsonpos.Value
+	if t.Kind == jsonpos.Array {
+		elems = t.Elems
+	} else {
+		elems = []*jsonpos.Value{t}
+	}
+	for _, elem := range elems {
+		switch s, _ := elem.Str(); s {
+		case "null":
+			nullable = true
+		case "string", "integer", "number", "boolean", "array", "object":
+			types = append(types, s)
+		default:
+			c.errorf(elem.Pos, diag.InvalidInput, "unknown type %s", elem.Text)
+		}
+	}
+	return types, nullable
+}
+
+// branches returns the schemas of oneOf or anyOf without null, and whether
+// one of them is null.
+func (c *fileConv) branches(node *jsonpos.Value) ([]*jsonpos.Value, bool) {
+	list := node.Lookup("oneOf")
+	if list == nil {
+		list = node.Lookup("anyOf")
+	}
+	if list == nil || list.Kind != jsonpos.Array {
+		return nil, false
+	}
+	var branches []*jsonpos.Value
+	nullable := false
+	for _, elem := range list.Elems {
+		if t, ok := elem.Lookup("type").Str(); ok && t == "null" && len(elem.Members) == 1 {
+			nullable = true
+			continue
+		}
+		branches = append(branches, elem)
+	}
+	return branches, nullable
+}
+
+// shape returns the kind of declaration a schema becomes: a struct, an
+// enum or a union, or an alias for schemas that are types of their own.
+func (c *fileConv) shape(node *jsonpos.Value) declKind {
+	if node.Kind != jsonpos.Object || node.Get("$ref") != nil {
+		return aliasDecl
+	}
+	if enum := node.Lookup("enum"); enum != nil {
+		if _, ok := enumKind(enum); ok {
+			return enumDecl
+		}
+		return aliasDecl
+	}
+	if branches, _ := c.branches(node); len(branches) > 1 {
+		for _, branch := range branches {
+			if !c.isObject(branch) {
+				return aliasDecl
+			}
+		}
+		return unionDecl
+	}
+	if all := node.Lookup("allOf"); all != nil && all.Kind == jsonpos.Array && len(all.Elems) > 1 {
+		for _, elem := range all.Elems {
+			if !c.isObject(elem) {
+				return aliasDecl
+			}
+		}
+		return structDecl
+	}
+	if node.Get("properties") != nil {
+		return structDecl
+	}
+	t := node.Lookup("type")
+	isObject := false
+	if s, ok := t.Str(); ok {
+		isObject = s == "object"
+	} else if t != nil && t.Kind == jsonpos.Array {
+		for _, elem := range t.Elems {
+			if s, _ := elem.Str(); s == "object" {
+				isObject = true
+			}
+		}
+	}
+	if isObject {
+		// Objects with additionalProperties are maps; other objects are
+		// structs, possibly empty ones.
+		if ap := node.Lookup("additionalProperties"); ap != nil && ap.Kind == jsonpos.Object {
+			return aliasDecl
+		}
+		return structDecl
+	}
+	return aliasDecl
+}
+
+// isObject reports whether a schema, or the target of its reference,
+// becomes a struct.
+func (c *fileConv) isObject(node *jsonpos.Value) bool {
+	if ref, ok := node.Lookup("$ref").Str(); ok {
+		target, file := c.target(ref)
+		if target == nil || file.active[target] {
+			return false
+		}
+		file.active[target] = true
+		defer delete(file.active, target)
+		return file.isObject(target)
+	}
+	return c.shape(node) == structDecl
+}
+
+// enumKind returns the kind of the values of an enum, ignoring null, if
+// they are all strings or all integers.
+func enumKind(enum *jsonpos.Value) (jsonpos.Kind, bool) {
+	if enum.Kind != jsonpos.Array {
+		return 0, false
+	}
+	k := jsonpos.Null
+	for _, elem := range enum.Elems {
+		switch {
+		case elem.Kind == jsonpos.Null:
+			continue
+		case elem.Kind == jsonpos.Number && !isInteger(elem.Text):
+			return 0, false
+		case elem.Kind != jsonpos.String && elem.Kind != jsonpos.Number:
+			return 0, false
+		case k != jsonpos.Null && elem.Kind != k:
+			return 0, false
+		}
+		k = elem.Kind
+	}
+	return k, k != jsonpos.Null
+}
+
+func isInteger(text string) bool {
+	_, err := strconv.ParseInt(text, 10, 64)
+	return err == nil
+}
+
+// typ returns the Lark type of a schema. Objects, enums and unions are
+// declared as nested declarations of parent named after hint. Keywords
+// that become annotations are added to f, which is nil where Lark has no
+// annotations. It also returns whether the schema is nullable, and false
+// if the schema has no Lark type, after reporting why.
+func (c *fileConv) typ(node *jsonpos.Value, hint string, parent *decl, f *field, what string) (string, bool, bool) {
+	if node.Kind == jsonpos.Object && node.Get("$ref") == nil && c.shape(node) != aliasDecl {
+		d := c.byNode[node]
+		if d == nil {
+			d = &decl{file: c, name: c.newName(hint), node: node}
+			c.byNode[node] = d
+			parent.nested = append(parent.nested, d)
+		}
+		_, nullable := c.types(node)
+		if _, null := c.branches(node); null {
+			nullable = true
+		}
+		c.define(d)
+		return d.name, nullable, d.kind != dropped
+	}
+	return c.inline(node, hint, parent, f, what)
+}
+
+// inline returns the Lark type of a schema that is not a declaration of
+// its own, as typ does.
+func (c *fileConv) inline(node *jsonpos.Value, hint string, parent *decl, f *field, what string) (typ string, nullable, ok bool) {
+	if node.Kind == jsonpos.Bool {
+		c.warnf(node.Pos, "schema %s of %s has no Lark equivalent and is dropped", strconv.FormatBool(node.Bool), what)
+		return "", false, false
+	}
+	if node.Kind != jsonpos.Object {
+		c.errorf(node.Pos, diag.InvalidInput, "schema of %s is a %s, not an object", what, node.Kind)
+		return "", false, false
+	}
+	defer c.unused(node, what)
+	// Descriptions of schemas without a declaration or a field document
+	// nothing Lark can hold.
+	c.take(node, "description")
+
+	if m := c.take(node, "$ref"); m != nil {
+		typ, nullable, ok = c.ref(m, hint, parent, what)
+		if ok {
+			c.constrain(node, c.refKind(m), f, what)
+		}
+		return typ, nullable, ok
+	}
+	if m := node.Get("oneOf"); m != nil || node.Get("anyOf") != nil {
+		key := "oneOf"
+		if m == nil {
+			key = "anyOf"
+		}
+		m = c.take(node, key)
+		branches, null := c.branches(node)
+		if len(branches) != 1 {
+			c.warnf(m.Pos, "%s of %s has no Lark equivalent: only objects form unions; it is dropped", key, what)
+			return "", false, false
+		}
+		typ, _, ok := c.typ(branches[0], hint, parent, f, what)
+		return typ, null, ok
+	}
+	if m := node.Get("allOf"); m != nil {
+		c.take(node, "allOf")
+		if m.Value.Kind != jsonpos.Array || len(m.Value.Elems) != 1 {
+			c.warnf(m.Pos, "allOf of %s has no Lark equivalent: only objects are merged; it is dropped", what)
+			return "", false, false
+		}
+		return c.typ(m.Value.Elems[0], hint, parent, f, what)
+	}
+	if m := c.take(node, "enum"); m != nil {
+		c.warnf(m.Pos, "enum of %s has no Lark equivalent: Lark enums have string or integer values; the enum is dropped", what)
+	}
+	types, nullable := c.types(node)
+	if m := c.take(node, "const"); m != nil {
+		c.warnf(m.Pos, "const of %s has no Lark equivalent and is dropped", what)
+		if len(types) == 0 {
+			// The type of the value stands for the type of the schema.
+			switch m.Value.Kind {
+			case jsonpos.String, jsonpos.Array, jsonpos.Object:
+				types = []string{m.Value.Kind.String()}
+			case jsonpos.Number:
+				types = []string{"number"}
+				if isInteger(m.Value.Text) {
+					types = []string{"integer"}
+				}
+			case jsonpos.Bool:
+				types = []string{"boolean"}
+			}
+		}
+	}
+	if len(types) == 0 {
+		switch {
+		case node.Get("items") != nil:
+			types = []string{"array"}
+		case node.Get("additionalProperties") != nil:
+			types = []string{"object"}
+		default:
+			c.warnf(node.Pos, "schema of %s accepts any value, which has no Lark type, and is dropped", what)
+			return "", false, false
+		}
+	}
+	if len(types) > 1 {
+		c.warnf(node.Pos, "schema of %s accepts values of several types (%s), which have no Lark type, and is dropped", what, strings.Join(types, ", "))
+		return "", false, false
+	}
+	switch types[0] {
+	case "boolean":
+		return "bool", nullable, true
+	case "string":
+		typ = c.stringType(node, what)
+		if typ == "string" {
+			c.constrain(node, "string", f, what)
+		}
+		return typ, nullable, true
+	case "integer":
+		return c.integerType(node, f, what), nullable, true
+	case "number":
+		typ = "float64"
+		if m := c.take(node, "format"); m != nil {
+			switch s, _ := m.Value.Str(); s {
+			case "float":
+				typ = "float32"
+			case "double":
+			default:
+				c.warnf(m.Pos, "format %s of %s has no Lark equivalent and is dropped", m.Value.Text, what)
+			}
+		}
+		c.constrain(node, "number", f, what)
+		return typ, nullable, true
+	case "array":
+		typ, ok = c.arrayType(node, hint, parent, f, what)
+		return typ, nullable, ok
+	default:
+		typ, ok = c.mapType(node, hint, parent, f, what)
+		return typ, nullable, ok
+	}
+}
+
+// stringType returns the Lark type of a string schema for its format.
+func (c *fileConv) stringType(node *jsonpos.Value, what string) string {
+	if m := c.take(node, "contentEncoding"); m != nil {
+		if s, _ := m.Value.Str(); s == "base64" {
+			return "bytes"
+		}
+		c.warnf(m.Pos, "content encoding %s of %s has no Lark equivalent and is dropped", m.Value.Text, what)
+	}
+	m := c.take(node, "format")
+	if m == nil {
+		return "string"
+	}
+	switch s, _ := m.Value.Str(); s {
+	case "date-time":
+		return "timestamp"
+	case "uuid":
+		return "uuid"
+	case "byte":
+		return "bytes"
+	}
+	c.warnf(m.Pos, "format %s of %s has no Lark equivalent and is dropped", m.Value.Text, what)
+	return "string"
+}
+
+// integerTypes are the integer types that an integer schema can become,
+// with their formats.
+var integerTypes = []struct {
+	p      schema.Primitive
+	format string
+}{
+	{schema.Int8, "int8"},
+	{schema.Uint8, "uint8"},
+	{schema.Int16, "int16"},
+	{schema.Uint16, "uint16"},
+	{schema.Int32, "int32"},
+	{schema.Uint32, "uint32"},
+	{schema.Int64, "int64"},
+	{schema.Uint64, "uint64"},
+}
+
+// integerType returns the Lark type of an integer schema: the type of its
+// format, or the type whose bounds are its minimum and maximum, which are
+// then not constraints.
+func (c *fileConv) integerType(node *jsonpos.Value, f *field, what string) string {
+	if m := c.take(node, "format"); m != nil {
+		s, _ := m.Value.Str()
+		for _, t := range integerTypes {
+			if t.format == s {
+				c.constrain(node, "number", f, what)
+				return t.p.String()
+			}
+		}
+		c.warnf(m.Pos, "format %s of %s has no Lark equivalent and is dropped", m.Value.Text, what)
+	}
+	lo, hi := node.Lookup("minimum"), node.Lookup("maximum")
+	if lo != nil && hi != nil && lo.Kind == jsonpos.Number && hi.Kind == jsonpos.Number {
+		for _, t := range integerTypes[:6] {
+			min, max := bounds(t.p)
+			if lo.Text == strconv.FormatInt(min, 10) && hi.Text == strconv.FormatInt(max, 10) {
+				c.take(node, "minimum")
+				c.take(node, "maximum")
+				return t.p.String()
+			}
+		}
+	}
+	c.constrain(node, "number", f, what)
+	return "int64"
+}
+
+// bounds returns the range of an integer type of less than 64 bits.
+func bounds(p schema.Primitive) (lo, hi int64) {
+	if p.IsUnsigned() {
+		return 0, 1<<p.Bits() - 1
+	}
+	return -1 << (p.Bits() - 1), 1<<(p.Bits()-1) - 1
+}
+
+func (c *fileConv) arrayType(node *jsonpos.Value, hint string, parent *decl, f *field, what string) (string, bool) {
+	if m := c.take(node, "prefixItems"); m != nil {
+		c.warnf(m.Pos, "prefixItems of %s has no Lark equivalent and is dropped", what)
+	}
+	items := c.takeValue(node, "items")
+	if items == nil {
+		c.warnf(node.Pos, "array %s has no items, whose type Lark needs, and is dropped", what)
+		return "", false
+	}
+	elem, _, ok := c.typ(items, hint+"Item", parent, nil, "items of "+what)
+	if !ok {
+		return "", false
+	}
+	lo, hi := node.Lookup("minItems"), node.Lookup("maxItems")
+	if lo != nil && hi != nil && lo.Kind == jsonpos.Number && lo.Text == hi.Text && isInteger(lo.Text) && lo.Text != "0" {
+		c.take(node, "minItems")
+		c.take(node, "maxItems")
+		return fmt.Sprintf("[%s; %s]", elem, lo.Text), true
+	}
+	c.constrain(node, "array", f, what)
+	return "list[" + elem + "]", true
+}
+
+func (c *fileConv) mapType(node *jsonpos.Value, hint string, parent *decl, f *field, what string) (string, bool) {
+	ap := c.takeValue(node, "additionalProperties")
+	if ap == nil || ap.Kind != jsonpos.Object {
+		c.warnf(node.Pos, "object %s has no properties and no additionalProperties schema, and is dropped", what)
+		return "", false
+	}
+	key := "string"
+	if names := c.takeValue(node, "propertyNames"); names != nil {
+		key = c.keyType(names, what)
+	}
+	elem, _, ok := c.typ(ap, hint+"Value", parent, nil, "values of "+what)
+	if !ok {
+		return "", false
+	}
+	c.constrain(node, "object", f, what)
+	return fmt.Sprintf("map[%s, %s]", key, elem), true
+}
+
+// keyType returns the key type for the propertyNames of a map: integers
+// for the patterns of the JSON Schema generator, or strings.
+func (c *fileConv) keyType(names *jsonpos.Value, what string) string {
+	key := "string"
+	switch p, _ := c.takeValue(names, "pattern").Str(); {
+	case p == "^(0|[1-9][0-9]*)$":
+		key = "uint64"
+	case p == "^(0|-?[1-9][0-9]*)$":
+		key = "int64"
+	case p != "":
+		c.warnf(names.Pos, "pattern of the property names of %s has no Lark equivalent and is dropped", what)
+	}
+	if enum := c.takeValue(names, "enum"); enum != nil {
+		key = "int32"
+		for _, elem := range enum.Elems {
+			if s, ok := elem.Str(); !ok || !isInteger(s) {
+				key = "string"
+			}
+		}
+		if key == "string" {
+			c.warnf(enum.Pos, "enum of the property names of %s has no Lark equivalent and is dropped", what)
+		}
+	}
+	c.take(names, "type")
+	c.unused(names, "the property names of "+what)
+	return key
+}
+
+// constraints maps the kinds of constrained values to the keywords that
+// become @min and @max.
+var constraints = map[string][2]string{
+	"number": {"minimum", "maximum"},
+	"string": {"minLength", "maxLength"},
+	"array":  {"minItems", "maxItems"},
+	"object": {"minProperties", "maxProperties"},
+}
+
+// constrain adds the annotations for the constraint keywords of a schema
+// of the given kind to f.
+func (c *fileConv) constrain(node *jsonpos.Value, kind string, f *field, what string) {
+	keys, ok := constraints[kind]
+	if !ok {
+		return
+	}
+	for i, name := range []string{"@min", "@max"} {
+		m := c.take(node, keys[i])
+		if m == nil {
+			continue
+		}
+		if m.Value.Kind != jsonpos.Number {
+			c.errorf(m.Pos, diag.InvalidInput, "%s of %s is not a number", keys[i], what)
+			continue
+		}
+		c.annotate(f, m, fmt.Sprintf("%s(%s)", name, literalOf(m.Value)), what)
+	}
+	if kind == "string" {
+		if m := c.take(node, "pattern"); m != nil {
+			c.annotate(f, m, fmt.Sprintf("@pattern(%s)", strconv.Quote(m.Value.Text)), what)
+		}
+	}
+}
+
+func literalOf(v *jsonpos.Value) string {
+	lit, _ := literal(v)
+	return lit
+}
+
+// annotate adds an annotation for the keyword m to f, or reports it if
+// there is no field to annotate.
+func (c *fileConv) annotate(f *field, m *jsonpos.Member, annotation, what string) {
+	if f == nil {
+		c.warnf(m.Pos, "keyword %s of %s has no Lark equivalent here: Lark annotates fields and aliases only; it is dropped", m.Key, what)
+		return
+	}
+	f.annotations = append(f.annotations, annotation)
+}
+
+// refKind returns the kind of constraints that apply to the target of a
+// reference.
+func (c *fileConv) refKind(m *jsonpos.Member) string {
+	ref, _ := m.Value.Str()
+	target, _ := c.target(ref)
+	if target == nil {
+		return ""
+	}
+	switch t, _ := target.Lookup("type").Str(); t {
+	case "integer", "number":
+		return "number"
+	default:
+		return t
+	}
+}
+
+// target returns the schema a reference points to and the document that
+// holds it, or nil if it is not among the converted documents.
+func (c *fileConv) target(ref string) (*jsonpos.Value, *fileConv) {
+	uri, fragment, _ := strings.Cut(ref, "#")
+	file := c
+	if uri != "" {
+		if strings.Contains(uri, ":") || strings.HasPrefix(uri, "/") {
+			return nil, nil
+		}
+		file = c.files[path.Join(path.Dir(c.path), uri)]
+		if file == nil {
+			return nil, nil
+		}
+	}
+	node := file.root
+	if fragment == "" {
+		return node, file
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return nil, nil
+	}
+	for _, token := range strings.Split(fragment[1:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node.Kind {
+		case jsonpos.Object:
+			node = node.Lookup(token)
+		case jsonpos.Array:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node.Elems) {
+				return nil, nil
+			}
+			node = node.Elems[i]
+		default:
+			return nil, nil
+		}
+		if node == nil {
+			return nil, nil
+		}
+	}
+	return node, file
+}
+
+// ref returns the Lark type for a reference.
+func (c *fileConv) ref(m *jsonpos.Member, hint string, parent *decl, what string) (string, bool, bool) {
+	ref, ok := m.Value.Str()
+	if !ok {
+		c.errorf(m.Pos, diag.InvalidInput, "$ref of %s is not a string", what)
+		return "", false, false
+	}
+	target, file := c.target(ref)
+	if target == nil {
+		uri, _, _ := strings.Cut(ref, "#")
+		if uri != "" && (strings.Contains(uri, ":") || strings.HasPrefix(uri, "/") || c.files[path.Join(path.Dir(c.path), uri)] == nil) {
+			c.warnf(m.Pos, "reference %s of %s is not to a converted document and is dropped", ref, what)
+		} else {
+			c.errorf(m.Pos, diag.UndefinedName, "reference %s of %s does not resolve", ref, what)
+		}
+		return "", false, false
+	}
+	d := file.byNode[target]
+	if d == nil && file == c && c.shape(target) != aliasDecl {
+		// A reference into the document to a schema that is not a
+		// definition declares the schema under the name of its key.
+		_, fragment, _ := strings.Cut(ref, "#")
+		return c.typ(target, path.Base(fragment), parent, nil, what)
+	}
+	if d == nil {
+		if file != c {
+			c.warnf(m.Pos, "reference %s of %s does not point to a definition of the document and is dropped", ref, what)
+			return "", false, false
+		}
+		if c.active[target] {
+			c.warnf(m.Pos, "reference %s of %s is recursive without an object in between, which Lark cannot express, and is dropped", ref, what)
+			return "", false, false
+		}
+		c.active[target] = true
+		defer delete(c.active, target)
+		return c.typ(target, hint, parent, nil, what)
+	}
+	file.define(d)
+	if d.active && d.kind == dropped {
+		// Only structs and unions are declared before they are defined.
+		c.warnf(m.Pos, "reference %s of %s is recursive without an object in between, which Lark cannot express, and is dropped", ref, what)
+		return "", false, false
+	}
+	if d.kind == dropped {
+		return "", false, false
+	}
+	return c.qualify(d), false, true
+}
+
+// qualify returns the name of a declaration, qualified with the name of its
+// import if it is declared in another document.
+func (c *fileConv) qualify(d *decl) string {
+	if d.file == c {
+		return d.name
+	}
+	name, ok := c.imports[d.file]
+	if !ok {
+		name = names.Ident(path.Base(d.file.module))
+		base := name
+		for i := 2; c.names[name] || c.importNameUsed(name); i++ {
+			name = base + strconv.Itoa(i)
+		}
+		c.imports[d.file] = name
+	}
+	return name + "." + d.name
+}
+
+func (c *fileConv) importNameUsed(name string) bool {
+	for _, used := range c.imports {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
+
+// structType converts the properties of an object, and of the objects of
+// its allOf, to fields of d. required holds the required properties of the
+// enclosing schemas of allOf.
+func (c *fileConv) structType(d *decl, node *jsonpos.Value, required map[string]bool) {
+	c.take(node, "type")
+	if req := c.takeValue(node, "required"); req != nil {
+		for _, elem := range req.Elems {
+			if s, ok := elem.Str(); ok {
+				required[s] = true
+			}
+		}
+	}
+	if all := c.takeValue(node, "allOf"); all != nil {
+		for i, elem := range all.Elems {
+			if m := c.take(elem, "$ref"); m != nil {
+				ref, _ := m.Value.Str()
+				target, file := c.target(ref)
+				if target == nil || file.byNode[target] == nil {
+					c.warnf(m.Pos, "allOf of %s refers to %s, which is not a definition, and it is dropped", d.name, ref)
+					continue
+				}
+				base := file.byNode[target]
+				file.define(base)
+				for _, f := range base.fields {
+					copied := *f
+					copied.annotations = append([]string(nil), f.annotations...)
+					d.fields = append(d.fields, &copied)
+				}
+				c.describeElem(elem, d, fmt.Sprintf("allOf[%d] of %s", i, d.name))
+				continue
+			}
+			c.structType(d, elem, required)
+			c.describeElem(elem, d, fmt.Sprintf("allOf[%d] of %s", i, d.name))
+		}
+	}
+	if props := c.takeValue(node, "properties"); props != nil {
+		for _, m := range props.Members {
+			c.property(d, m, required[m.Key])
+		}
+	}
+	if ap := c.take(node, "additionalProperties"); ap != nil && ap.Value.Kind == jsonpos.Object {
+		c.warnf(ap.Pos, "additional properties of %s have no Lark equivalent and are dropped", d.name)
+	}
+}
+
+// describeElem reports the unused keywords of an element of allOf.
+func (c *fileConv) describeElem(elem *jsonpos.Value, d *decl, what string) {
+	c.take(elem, "description")
+	c.unused(elem, what)
+}
+
+// property converts a property of an object to a field.
+func (c *fileConv) property(d *decl, m *jsonpos.Member, required bool) {
+	name, json := names.Field(m.Key)
+	f := &field{name: name, json: json, node: m.Value, required: required}
+	what := fmt.Sprintf("property %s of %s", m.Key, d.name)
+	if m.Value.Get("$ref") != nil || c.shape(m.Value) == aliasDecl {
+		// Schemas with a declaration of their own keep their
+		// description there.
+		c.describe(m.Value, &f.doc, &f.annotations, what)
+	}
+	typ, nullable, ok := c.typ(m.Value, d.name+names.Type(m.Key), d, f, what)
+	if !ok {
+		return
+	}
+	f.typ = typ
+	f.nullable = nullable
+	if json != "" {
+		f.annotations = append([]string{fmt.Sprintf("@json(%q)", json)}, f.annotations...)
+	} else {
+		f.json = m.Key
+	}
+	for _, other := range d.fields {
+		if other.name == f.name {
+			c.warnf(m.Pos, "property %s of %s clashes with another property as field %s and is dropped", m.Key, d.name, f.name)
+			return
+		}
+	}
+	d.fields = append(d.fields, f)
+}
+
+// enumType converts the values of an enum to the members of d.
+func (c *fileConv) enumType(d *decl, node *jsonpos.Value) {
+	c.take(node, "type")
+	enum := c.take(node, "enum")
+	k, _ := enumKind(enum.Value)
+	seen := make(map[string]bool)
+	next := int64(0)
+	for _, elem := range enum.Value.Elems {
+		var member enumMember
+		switch elem.Kind {
+		case jsonpos.Null:
+			continue
+		case jsonpos.String:
+			member = enumMember{name: names.Ident(elem.Text), value: next}
+			next++
+		default:
+			n, _ := strconv.ParseInt(elem.Text, 10, 64)
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				c.warnf(elem.Pos, "value %d of %s is not an int32, which Lark enums need, and is dropped", n, d.name)
+				continue
+			}
+			name := "value_" + strconv.FormatInt(n, 10)
+			if n < 0 {
+				name = "value_minus_" + strconv.FormatInt(-n, 10)
+			}
+			member = enumMember{name: name, value: n}
+		}
+		base := member.name
+		for i := 2; seen[member.name]; i++ {
+			member.name = base + "_" + strconv.Itoa(i)
+		}
+		seen[member.name] = true
+		if elem.Kind == jsonpos.String && member.name != elem.Text {
+			member.json = elem.Text
+		}
+		d.members = append(d.members, &member)
+	}
+	if k == jsonpos.String {
+		c.warnf(enum.Pos, "the string values of %s become members of a Lark enum, which are encoded as integers", d.name)
+	}
+}
+
+// unionType converts the branches of oneOf or anyOf to the variants of d.
+func (c *fileConv) unionType(d *decl, node *jsonpos.Value) {
+	key := "oneOf"
+	m := c.take(node, key)
+	if m == nil {
+		key = "anyOf"
+		m = c.take(node, key)
+		c.warnf(m.Pos, "anyOf of %s becomes a union, whose values match exactly one variant", d.name)
+	}
+	branches, null := c.branches(node)
+	if null {
+		c.warnf(m.Pos, "null of %s has no Lark equivalent in a declaration and is dropped", d.name)
+	}
+
+	// The tag is the discriminator property, or the property with a
+	// const in every branch.
+	tag := ""
+	mapping := make(map[string]string) // by reference
+	if disc := c.takeValue(node, "discriminator"); disc != nil {
+		tag, _ = disc.Lookup("propertyName").Str()
+		if mp := disc.Lookup("mapping"); mp != nil {
+			for _, m := range mp.Members {
+				if ref, ok := m.Value.Str(); ok {
+					mapping[ref] = m.Key
+				}
+			}
+		}
+	}
+	if tag == "" {
+		tag = c.commonConst(branches)
+	}
+	if tag == "" {
+		tag = schema.DefaultTag
+		c.warnf(m.Pos, "%s of %s has no discriminator, so the union gets the tag %q", key, d.name, tag)
+	}
+	if tag != schema.DefaultTag {
+		d.annotations = append(d.annotations, fmt.Sprintf("@tag(%q)", tag))
+	}
+	d.tag = tag
+
+	seen := make(map[string]bool)
+	for i, branch := range branches {
+		what := fmt.Sprintf("%s[%d] of %s", key, i, d.name)
+		v := &variant{}
+		var target *decl
+		ref := ""
+		if r := c.take(branch, "$ref"); r != nil {
+			ref, _ = r.Value.Str()
+			node, file := c.target(ref)
+			if node != nil {
+				target = file.byNode[node]
+			}
+			if target == nil {
+				c.warnf(r.Pos, "variant %s of %s is not a definition and is dropped", ref, d.name)
+				continue
+			}
+			target.file.define(target)
+		}
+		value := c.tagValue(branch, target, tag)
+		if value == "" {
+			value = mapping[ref]
+		}
+		if target == nil {
+			hint := d.name + names.Type(value)
+			if value == "" {
+				hint = d.name + "Variant" + strconv.Itoa(i+1)
+			}
+			if _, _, ok := c.typ(branch, hint, d, nil, what); !ok {
+				continue
+			}
+			target = c.byNode[branch]
+		} else {
+			if s, ok := c.takeValue(branch, "description").Str(); ok {
+				v.doc = s
+			}
+			c.take(branch, "required")
+			c.unused(branch, what)
+		}
+		if target == nil || target.kind != structDecl {
+			c.warnf(branch.Pos, "variant of %s is not an object and is dropped", d.name)
+			continue
+		}
+		if value == "" {
+			value = target.name
+		}
+		v.name = names.Ident(value)
+		if v.name != value {
+			c.warnf(branch.Pos, "tag value %q of %s is not a Lark name and becomes %s", value, d.name, v.name)
+		}
+		if seen[v.name] {
+			c.warnf(branch.Pos, "variant %s of %s is duplicated and is dropped", v.name, d.name)
+			continue
+		}
+		seen[v.name] = true
+		v.decl = target
+		v.typ = c.qualify(target)
+		d.variants = append(d.variants, v)
+	}
+}
+
+// commonConst returns the name of a property that has a const string in
+// every branch, or "".
+func (c *fileConv) commonConst(branches []*jsonpos.Value) string {
+	if len(branches) == 0 {
+		return ""
+	}
+	for _, m := range c.branchProps(branches[0]) {
+		if _, ok := constOf(m.Value); !ok {
+			continue
+		}
+		all := true
+		for _, branch := range branches[1:] {
+			found := false
+			for _, other := range c.branchProps(branch) {
+				if _, ok := constOf(other.Value); ok && other.Key == m.Key {
+					found = true
+				}
+			}
+			all = all && found
+		}
+		if all {
+			return m.Key
+		}
+	}
+	return ""
+}
+
+// branchProps returns the properties of a branch and of the target of its
+// reference.
+func (c *fileConv) branchProps(branch *jsonpos.Value) []*jsonpos.Member {
+	var props []*jsonpos.Member
+	if p := branch.Lookup("properties"); p != nil {
+		props = append(props, p.Members...)
+	}
+	if ref, ok := branch.Lookup("$ref").Str(); ok {
+		if target, _ := c.target(ref); target != nil {
+			if p := target.Lookup("properties"); p != nil {
+				props = append(props, p.Members...)
+			}
+		}
+	}
+	return props
+}
+
+// constOf returns the const string of a schema, or the only value of its
+// enum.
+func constOf(node *jsonpos.Value) (string, bool) {
+	if s, ok := node.Lookup("const").Str(); ok {
+		return s, true
+	}
+	if enum := node.Lookup("enum"); enum != nil && len(enum.Elems) == 1 {
+		return enum.Elems[0].Str()
+	}
+	return "", false
+}
+
+// tagValue returns the value of the tag property of a branch: its const in
+// the branch, which is then used, or in the target of its reference.
+func (c *fileConv) tagValue(branch *jsonpos.Value, target *decl, tag string) string {
+	if props := branch.Lookup("properties"); props != nil {
+		if p := props.Lookup(tag); p != nil {
+			if s, ok := constOf(p); ok {
+				if len(props.Members) == 1 && target != nil {
+					c.take(branch, "properties")
+				}
+				return s
+			}
+		}
+	}
+	if target != nil {
+		if s, ok := constOf(target.node.Lookup("properties").Lookup(tag)); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// dropTags removes the fields that hold the tag of a union from its
+// variants, since the union holds the tag, with the warnings about the
+// const of the tag.
+func (c *fileConv) dropTags(decls []*decl) {
+	for _, d := range decls {
+		if d.kind == unionDecl {
+			for _, v := range d.variants {
+				fields := v.decl.fields[:0]
+				for _, f := range v.decl.fields {
+					if f.json != d.tag {
+						fields = append(fields, f)
+						continue
+					}
+					if m := f.node.Get("const"); m != nil {
+						v.decl.file.forget(m.Pos)
+					}
+				}
+				v.decl.fields = fields
+			}
+		}
+		c.dropTags(d.nested)
+	}
+}
+
+// forget removes the diagnostics at a position.
+func (c *fileConv) forget(pos diag.Pos) {
+	diags := c.diags[:0]
+	for _, d := range c.diags {
+		if d.Pos() != pos {
+			diags = append(diags, d)
+		}
+	}
+	c.diags = diags
+}
@@ -0,0 +1,134 @@
+package jsonschema
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"larklang.io/lark/pkg/format"
+)
+
+// emit returns the formatted Lark source of the document.
+func (c *fileConv) emit() ([]byte, error) {
+	var b strings.Builder
+	if c.byNode[c.root] == nil {
+		if s, ok := c.takeValue(c.root, "description").Str(); ok {
+			comment(&b, s)
+			b.WriteString("\n")
+		}
+		c.unused(c.root, "the document")
+	}
+	c.importDecls(&b)
+	var write func(d *decl)
+	write = func(d *decl) {
+		c.decl(&b, d)
+		for _, n := range d.nested {
+			write(n)
+		}
+	}
+	for _, d := range c.decls {
+		write(d)
+	}
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("converting %s: %v", c.path, err)
+	}
+	return src, nil
+}
+
+// comment writes text as a comment.
+func comment(b *strings.Builder, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			b.WriteString("//\n")
+		} else {
+			fmt.Fprintf(b, "// %s\n", line)
+		}
+	}
+}
+
+// annotate writes the doc comment and the annotations of a declaration or
+// an entry.
+func annotate(b *strings.Builder, doc string, annotations []string) {
+	if doc != "" {
+		comment(b, doc)
+	}
+	for _, a := range annotations {
+		b.WriteString(a + "\n")
+	}
+}
+
+// importDecls writes the imports of the referenced documents in the order
+// of their paths.
+func (c *fileConv) importDecls(b *strings.Builder) {
+	var used []*fileConv
+	for file := range c.imports {
+		used = append(used, file)
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].module < used[j].module })
+	for _, file := range used {
+		module := file.module
+		// Modules below the directory of the document are imported
+		// relative to it.
+		if dir := path.Dir(c.module); dir != "." {
+			if rel, ok := strings.CutPrefix(module, dir+"/"); ok {
+				module = rel
+			}
+		}
+		if name := c.imports[file]; name == path.Base(module) {
+			fmt.Fprintf(b, "import %q\n", module)
+		} else {
+			fmt.Fprintf(b, "import %q as %s\n", module, name)
+		}
+	}
+	if len(used) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+// decl writes a declaration.
+func (c *fileConv) decl(b *strings.Builder, d *decl) {
+	if d.kind == dropped {
+		return
+	}
+	annotate(b, d.doc, d.annotations)
+	switch d.kind {
+	case aliasDecl:
+		fmt.Fprintf(b, "type %s = %s\n\n", d.name, d.typ)
+	case structDecl:
+		fmt.Fprintf(b, "struct %s {\n", d.name)
+		for _, f := range d.fields {
+			annotate(b, f.doc, f.annotations)
+			mark := ""
+			if f.optional {
+				mark = "?"
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", f.name, mark, f.typ)
+		}
+		b.WriteString("}\n\n")
+	case enumDecl:
+		fmt.Fprintf(b, "enum %s {\n", d.name)
+		next := int64(0)
+		for _, m := range d.members {
+			if m.json != "" {
+				fmt.Fprintf(b, "@json(%q)\n", m.json)
+			}
+			if m.value == next {
+				fmt.Fprintf(b, "%s\n", m.name)
+			} else {
+				fmt.Fprintf(b, "%s = %d\n", m.name, m.value)
+			}
+			next = m.value + 1
+		}
+		b.WriteString("}\n\n")
+	case unionDecl:
+		fmt.Fprintf(b, "union %s {\n", d.name)
+		for _, v := range d.variants {
+			annotate(b, v.doc, nil)
+			fmt.Fprintf(b, "%s: %s\n", v.name, v.typ)
+		}
+		b.WriteString("}\n\n")
+	}
+}
@@ -0,0 +1,171 @@
+// Package jsonschema converts JSON Schema documents to Lark source.
+//
+// Every document becomes a Lark file with the same path, without the
+// .schema.json or .json extension and with the .lark extension. The root
+// schema becomes a declaration named after its title, or after the file if
+// it has none, unless it only holds definitions; every schema under $defs
+// or definitions becomes a declaration named after its key. Schemas are
+// translated as follows:
+//
+//   - Objects with properties become structs. Required properties are
+//     fields, the others optional fields, as are nullable properties.
+//     Properties whose names are not Lark identifiers are renamed and
+//     keep their name in @json("name"). allOf merges the properties of
+//     its objects into one struct.
+//   - Objects with only additionalProperties become maps, arrays become
+//     lists, and arrays with equal minItems and maxItems fixed-size
+//     arrays.
+//   - Integers become the integer type whose bounds are their minimum and
+//     maximum, or the type of their format, and int64 otherwise. Numbers
+//     become float64, or float32 for the format "float". Strings become
+//     timestamp for the format "date-time", uuid for "uuid" and bytes for
+//     base64 content or the format "byte".
+//   - Enums of strings or of integers become enums. Members named after
+//     strings are numbered in order, and those renamed keep their string
+//     in @json("string"); integer values are kept and name their members
+//     value_n.
+//   - oneOf and anyOf of objects become unions. The tag is the
+//     discriminator property, or the property that has a const in every
+//     branch; the tag values name the variants. oneOf of a schema and null
+//     makes the schema nullable.
+//   - $ref refers to the declaration of its target. References may point
+//     anywhere in the document and into other converted documents by a
+//     relative URI; the latter become imports.
+//
+// Anonymous objects, enums and unions are declared under a name derived
+// from their position: the name of the enclosing declaration followed by
+// the property name, so that the object of property address of User
+// becomes UserAddress, with Item appended for array items and Value for
+// map values. A number is appended to names that are taken.
+//
+// minimum, maximum, minLength, maxLength, minItems, maxItems,
+// minProperties and maxProperties become @min and @max, and pattern
+// becomes @pattern, where Lark allows annotations. Descriptions become doc
+// comments, deprecated becomes @deprecated and default @default. Other
+// keywords, and those that do not apply where they appear, are reported as
+// warnings.
+package jsonschema
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"larklang.io/lark/internal/jsonpos"
+	"larklang.io/lark/pkg/diag"
+)
+
+// A Config controls how documents are converted.
+type Config struct {
+	// ReadFile reads a file; nil means os.ReadFile.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// A Source names a document to convert. If Src is nil, the document is
+// read from Path.
+type Source struct {
+	Path string
+	Src  []byte
+}
+
+// A File is the Lark file converted from a source.
+type File struct {
+	Source string // path of the source
+	Path   string // slash-separated path of the Lark file
+
+	// Content is the formatted Lark source. It is nil if the source is not
+	// valid JSON.
+	Content []byte
+
+	// Lines holds the lines of the source, for rendering the diagnostics,
+	// whose positions refer to the source.
+	Lines       []string
+	Diagnostics []diag.Diagnostic
+}
+
+// Convert converts the sources. References between them are resolved
+// relative to their paths. Problems of the sources are reported as
+// diagnostics of the files; Convert fails only if a source cannot be read.
+func (c *Config) Convert(sources ...Source) ([]*File, error) {
+	cv := &converter{files: make(map[string]*fileConv)}
+	var files []*File
+	var convs []*fileConv
+	for _, source := range sources {
+		src := source.Src
+		if src == nil {
+			var err error
+			if src, err = c.read(source.Path); err != nil {
+				return nil, err
+			}
+		}
+		name := path.Clean(filepath.ToSlash(source.Path))
+		file := &File{
+			Source: source.Path,
+			Path:   modulePath(name) + ".lark",
+			Lines:  strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n"),
+		}
+		files = append(files, file)
+		root, err := jsonpos.Parse(src)
+		if err != nil {
+			file.Diagnostics = []diag.Diagnostic{{
+				Severity: diag.Error,
+				Code:     diag.InvalidInput,
+				Range:    diag.At(err.Pos),
+				Message:  err.Msg,
+			}}
+			convs = append(convs, nil)
+			continue
+		}
+		fc := newFileConv(cv, name, root)
+		cv.files[name] = fc
+		convs = append(convs, fc)
+	}
+	for _, fc := range convs {
+		if fc != nil {
+			fc.declare()
+		}
+	}
+	for _, fc := range convs {
+		if fc != nil {
+			fc.defineAll()
+		}
+	}
+	for _, fc := range convs {
+		if fc != nil {
+			fc.dropTags(fc.decls)
+		}
+	}
+	for i, fc := range convs {
+		if fc == nil {
+			continue
+		}
+		var err error
+		if files[i].Content, err = fc.emit(); err != nil {
+			return nil, err
+		}
+		files[i].Diagnostics = fc.sortedDiags()
+	}
+	return files, nil
+}
+
+func (c *Config) read(name string) ([]byte, error) {
+	if c.ReadFile != nil {
+		return c.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// modulePath returns the Lark module path for the path of a document.
+func modulePath(name string) string {
+	for _, ext := range []string{".schema.json", ".json"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+type converter struct {
+	files map[string]*fileConv // by cleaned slash-separated path
+}
@@ -0,0 +1,157 @@
+package jsonschema
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// convert converts the given sources.
+func convert(t *testing.T, sources ...Source) []*File {
+	t.Helper()
+	files, err := new(Config).Convert(sources...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// messages returns the diagnostics of a file as strings.
+func messages(file *File) []string {
+	var list []string
+	for _, d := range file.Diagnostics {
+		list = append(list, d.Error())
+	}
+	return list
+}
+
+// The testdata holds the output of the JSON Schema generator for users and
+// common/types, and an order as written by hand.
+func TestGolden(t *testing.T) {
+	files := convert(t,
+		Source{Path: "testdata/users.schema.json"},
+		Source{Path: "testdata/common/types.schema.json"},
+		Source{Path: "testdata/order.json"},
+	)
+	lark := make(map[string][]byte)
+	for _, file := range files {
+		name := strings.TrimPrefix(file.Path, "testdata/")
+		lark[name] = file.Content
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(name, ".lark")+".golden"))
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(file.Content, want) {
+			t.Errorf("%s differs from %s:\n%s", file.Path, golden, diff.Unified(golden, file.Path, want, file.Content))
+		}
+	}
+
+	// The converted files are valid Lark.
+	c := &loader.Config{
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := lark[filepath.ToSlash(name)]; ok {
+				return src, nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+	prog, err := c.Load(loader.Source{Path: "order.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.Check(prog)
+	for _, file := range prog.Diagnostics() {
+		for _, d := range file.Diagnostics {
+			t.Errorf("%s: %v", file.Name, d)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	files := convert(t,
+		Source{Path: "testdata/users.schema.json"},
+		Source{Path: "testdata/order.json"},
+		Source{Path: "testdata/common/types.schema.json"},
+	)
+	tests := [][]string{
+		{
+			"155:16: warning[W0100]: schema of Nothing accepts any value, which has no Lark type, and is dropped",
+			"156:7: warning[W0100]: keyword not of Nothing has no Lark equivalent and is dropped",
+		},
+		{
+			"11:7: warning[W0100]: the string values of OrderStatus become members of a Lark enum, which are encoded as integers",
+			"17:38: warning[W0100]: format email of property e-mail of OrderCustomer has no Lark equivalent and is dropped",
+			"29:62: warning[W0100]: keyword exclusiveMinimum of property unitPrice of OrderLinesItem has no Lark equivalent and is dropped",
+			"41:16: warning[W0100]: reference https://example.com/source.json of property source of Order is not to a converted document and is dropped",
+			"42:31: warning[W0100]: prefixItems of property pair of Order has no Lark equivalent and is dropped",
+			"43:17: error[E0300]: reference #/definitions/nothing of property missing of Order does not resolve",
+			"44:12: warning[W0100]: schema of property any of Order accepts any value, which has no Lark type, and is dropped",
+			"45:37: warning[W0100]: keyword readOnly of property readonly of Order has no Lark equivalent and is dropped",
+			"77:7: warning[W0100]: anyOf of Payment becomes a union, whose values match exactly one variant",
+			`86:9: warning[W0100]: tag value "bank-transfer" of Payment is not a Lark name and becomes bank_transfer`,
+		},
+		nil,
+	}
+	for i, file := range files {
+		got := messages(file)
+		if strings.Join(got, "\n") != strings.Join(tests[i], "\n") {
+			t.Errorf("%s: got diagnostics\n%s\nwant\n%s", file.Source, strings.Join(got, "\n"), strings.Join(tests[i], "\n"))
+		}
+	}
+}
+
+func TestUnsupportedSchemas(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`true`, []string{"1:1: warning[W0100]: schema true of x has no Lark equivalent and is dropped"}},
+		{`1`, []string{"1:1: error[E0400]: schema of x is a number, not an object"}},
+		{`{"$defs": {"Loop": {"$ref": "#/$defs/Loop"}}}`, []string{
+			"1:21: warning[W0100]: reference #/$defs/Loop of Loop is recursive without an object in between, which Lark cannot express, and is dropped",
+		}},
+		{`{"$defs": {"A": {"$ref": "#/$defs/B"}, "B": {"$ref": "#/$defs/A"}}}`, []string{
+			"1:46: warning[W0100]: reference #/$defs/A of B is recursive without an object in between, which Lark cannot express, and is dropped",
+		}},
+	}
+	for _, test := range tests {
+		files := convert(t, Source{Path: "x.json", Src: []byte(test.src)})
+		if got := messages(files[0]); strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got %q; want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`{"type": "object",}`, `1:19: error[E0400]: expected a string, found '}'`},
+		{`{"type" "object"}`, `1:9: error[E0400]: expected ':', found '"'`},
+		{"{\n  \"title\": \"x\n}", `2:14: error[E0400]: unterminated string`},
+		{`{"minimum": 1.}`, `1:13: error[E0400]: invalid number 1.`},
+		{`{} {}`, `1:4: error[E0400]: unexpected '{' after the end of the document`},
+		{`{"a": tru}`, `1:7: error[E0400]: unexpected 't'`},
+	}
+	for _, test := range tests {
+		files := convert(t, Source{Path: "errors.json", Src: []byte(test.src)})
+		if files[0].Content != nil {
+			t.Errorf("%q: got content for a file with errors", test.src)
+		}
+		if got := messages(files[0]); len(got) != 1 || got[0] != test.err {
+			t.Errorf("%q: got %v; want %s", test.src, got, test.err)
+		}
+		if !diag.HasErrors(files[0].Diagnostics) {
+			t.Errorf("%q: no errors", test.src)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package protobuf
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/internal/names"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/format"
+)
+
+// scalars maps the scalar types of protobuf to Lark.
+var scalars = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"int64":    "int64",
+	"uint32":   "uint32",
+	"uint64":   "uint64",
+	"sint32":   "int32",
+	"sint64":   "int64",
+	"fixed32":  "uint32",
+	"fixed64":  "uint64",
+	"sfixed32": "int32",
+	"sfixed64": "int64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "bytes",
+}
+
+// wrappers maps the wrapper types of protobuf to the Lark type they make
+// optional.
+var wrappers = map[string]string{
+	".google.protobuf.DoubleValue": "float64",
+	".google.protobuf.FloatValue":  "float32",
+	".google.protobuf.Int64Value":  "int64",
+	".google.protobuf.UInt64Value": "uint64",
+	".google.protobuf.Int32Value":  "int32",
+	".google.protobuf.UInt32Value": "uint32",
+	".google.protobuf.BoolValue":   "bool",
+	".google.protobuf.StringValue": "string",
+	".google.protobuf.BytesValue":  "bytes",
+}
+
+const (
+	timestampType = ".google.protobuf.Timestamp"
+	emptyType     = ".google.protobuf.Empty"
+)
+
+// fileConv converts one file.
+type fileConv struct {
+	*converter
+	file    *protoFile
+	b       strings.Builder
+	diags   []diag.Diagnostic
+	imports map[*protoFile]string // Lark name of the used imports
+}
+
+// convert converts a parsed file to formatted Lark source.
+func (cv *converter) convert(file *protoFile, diags []diag.Diagnostic) ([]byte, []diag.Diagnostic, error) {
+	c := &fileConv{converter: cv, file: file, diags: diags, imports: make(map[*protoFile]string)}
+	scope := ""
+	if file.pkg != "" {
+		scope = "." + file.pkg
+	}
+	for _, opt := range file.options {
+		c.warnf(opt.pos, "file option %s is dropped: Lark has no file options", opt.name)
+	}
+	for _, decl := range file.decls {
+		c.decl(scope, decl)
+	}
+	body := c.b.String()
+
+	c.b.Reset()
+	if file.doc != nil {
+		c.comment(file.doc)
+		c.b.WriteString("\n")
+	}
+	c.importDecls()
+	for _, lines := range file.detached {
+		c.comment(lines)
+		c.b.WriteString("\n")
+	}
+	c.b.WriteString(body)
+
+	src, err := format.Source([]byte(c.b.String()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("converting %s: %v", file.path, err)
+	}
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[j].Pos().Greater(c.diags[i].Pos())
+	})
+	return src, c.diags, nil
+}
+
+func (c *fileConv) warnf(pos diag.Pos, format string, args ...any) {
+	c.diags = append(c.diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Code:     diag.Untranslatable,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *fileConv) errorf(pos diag.Pos, format string, args ...any) {
+	c.diags = append(c.diags, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.UndefinedName,
+		Range:    diag.At(pos),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *fileConv) printf(format string, args ...any) {
+	fmt.Fprintf(&c.b, format, args...)
+}
+
+// comment writes the lines of a comment.
+func (c *fileConv) comment(lines []string) {
+	for _, line := range lines {
+		if line == "" {
+			c.b.WriteString("//\n")
+		} else {
+			c.printf("// %s\n", line)
+		}
+	}
+}
+
+// doc writes the comments before a declaration.
+func (c *fileConv) doc(n *node) {
+	for _, lines := range n.cmt.detached {
+		c.comment(lines)
+		c.b.WriteString("\n")
+	}
+	c.comment(n.cmt.leading)
+}
+
+// trail writes the trailing comment of a declaration and ends its line.
+func (c *fileConv) trail(n *node) {
+	if n.trail != "" {
+		c.printf(" // %s", n.trail)
+	}
+	c.b.WriteString("\n")
+}
+
+// importDecls writes the imports of the used files in the order of the
+// protobuf imports.
+func (c *fileConv) importDecls() {
+	var used []*protoFile
+	for file := range c.imports {
+		used = append(used, file)
+	}
+	// Files that are not imported directly, but through public imports,
+	// come last.
+	order := make(map[string]int)
+	for _, file := range used {
+		order[file.path] = len(c.file.imports)
+	}
+	for i, imp := range c.file.imports {
+		order[imp.path] = i
+	}
+	sort.Slice(used, func(i, j int) bool {
+		oi, oj := order[used[i].path], order[used[j].path]
+		return oi < oj || oi == oj && used[i].path < used[j].path
+	})
+	for _, imp := range c.file.imports {
+		if file := c.files[imp.path]; file == nil || c.imports[file] == "" {
+			// Comments of imports that are dropped are kept.
+			for _, lines := range append(imp.cmt.detached, imp.cmt.leading) {
+				if lines != nil {
+					c.file.detached = append(c.file.detached, lines)
+				}
+			}
+		}
+	}
+	for _, file := range used {
+		module := strings.TrimSuffix(file.path, ".proto")
+		name := c.imports[file]
+		for _, imp := range c.file.imports {
+			if imp.path == file.path {
+				c.doc(&node{cmt: imp.cmt})
+			}
+		}
+		if name == importName(module) {
+			c.printf("import %q\n", module)
+		} else {
+			c.printf("import %q as %s\n", module, name)
+		}
+	}
+	if len(used) > 0 {
+		c.b.WriteString("\n")
+	}
+}
+
+// importName returns the name under which Lark makes a module visible.
+func importName(module string) string {
+	return path.Base(module)
+}
+
+// ref returns the Lark name of a symbol, qualified with the name of its
+// import if it is declared in another file.
+func (c *fileConv) ref(sym *symbol) string {
+	if sym.file == c.file {
+		return sym.name
+	}
+	name, ok := c.imports[sym.file]
+	if !ok {
+		module := strings.TrimSuffix(sym.file.path, ".proto")
+		name = importName(module)
+		if !names.IsIdent(name) || c.names[c.file][name] || c.importNameUsed(name) {
+			base := identifier(name)
+			name = base
+			for i := 2; c.names[c.file][name] || c.importNameUsed(name); i++ {
+				name = base + strconv.Itoa(i)
+			}
+		}
+		c.imports[sym.file] = name
+	}
+	return name + "." + sym.name
+}
+
+func (c *fileConv) importNameUsed(name string) bool {
+	for _, used := range c.imports {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fileConv) decl(scope string, decl any) {
+	switch decl := decl.(type) {
+	case *message:
+		c.message(scope, decl)
+	case *enum:
+		c.enum(scope, decl)
+	case *service:
+		c.service(scope, decl)
+	}
+}
+
+// options writes the annotations for the options of a declaration, skipping
+// the options in skip.
+func (c *fileConv) options(options []*option, skip ...string) {
+	for _, opt := range options {
+		if contains(skip, opt.name) {
+			continue
+		}
+		if opt.name == "deprecated" {
+			if opt.value.text == "true" {
+				c.b.WriteString("@deprecated\n")
+			}
+			continue
+		}
+		if annotation := c.annotation(opt); annotation != "" {
+			c.printf("%s\n", annotation)
+		}
+	}
+}
+
+// annotation returns the annotation for an option, or "" after reporting
+// that it cannot be translated.
+func (c *fileConv) annotation(opt *option) string {
+	if strings.HasPrefix(opt.name, "(") {
+		c.warnf(opt.pos, "custom option %s is dropped: Lark annotations cannot express extensions", opt.name)
+		return ""
+	}
+	if strings.Contains(opt.name, ".") {
+		c.warnf(opt.pos, "option %s is dropped: Lark annotations have no fields", opt.name)
+		return ""
+	}
+	name := opt.name
+	if name == "json_name" {
+		name = "json"
+	}
+	value, ok := c.constant(opt)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("@%s(%s)", name, value)
+}
+
+// constant returns the Lark literal for the value of an option.
+func (c *fileConv) constant(opt *option) (string, bool) {
+	v := opt.value
+	switch v.kind {
+	case tokString:
+		return strconv.Quote(v.text), true
+	case tokInt:
+		n, _ := new(big.Int).SetString(v.text, 0)
+		if v.neg {
+			n.Neg(n)
+		}
+		return constant.MakeInt(n).String(), true
+	case tokFloat:
+		f, _ := strconv.ParseFloat(v.text, 64)
+		if v.neg {
+			f = -f
+		}
+		return constant.MakeFloat64(f).String(), true
+	case tokIdent:
+		switch {
+		case v.text == "true" || v.text == "false":
+			return v.text, true
+		case v.text == "inf" || v.text == "nan":
+			c.warnf(opt.pos, "option %s is dropped: Lark has no constant for %s", opt.name, v.text)
+			return "", false
+		}
+		return strconv.Quote(v.text), true
+	}
+	c.warnf(opt.pos, "option %s is dropped: Lark annotations cannot express aggregate values", opt.name)
+	return "", false
+}
+
+// reserved writes the annotation for reserved numbers and names.
+func (c *fileConv) reserved(reserved []string) {
+	if len(reserved) > 0 {
+		c.printf("@reserved(%s)\n", strings.Join(reserved, ", "))
+	}
+}
+
+func (c *fileConv) message(scope string, m *message) {
+	full := scope + "." + m.name
+	sym := c.symbols[full]
+	c.doc(&m.node)
+	c.options(m.options)
+	c.reserved(m.reserved)
+	c.printf("struct %s {", sym.name)
+	c.trail(&m.node)
+	for _, f := range m.fields {
+		c.field(full, f)
+	}
+	c.b.WriteString("}\n\n")
+	for _, decl := range m.decls {
+		c.decl(full, decl)
+	}
+}
+
+func (c *fileConv) field(scope string, f *field) {
+	typ, optional, ok := c.fieldType(scope, f)
+	if !ok {
+		return
+	}
+	c.doc(&f.node)
+	name := f.name
+	annotations := []string{fmt.Sprintf("@id(%d)", f.number)}
+	if names.IsKeyword(name) && !hasOption(f.options, "json_name") {
+		annotations = append(annotations, fmt.Sprintf("@json(%q)", name))
+		name += "_"
+	}
+	if f.oneof != "" {
+		annotations = append(annotations, fmt.Sprintf("@oneof(%q)", f.oneof))
+		optional = true
+	}
+	if f.label == "optional" {
+		optional = true
+	}
+	c.printf("%s\n", strings.Join(annotations, " "))
+	for _, opt := range f.options {
+		if opt.name == "default" {
+			if value, ok := c.defaultValue(scope, f, opt); ok {
+				c.printf("@default(%s)\n", value)
+			}
+			continue
+		}
+		c.options([]*option{opt})
+	}
+	mark := ""
+	if optional {
+		mark = "?"
+	}
+	c.printf("%s%s: %s", name, mark, typ)
+	c.trail(&f.node)
+}
+
+// defaultValue returns the value of the default option of a field; the
+// default of an enum field is the Lark name of the member.
+func (c *fileConv) defaultValue(scope string, f *field, opt *option) (string, bool) {
+	if opt.value.kind == tokIdent && opt.value.text != "true" && opt.value.text != "false" {
+		if _, sym := c.resolve(scope, f.typ); sym != nil {
+			if e, ok := sym.decl.(*enum); ok {
+				names := valueNames(e)
+				for i, v := range e.values {
+					if v.name == opt.value.text {
+						return strconv.Quote(names[i]), true
+					}
+				}
+			}
+		}
+	}
+	return c.constant(opt)
+}
+
+// fieldType returns the Lark type of a field, and whether the field is
+// optional because of its type.
+func (c *fileConv) fieldType(scope string, f *field) (string, bool, bool) {
+	if f.label == "map" {
+		key, ok := scalars[f.key]
+		if !ok || f.key == "double" || f.key == "float" || f.key == "bytes" {
+			c.warnf(f.pos, "field %s is dropped: protobuf does not allow %s map keys", f.name, f.key)
+			return "", false, false
+		}
+		value, _, ok := c.typ(scope, f.typ, f.pos, "field "+f.name)
+		if !ok {
+			return "", false, false
+		}
+		return fmt.Sprintf("map[%s, %s]", key, value), false, true
+	}
+	typ, presence, ok := c.typ(scope, f.typ, f.pos, "field "+f.name)
+	if !ok {
+		return "", false, false
+	}
+	if f.label == "repeated" {
+		return "list[" + typ + "]", false, true
+	}
+	return typ, presence && f.label != "required", true
+}
+
+// typ returns the Lark type for a protobuf type, and whether values of the
+// type have presence, which they have for messages. what describes the
+// user of the type in diagnostics.
+func (c *fileConv) typ(scope, name string, pos diag.Pos, what string) (string, bool, bool) {
+	if typ, ok := scalars[name]; ok {
+		return typ, false, true
+	}
+	full, sym := c.resolve(scope, name)
+	switch {
+	case full == timestampType:
+		return "timestamp", true, true
+	case wrappers[full] != "":
+		return wrappers[full], true, true
+	case wellKnown(full):
+		c.warnf(pos, "%s is dropped: Lark has no type for %s", what, full[1:])
+		return "", false, false
+	case sym == nil:
+		c.errorf(pos, "%s is dropped: undefined type %s", what, name)
+		return "", false, false
+	}
+	_, isMessage := sym.decl.(*message)
+	return c.ref(sym), isMessage, true
+}
+
+func (c *fileConv) enum(scope string, e *enum) {
+	sym := c.symbols[scope+"."+e.name]
+	c.doc(&e.node)
+	c.options(e.options, "allow_alias")
+	c.reserved(e.reserved)
+	c.printf("enum %s {", sym.name)
+	c.trail(&e.node)
+	names := valueNames(e)
+	seen := make(map[int64]string)
+	for i, v := range e.values {
+		if first, ok := seen[v.number]; ok {
+			c.warnf(v.pos, "enum value %s is dropped: it is an alias of %s, and Lark enum values are distinct", v.name, first)
+			continue
+		}
+		seen[v.number] = v.name
+		if v.number < math.MinInt32 || v.number > math.MaxInt32 {
+			c.warnf(v.pos, "enum value %s is dropped: %d is not an int32", v.name, v.number)
+			continue
+		}
+		c.doc(&v.node)
+		c.options(v.options)
+		c.printf("%s = %d", names[i], v.number)
+		c.trail(&v.node)
+	}
+	c.b.WriteString("}\n\n")
+}
+
+func (c *fileConv) service(scope string, s *service) {
+	c.doc(&s.node)
+	c.options(s.options)
+	c.printf("interface %s {", declName(s.name))
+	c.trail(&s.node)
+	for _, m := range s.methods {
+		c.method(scope, m)
+	}
+	c.b.WriteString("}\n\n")
+}
+
+func (c *fileConv) method(scope string, m *method) {
+	if m.inputStream || m.outputStream {
+		c.warnf(m.pos, "streaming of rpc %s is dropped: Lark methods are unary", m.name)
+	}
+	param, result := "", ""
+	if full, _ := c.resolve(scope, m.input); full != emptyType {
+		typ, _, ok := c.typ(scope, m.input, m.pos, "request of rpc "+m.name)
+		if ok {
+			param = "request: " + typ
+		}
+	}
+	if full, _ := c.resolve(scope, m.output); full != emptyType {
+		typ, _, ok := c.typ(scope, m.output, m.pos, "response of rpc "+m.name)
+		if ok {
+			result = " -> " + typ
+		}
+	}
+	c.doc(&m.node)
+	c.options(m.options)
+	c.printf("func %s(%s)%s", methodName(m.name), param, result)
+	c.trail(&m.node)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOption(options []*option, name string) bool {
+	for _, opt := range options {
+		if opt.name == name {
+			return true
+		}
+	}
+	return false
+}
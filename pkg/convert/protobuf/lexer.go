@@ -0,0 +1,309 @@
+package protobuf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokSymbol
+)
+
+// A token is a token of a .proto file with the comments around it.
+type token struct {
+	kind  tokenKind
+	text  string // source text; the value of strings
+	pos   diag.Pos
+	line  int // line of the end of the token
+	cmt   comments
+	trail *string // trailing comment of the token, set when it is scanned
+}
+
+// comments are the comments before a token.
+type comments struct {
+	detached [][]string // groups separated from the token by blank lines
+	leading  []string   // group that ends on the line before the token
+}
+
+type lexer struct {
+	src      []byte
+	offset   int
+	pos      diag.Pos
+	prev     *token // last token
+	groups   []group
+	err      func(pos diag.Pos, format string, args ...any)
+	lastLine int // line of the end of the last comment or token
+}
+
+// A group is a group of adjacent comments.
+type group struct {
+	lines []string
+	end   int // line of the last comment
+}
+
+func newLexer(src []byte, errf func(pos diag.Pos, format string, args ...any)) *lexer {
+	return &lexer{src: src, err: errf, lastLine: -1}
+}
+
+func (l *lexer) peekByte(i int) byte {
+	if l.offset+i < len(l.src) {
+		return l.src[l.offset+i]
+	}
+	return 0
+}
+
+// advance moves past n bytes, which do not contain line breaks unless
+// they are a single '\n'.
+func (l *lexer) advance(n int) {
+	for i := 0; i < n; {
+		r, size := utf8.DecodeRune(l.src[l.offset:])
+		l.offset += size
+		i += size
+		if r == '\n' {
+			l.pos.Line++
+			l.pos.Column = 0
+		} else {
+			l.pos.Column++
+		}
+	}
+}
+
+// next returns the next token.
+func (l *lexer) next() *token {
+	for {
+		c := l.peekByte(0)
+		switch {
+		case l.offset >= len(l.src):
+			tok := &token{kind: tokEOF, pos: l.pos, line: l.pos.Line}
+			tok.cmt = l.take(tok.pos.Line)
+			return tok
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == '\v':
+			l.advance(1)
+		case c == '/' && l.peekByte(1) == '/':
+			l.comment(false)
+		case c == '/' && l.peekByte(1) == '*':
+			l.comment(true)
+		default:
+			return l.token()
+		}
+	}
+}
+
+func (l *lexer) comment(block bool) {
+	start := l.pos
+	begin := l.offset
+	var text string
+	if block {
+		end := strings.Index(string(l.src[l.offset+2:]), "*/")
+		if end < 0 {
+			l.err(start, "unterminated comment")
+			end = len(l.src) - l.offset - 2
+			l.advance(len(l.src) - l.offset)
+		} else {
+			l.advance(end + 4)
+		}
+		text = string(l.src[begin+2 : begin+2+end])
+	} else {
+		end := strings.IndexByte(string(l.src[l.offset:]), '\n')
+		if end < 0 {
+			end = len(l.src) - l.offset
+		}
+		l.advance(end)
+		text = string(l.src[begin+2 : begin+end])
+	}
+	lines := commentLines(text, block)
+
+	// A comment on the line of the previous token trails it.
+	if l.prev != nil && l.prev.line == start.Line && l.prev.trail == nil && len(l.groups) == 0 {
+		s := strings.Join(lines, " ")
+		l.prev.trail = &s
+		l.lastLine = l.pos.Line
+		return
+	}
+	if n := len(l.groups); n > 0 && !block && l.groups[n-1].end == start.Line-1 && l.lastLine == start.Line-1 {
+		l.groups[n-1].lines = append(l.groups[n-1].lines, lines...)
+		l.groups[n-1].end = l.pos.Line
+	} else {
+		l.groups = append(l.groups, group{lines: lines, end: l.pos.Line})
+	}
+	l.lastLine = l.pos.Line
+}
+
+// commentLines returns the lines of the text of a comment, without the
+// decoration of block comments.
+func commentLines(text string, block bool) []string {
+	if !block {
+		return []string{strings.TrimRight(strings.TrimPrefix(text, " "), " \t\r")}
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimPrefix(strings.TrimPrefix(line, "*"), " ")
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// take returns the comments before a token on the given line.
+func (l *lexer) take(line int) comments {
+	var c comments
+	for i, g := range l.groups {
+		if i == len(l.groups)-1 && (g.end == line-1 || g.end == line) {
+			c.leading = g.lines
+		} else if len(g.lines) > 0 {
+			c.detached = append(c.detached, g.lines)
+		}
+	}
+	l.groups = nil
+	return c
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) token() *token {
+	tok := &token{pos: l.pos}
+	tok.cmt = l.take(tok.pos.Line)
+	begin := l.offset
+	c := l.peekByte(0)
+	switch {
+	case isLetter(c):
+		n := 1
+		for isLetter(l.peekByte(n)) || isDigit(l.peekByte(n)) {
+			n++
+		}
+		l.advance(n)
+		tok.kind, tok.text = tokIdent, string(l.src[begin:l.offset])
+	case isDigit(c) || c == '.' && isDigit(l.peekByte(1)):
+		n := 1
+		for {
+			b := l.peekByte(n)
+			if (b == '+' || b == '-') && (l.peekByte(n-1) == 'e' || l.peekByte(n-1) == 'E') && !strings.HasPrefix(strings.ToLower(string(l.src[begin:])), "0x") {
+				n++
+				continue
+			}
+			if !isLetter(b) && !isDigit(b) && b != '.' {
+				break
+			}
+			n++
+		}
+		l.advance(n)
+		tok.text = string(l.src[begin:l.offset])
+		tok.kind = tokInt
+		if _, err := strconv.ParseUint(tok.text, 0, 64); err != nil {
+			tok.kind = tokFloat
+			if _, err := strconv.ParseFloat(tok.text, 64); err != nil {
+				l.err(tok.pos, "invalid number %s", tok.text)
+			}
+		}
+	case c == '"' || c == '\'':
+		tok.kind, tok.text = tokString, l.str(c)
+	default:
+		_, size := utf8.DecodeRune(l.src[l.offset:])
+		l.advance(size)
+		tok.kind, tok.text = tokSymbol, string(l.src[begin:l.offset])
+	}
+	tok.line = l.pos.Line
+	l.prev = tok
+	l.lastLine = l.pos.Line
+	return tok
+}
+
+// str scans a string literal and returns its value.
+func (l *lexer) str(quote byte) string {
+	start := l.pos
+	l.advance(1)
+	var b strings.Builder
+	for {
+		c := l.peekByte(0)
+		switch {
+		case l.offset >= len(l.src) || c == '\n':
+			l.err(start, "unterminated string")
+			return b.String()
+		case c == quote:
+			l.advance(1)
+			return b.String()
+		case c == '\\':
+			l.escape(&b)
+		default:
+			_, size := utf8.DecodeRune(l.src[l.offset:])
+			b.Write(l.src[l.offset : l.offset+size])
+			l.advance(size)
+		}
+	}
+}
+
+func (l *lexer) escape(b *strings.Builder) {
+	pos := l.pos
+	c := l.peekByte(1)
+	l.advance(2)
+	switch c {
+	case 'a':
+		b.WriteByte('\a')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'v':
+		b.WriteByte('\v')
+	case '\\', '\'', '"', '?':
+		b.WriteByte(c)
+	case 'x', 'X':
+		b.WriteByte(byte(l.digits(16, 2)))
+	case 'u':
+		b.WriteRune(rune(l.digits(16, 4)))
+	case 'U':
+		b.WriteRune(rune(l.digits(16, 8)))
+	default:
+		if c >= '0' && c <= '7' {
+			l.offset--
+			l.pos.Column--
+			b.WriteByte(byte(l.digits(8, 3)))
+			return
+		}
+		l.err(pos, "unknown escape sequence")
+	}
+}
+
+// digits scans up to max digits of a number in the given base.
+func (l *lexer) digits(base, max int) int {
+	x := 0
+	for n := 0; n < max; n++ {
+		d, err := strconv.ParseUint(string(l.peekByte(0)), base, 8)
+		if err != nil {
+			break
+		}
+		x = x*base + int(d)
+		l.advance(1)
+	}
+	return x
+}
@@ -0,0 +1,74 @@
+package protobuf
+
+import (
+	"strings"
+
+	"larklang.io/lark/internal/names"
+	"larklang.io/lark/pkg/gen"
+)
+
+// identifier turns name into an identifier by replacing the characters
+// that cannot appear in identifiers with underscores.
+func identifier(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !isLetter(c) && !isDigit(c) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || isDigit(b[0]) || names.IsKeyword(string(b)) {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// declName returns the name of a top-level declaration for a message, an
+// enum or a service. Names that are keywords or built-in type names get an
+// underscore appended.
+func declName(name string) string {
+	if names.IsKeyword(name) || names.IsBuiltinType(name) {
+		return name + "_"
+	}
+	return name
+}
+
+// methodName returns the name of the method for an rpc in snake case:
+// "GetUser" becomes "get_user".
+func methodName(name string) string {
+	words := gen.Words(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// valueNames returns the names of the members for the values of an enum.
+// The values are lowercased, without the prefix that protobuf style derives
+// from the name of the enum if all of them have it: ROLE_ADMIN of Role
+// becomes admin.
+func valueNames(e *enum) []string {
+	words := gen.Words(e.name)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word)
+	}
+	prefix := strings.Join(words, "_") + "_"
+	strip := true
+	for _, v := range e.values {
+		rest, ok := strings.CutPrefix(v.name, prefix)
+		if !ok || !names.IsIdent(strings.ToLower(rest)) {
+			strip = false
+		}
+	}
+	list := make([]string, len(e.values))
+	for i, v := range e.values {
+		name := v.name
+		if strip {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		list[i] = strings.ToLower(name)
+		if names.IsKeyword(list[i]) {
+			list[i] += "_"
+		}
+	}
+	return list
+}
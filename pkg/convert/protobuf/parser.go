@@ -0,0 +1,646 @@
+package protobuf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+// The syntax tree of a .proto file keeps what the conversion needs: names,
+// types, numbers, options and comments, in source order.
+
+type protoFile struct {
+	path     string
+	syntax   string // "proto2", "proto3" or "editions"
+	pkg      string
+	doc      []string   // comment that starts the file
+	detached [][]string // comments of statements without a Lark counterpart
+	imports  []*protoImport
+	options  []*option
+	decls    []any // *message, *enum, *service
+}
+
+type protoImport struct {
+	path string
+	pos  diag.Pos
+	cmt  comments
+}
+
+// A node holds the position and comments of a declaration.
+type node struct {
+	name  string
+	pos   diag.Pos
+	cmt   comments
+	trail string
+}
+
+type message struct {
+	node
+	fields   []*field
+	decls    []any // nested *message and *enum
+	options  []*option
+	reserved []string
+}
+
+type field struct {
+	node
+	label   string // "optional", "required", "repeated" or ""
+	typ     string // type name, or "map"
+	key     string // map key type
+	number  int64
+	oneof   string
+	options []*option
+}
+
+type enum struct {
+	node
+	values   []*enumValue
+	options  []*option
+	reserved []string
+}
+
+type enumValue struct {
+	node
+	number  int64
+	options []*option
+}
+
+type service struct {
+	node
+	methods []*method
+	options []*option
+}
+
+type method struct {
+	node
+	input, output             string
+	inputStream, outputStream bool
+	options                   []*option
+}
+
+type option struct {
+	name  string // with parentheses for custom options
+	value value
+	pos   diag.Pos
+}
+
+type value struct {
+	kind tokenKind // tokIdent, tokInt, tokFloat or tokString; tokEOF for aggregates
+	text string    // identifier or literal text, or value of strings
+	neg  bool
+}
+
+// bailout stops a parse at the first syntax error.
+type bailout struct{}
+
+type parser struct {
+	lex   *lexer
+	tok   *token
+	prev  *token
+	diags []diag.Diagnostic
+	warn  func(pos diag.Pos, format string, args ...any)
+}
+
+// parse parses a .proto file. It returns a nil file after a syntax error.
+func parse(path string, src []byte) (file *protoFile, diags []diag.Diagnostic) {
+	p := &parser{}
+	errf := func(pos diag.Pos, format string, args ...any) {
+		p.diags = append(p.diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Code:     diag.InvalidInput,
+			Range:    diag.At(pos),
+			Message:  fmt.Sprintf(format, args...),
+		})
+		panic(bailout{})
+	}
+	p.warn = func(pos diag.Pos, format string, args ...any) {
+		p.diags = append(p.diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Code:     diag.Untranslatable,
+			Range:    diag.At(pos),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	p.lex = newLexer(src, errf)
+	defer func() {
+		diags = p.diags
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			file = nil
+		}
+	}()
+	p.next()
+	return p.file(path), p.diags
+}
+
+func (p *parser) next() {
+	p.prev = p.tok
+	p.tok = p.lex.next()
+}
+
+func (p *parser) errorf(pos diag.Pos, format string, args ...any) {
+	p.lex.err(pos, format, args...)
+}
+
+// describe returns a description of the current token for errors.
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return "string " + strconv.Quote(p.tok.text)
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *parser) is(text string) bool {
+	return p.tok.kind != tokString && p.tok.text == text
+}
+
+func (p *parser) got(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) *token {
+	tok := p.tok
+	if !p.is(text) {
+		p.errorf(tok.pos, "expected %q, found %s", text, p.describe())
+	}
+	p.next()
+	return tok
+}
+
+func (p *parser) ident() string {
+	if p.tok.kind != tokIdent {
+		p.errorf(p.tok.pos, "expected a name, found %s", p.describe())
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+// fullIdent parses a dotted name, with a leading dot if it is absolute.
+func (p *parser) fullIdent() string {
+	var b strings.Builder
+	if p.got(".") {
+		b.WriteByte('.')
+	}
+	b.WriteString(p.ident())
+	for p.got(".") {
+		b.WriteByte('.')
+		b.WriteString(p.ident())
+	}
+	return b.String()
+}
+
+func (p *parser) str() string {
+	if p.tok.kind != tokString {
+		p.errorf(p.tok.pos, "expected a string, found %s", p.describe())
+	}
+	var b strings.Builder
+	for p.tok.kind == tokString {
+		b.WriteString(p.tok.text)
+		p.next()
+	}
+	return b.String()
+}
+
+func (p *parser) integer() int64 {
+	pos := p.tok.pos
+	neg := p.got("-")
+	if p.tok.kind != tokInt {
+		p.errorf(p.tok.pos, "expected an integer, found %s", p.describe())
+	}
+	n, err := strconv.ParseInt(p.tok.text, 0, 64)
+	if err != nil {
+		p.errorf(pos, "integer %s is out of range", p.tok.text)
+	}
+	p.next()
+	if neg {
+		n = -n
+	}
+	return n
+}
+
+// node starts a declaration at the current token.
+func (p *parser) node() node {
+	return node{pos: p.tok.pos, cmt: p.tok.cmt}
+}
+
+// end ends a declaration at the previous token, which can have a trailing
+// comment.
+func (p *parser) end(n *node) {
+	if p.prev.trail != nil {
+		n.trail = *p.prev.trail
+	}
+}
+
+// semi skips an empty statement.
+func (p *parser) semi() bool {
+	return p.got(";")
+}
+
+func (p *parser) file(path string) *protoFile {
+	file := &protoFile{path: path, syntax: "proto2"}
+	first := true
+	for p.tok.kind != tokEOF {
+		// The first comment documents the file unless it is attached to
+		// the first declaration. Comments of the statements that have no
+		// Lark counterpart are kept as free comments.
+		cmt := p.tok.cmt
+		if first && len(cmt.detached) > 0 {
+			file.doc, cmt.detached = cmt.detached[0], cmt.detached[1:]
+			p.tok.cmt = cmt
+		}
+		if p.is("syntax") || p.is("edition") || p.is("package") || p.is("option") {
+			if first && file.doc == nil {
+				file.doc, cmt.leading = cmt.leading, nil
+			}
+			file.detached = append(file.detached, cmt.detached...)
+			if cmt.leading != nil {
+				file.detached = append(file.detached, cmt.leading)
+			}
+		}
+		first = false
+		switch {
+		case p.semi():
+		case p.is("syntax"):
+			p.next()
+			p.expect("=")
+			pos := p.tok.pos
+			file.syntax = p.str()
+			if file.syntax != "proto2" && file.syntax != "proto3" {
+				p.errorf(pos, "unknown syntax %q", file.syntax)
+			}
+			p.expect(";")
+		case p.is("edition"):
+			pos := p.tok.pos
+			p.next()
+			p.expect("=")
+			edition := p.str()
+			p.expect(";")
+			file.syntax = "editions"
+			p.warn(pos, "edition %s is converted like proto3: features are dropped", edition)
+		case p.is("package"):
+			pos := p.tok.pos
+			p.next()
+			file.pkg = p.fullIdent()
+			p.expect(";")
+			p.warn(pos, "package %s is dropped: Lark modules are named by their file path", file.pkg)
+		case p.is("import"):
+			imp := &protoImport{pos: p.tok.pos, cmt: p.tok.cmt}
+			p.next()
+			if p.is("public") {
+				p.warn(p.tok.pos, "public import is converted to a plain import: Lark modules do not re-export their imports")
+				p.next()
+			} else if p.is("weak") {
+				p.next()
+			}
+			imp.path = p.str()
+			p.expect(";")
+			file.imports = append(file.imports, imp)
+		case p.is("option"):
+			file.options = append(file.options, p.option())
+		case p.is("message"):
+			file.decls = append(file.decls, p.message())
+		case p.is("enum"):
+			file.decls = append(file.decls, p.enum())
+		case p.is("service"):
+			file.decls = append(file.decls, p.service())
+		case p.is("extend"):
+			p.extend()
+		default:
+			p.errorf(p.tok.pos, "unexpected %s", p.describe())
+		}
+	}
+	return file
+}
+
+// option parses an option statement.
+func (p *parser) option() *option {
+	p.expect("option")
+	opt := p.optionBody()
+	p.expect(";")
+	return opt
+}
+
+// optionBody parses name = value.
+func (p *parser) optionBody() *option {
+	opt := &option{pos: p.tok.pos}
+	var b strings.Builder
+	if p.got("(") {
+		b.WriteString("(" + p.fullIdent() + ")")
+		p.expect(")")
+	} else {
+		b.WriteString(p.ident())
+	}
+	for p.got(".") {
+		if p.got("(") {
+			b.WriteString(".(" + p.fullIdent() + ")")
+			p.expect(")")
+		} else {
+			b.WriteString("." + p.ident())
+		}
+	}
+	opt.name = b.String()
+	p.expect("=")
+	opt.value = p.value()
+	return opt
+}
+
+// value parses a constant, skipping aggregates.
+func (p *parser) value() value {
+	switch {
+	case p.is("{"):
+		p.skipBlock()
+		return value{kind: tokEOF}
+	case p.tok.kind == tokString:
+		return value{kind: tokString, text: p.str()}
+	}
+	neg := p.got("-")
+	if !neg {
+		p.got("+")
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokIdent, tokInt, tokFloat:
+		p.next()
+		return value{kind: tok.kind, text: tok.text, neg: neg}
+	}
+	p.errorf(tok.pos, "expected a constant, found %s", p.describe())
+	return value{}
+}
+
+// skipBlock skips a block in braces.
+func (p *parser) skipBlock() {
+	p.expect("{")
+	for depth := 1; depth > 0; p.next() {
+		switch {
+		case p.tok.kind == tokEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found end of file")
+		case p.is("{"):
+			depth++
+		case p.is("}"):
+			depth--
+		}
+	}
+}
+
+// fieldOptions parses the options in brackets after a field or an enum
+// value.
+func (p *parser) fieldOptions() []*option {
+	var options []*option
+	if p.got("[") {
+		for {
+			options = append(options, p.optionBody())
+			if !p.got(",") {
+				break
+			}
+		}
+		p.expect("]")
+	}
+	return options
+}
+
+// ranges parses the ranges and names of reserved and extensions
+// statements.
+func (p *parser) ranges() []string {
+	var ranges []string
+	for {
+		if p.tok.kind == tokString {
+			ranges = append(ranges, strconv.Quote(p.str()))
+		} else if p.tok.kind == tokIdent {
+			ranges = append(ranges, strconv.Quote(p.ident()))
+		} else {
+			lo := p.integer()
+			r := strconv.FormatInt(lo, 10)
+			if p.got("to") {
+				if p.got("max") {
+					r = strconv.Quote(r + " to max")
+				} else {
+					r = strconv.Quote(r + " to " + strconv.FormatInt(p.integer(), 10))
+				}
+			}
+			ranges = append(ranges, r)
+		}
+		if !p.got(",") {
+			break
+		}
+	}
+	p.fieldOptions()
+	p.expect(";")
+	return ranges
+}
+
+func (p *parser) message() *message {
+	m := &message{node: p.node()}
+	p.expect("message")
+	m.name = p.ident()
+	p.expect("{")
+	p.end(&m.node)
+	for !p.got("}") {
+		p.messageMember(m, "")
+	}
+	return m
+}
+
+// messageMember parses a member of a message or of a oneof in it.
+func (p *parser) messageMember(m *message, oneof string) {
+	switch {
+	case p.tok.kind == tokEOF:
+		p.errorf(p.tok.pos, "expected \"}\", found end of file")
+	case p.semi():
+	case p.is("option"):
+		opt := p.option()
+		if oneof != "" {
+			p.warn(opt.pos, "option %s of oneof %s is dropped", opt.name, oneof)
+			return
+		}
+		m.options = append(m.options, opt)
+	case oneof == "" && p.is("message"):
+		m.decls = append(m.decls, p.message())
+	case oneof == "" && p.is("enum"):
+		m.decls = append(m.decls, p.enum())
+	case oneof == "" && p.is("reserved"):
+		p.next()
+		m.reserved = append(m.reserved, p.ranges()...)
+	case oneof == "" && p.is("extensions"):
+		pos := p.tok.pos
+		p.next()
+		p.ranges()
+		p.warn(pos, "extension ranges of %s are dropped: Lark has no extensions", m.name)
+	case oneof == "" && p.is("extend"):
+		p.extend()
+	case oneof == "" && p.is("oneof"):
+		pos := p.tok.pos
+		p.next()
+		name := p.ident()
+		p.expect("{")
+		n := len(m.fields)
+		for !p.got("}") {
+			p.messageMember(m, name)
+		}
+		if len(m.fields) == n {
+			p.warn(pos, "oneof %s has no fields and is dropped", name)
+		}
+	default:
+		f := p.field(m)
+		if f != nil {
+			f.oneof = oneof
+			m.fields = append(m.fields, f)
+		}
+	}
+}
+
+// field parses a field. It returns nil for groups, which are dropped.
+func (p *parser) field(m *message) *field {
+	f := &field{node: p.node()}
+	if p.is("optional") || p.is("required") || p.is("repeated") {
+		f.label = p.tok.text
+		p.next()
+	}
+	if p.is("group") {
+		pos := p.tok.pos
+		p.next()
+		name := p.ident()
+		p.expect("=")
+		p.integer()
+		p.fieldOptions()
+		p.skipBlock()
+		p.warn(pos, "group %s of %s is dropped: use a nested message instead", name, m.name)
+		return nil
+	}
+	if p.is("map") {
+		p.next()
+		p.expect("<")
+		f.key = p.fullIdent()
+		p.expect(",")
+		f.typ = p.fullIdent()
+		p.expect(">")
+		f.label = "map"
+	} else {
+		f.typ = p.fullIdent()
+	}
+	f.name = p.ident()
+	p.expect("=")
+	f.number = p.integer()
+	f.options = p.fieldOptions()
+	p.expect(";")
+	p.end(&f.node)
+	return f
+}
+
+func (p *parser) enum() *enum {
+	e := &enum{node: p.node()}
+	p.expect("enum")
+	e.name = p.ident()
+	p.expect("{")
+	p.end(&e.node)
+	for !p.got("}") {
+		switch {
+		case p.tok.kind == tokEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found end of file")
+		case p.semi():
+		case p.is("option"):
+			e.options = append(e.options, p.option())
+		case p.is("reserved"):
+			p.next()
+			e.reserved = append(e.reserved, p.ranges()...)
+		default:
+			v := &enumValue{node: p.node()}
+			v.name = p.ident()
+			p.expect("=")
+			v.number = p.integer()
+			v.options = p.fieldOptions()
+			p.expect(";")
+			p.end(&v.node)
+			e.values = append(e.values, v)
+		}
+	}
+	return e
+}
+
+func (p *parser) service() *service {
+	s := &service{node: p.node()}
+	p.expect("service")
+	s.name = p.ident()
+	p.expect("{")
+	p.end(&s.node)
+	for !p.got("}") {
+		switch {
+		case p.tok.kind == tokEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found end of file")
+		case p.semi():
+		case p.is("option"):
+			s.options = append(s.options, p.option())
+		default:
+			s.methods = append(s.methods, p.method())
+		}
+	}
+	return s
+}
+
+func (p *parser) method() *method {
+	m := &method{node: p.node()}
+	p.expect("rpc")
+	m.name = p.ident()
+	m.input, m.inputStream = p.methodType()
+	p.expect("returns")
+	m.output, m.outputStream = p.methodType()
+	if p.is("{") {
+		p.next()
+		for !p.got("}") {
+			switch {
+			case p.tok.kind == tokEOF:
+				p.errorf(p.tok.pos, "expected \"}\", found end of file")
+			case p.semi():
+			default:
+				m.options = append(m.options, p.option())
+			}
+		}
+		p.got(";")
+	} else {
+		p.expect(";")
+	}
+	p.end(&m.node)
+	return m
+}
+
+// methodType parses the request or response type of a method.
+func (p *parser) methodType() (string, bool) {
+	p.expect("(")
+	stream := false
+	// "stream" can be the name of a message, as in (stream) or (stream.X).
+	if p.is("stream") {
+		p.next()
+		if p.is(")") || p.is(".") {
+			name := "stream"
+			if p.got(".") {
+				name += "." + p.fullIdent()
+			}
+			p.expect(")")
+			return name, false
+		}
+		stream = true
+	}
+	typ := p.fullIdent()
+	p.expect(")")
+	return typ, stream
+}
+
+// extend skips an extend block, which Lark cannot express.
+func (p *parser) extend() {
+	pos := p.tok.pos
+	p.expect("extend")
+	name := p.fullIdent()
+	p.skipBlock()
+	p.warn(pos, "extension of %s is dropped: Lark has no extensions", name)
+}
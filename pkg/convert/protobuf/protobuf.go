@@ -0,0 +1,260 @@
+// Package protobuf converts protobuf definitions, proto2 and proto3, to
+// Lark source.
+//
+// Every .proto file becomes a Lark file with the same path and the .lark
+// extension, and every construct its closest Lark counterpart:
+//
+//   - messages become structs; nested messages and enums are declared at
+//     the top level under the name of their parent followed by their own
+//     name, so that Outer.Inner becomes OuterInner
+//   - fields keep their number in @id(n); repeated fields become lists and
+//     map fields maps; fields with explicit presence (optional fields,
+//     message fields and members of a oneof) are optional, and the members
+//     of a oneof are marked with @oneof("name")
+//   - enums become enums; the prefix that protobuf style puts on the values
+//     is removed and the names are lowercased, so that ROLE_ADMIN of Role
+//     becomes admin
+//   - services become interfaces, with one method per rpc that takes the
+//     request as its parameter; google.protobuf.Empty stands for no
+//     parameter or no result
+//   - the option deprecated becomes @deprecated, json_name becomes @json
+//     and default becomes @default; other options become annotations of
+//     their name, so that [packed = true] becomes @packed(true); reserved
+//     numbers and names become @reserved
+//   - google.protobuf.Timestamp becomes timestamp, and wrapper types such
+//     as google.protobuf.Int32Value optional primitives
+//   - leading comments become doc comments; trailing and detached
+//     comments are kept as comments
+//
+// The result is printed by the Lark formatter. Constructs that Lark cannot
+// express, such as packages, extensions, groups, custom options, streaming
+// and file options, are reported as warnings with their position in the
+// .proto file, as are types that cannot be resolved.
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+// A Config controls how files are converted.
+type Config struct {
+	// Path lists the directories that protobuf imports are relative to, as
+	// with the -I flag of protoc. Imported files that are not among the
+	// sources are read from them to resolve types. The import path of a
+	// source in one of the directories is relative to it.
+	Path []string
+
+	// ReadFile reads a file; nil means os.ReadFile.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// A Source names a .proto file to convert. If Src is nil, the file is read
+// from Path.
+type Source struct {
+	Path string
+	Src  []byte
+}
+
+// A File is the Lark file converted from a source.
+type File struct {
+	Source string // path of the source
+	Path   string // slash-separated import path with the .lark extension
+
+	// Content is the formatted Lark source. It is nil if the source has
+	// syntax errors.
+	Content []byte
+
+	// Lines holds the lines of the source, for rendering the diagnostics,
+	// whose positions refer to the source.
+	Lines       []string
+	Diagnostics []diag.Diagnostic
+}
+
+// Convert converts the sources. Problems of the sources are reported as
+// diagnostics of the files; Convert fails only if a source cannot be read.
+func (c *Config) Convert(sources ...Source) ([]*File, error) {
+	cv := &converter{
+		config:  c,
+		files:   make(map[string]*protoFile),
+		symbols: make(map[string]*symbol),
+	}
+	var files []*File
+	var parsed []*protoFile
+	for _, source := range sources {
+		src := source.Src
+		if src == nil {
+			var err error
+			if src, err = c.read(source.Path); err != nil {
+				return nil, err
+			}
+		}
+		importPath := c.importPath(source.Path)
+		file, diags := parse(importPath, src)
+		files = append(files, &File{
+			Source:      source.Path,
+			Path:        strings.TrimSuffix(importPath, ".proto") + ".lark",
+			Lines:       strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n"),
+			Diagnostics: diags,
+		})
+		parsed = append(parsed, file)
+		if file != nil {
+			cv.files[importPath] = file
+		}
+	}
+	for _, file := range parsed {
+		if file != nil {
+			cv.load(file)
+		}
+	}
+	for i, file := range parsed {
+		if file == nil {
+			continue
+		}
+		var err error
+		files[i].Content, files[i].Diagnostics, err = cv.convert(file, files[i].Diagnostics)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (c *Config) read(name string) ([]byte, error) {
+	if c.ReadFile != nil {
+		return c.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// importPath returns the import path of a source: its path relative to the
+// first directory of c.Path that contains it, or its path as given.
+func (c *Config) importPath(name string) string {
+	for _, dir := range c.Path {
+		rel, err := filepath.Rel(dir, name)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// A symbol is a message or an enum.
+type symbol struct {
+	file *protoFile
+	name string // name of the Lark declaration
+	decl any    // *message or *enum
+}
+
+type converter struct {
+	config  *Config
+	files   map[string]*protoFile // by import path
+	symbols map[string]*symbol    // by full name, such as ".api.User.Role"
+	names   map[*protoFile]map[string]bool
+}
+
+// load declares the symbols of a file and loads the files it imports.
+func (cv *converter) load(file *protoFile) {
+	if cv.names == nil {
+		cv.names = make(map[*protoFile]map[string]bool)
+	}
+	if cv.names[file] != nil {
+		return
+	}
+	cv.names[file] = make(map[string]bool)
+	scope := ""
+	if file.pkg != "" {
+		scope = "." + file.pkg
+	}
+	cv.declare(file, scope, "", file.decls)
+	for _, imp := range file.imports {
+		if imported := cv.open(imp.path); imported != nil {
+			cv.load(imported)
+		}
+	}
+}
+
+// open returns an imported file, reading it from the config path if it is
+// not among the sources.
+func (cv *converter) open(importPath string) *protoFile {
+	if file, ok := cv.files[importPath]; ok {
+		return file
+	}
+	cv.files[importPath] = nil
+	if wellKnown(importPath) {
+		return nil
+	}
+	for _, dir := range cv.config.Path {
+		src, err := cv.config.read(filepath.Join(dir, filepath.FromSlash(importPath)))
+		if err != nil {
+			continue
+		}
+		// Errors in imported files are reported when they are converted
+		// themselves.
+		file, _ := parse(importPath, src)
+		cv.files[importPath] = file
+		return file
+	}
+	return nil
+}
+
+// declare declares nested messages and enums under the Lark name of their
+// parent.
+func (cv *converter) declare(file *protoFile, scope, parent string, decls []any) {
+	for _, decl := range decls {
+		var name string
+		var nested []any
+		switch decl := decl.(type) {
+		case *message:
+			name, nested = decl.name, decl.decls
+		case *enum:
+			name = decl.name
+		default:
+			continue
+		}
+		lark := parent + name
+		if parent == "" {
+			lark = declName(name)
+		}
+		if parent != "" && cv.names[file][lark] {
+			lark = parent + "_" + name
+		}
+		for i := 2; cv.names[file][lark]; i++ {
+			lark = fmt.Sprintf("%s%s%d", parent, name, i)
+		}
+		cv.names[file][lark] = true
+		full := scope + "." + name
+		cv.symbols[full] = &symbol{file: file, name: lark, decl: decl}
+		cv.declare(file, full, lark, nested)
+	}
+}
+
+// resolve resolves a type name used in scope following the scoping rules
+// of protobuf: the innermost scope that declares the name wins.
+func (cv *converter) resolve(scope, name string) (string, *symbol) {
+	if strings.HasPrefix(name, ".") {
+		return name, cv.symbols[name]
+	}
+	for {
+		full := scope + "." + name
+		if sym := cv.symbols[full]; sym != nil || wellKnown(full) {
+			return full, sym
+		}
+		if scope == "" {
+			return "." + name, nil
+		}
+		i := strings.LastIndexByte(scope, '.')
+		scope = scope[:i]
+	}
+}
+
+// wellKnown reports whether a file or a full type name belongs to the well
+// known types of protobuf.
+func wellKnown(name string) bool {
+	return strings.HasPrefix(name, "google/protobuf/") || strings.HasPrefix(name, ".google.protobuf.")
+}
@@ -0,0 +1,186 @@
+package protobuf
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+// convert converts the given sources with testdata as the import path.
+func convert(t *testing.T, sources ...Source) []*File {
+	t.Helper()
+	c := &Config{Path: []string{"testdata"}}
+	files, err := c.Convert(sources...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// messages returns the diagnostics of a file as strings.
+func messages(file *File) []string {
+	var list []string
+	for _, d := range file.Diagnostics {
+		list = append(list, d.Error())
+	}
+	return list
+}
+
+func TestGolden(t *testing.T) {
+	files := convert(t,
+		Source{Path: "testdata/users.proto"},
+		Source{Path: "testdata/common/types.proto"},
+		Source{Path: "testdata/legacy.proto"},
+	)
+	lark := make(map[string][]byte)
+	for _, file := range files {
+		lark[file.Path] = file.Content
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Path, ".lark")+".golden"))
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(file.Content, want) {
+			t.Errorf("%s differs from %s:\n%s", file.Path, golden, diff.Unified(golden, file.Path, want, file.Content))
+		}
+	}
+
+	// The converted files are valid Lark.
+	c := &loader.Config{
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := lark[filepath.ToSlash(name)]; ok {
+				return src, nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+	prog, err := c.Load(loader.Source{Path: "users.lark"}, loader.Source{Path: "legacy.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.Check(prog)
+	for _, file := range prog.Diagnostics() {
+		for _, d := range file.Diagnostics {
+			t.Errorf("%s: %v", file.Name, d)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	files := convert(t, Source{Path: "testdata/users.proto"}, Source{Path: "testdata/legacy.proto"})
+	tests := [][]string{
+		{
+			"6:1: warning[W0100]: package example.users is dropped: Lark modules are named by their file path",
+			"14:8: warning[W0100]: file option go_package is dropped: Lark has no file options",
+			"69:3: warning[W0100]: field elapsed is dropped: Lark has no type for google.protobuf.Duration",
+			"76:3: warning[W0100]: streaming of rpc ListUsers is dropped: Lark methods are unary",
+		},
+		{
+			"6:1: warning[W0100]: package legacy is dropped: Lark modules are named by their file path",
+			"16:29: warning[W0100]: option default is dropped: Lark has no constant for inf",
+			"17:31: warning[W0100]: custom option (custom.unit) is dropped: Lark annotations cannot express extensions",
+			"18:12: warning[W0100]: group Item of Config is dropped: use a nested message instead",
+			"21:3: error[E0300]: field missing is dropped: undefined type Missing",
+			"23:3: warning[W0100]: extension ranges of Config are dropped: Lark has no extensions",
+			"30:3: warning[W0100]: enum value TOP is dropped: it is an alias of HIGH, and Lark enum values are distinct",
+			"33:1: warning[W0100]: extension of Config is dropped: Lark has no extensions",
+		},
+	}
+	for i, file := range files {
+		got := messages(file)
+		if strings.Join(got, "\n") != strings.Join(tests[i], "\n") {
+			t.Errorf("%s: got diagnostics\n%s\nwant\n%s", file.Source, strings.Join(got, "\n"), strings.Join(tests[i], "\n"))
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`syntax = "proto4";`, `1:10: error[E0400]: unknown syntax "proto4"`},
+		{"message M { int32 x = 1 }", `1:25: error[E0400]: expected ";", found "}"`},
+		{"message M {\n  int32 x = 1;\n", `3:1: error[E0400]: expected "}", found end of file`},
+		{"enum E { A = ; }", `1:14: error[E0400]: expected an integer, found ";"`},
+		{"message M { string s = 1 [default = \"x]; }", `1:37: error[E0400]: unterminated string`},
+		{"service S { rpc F(A) (B); }", `1:22: error[E0400]: expected "returns", found "("`},
+		{"foo bar;", `1:1: error[E0400]: unexpected "foo"`},
+	}
+	for _, test := range tests {
+		files := convert(t, Source{Path: "errors.proto", Src: []byte(test.src)})
+		if files[0].Content != nil {
+			t.Errorf("%q: got content for a file with errors", test.src)
+		}
+		if got := messages(files[0]); len(got) != 1 || got[0] != test.err {
+			t.Errorf("%q: got %v; want %s", test.src, got, test.err)
+		}
+		if !diag.HasErrors(files[0].Diagnostics) {
+			t.Errorf("%q: no errors", test.src)
+		}
+	}
+}
+
+func TestDeclNames(t *testing.T) {
+	src := "message string { int32 x = 1; }\nmessage M { string s = 1; .string t = 2; }\nenum map { A = 0; }\nservice list {}\n"
+	files := convert(t, Source{Path: "names.proto", Src: []byte(src)})
+	content := string(files[0].Content)
+	for _, want := range []string{"struct string_ {", "    s: string\n", "    t?: string_\n", "enum map_ {", "interface list_ {"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("output does not contain %q:\n%s", want, content)
+		}
+	}
+
+	gentest.Check(t, loader.Source{Path: "names.lark", Src: files[0].Content})
+}
+
+func TestImportPath(t *testing.T) {
+	c := &Config{Path: []string{"protos"}}
+	tests := []struct {
+		path, want string
+	}{
+		{"protos/api/users.proto", "api/users.proto"},
+		{"./other/users.proto", "other/users.proto"},
+		{"protos/../x.proto", "x.proto"},
+	}
+	for _, test := range tests {
+		if got := c.importPath(filepath.FromSlash(test.path)); got != test.want {
+			t.Errorf("importPath(%q) = %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		enum   string
+		values []string
+		want   string
+	}{
+		{"Role", []string{"ROLE_UNSPECIFIED", "ROLE_ADMIN"}, "unspecified admin"},
+		{"HTTPMethod", []string{"HTTP_METHOD_GET", "HTTP_METHOD_POST"}, "get post"},
+		{"Level", []string{"LOW", "LEVEL_HIGH"}, "low level_high"},
+		{"Shape", []string{"SHAPE_2D", "SHAPE_3D"}, "shape_2d shape_3d"},
+		{"Kind", []string{"KIND_ENUM", "KIND_STRUCT"}, "kind_enum kind_struct"},
+		{"Op", []string{"IMPORT", "AS"}, "import_ as_"},
+	}
+	for _, test := range tests {
+		e := &enum{node: node{name: test.enum}}
+		for _, value := range test.values {
+			e.values = append(e.values, &enumValue{node: node{name: value}})
+		}
+		if got := strings.Join(valueNames(e), " "); got != test.want {
+			t.Errorf("valueNames(%s) = %s; want %s", test.enum, got, test.want)
+		}
+	}
+	if got, want := methodName("GetHTTPServer"), "get_http_server"; got != want {
+		t.Errorf("methodName = %q; want %q", got, want)
+	}
+}
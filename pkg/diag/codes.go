@@ -0,0 +1,116 @@
+package diag
+
+// A Code identifies the kind of a diagnostic. Codes are stable: once
+// published, a code keeps its meaning. Errors start with 'E', warnings
+// with 'W'.
+type Code string
+
+const (
+	// scanner
+	IllegalCharacter      Code = "E0001"
+	InvalidEncoding       Code = "E0002"
+	IllegalByteOrderMark  Code = "E0003"
+	MissingDigits         Code = "E0004"
+	InvalidRadixPoint     Code = "E0005"
+	MissingExponent       Code = "E0006"
+	LeadingZeros          Code = "E0007"
+	InvalidDigitSeparator Code = "E0008"
+	UnknownEscape         Code = "E0009"
+	InvalidEscapeDigit    Code = "E0010"
+	InvalidCodePoint      Code = "E0011"
+	UnterminatedString    Code = "E0012"
+
+	// parser
+	UnexpectedToken   Code = "E0100"
+	InvalidImportPath Code = "E0101"
+
+	// loader
+	ModuleNotFound Code = "E0200"
+	ImportCycle    Code = "E0201"
+
+	// checker
+	UndefinedName    Code = "E0300"
+	NotAType         Code = "E0301"
+	InvalidTypeArgs  Code = "E0302"
+	NotAConstant     Code = "E0303"
+	InvalidConstant  Code = "E0304"
+	DeclarationCycle Code = "E0305"
+	Redeclared       Code = "E0306"
+	ConstantType     Code = "E0307"
+	InvalidEnum      Code = "E0308"
+	InvalidUnion     Code = "E0309"
+	InvalidArrayLen  Code = "E0310"
+
+	// converters
+	InvalidInput Code = "E0400"
+
+	// imports
+	UnusedImport    Code = "W0001"
+	DuplicateImport Code = "W0002"
+	ShadowedImport  Code = "W0003"
+
+	// converters
+	Untranslatable Code = "W0100"
+
+	// migrations
+	UnsafeMigration Code = "W0200"
+)
+
+// CodeInfo describes a diagnostic code for documentation and tools.
+type CodeInfo struct {
+	Code    Code
+	Name    string
+	Summary string
+}
+
+var codes = []CodeInfo{
+	{IllegalCharacter, "illegal-character", "The source contains a character that cannot start a token."},
+	{InvalidEncoding, "invalid-encoding", "The source is not valid UTF-8."},
+	{IllegalByteOrderMark, "illegal-byte-order-mark", "A byte order mark appears after the beginning of the file."},
+	{MissingDigits, "missing-digits", "A number literal with a base prefix has no digits."},
+	{InvalidRadixPoint, "invalid-radix-point", "A non-decimal number literal contains a radix point."},
+	{MissingExponent, "missing-exponent", "The exponent of a float literal has no digits."},
+	{LeadingZeros, "leading-zeros", "A decimal integer literal starts with zero."},
+	{InvalidDigitSeparator, "invalid-digit-separator", "A '_' in a number literal does not separate two digits."},
+	{UnknownEscape, "unknown-escape", "A string literal contains an unknown escape sequence."},
+	{InvalidEscapeDigit, "invalid-escape-digit", "A numeric escape sequence contains a non-hexadecimal digit."},
+	{InvalidCodePoint, "invalid-code-point", "An escape sequence denotes an invalid Unicode code point."},
+	{UnterminatedString, "unterminated-string", "A string literal is not closed before the end of the line."},
+	{UnexpectedToken, "unexpected-token", "The parser found a token that the grammar does not allow here."},
+	{InvalidImportPath, "invalid-import-path", "An import path is not a string literal."},
+	{ModuleNotFound, "module-not-found", "An imported module cannot be found on the import path."},
+	{ImportCycle, "import-cycle", "A module imports itself, directly or through other modules."},
+	{UndefinedName, "undefined-name", "A name does not refer to a declaration, a built-in type or an imported module."},
+	{NotAType, "not-a-type", "A name used as a type does not denote a data type."},
+	{InvalidTypeArgs, "invalid-type-arguments", "A type has the wrong number or kind of type arguments."},
+	{NotAConstant, "not-a-constant", "A name used in a constant expression does not denote a constant."},
+	{InvalidConstant, "invalid-constant", "A constant expression cannot be evaluated."},
+	{DeclarationCycle, "declaration-cycle", "A constant, a type alias or a struct is defined in terms of itself."},
+	{Redeclared, "redeclared", "A name is declared twice in the same scope."},
+	{ConstantType, "constant-type", "A constant value cannot be represented by its declared type."},
+	{InvalidEnum, "invalid-enum", "An enum member value is not an int32 or is used by another member."},
+	{InvalidUnion, "invalid-union", "A union variant is not a struct or has a field that clashes with the tag."},
+	{InvalidArrayLen, "invalid-array-length", "The length of an array type is not a positive int32 constant."},
+	{InvalidInput, "invalid-input", "The input of a converter is not valid in its language."},
+	{UnusedImport, "unused-import", "A module is imported but none of its members are referenced."},
+	{DuplicateImport, "duplicate-import", "The same path is imported more than once."},
+	{ShadowedImport, "shadowed-import", "An import name collides with a declaration of the file."},
+	{Untranslatable, "untranslatable", "A converted construct has no Lark equivalent and is dropped or approximated."},
+	{UnsafeMigration, "unsafe-migration", "A migration step fails for tables with rows, such as a column that becomes NOT NULL."},
+}
+
+// Codes returns the descriptions of all known codes.
+func Codes() []CodeInfo {
+	return codes
+}
+
+// Info returns the description of code. The description of an unknown code
+// has an empty name.
+func (c Code) Info() CodeInfo {
+	for _, info := range codes {
+		if info.Code == c {
+			return info
+		}
+	}
+	return CodeInfo{Code: c}
+}
@@ -0,0 +1,112 @@
+// Package diag defines the diagnostics reported by the scanner, the parser
+// and the tools built on top of them.
+package diag
+
+import "fmt"
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+var severities = [...]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string {
+	if 0 <= s && s < Severity(len(severities)) {
+		return severities[s]
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Pos is a zero-based position in a source text. Column counts runes.
+type Pos struct {
+	Line, Column int
+}
+
+func (p Pos) Greater(other Pos) bool {
+	return p.Line > other.Line || p.Line == other.Line && p.Column > other.Column
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line+1, p.Column+1)
+}
+
+// Range is a half-open interval [Start, End) of a source text. An empty
+// range denotes a single position.
+type Range struct {
+	Start, End Pos
+}
+
+// At returns an empty range at pos.
+func At(pos Pos) Range {
+	return Range{pos, pos}
+}
+
+// Span returns a range of n runes starting at pos. The range must not cross
+// a line break.
+func Span(pos Pos, n int) Range {
+	return Range{pos, Pos{pos.Line, pos.Column + n}}
+}
+
+func (r Range) Empty() bool {
+	return r.Start == r.End
+}
+
+// Related is a secondary location that helps to understand a diagnostic,
+// such as a previous declaration of a name.
+type Related struct {
+	Range Range
+	Label string
+}
+
+// TextEdit replaces the text of Range with NewText.
+type TextEdit struct {
+	Range   Range
+	NewText string
+}
+
+// A Fix is a suggested change that resolves a diagnostic. Its edits do not
+// overlap and can be applied mechanically.
+type Fix struct {
+	Message string
+	Edits   []TextEdit
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Range    Range
+	Message  string
+	Related  []Related
+	Fixes    []Fix
+}
+
+func (d Diagnostic) Pos() Pos {
+	return d.Range.Start
+}
+
+// Error implements the error interface, so that a diagnostic can be returned
+// where an error is expected.
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Range.Start, d.Severity, d.Code, d.Message)
+}
+
+// A Handler is called for each diagnostic reported by a component.
+type Handler func(d Diagnostic)
+
+// HasErrors reports whether any of diagnostics has Error severity.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonPos is a one-based position as shown to users.
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonRange struct {
+	Start jsonPos `json:"start"`
+	End   jsonPos `json:"end"`
+}
+
+type jsonRelated struct {
+	Range jsonRange `json:"range"`
+	Label string    `json:"label"`
+}
+
+type jsonEdit struct {
+	Range   jsonRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+type jsonFix struct {
+	Message string     `json:"message"`
+	Edits   []jsonEdit `json:"edits"`
+}
+
+type jsonDiagnostic struct {
+	File     string        `json:"file"`
+	Range    jsonRange     `json:"range"`
+	Severity string        `json:"severity"`
+	Code     Code          `json:"code,omitempty"`
+	Message  string        `json:"message"`
+	Related  []jsonRelated `json:"related,omitempty"`
+	Fixes    []jsonFix     `json:"fixes,omitempty"`
+}
+
+func toJSONRange(rng Range) jsonRange {
+	return jsonRange{
+		Start: jsonPos{rng.Start.Line + 1, rng.Start.Column + 1},
+		End:   jsonPos{rng.End.Line + 1, rng.End.Column + 1},
+	}
+}
+
+// WriteJSON writes the diagnostics of files as newline-delimited JSON, one
+// object per diagnostic. Lines and columns are one-based; columns count
+// Unicode code points.
+func WriteJSON(w io.Writer, files []File) error {
+	encoder := json.NewEncoder(w)
+	for _, file := range files {
+		for _, d := range file.Diagnostics {
+			object := jsonDiagnostic{
+				File:     file.Name,
+				Range:    toJSONRange(d.Range),
+				Severity: d.Severity.String(),
+				Code:     d.Code,
+				Message:  d.Message,
+			}
+			for _, related := range d.Related {
+				object.Related = append(object.Related, jsonRelated{toJSONRange(related.Range), related.Label})
+			}
+			for _, fix := range d.Fixes {
+				edits := []jsonEdit{}
+				for _, edit := range fix.Edits {
+					edits = append(edits, jsonEdit{toJSONRange(edit.Range), edit.NewText})
+				}
+				object.Fixes = append(object.Fixes, jsonFix{fix.Message, edits})
+			}
+
+			if err := encoder.Encode(object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
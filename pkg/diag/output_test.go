@@ -0,0 +1,104 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var outputFiles = []File{{
+	Name: "dir/test.lark",
+	Diagnostics: []Diagnostic{
+		{
+			Severity: Warning,
+			Code:     ShadowedImport,
+			Range:    Range{Pos{0, 16}, Pos{0, 17}},
+			Message:  "import name x collides with a declaration",
+			Related:  []Related{{Range{Pos{1, 6}, Pos{1, 7}}, "x declared here"}},
+			Fixes: []Fix{{
+				Message: "rename import alias to x2",
+				Edits:   []TextEdit{{Range{Pos{0, 16}, Pos{0, 17}}, "x2"}},
+			}},
+		},
+		{
+			Severity: Error,
+			Code:     UnexpectedToken,
+			Range:    Range{Pos{2, 0}, Pos{2, 3}},
+			Message:  "expected declaration, found 'foo'",
+		},
+	},
+}}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, outputFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines; want 2", len(lines))
+	}
+
+	var object jsonDiagnostic
+	if err := json.Unmarshal([]byte(lines[0]), &object); err != nil {
+		t.Fatal(err)
+	}
+	if object.File != "dir/test.lark" || object.Severity != "warning" || object.Code != ShadowedImport {
+		t.Errorf("got %+v", object)
+	}
+	if object.Range.Start != (jsonPos{1, 17}) || object.Range.End != (jsonPos{1, 18}) {
+		t.Errorf("got range %+v; want 1:17-1:18", object.Range)
+	}
+	if len(object.Fixes) != 1 || object.Fixes[0].Edits[0].NewText != "x2" {
+		t.Errorf("got fixes %+v", object.Fixes)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteSARIF(&out, Tool{Name: "lark"}, outputFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("got version %q with %d runs", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Codes()) {
+		t.Errorf("got %d rules; want %d", len(run.Tool.Driver.Rules), len(Codes()))
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results; want 2", len(run.Results))
+	}
+
+	result := run.Results[0]
+	rule := run.Tool.Driver.Rules[*result.RuleIndex]
+	if rule.ID != string(ShadowedImport) || result.Level != "warning" {
+		t.Errorf("got rule %s with level %s", rule.ID, result.Level)
+	}
+
+	location := result.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "dir/test.lark" {
+		t.Errorf("got uri %q", location.ArtifactLocation.URI)
+	}
+	if location.Region != (sarifRegion{1, 17, 1, 18}) {
+		t.Errorf("got region %+v", location.Region)
+	}
+	if len(result.RelatedLocations) != 1 || result.RelatedLocations[0].Message.Text != "x declared here" {
+		t.Errorf("got related locations %+v", result.RelatedLocations)
+	}
+	if len(result.Fixes) != 1 || result.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "x2" {
+		t.Errorf("got fixes %+v", result.Fixes)
+	}
+
+	if run.Results[1].Level != "error" {
+		t.Errorf("got level %q; want error", run.Results[1].Level)
+	}
+}
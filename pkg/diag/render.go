@@ -0,0 +1,271 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A File groups the diagnostics reported for one source file.
+type File struct {
+	Name        string
+	Lines       []string // source lines without line breaks
+	Diagnostics []Diagnostic
+}
+
+// defaultTabWidth is the display width of a tab stop of a Renderer that
+// does not set TabWidth.
+const defaultTabWidth = 4
+
+// Renderer writes diagnostics in a human readable form: every diagnostic is
+// followed by the source lines it refers to, with the primary range
+// underlined by carets and related ranges by dashes and their labels.
+type Renderer struct {
+	Writer   io.Writer
+	Color    bool // use ANSI escape sequences
+	Context  int  // number of source lines shown around the primary range
+	TabWidth int  // display width of a tab stop; 4 if not positive
+}
+
+// NewRenderer returns a renderer writing to w. Colors are enabled when w is
+// a terminal and the NO_COLOR environment variable is not set.
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{
+		Writer:   w,
+		Color:    IsTerminal(w) && os.Getenv("NO_COLOR") == "",
+		Context:  1,
+		TabWidth: defaultTabWidth,
+	}
+}
+
+// IsTerminal reports whether w is a character device such as a terminal.
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	cyan   = "\x1b[1;36m"
+	blue   = "\x1b[1;34m"
+	green  = "\x1b[1;32m"
+)
+
+func (r *Renderer) paint(color, text string) string {
+	if !r.Color || color == "" {
+		return text
+	}
+	return color + text + reset
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Error:
+		return red
+	case Warning:
+		return yellow
+	case Note:
+		return cyan
+	}
+	return ""
+}
+
+// Summary counts the rendered diagnostics by severity.
+type Summary struct {
+	Errors   int
+	Warnings int
+	Notes    int
+	Files    int // number of files with at least one diagnostic
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
+
+func (s Summary) String() string {
+	var parts []string
+	if s.Errors > 0 {
+		parts = append(parts, plural(s.Errors, "error"))
+	}
+	if s.Warnings > 0 {
+		parts = append(parts, plural(s.Warnings, "warning"))
+	}
+	if s.Notes > 0 {
+		parts = append(parts, plural(s.Notes, "note"))
+	}
+	if len(parts) == 0 {
+		return "no problems"
+	}
+	return strings.Join(parts, ", ") + " in " + plural(s.Files, "file")
+}
+
+// Render writes the diagnostics of files ordered by file and position,
+// followed by a summary line, and returns the summary.
+func (r *Renderer) Render(files []File) Summary {
+	var summary Summary
+	for _, file := range files {
+		if len(file.Diagnostics) == 0 {
+			continue
+		}
+		summary.Files++
+
+		diagnostics := append([]Diagnostic(nil), file.Diagnostics...)
+		sort.SliceStable(diagnostics, func(i, j int) bool {
+			return diagnostics[j].Range.Start.Greater(diagnostics[i].Range.Start)
+		})
+
+		for _, d := range diagnostics {
+			switch d.Severity {
+			case Error:
+				summary.Errors++
+			case Warning:
+				summary.Warnings++
+			default:
+				summary.Notes++
+			}
+			r.render(file, d)
+		}
+	}
+
+	if summary.Files > 0 {
+		color := yellow
+		if summary.Errors > 0 {
+			color = red
+		}
+		fmt.Fprintln(r.Writer, r.paint(color, summary.String()))
+	}
+
+	return summary
+}
+
+// mark is an underlined range of a single source line.
+type mark struct {
+	line     int
+	from, to int // rune columns
+	primary  bool
+	label    string
+}
+
+func (r *Renderer) render(file File, d Diagnostic) {
+	header := r.paint(severityColor(d.Severity), d.Severity.String())
+	if d.Code != "" {
+		header += r.paint(severityColor(d.Severity), "["+string(d.Code)+"]")
+	}
+	fmt.Fprintf(r.Writer, "%s: %s\n", header, r.paint(bold, d.Message))
+	fmt.Fprintf(r.Writer, "  %s %s:%s\n", r.paint(blue, "-->"), file.Name, d.Range.Start)
+
+	marks := []mark{lineMark(file, d.Range, true, "")}
+	for _, related := range d.Related {
+		marks = append(marks, lineMark(file, related.Range, false, related.Label))
+	}
+	r.snippet(file, marks, severityColor(d.Severity))
+
+	for _, fix := range d.Fixes {
+		fmt.Fprintf(r.Writer, "  %s %s: %s\n", r.paint(blue, "="), r.paint(green, "help"), fix.Message)
+	}
+	fmt.Fprintln(r.Writer)
+}
+
+// lineMark clips rng to its first line. An empty range is widened to one
+// column so that it stays visible.
+func lineMark(file File, rng Range, primary bool, label string) mark {
+	m := mark{line: rng.Start.Line, from: rng.Start.Column, to: rng.End.Column, primary: primary, label: label}
+	if rng.End.Line != rng.Start.Line {
+		m.to = m.from + 1
+		if m.line < len(file.Lines) {
+			m.to = max(m.to, len([]rune(file.Lines[m.line])))
+		}
+	}
+	if m.to <= m.from {
+		m.to = m.from + 1
+	}
+	return m
+}
+
+// snippet prints the source lines covered by marks, the context lines
+// around the primary mark, and the underlines. Marks at the end of the
+// text, on the line after the last one, are shown on an empty line.
+func (r *Renderer) snippet(file File, marks []mark, primary string) {
+	lines := file.Lines
+	for _, m := range marks {
+		if m.line == len(file.Lines) {
+			lines = append(file.Lines[:len(file.Lines):len(file.Lines)], "")
+		}
+	}
+
+	shown := map[int]bool{}
+	for _, m := range marks {
+		from, to := m.line, m.line
+		if m.primary {
+			from, to = m.line-r.Context, m.line+r.Context
+		}
+		for line := max(from, 0); line <= to && line < len(lines); line++ {
+			shown[line] = true
+		}
+	}
+	if len(shown) == 0 {
+		return
+	}
+
+	var numbers []int
+	for line := range shown {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+
+	gutter := len(strconv.Itoa(numbers[len(numbers)-1] + 1))
+	empty := strings.Repeat(" ", gutter+1) + r.paint(blue, "|")
+	fmt.Fprintln(r.Writer, empty)
+
+	for i, line := range numbers {
+		if i > 0 && line != numbers[i-1]+1 {
+			fmt.Fprintln(r.Writer, r.paint(blue, strings.Repeat(".", gutter+1)))
+		}
+
+		text, columns := expandLine(lines[line], r.TabWidth)
+		number := fmt.Sprintf("%*d", gutter, line+1)
+		fmt.Fprintf(r.Writer, "%s %s %s\n", r.paint(blue, number), r.paint(blue, "|"), text)
+
+		for _, m := range marks {
+			if m.line != line {
+				continue
+			}
+			start, end := displayColumn(columns, m.from), displayColumn(columns, m.to)
+			if end <= start {
+				end = start + 1
+			}
+
+			char, color := "-", blue
+			if m.primary {
+				char, color = "^", primary
+			}
+			underline := strings.Repeat(char, end-start)
+			if m.label != "" {
+				underline += " " + m.label
+			}
+			fmt.Fprintf(r.Writer, "%s %s\n", empty, strings.Repeat(" ", start)+r.paint(color, underline))
+		}
+	}
+}
+
+// displayColumn converts a rune column into a display column. Columns past
+// the end of the line continue with one display column per rune.
+func displayColumn(columns []int, column int) int {
+	if column < len(columns) {
+		return columns[column]
+	}
+	return columns[len(columns)-1] + column - len(columns) + 1
+}
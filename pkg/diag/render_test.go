@@ -0,0 +1,154 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandLine(t *testing.T) {
+	type testCase struct {
+		input   string
+		text    string
+		columns []int
+	}
+
+	tests := []testCase{
+		{"ab", "ab", []int{0, 1, 2}},
+		{"\tx", "    x", []int{0, 4, 5}},
+		{"a\tx", "a   x", []int{0, 1, 4, 5}},
+		{"日x", "日x", []int{0, 2, 3}},
+		{"éx", "éx", []int{0, 1, 1, 2}},
+	}
+
+	for _, test := range tests {
+		text, columns := expandLine(test.input, 4)
+		if text != test.text {
+			t.Errorf("%q: got text %q; want %q", test.input, text, test.text)
+		}
+		if len(columns) != len(test.columns) {
+			t.Errorf("%q: got columns %v; want %v", test.input, columns, test.columns)
+			continue
+		}
+		for i := range columns {
+			if columns[i] != test.columns[i] {
+				t.Errorf("%q: got columns %v; want %v", test.input, columns, test.columns)
+				break
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	file := File{
+		Name: "test.lark",
+		Lines: []string{
+			`import "a"`,
+			`import "a"`,
+			"\tconst 名 = x",
+		},
+		Diagnostics: []Diagnostic{
+			{
+				Severity: Error,
+				Code:     UnexpectedToken,
+				Range:    Range{Pos{2, 11}, Pos{2, 12}},
+				Message:  "bad",
+			},
+			{
+				Severity: Warning,
+				Code:     DuplicateImport,
+				Range:    Range{Pos{1, 7}, Pos{1, 10}},
+				Message:  `"a" imported more than once`,
+				Related:  []Related{{Range{Pos{0, 7}, Pos{0, 10}}, "first imported here"}},
+				Fixes:    []Fix{{Message: "remove duplicate import"}},
+			},
+		},
+	}
+
+	var out strings.Builder
+	renderer := &Renderer{Writer: &out, Context: 0, TabWidth: 4}
+	summary := renderer.Render([]File{file})
+
+	want := `warning[W0002]: "a" imported more than once
+  --> test.lark:2:8
+  |
+1 | import "a"
+  |        --- first imported here
+2 | import "a"
+  |        ^^^
+  = help: remove duplicate import
+
+error[E0100]: bad
+  --> test.lark:3:12
+  |
+3 |     const 名 = x
+  |                ^
+
+1 error, 1 warning in 1 file
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if summary.Errors != 1 || summary.Warnings != 1 || summary.Files != 1 {
+		t.Errorf("got summary %+v; want 1 error and 1 warning in 1 file", summary)
+	}
+}
+
+func TestRenderEOF(t *testing.T) {
+	file := File{
+		Name:  "test.lark",
+		Lines: []string{"struct S {", "    x: int"},
+		Diagnostics: []Diagnostic{{
+			Severity: Error,
+			Code:     UnexpectedToken,
+			Range:    Range{Pos{2, 0}, Pos{2, 0}},
+			Message:  "unexpected EOF",
+		}},
+	}
+
+	var out strings.Builder
+	renderer := &Renderer{Writer: &out, Context: 1, TabWidth: 4}
+	renderer.Render([]File{file})
+
+	want := `error[E0100]: unexpected EOF
+  --> test.lark:3:1
+  |
+2 |     x: int
+3 | 
+  | ^
+
+1 error in 1 file
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderZeroValue(t *testing.T) {
+	file := File{
+		Name:  "test.lark",
+		Lines: []string{"\tx: int"},
+		Diagnostics: []Diagnostic{{
+			Severity: Error,
+			Code:     UnexpectedToken,
+			Range:    Range{Pos{0, 1}, Pos{0, 2}},
+			Message:  "bad",
+		}},
+	}
+
+	var out strings.Builder
+	renderer := &Renderer{Writer: &out}
+	renderer.Render([]File{file})
+
+	want := `error[E0100]: bad
+  --> test.lark:1:2
+  |
+1 |     x: int
+  |     ^
+
+1 error in 1 file
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
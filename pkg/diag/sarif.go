@@ -0,0 +1,218 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// The subset of the SARIF 2.1.0 object model written by WriteSARIF.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version,omitempty"`
+		InformationURI string      `json:"informationUri,omitempty"`
+		Rules          []sarifRule `json:"rules"`
+	}
+
+	sarifRule struct {
+		ID                   string             `json:"id"`
+		Name                 string             `json:"name"`
+		ShortDescription     sarifMessage       `json:"shortDescription"`
+		DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	}
+
+	sarifConfiguration struct {
+		Level string `json:"level"`
+	}
+
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+
+	sarifResult struct {
+		RuleID           string          `json:"ruleId,omitempty"`
+		RuleIndex        *int            `json:"ruleIndex,omitempty"`
+		Level            string          `json:"level"`
+		Message          sarifMessage    `json:"message"`
+		Locations        []sarifLocation `json:"locations"`
+		RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+		Fixes            []sarifFix      `json:"fixes,omitempty"`
+	}
+
+	sarifLocation struct {
+		ID               *int                  `json:"id,omitempty"`
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		Message          *sarifMessage         `json:"message,omitempty"`
+	}
+
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+
+	sarifFix struct {
+		Description     sarifMessage          `json:"description"`
+		ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+	}
+
+	sarifArtifactChange struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Replacements     []sarifReplacement    `json:"replacements"`
+	}
+
+	sarifReplacement struct {
+		DeletedRegion   sarifRegion   `json:"deletedRegion"`
+		InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+	}
+)
+
+// Tool identifies the program that produced a SARIF log.
+type Tool struct {
+	Name           string
+	Version        string
+	InformationURI string
+}
+
+func sarifLevel(s Severity) string {
+	if s == Note {
+		return "note"
+	}
+	return s.String()
+}
+
+func toSARIFRegion(rng Range) sarifRegion {
+	return sarifRegion{
+		StartLine:   rng.Start.Line + 1,
+		StartColumn: rng.Start.Column + 1,
+		EndLine:     rng.End.Line + 1,
+		EndColumn:   rng.End.Column + 1,
+	}
+}
+
+func toSARIFLocation(uri string, rng Range) sarifLocation {
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri},
+			Region:           toSARIFRegion(rng),
+		},
+	}
+}
+
+// WriteSARIF writes the diagnostics of files as a SARIF 2.1.0 log with a
+// single run. Every known diagnostic code is listed as a rule of the tool.
+func WriteSARIF(w io.Writer, tool Tool, files []File) error {
+	driver := sarifDriver{
+		Name:           tool.Name,
+		Version:        tool.Version,
+		InformationURI: tool.InformationURI,
+	}
+
+	ruleIndex := map[Code]int{}
+	for i, info := range Codes() {
+		level := "error"
+		if strings.HasPrefix(string(info.Code), "W") {
+			level = "warning"
+		}
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   string(info.Code),
+			Name:                 info.Name,
+			ShortDescription:     sarifMessage{info.Summary},
+			DefaultConfiguration: sarifConfiguration{level},
+		})
+		ruleIndex[info.Code] = i
+	}
+
+	results := []sarifResult{}
+	for _, file := range files {
+		uri := uriOf(file.Name)
+		for _, d := range file.Diagnostics {
+			result := sarifResult{
+				RuleID:    string(d.Code),
+				Level:     sarifLevel(d.Severity),
+				Message:   sarifMessage{d.Message},
+				Locations: []sarifLocation{toSARIFLocation(uri, d.Range)},
+			}
+			if index, ok := ruleIndex[d.Code]; ok {
+				result.RuleIndex = &index
+			}
+
+			for i, related := range d.Related {
+				location := toSARIFLocation(uri, related.Range)
+				location.ID = &i
+				location.Message = &sarifMessage{related.Label}
+				result.RelatedLocations = append(result.RelatedLocations, location)
+			}
+
+			for _, fix := range d.Fixes {
+				change := sarifArtifactChange{ArtifactLocation: sarifArtifactLocation{URI: uri}}
+				for _, edit := range fix.Edits {
+					replacement := sarifReplacement{DeletedRegion: toSARIFRegion(edit.Range)}
+					if edit.NewText != "" {
+						replacement.InsertedContent = &sarifMessage{edit.NewText}
+					}
+					change.Replacements = append(change.Replacements, replacement)
+				}
+				result.Fixes = append(result.Fixes, sarifFix{
+					Description:     sarifMessage{fix.Message},
+					ArtifactChanges: []sarifArtifactChange{change},
+				})
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{driver},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// uriOf converts a file name into a URI: absolute file names become file
+// URIs, relative ones relative references.
+func uriOf(name string) string {
+	uri := url.URL{Path: filepath.ToSlash(name)}
+	if filepath.IsAbs(name) {
+		uri.Scheme = "file"
+	}
+	return uri.String()
+}
@@ -0,0 +1,82 @@
+package diag
+
+import "unicode"
+
+// wide lists the ranges of East Asian wide and fullwidth characters, which
+// take two columns in a terminal.
+var wide = [][2]rune{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2329, 0x232A},
+	{0x23E9, 0x23EC},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of terminal columns taken by r.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7F:
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+
+	for _, rng := range wide {
+		if r < rng[0] {
+			break
+		}
+		if r <= rng[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// expandLine converts a source line into its display form and returns it
+// together with the display column at which each rune starts. The column
+// of the position right after the last rune is included, so the result
+// has one more entry than the line has runes. Tabs are expanded to the next
+// multiple of tabWidth, or of defaultTabWidth if tabWidth is not positive.
+func expandLine(line string, tabWidth int) (string, []int) {
+	if tabWidth <= 0 {
+		tabWidth = defaultTabWidth
+	}
+
+	var text []rune
+	var columns []int
+	width := 0
+	for _, r := range line {
+		columns = append(columns, width)
+		switch {
+		case r == '\t':
+			n := tabWidth - width%tabWidth
+			for i := 0; i < n; i++ {
+				text = append(text, ' ')
+			}
+			width += n
+		case r == '\r' || r == '\n':
+			// dropped
+		default:
+			text = append(text, r)
+			width += runeWidth(r)
+		}
+	}
+	columns = append(columns, width)
+
+	return string(text), columns
+}
@@ -0,0 +1,472 @@
+// Package format implements canonical formatting of Lark source.
+//
+// The canonical style indents bodies by four spaces, puts single spaces
+// around binary operators and after commas, uses the minimal number of
+// parentheses required by operator precedence, aligns the '=' of adjacent
+// declarations of the same kind, the types of adjacent struct fields and
+// trailing comments, and separates struct and interface declarations by
+// blank lines. Blank lines between other declarations are kept, but
+// collapsed to one. A negation of a negation keeps its parentheses, as in
+// -(-x), so that the two minus signs do not run together.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/parser"
+	"larklang.io/lark/pkg/scanner"
+)
+
+const indent = "    "
+
+// precPrimary is higher than the precedence of any operator.
+const precPrimary = 100
+
+type printer struct {
+	buf      bytes.Buffer
+	comments []*ast.CommentGroup
+	cindex   int // index of the next comment group to print
+	indent   int
+	lastLine int  // source line of the last printed line; -1 at the start of a block
+	blank    bool // the next construct is separated by a blank line
+	err      error
+}
+
+// eof is a position after any source position.
+var eof = scanner.Pos{Line: int(^uint(0) >> 1)}
+
+// Node writes the canonical source of node to w. Node accepts files,
+// declarations, fields, methods, enum members, union variants, types and
+// expressions. Comments are only preserved if node is an *ast.File.
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{lastLine: -1}
+	switch n := node.(type) {
+	case *ast.File:
+		p.comments = n.Comments
+		p.file(n)
+	case *ast.Type:
+		p.writeLine(p.typ(n))
+	case *ast.Annotation:
+		p.writeLine(p.annotation(n))
+	case *ast.Field:
+		for _, annotation := range n.Annotations {
+			p.writeLine(p.annotation(annotation))
+		}
+		p.writeLine(p.field(n))
+	case *ast.Method:
+		for _, annotation := range n.Annotations {
+			p.writeLine(p.annotation(annotation))
+		}
+		p.writeLine(p.method(n))
+	case *ast.EnumMember:
+		for _, annotation := range n.Annotations {
+			p.writeLine(p.annotation(annotation))
+		}
+		p.writeLine(p.enumMember(n))
+	case *ast.Variant:
+		for _, annotation := range n.Annotations {
+			p.writeLine(p.annotation(annotation))
+		}
+		p.writeLine(p.variant(n))
+	case *ast.BasicLit, *ast.QualName, *ast.UnaryExpr, *ast.BinaryExpr:
+		p.writeLine(p.expr(n, 0))
+	default:
+		p.decl(node, eof)
+	}
+	if p.err != nil {
+		return p.err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', tabwriter.StripEscape|tabwriter.DiscardEmptyColumns)
+	if _, err := tw.Write(p.buf.Bytes()); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// Source formats Lark source text. It fails if the source contains syntax
+// errors.
+func Source(src []byte) ([]byte, error) {
+	parsed := parser.ParseWithOptions(src, parser.Options{MaxErrors: 1})
+	for _, d := range parsed.Diagnostics {
+		if d.Severity == diag.Error {
+			return nil, d
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Node(&buf, parsed.File); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escape protects text from being split into cells by the tabwriter.
+func escape(text string) string {
+	if strings.ContainsRune(text, '\t') {
+		esc := string([]byte{tabwriter.Escape})
+		return esc + text + esc
+	}
+	return text
+}
+
+// error records the first error encountered.
+func (p *printer) error(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *printer) nextComment() *ast.CommentGroup {
+	if p.cindex < len(p.comments) {
+		return p.comments[p.cindex]
+	}
+	return nil
+}
+
+// separate writes a blank line before a construct starting at the given
+// source line if the source has a blank line there or one is required.
+func (p *printer) separate(line int) {
+	if p.lastLine >= 0 && (p.blank || line > p.lastLine+1) {
+		p.buf.WriteByte('\n')
+	}
+	p.blank = false
+}
+
+func (p *printer) writeLine(text string) {
+	if text != "" {
+		p.buf.WriteString(strings.Repeat(indent, p.indent))
+		p.buf.WriteString(text)
+	}
+	p.buf.WriteByte('\n')
+}
+
+// flush writes the comment groups that start before pos on lines of their
+// own.
+func (p *printer) flush(pos scanner.Pos) {
+	for c := p.nextComment(); c != nil && pos.Greater(c.Pos()); c = p.nextComment() {
+		p.separate(c.Pos().Line)
+		for _, comment := range c.List {
+			p.writeLine(escape(strings.TrimRight(comment.Text, " \t\r")))
+		}
+		p.lastLine = c.EndLine()
+		p.cindex++
+	}
+}
+
+// line writes a line of output for a construct that spans the source lines
+// from first to last. A trailing comment on these lines is appended.
+func (p *printer) line(text string, first, last int) {
+	p.flush(scanner.Pos{Line: first, Column: 0})
+	p.separate(first)
+
+	if c := p.nextComment(); c != nil && c.Trailing && c.Pos().Line >= first && c.Pos().Line <= last {
+		text += "\t" + escape(strings.TrimRight(c.List[0].Text, " \t\r"))
+		p.cindex++
+	}
+	p.writeLine(text)
+	p.lastLine = last
+}
+
+// section ends the current section of the output: the cells of the lines
+// written after it are aligned independently of those written before it.
+func (p *printer) section() {
+	if b := p.buf.Bytes(); len(b) > 0 && b[len(b)-1] == '\n' {
+		b[len(b)-1] = '\f'
+	}
+}
+
+// endLine returns the last source line of node.
+func endLine(node ast.Node) int {
+	switch n := node.(type) {
+	case *ast.Struct:
+		return n.Rbrace.Line
+	case *ast.Interface:
+		return n.Rbrace.Line
+	case *ast.Enum:
+		return n.Rbrace.Line
+	case *ast.Union:
+		return n.Rbrace.Line
+	}
+
+	line := node.Pos().Line
+	ast.Inspect(node, func(node ast.Node) bool {
+		line = max(line, node.Pos().Line)
+		return true
+	})
+	return line
+}
+
+// multiline reports whether node is printed on more than one line.
+func multiline(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.Struct:
+		return len(n.Fields) > 0
+	case *ast.Interface:
+		return len(n.Methods) > 0
+	case *ast.Enum:
+		return len(n.Members) > 0
+	case *ast.Union:
+		return len(n.Variants) > 0
+	}
+	return false
+}
+
+func (p *printer) file(file *ast.File) {
+	for i, node := range file.Nodes {
+		if multiline(node) || i > 0 && multiline(file.Nodes[i-1]) {
+			p.blank = true
+		}
+		if i > 0 && reflect.TypeOf(node) != reflect.TypeOf(file.Nodes[i-1]) {
+			p.section()
+		}
+
+		limit := eof
+		if i+1 < len(file.Nodes) {
+			limit = file.Nodes[i+1].Pos()
+		}
+		p.decl(node, limit)
+	}
+	p.flush(eof)
+}
+
+// body writes the members of a struct or an interface followed by the
+// closing brace.
+func (p *printer) body(members []ast.Node, rbrace scanner.Pos, member func(ast.Node)) {
+	p.indent++
+	p.lastLine = -1
+	for _, node := range members {
+		member(node)
+	}
+	p.flush(rbrace)
+	p.indent--
+
+	p.line("}", rbrace.Line, rbrace.Line)
+}
+
+func (p *printer) decl(node ast.Node, limit scanner.Pos) {
+	switch n := node.(type) {
+	case *ast.ImportSpec:
+		text := "import " + escape(n.Path.Value)
+		if n.Alias != nil {
+			text += " as " + n.Alias.Name
+		}
+		p.line(text, n.Pos().Line, endLine(n))
+
+	case *ast.ConstSpec:
+		p.annotations(n.Annotations)
+		text := "const " + n.Name.Name
+		if n.Type != nil {
+			text += ": " + p.typ(n.Type)
+		}
+		p.line(text+"\t= "+p.expr(n.Expr, 0), n.Pos().Line, endLine(n))
+
+	case *ast.TypeAlias:
+		p.annotations(n.Annotations)
+		p.line("type "+n.Name.Name+"\t= "+p.typ(n.Type), n.Pos().Line, endLine(n))
+
+	case *ast.Struct:
+		p.annotations(n.Annotations)
+		header := "struct " + n.Name.Name + " {"
+		if len(n.Fields) == 0 && !p.commentsBefore(n.Rbrace) {
+			p.line(header+"}", n.Pos().Line, n.Rbrace.Line)
+			return
+		}
+		p.line(header, n.Pos().Line, n.Pos().Line)
+
+		members := make([]ast.Node, len(n.Fields))
+		for i, field := range n.Fields {
+			members[i] = field
+		}
+		p.body(members, n.Rbrace, func(node ast.Node) {
+			field := node.(*ast.Field)
+			p.annotations(field.Annotations)
+			p.line(p.field(field), field.Pos().Line, endLine(field))
+		})
+
+	case *ast.Interface:
+		p.annotations(n.Annotations)
+		header := "interface " + n.Name.Name + " {"
+		if len(n.Methods) == 0 && !p.commentsBefore(n.Rbrace) {
+			p.line(header+"}", n.Pos().Line, n.Rbrace.Line)
+			return
+		}
+		p.line(header, n.Pos().Line, n.Pos().Line)
+
+		members := make([]ast.Node, len(n.Methods))
+		for i, method := range n.Methods {
+			members[i] = method
+		}
+		p.body(members, n.Rbrace, func(node ast.Node) {
+			method := node.(*ast.Method)
+			p.annotations(method.Annotations)
+			p.line(p.method(method), method.Pos().Line, endLine(method))
+		})
+
+	case *ast.Enum:
+		p.annotations(n.Annotations)
+		header := "enum " + n.Name.Name + " {"
+		if len(n.Members) == 0 && !p.commentsBefore(n.Rbrace) {
+			p.line(header+"}", n.Pos().Line, n.Rbrace.Line)
+			return
+		}
+		p.line(header, n.Pos().Line, n.Pos().Line)
+
+		members := make([]ast.Node, len(n.Members))
+		for i, member := range n.Members {
+			members[i] = member
+		}
+		p.body(members, n.Rbrace, func(node ast.Node) {
+			member := node.(*ast.EnumMember)
+			p.annotations(member.Annotations)
+			p.line(p.enumMember(member), member.Pos().Line, endLine(member))
+		})
+
+	case *ast.Union:
+		p.annotations(n.Annotations)
+		header := "union " + n.Name.Name + " {"
+		if len(n.Variants) == 0 && !p.commentsBefore(n.Rbrace) {
+			p.line(header+"}", n.Pos().Line, n.Rbrace.Line)
+			return
+		}
+		p.line(header, n.Pos().Line, n.Pos().Line)
+
+		members := make([]ast.Node, len(n.Variants))
+		for i, variant := range n.Variants {
+			members[i] = variant
+		}
+		p.body(members, n.Rbrace, func(node ast.Node) {
+			variant := node.(*ast.Variant)
+			p.annotations(variant.Annotations)
+			p.line(p.variant(variant), variant.Pos().Line, endLine(variant))
+		})
+
+	default:
+		p.error(fmt.Errorf("format: unexpected declaration %T at %s", node, node.Pos()))
+	}
+}
+
+// commentsBefore reports whether there are unprinted comments before pos.
+func (p *printer) commentsBefore(pos scanner.Pos) bool {
+	c := p.nextComment()
+	return c != nil && pos.Greater(c.Pos())
+}
+
+// annotations writes annotations on lines of their own.
+func (p *printer) annotations(annotations []*ast.Annotation) {
+	for _, annotation := range annotations {
+		p.line(p.annotation(annotation), annotation.Pos().Line, endLine(annotation))
+	}
+}
+
+func (p *printer) annotation(annotation *ast.Annotation) string {
+	text := "@" + annotation.Name.Name
+	if annotation.Args != nil {
+		args := make([]string, len(annotation.Args))
+		for i, arg := range annotation.Args {
+			args[i] = p.expr(arg, 0)
+		}
+		text += "(" + strings.Join(args, ", ") + ")"
+	}
+	return text
+}
+
+func (p *printer) field(field *ast.Field) string {
+	name := field.Name.Name
+	if field.Optional {
+		name += "?"
+	}
+	return name + ":\t" + p.typ(field.Type)
+}
+
+func (p *printer) enumMember(member *ast.EnumMember) string {
+	if member.Value == nil {
+		return member.Name.Name
+	}
+	return member.Name.Name + "\t= " + p.expr(member.Value, 0)
+}
+
+func (p *printer) variant(variant *ast.Variant) string {
+	return variant.Name.Name + ":\t" + p.typ(variant.Type)
+}
+
+func (p *printer) method(method *ast.Method) string {
+	params := make([]string, len(method.Params))
+	for i, param := range method.Params {
+		for _, annotation := range param.Annotations {
+			params[i] += p.annotation(annotation) + " "
+		}
+		params[i] += param.Name.Name + ": " + p.typ(param.Type)
+	}
+
+	text := "func " + method.Name.Name + "(" + strings.Join(params, ", ") + ")"
+	if method.Result != nil {
+		text += " -> " + p.typ(method.Result)
+	}
+	return text
+}
+
+func qualName(name *ast.QualName) string {
+	if name.Module != nil {
+		return name.Module.Name + "." + name.Name.Name
+	}
+	return name.Name.Name
+}
+
+func (p *printer) typ(typ *ast.Type) string {
+	if typ.Name == nil {
+		return "[" + p.typ(typ.Elem) + "; " + p.expr(typ.Len, 0) + "]"
+	}
+	text := qualName(typ.Name)
+	if len(typ.Args) > 0 {
+		args := make([]string, len(typ.Args))
+		for i, arg := range typ.Args {
+			if t, ok := arg.(*ast.Type); ok {
+				args[i] = p.typ(t)
+			} else {
+				args[i] = p.expr(arg, 0)
+			}
+		}
+		text += "[" + strings.Join(args, ", ") + "]"
+	}
+	return text
+}
+
+// expr returns the source of an expression. It is parenthesized if its
+// precedence is lower than prec.
+func (p *printer) expr(node ast.Node, prec int) string {
+	var text string
+	var own int
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		text, own = escape(n.Value), precPrimary
+	case *ast.QualName:
+		text, own = qualName(n), precPrimary
+	case *ast.UnaryExpr:
+		own = parser.UnaryPrecedence
+		// -(-x) keeps its parentheses rather than becoming --x.
+		operand := own
+		if inner, ok := n.Expr.(*ast.UnaryExpr); ok && inner.Op == scanner.MINUS && n.Op == scanner.MINUS {
+			operand = precPrimary
+		}
+		text = n.Op.String() + p.expr(n.Expr, operand)
+	case *ast.BinaryExpr:
+		own = parser.Precedence(n.Op)
+		text = p.expr(n.Lhs, own) + " " + n.Op.String() + " " + p.expr(n.Rhs, own+1)
+	default:
+		p.error(fmt.Errorf("format: unexpected expression %T at %s", node, node.Pos()))
+		return ""
+	}
+
+	if own < prec {
+		return "(" + text + ")"
+	}
+	return text
+}
@@ -0,0 +1,90 @@
+package format
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/diff"
+)
+
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden := strings.TrimSuffix(input, ".input") + ".golden"
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Source(src)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: formatted source differs from %s:\n%s", input, golden, diff.Unified(golden, input, want, got))
+		}
+
+		// formatting must be idempotent
+		again, err := Source(got)
+		if err != nil {
+			t.Errorf("%s: reformatting: %v", input, err)
+			continue
+		}
+		if !bytes.Equal(again, got) {
+			t.Errorf("%s: formatting is not idempotent:\n%s", input, diff.Unified("once", "twice", got, again))
+		}
+	}
+}
+
+func TestExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1+2", "1 + 2"},
+		{"1+2*3", "1 + 2 * 3"},
+		{"(1+2)*3", "(1 + 2) * 3"},
+		{"1-(2-3)", "1 - (2 - 3)"},
+		{"(1-2)-3", "1 - 2 - 3"},
+		{"((a))", "a"},
+		{"-(1+2)", "-(1 + 2)"},
+		{"-(-a)", "-(-a)"},
+		{"- -1", "-(-1)"},
+		{"1 - -a", "1 - -a"},
+		{"!(!a)", "!!a"},
+		{"-(!a)", "-!a"},
+		{"!(a==b)", "!(a == b)"},
+		{"a||b&&c", "a || b && c"},
+		{"(a||b)&&c", "(a || b) && c"},
+		{"m . x<=1%2", "m.x <= 1 % 2"},
+	}
+
+	for _, test := range tests {
+		got, err := Source([]byte("const x = " + test.input))
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if want := "const x = " + test.want + "\n"; string(got) != want {
+			t.Errorf("%q: got %q; want %q", test.input, got, want)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	if _, err := Source([]byte("struct {")); err == nil {
+		t.Errorf("got no error for invalid source")
+	}
+}
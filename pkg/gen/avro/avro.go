@@ -0,0 +1,416 @@
+// Package avro implements the "avro" generator, which produces Apache
+// Avro schemas (.avsc) from checked Lark schemas.
+//
+// Every struct and enum of the root files becomes a schema file below
+// the module path: struct User of module "api/users" becomes
+// api/users/User.avsc, with the name User in the namespace api.users.
+// The files are self-contained: named types are defined where they first
+// appear and referred to by their full name after that. Types are
+// translated as follows:
+//
+//   - Structs become records and enums become enums with the member
+//     names as symbols; Avro enums carry no numbers. Fields are named by
+//     their JSON names, which must be Avro names: letters, digits and
+//     underscores, not starting with a digit.
+//   - Optional fields become unions of null and their type, with the
+//     default null. Optional fields with a @default annotation put the
+//     type first instead, since the default must match the first branch.
+//   - Unions become unions of the records of their variants. Avro tells
+//     the branches apart by their records, so the tag is not part of the
+//     schema.
+//   - Lists and arrays become arrays, except that arrays of bytes become
+//     fixed types, named after their alias or after the record and the
+//     field. Maps become maps, which need string keys.
+//   - Integers of up to 32 bits other than uint32 become int, uint32 and
+//     int64 become long and uint64 becomes a decimal of 20 digits.
+//     Timestamps become longs with the logical type timestamp-micros and
+//     UUIDs strings with the logical type uuid.
+//   - Other aliases are replaced by the aliased type. Constants and
+//     interfaces are left out.
+//
+// @default annotations become the defaults of fields; their values are
+// evaluated as constants and converted to the Avro encoding of the field
+// type. Enum defaults name the member, as in @default("admin"). Doc
+// comments become docs, and @deprecated annotations "Deprecated:"
+// paragraphs of them.
+//
+// The generator takes this parameter:
+//
+//	namespace  prefix of the namespaces, such as "com.acme" (default: none)
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"larklang.io/lark/internal/jsonobj"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("avro", generator{})
+}
+
+type generator struct{}
+
+// Generate returns one schema file for every struct and enum of the root
+// files of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	var files []gen.File
+	for _, file := range s.Roots {
+		for _, decl := range file.Decls {
+			switch decl.(type) {
+			case *schema.Struct, *schema.Enum:
+			default:
+				continue
+			}
+			g := &fileGen{prefix: params["namespace"], defined: make(map[schema.Decl]bool)}
+			def, err := g.named(decl)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file.Path, err)
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(def); err != nil {
+				return nil, err
+			}
+			name := file.Module + "/" + decl.DeclInfo().Name + ".avsc"
+			files = append(files, gen.File{Name: name, Content: buf.Bytes()})
+		}
+	}
+	return files, nil
+}
+
+// fileGen generates one schema file.
+type fileGen struct {
+	prefix  string
+	defined map[schema.Decl]bool // named types defined so far
+	fixed   map[string]bool      // full names of the fixed types defined so far
+}
+
+// namespace returns the namespace of a module: module "api/users" is
+// namespace api.users, after the namespace prefix if there is one.
+func (g *fileGen) namespace(module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		switch {
+		case r == '/':
+			b.WriteByte('.')
+		case r == '_' || r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if g.prefix == "" {
+		return b.String()
+	}
+	return strings.TrimSuffix(g.prefix, ".") + "." + b.String()
+}
+
+// fullName returns the full name of a named type.
+func (g *fileGen) fullName(info *schema.Info) string {
+	return g.namespace(info.File.Module) + "." + info.Name
+}
+
+// doc returns the doc of a declaration or a field, with a "Deprecated:"
+// paragraph for the message of a @deprecated annotation.
+func doc(info *schema.Info) string {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok {
+		if text != "" {
+			text += "\n\n"
+		}
+		if msg == "" {
+			text += "Deprecated."
+		} else {
+			text += "Deprecated: " + msg
+		}
+	}
+	return text
+}
+
+// named returns the schema of a struct, an enum or a union: its
+// definition the first time, and its full name after that.
+func (g *fileGen) named(decl schema.Decl) (any, error) {
+	info := decl.DeclInfo()
+	if u, ok := decl.(*schema.Union); ok {
+		var branches []any
+		seen := make(map[schema.Decl]string)
+		for _, v := range u.Variants {
+			variant := v.Type.Underlying().Decl
+			if other, ok := seen[variant]; ok {
+				return nil, fmt.Errorf("variants %s and %s of %s have the same type, which Avro cannot tell apart", other, v.Name, u.Name)
+			}
+			seen[variant] = v.Name
+			b, err := g.named(variant)
+			if err != nil {
+				return nil, err
+			}
+			branches = append(branches, b)
+		}
+		return branches, nil
+	}
+	if g.defined[decl] {
+		return g.fullName(info), nil
+	}
+	g.defined[decl] = true
+
+	var def jsonobj.Object
+	switch decl.(type) {
+	case *schema.Struct:
+		def.Set("type", "record")
+	case *schema.Enum:
+		def.Set("type", "enum")
+	}
+	def.Set("name", info.Name)
+	def.Set("namespace", g.namespace(info.File.Module))
+	if text := doc(info); text != "" {
+		def.Set("doc", text)
+	}
+	switch decl := decl.(type) {
+	case *schema.Struct:
+		fields := []jsonobj.Object{}
+		for _, f := range decl.Fields {
+			field, err := g.field(decl, f)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %v", f.Name, decl.Name, err)
+			}
+			fields = append(fields, field)
+		}
+		def.Set("fields", fields)
+	case *schema.Enum:
+		symbols := []string{}
+		for _, m := range decl.Members {
+			symbols = append(symbols, m.Name)
+		}
+		def.Set("symbols", symbols)
+	}
+	return def, nil
+}
+
+// field returns the schema of a field of s.
+func (g *fileGen) field(s *schema.Struct, f *schema.Field) (jsonobj.Object, error) {
+	var field jsonobj.Object
+	name := f.JSONName()
+	if !isName(name) {
+		return nil, fmt.Errorf("JSON name %q is not an Avro name", name)
+	}
+	field.Set("name", name)
+	if text := doc(&f.Info); text != "" {
+		field.Set("doc", text)
+	}
+	typ, err := g.typ(f.Type, s.Name+pascal(f.Name))
+	if err != nil {
+		return nil, err
+	}
+	var def any
+	a := f.Annotations.Lookup("default")
+	if a != nil {
+		if len(a.Args) != 1 {
+			return nil, fmt.Errorf("@default takes one value")
+		}
+		if def, err = defaultValue(f.Type, a.Args[0]); err != nil {
+			return nil, fmt.Errorf("@default: %v", err)
+		}
+	}
+	switch {
+	case !f.Optional:
+	case a == nil:
+		typ = append([]any{"null"}, branches(typ)...)
+	default:
+		typ = []any{typ, "null"}
+	}
+	field.Set("type", typ)
+	switch {
+	case a != nil:
+		field.Set("default", def)
+	case f.Optional:
+		field.Set("default", nil)
+	}
+	return field, nil
+}
+
+// branches returns the branches of a union, or the schema as the only
+// branch.
+func branches(s any) []any {
+	if list, ok := s.([]any); ok {
+		return list
+	}
+	return []any{s}
+}
+
+// typ returns the schema of a type. Fixed types that have no alias are
+// named hint.
+func (g *fileGen) typ(t *schema.Type, hint string) (any, error) {
+	if t.Kind == schema.NamedType {
+		if alias, ok := t.Decl.(*schema.Alias); ok {
+			if isFixed(alias.Type) {
+				return g.fixedType(&alias.Info, g.fullName(&alias.Info), alias.Type.Len)
+			}
+			return g.typ(alias.Type, hint)
+		}
+		return g.named(t.Decl)
+	}
+	switch t.Kind {
+	case schema.PrimitiveType:
+		return primitive(t.Primitive), nil
+	case schema.ArrayType, schema.ListType:
+		if isFixed(t) {
+			return g.fixedType(nil, hint, t.Len)
+		}
+		items, err := g.typ(t.Elem, hint+"Item")
+		if err != nil {
+			return nil, err
+		}
+		var s jsonobj.Object
+		s.Set("type", "array")
+		s.Set("items", items)
+		return s, nil
+	case schema.MapType:
+		if k := t.Key.Underlying(); k.Kind != schema.PrimitiveType || k.Primitive != schema.String {
+			return nil, fmt.Errorf("Avro maps have string keys, not %s", t.Key)
+		}
+		values, err := g.typ(t.Elem, hint+"Value")
+		if err != nil {
+			return nil, err
+		}
+		var s jsonobj.Object
+		s.Set("type", "map")
+		s.Set("values", values)
+		return s, nil
+	}
+	panic(fmt.Sprintf("avro: invalid type %s", t))
+}
+
+// isFixed reports whether t is an array of bytes.
+func isFixed(t *schema.Type) bool {
+	if t.Kind != schema.ArrayType {
+		return false
+	}
+	elem := t.Elem.Underlying()
+	return elem.Kind == schema.PrimitiveType && elem.Primitive == schema.Uint8
+}
+
+// fixedType returns the schema of a fixed type of the given size, named
+// after an alias or, without one, after a record and a field.
+func (g *fileGen) fixedType(info *schema.Info, name string, size int64) (any, error) {
+	if g.fixed == nil {
+		g.fixed = make(map[string]bool)
+	}
+	if g.fixed[name] {
+		return name, nil
+	}
+	g.fixed[name] = true
+	var s jsonobj.Object
+	s.Set("type", "fixed")
+	if info != nil {
+		s.Set("name", info.Name)
+		s.Set("namespace", g.namespace(info.File.Module))
+		if text := doc(info); text != "" {
+			s.Set("doc", text)
+		}
+	} else {
+		s.Set("name", name)
+	}
+	s.Set("size", size)
+	return s, nil
+}
+
+// primitive returns the schema of a primitive type.
+func primitive(p schema.Primitive) any {
+	var s jsonobj.Object
+	switch {
+	case p == schema.Bool:
+		return "boolean"
+	case p == schema.Uint32 || p == schema.Int64:
+		return "long"
+	case p == schema.Uint64:
+		s.Set("type", "bytes")
+		s.Set("logicalType", "decimal")
+		s.Set("precision", 20)
+		s.Set("scale", 0)
+	case p.IsInteger():
+		return "int"
+	case p == schema.Float32:
+		return "float"
+	case p == schema.Float64:
+		return "double"
+	case p == schema.String:
+		return "string"
+	case p == schema.Bytes:
+		return "bytes"
+	case p == schema.Timestamp:
+		s.Set("type", "long")
+		s.Set("logicalType", "timestamp-micros")
+	case p == schema.UUID:
+		s.Set("type", "string")
+		s.Set("logicalType", "uuid")
+	}
+	return s
+}
+
+// defaultValue returns the Avro encoding of the default v of a field of
+// type t.
+func defaultValue(t *schema.Type, v constant.Value) (any, error) {
+	u := t.Underlying()
+	if u.Kind == schema.NamedType {
+		if e, ok := u.Decl.(*schema.Enum); ok && v.Kind() == constant.String {
+			for _, m := range e.Members {
+				if m.Name == v.StringVal() {
+					return m.Name, nil
+				}
+			}
+			return nil, fmt.Errorf("%s is not a member of %s", v, e.Name)
+		}
+	}
+	if u.Kind == schema.PrimitiveType {
+		p := u.Primitive
+		switch {
+		case p == schema.Bool && v.Kind() == constant.Bool:
+			return v.BoolVal(), nil
+		case p.IsInteger() && p != schema.Uint64 && v.Kind() == constant.Int:
+			if i, ok := v.Int64(); ok {
+				return i, nil
+			}
+		case p.IsFloat() && (v.Kind() == constant.Int || v.Kind() == constant.Float):
+			return v.Float64(), nil
+		case (p == schema.String || p == schema.Bytes || p == schema.UUID) && v.Kind() == constant.String:
+			return v.StringVal(), nil
+		case p == schema.Timestamp && v.Kind() == constant.String:
+			ts, err := time.Parse(time.RFC3339Nano, v.StringVal())
+			if err != nil {
+				return nil, fmt.Errorf("%s is not an RFC 3339 timestamp", v)
+			}
+			return ts.UnixMicro(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot use %s constant %s as %s default", v.Kind(), v, t)
+}
+
+// isName reports whether name is an Avro name.
+func isName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// pascal returns a name in Pascal case: "user_id" becomes "UserId".
+func pascal(name string) string {
+	var b strings.Builder
+	for _, word := range gen.Words(name) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
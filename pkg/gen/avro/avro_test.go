@@ -0,0 +1,45 @@
+package avro
+
+import (
+	"encoding/json"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"namespace": "com.example"}, "users.lark")
+	var got []byte
+	for _, f := range files {
+		if !json.Valid(f.Content) {
+			t.Errorf("%s is not valid JSON", f.Name)
+		}
+		got = append(got, "-- "+f.Name+" --\n"...)
+		got = append(got, f.Content...)
+	}
+	gentest.Compare(t, "testdata/users.golden", "output", got)
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"struct S { m: map[int32, string] }", "field m of S: Avro maps have string keys, not int32"},
+		{"struct S { @json(\"x-y\") x: int32 }", `field x of S: JSON name "x-y" is not an Avro name`},
+		{"struct S { @default(\"x\") n: int32 }", `field n of S: @default: cannot use string constant "x" as int32 default`},
+		{"struct S { @default(1) n: uint64 }", "field n of S: @default: cannot use int constant 1 as uint64 default"},
+		{"enum E { a }\nstruct S { @default(\"b\") e: E }", `field e of S: @default: "b" is not a member of E`},
+		{"struct S { @default(\"yesterday\") t: timestamp }", `field t of S: @default: "yesterday" is not an RFC 3339 timestamp`},
+		{"struct A {}\nunion U { a: A\n b: A }\nstruct S { u: U }", "field u of S: variants a and b of U have the same type, which Avro cannot tell apart"},
+		{"struct A {}\nunion U { a: A }\nstruct S { @default(1) u?: U }", "field u of S: @default: cannot use int constant 1 as errors.U default"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+// Package c implements the "c" generator, which produces C11 headers of
+// fixed-size types from checked Lark schemas, for firmware that reads and
+// writes messages as raw memory.
+//
+// Every Lark module becomes a header at its module path: module
+// "api/users" becomes api/users.h, guarded by API_USERS_H. Headers
+// include the headers of the modules they use by their path, so the
+// output directory must be on the include path. Declarations are
+// translated as follows:
+//
+//   - Structs become typedef'd structs. An optional field is preceded by
+//     a bool field has_<name> that tells whether it is set. Structs must
+//     have fields, since C has no empty structs.
+//   - Integers and floats become the fixed-width types of stdint.h, float
+//     and double. Timestamps become int64_t nanoseconds since the Unix
+//     epoch, and UUIDs become uint8_t[16] in network byte order.
+//   - Arrays [T; N] become C arrays. Strings, bytes, lists and maps have
+//     no fixed size and are rejected.
+//   - Enums become int32_t typedefs with one enumeration constant per
+//     member, such as ROLE_ADMIN.
+//   - Unions become structs of an int32_t tag, named like the tag
+//     member, and a C union value with one member per variant. The tag
+//     constants, such as MESSAGE_PING, count from 1, so that 0 means no
+//     variant.
+//   - Constants become macros in upper case. Integer constants use the
+//     INTN_C macros of stdint.h, timestamps are nanoseconds and UUIDs are
+//     brace initializers of 16 bytes. Null constants are left out.
+//   - Type aliases become typedefs.
+//   - Interfaces are left out.
+//
+// Declarations are reordered where a type is used before its
+// declaration. The @packed annotation of a struct and the @align(n)
+// annotations of structs and fields set the layout with the packed and
+// aligned attributes of GCC and Clang and with _Alignas. Every header
+// ends with the size, the alignment and the member offsets that the
+// generator computed for its structs and unions, checked with
+// _Static_assert, so that a compiler with a different layout rejects the
+// header instead of misreading messages.
+//
+// Doc comments are carried over, and @deprecated annotations become
+// "Deprecated:" paragraphs. C names are global, so declarations of
+// different modules must not map to the same name.
+//
+// The generator takes one parameter:
+//
+//	prefix  prefix of type names, and in upper case of macros (default: none)
+package c
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("c", generator{})
+}
+
+type generator struct{}
+
+// Generate returns one header for every root file of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	prefix := params["prefix"]
+	if err := checkNames(s, prefix); err != nil {
+		return nil, err
+	}
+	l := newLayouts()
+	var files []gen.File
+	for _, file := range s.Roots {
+		g := &fileGen{file: file, prefix: prefix, layouts: l, includes: map[string]bool{}}
+		src, err := g.generate()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, gen.File{Name: file.Module + ".h", Content: src})
+	}
+	return files, nil
+}
+
+// checkNames reports an error if declarations of s map to the same C
+// name. A module that is loaded both as a root and as an import is only
+// checked once.
+func checkNames(s *schema.Schema, prefix string) error {
+	seen := map[string]string{}
+	modules := map[string]bool{}
+	add := func(file *schema.File, cname, name string) error {
+		qualified := file.Module + "." + name
+		if other, ok := seen[cname]; ok {
+			return fmt.Errorf("%s: %s and %s are both named %s in C", file.Path, other, qualified, cname)
+		}
+		seen[cname] = qualified
+		return nil
+	}
+	for _, file := range s.Files {
+		if modules[file.Module] {
+			continue
+		}
+		modules[file.Module] = true
+		for _, decl := range file.Decls {
+			var err error
+			switch decl := decl.(type) {
+			case *schema.Const:
+				err = add(file, macroName(prefix, decl.Name), decl.Name)
+			case *schema.Alias, *schema.Struct:
+				err = add(file, typeName(prefix, decl.DeclInfo().Name), decl.DeclInfo().Name)
+			case *schema.Enum:
+				err = add(file, typeName(prefix, decl.Name), decl.Name)
+				for _, m := range decl.Members {
+					if err == nil {
+						err = add(file, macroName(prefix, decl.Name, m.Name), decl.Name+"."+m.Name)
+					}
+				}
+			case *schema.Union:
+				err = add(file, typeName(prefix, decl.Name), decl.Name)
+				for _, v := range decl.Variants {
+					if err == nil {
+						err = add(file, macroName(prefix, decl.Name, v.Name), decl.Name+"."+v.Name)
+					}
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fileGen generates the header for one Lark file.
+type fileGen struct {
+	file     *schema.File
+	prefix   string
+	layouts  *layouts
+	includes map[string]bool // modules whose headers are included
+	stdbool  bool            // bool, true or false is used
+	stdint   bool            // a type or macro of stdint.h is used
+	asserts  bytes.Buffer    // layout section
+	buf      bytes.Buffer
+}
+
+func (g *fileGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *fileGen) generate() ([]byte, error) {
+	for _, decl := range g.order() {
+		var err error
+		switch decl := decl.(type) {
+		case *schema.Const:
+			err = g.constant(decl)
+		case *schema.Alias:
+			err = g.alias(decl)
+		case *schema.Struct:
+			err = g.structType(decl)
+		case *schema.Enum:
+			g.enum(decl)
+		case *schema.Union:
+			err = g.union(decl)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g.file.Path, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "/* Code generated by lark gen from %s. DO NOT EDIT. */\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		out.WriteString("\n")
+		out.WriteString(comment(g.file.Doc, ""))
+	}
+	guard := guardName(g.prefix, g.file.Module)
+	fmt.Fprintf(&out, "\n#ifndef %s\n#define %s\n", guard, guard)
+
+	var std []string
+	if g.stdbool {
+		std = append(std, "stdbool.h")
+	}
+	if g.asserts.Len() > 0 {
+		std = append(std, "stddef.h")
+	}
+	if g.stdint {
+		std = append(std, "stdint.h")
+	}
+	if len(std) > 0 {
+		out.WriteString("\n")
+		for _, h := range std {
+			fmt.Fprintf(&out, "#include <%s>\n", h)
+		}
+	}
+	modules := make([]string, 0, len(g.includes))
+	for module := range g.includes {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	if len(modules) > 0 {
+		out.WriteString("\n")
+		for _, module := range modules {
+			fmt.Fprintf(&out, "#include \"%s.h\"\n", module)
+		}
+	}
+
+	out.Write(g.buf.Bytes())
+	if g.asserts.Len() > 0 {
+		out.WriteString("\n/* Layout */\n")
+		out.Write(g.asserts.Bytes())
+	}
+	fmt.Fprintf(&out, "\n#endif /* %s */\n", guard)
+	return out.Bytes(), nil
+}
+
+// order returns the declarations of the file in source order, except that
+// types come after the types of the file that they use, as C requires.
+// Interfaces are left out.
+func (g *fileGen) order() []schema.Decl {
+	var list []schema.Decl
+	done := map[schema.Decl]bool{}
+	var visit func(decl schema.Decl)
+	var visitType func(t *schema.Type)
+	visitType = func(t *schema.Type) {
+		switch t.Kind {
+		case schema.ArrayType, schema.ListType:
+			visitType(t.Elem)
+		case schema.MapType:
+			visitType(t.Key)
+			visitType(t.Elem)
+		case schema.NamedType:
+			if t.Decl.DeclInfo().File == g.file {
+				visit(t.Decl)
+			}
+		}
+	}
+	visit = func(decl schema.Decl) {
+		if done[decl] {
+			return
+		}
+		// Types that contain themselves are rejected by their layout.
+		done[decl] = true
+		switch decl := decl.(type) {
+		case *schema.Alias:
+			visitType(decl.Type)
+		case *schema.Struct:
+			for _, field := range decl.Fields {
+				visitType(field.Type)
+			}
+		case *schema.Union:
+			for _, variant := range decl.Variants {
+				visitType(variant.Type)
+			}
+		case *schema.Interface:
+			return
+		}
+		list = append(list, decl)
+	}
+	for _, decl := range g.file.Decls {
+		visit(decl)
+	}
+	return list
+}
+
+// comment returns text as a C comment with the given indent.
+func comment(text, indent string) string {
+	text = strings.ReplaceAll(text, "*/", "* /")
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		return indent + "/* " + text + " */\n"
+	}
+	var b strings.Builder
+	b.WriteString(indent + "/*\n")
+	for _, line := range lines {
+		b.WriteString(strings.TrimRight(indent+" * "+line, " ") + "\n")
+	}
+	b.WriteString(indent + " */\n")
+	return b.String()
+}
+
+// doc writes the doc comment of an item, with a "Deprecated:" paragraph
+// for @deprecated annotations.
+func (g *fileGen) doc(info *schema.Info, indent string) {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok {
+		if msg == "" {
+			msg = "do not use."
+		}
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Deprecated: " + msg
+	}
+	if text != "" {
+		g.buf.WriteString(comment(text, indent))
+	}
+}
+
+// decl returns the C declaration of name with type t: arrays become
+// array declarators.
+func (g *fileGen) decl(t *schema.Type, name string) string {
+	var dims strings.Builder
+	for t.Kind == schema.ArrayType {
+		fmt.Fprintf(&dims, "[%d]", t.Len)
+		t = t.Elem
+	}
+	if t.Kind == schema.PrimitiveType && t.Primitive == schema.UUID {
+		dims.WriteString("[16]")
+	}
+	return g.typ(t) + " " + name + dims.String()
+}
+
+// typ returns the C type of a Lark type that is not an array. UUIDs are
+// uint8_t, the element type of their array.
+func (g *fileGen) typ(t *schema.Type) string {
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch p := t.Primitive; {
+		case p == schema.Bool:
+			g.stdbool = true
+			return "bool"
+		case p.IsInteger():
+			g.stdint = true
+			return p.String() + "_t"
+		case p == schema.Float32:
+			return "float"
+		case p == schema.Float64:
+			return "double"
+		case p == schema.Timestamp:
+			g.stdint = true
+			return "int64_t"
+		case p == schema.UUID:
+			g.stdint = true
+			return "uint8_t"
+		}
+	case schema.NamedType:
+		info := t.Decl.DeclInfo()
+		if info.File != g.file {
+			g.includes[info.File.Module] = true
+		}
+		return typeName(g.prefix, info.Name)
+	}
+	// Layouts reject the other types before they are printed.
+	panic(fmt.Sprintf("c: %s has no C type", t))
+}
+
+func (g *fileGen) constant(k *schema.Const) error {
+	if k.Value.Kind() == constant.Null {
+		return nil
+	}
+	var value string
+	switch p := k.Type.Underlying().Primitive; {
+	case p.IsInteger():
+		value = g.intLiteral(p, k.Value)
+	case p.IsFloat():
+		value = k.Value.String()
+		if !strings.ContainsAny(value, ".e") {
+			value += ".0"
+		}
+		if p == schema.Float32 {
+			value += "f"
+		}
+	case p == schema.Bool:
+		g.stdbool = true
+		value = strconv.FormatBool(k.Value.BoolVal())
+	case p == schema.String, p == schema.Bytes:
+		value = stringLiteral(k.Value.StringVal())
+	case p == schema.Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, k.Value.StringVal())
+		if err != nil {
+			return fmt.Errorf("constant %s: %v", k.Name, err)
+		}
+		if t.Before(time.Unix(0, math.MinInt64)) || t.After(time.Unix(0, math.MaxInt64)) {
+			return fmt.Errorf("constant %s: %s is out of the range of int64 nanoseconds", k.Name, k.Value.StringVal())
+		}
+		g.stdint = true
+		value = fmt.Sprintf("INT64_C(%d) /* %s */", t.UnixNano(), t.UTC().Format(time.RFC3339Nano))
+	case p == schema.UUID:
+		id := strings.ToLower(k.Value.StringVal())
+		hex := strings.ReplaceAll(id, "-", "")
+		var octets []string
+		for i := 0; i < len(hex); i += 2 {
+			octets = append(octets, "0x"+hex[i:i+2])
+		}
+		value = fmt.Sprintf("{%s} /* %s */", strings.Join(octets, ", "), id)
+	}
+	g.printf("\n")
+	g.doc(&k.Info, "")
+	g.printf("#define %s %s\n", macroName(g.prefix, k.Name), value)
+	return nil
+}
+
+// intLiteral returns an integer constant of type p with the INTN_C macro
+// of its width.
+func (g *fileGen) intLiteral(p schema.Primitive, v constant.Value) string {
+	g.stdint = true
+	macro := strings.ToUpper(p.String()) + "_C"
+	if i, ok := v.Int64(); ok && i == math.MinInt64 {
+		// 9223372036854775808 is not an int64_t literal.
+		return "(-INT64_C(9223372036854775807) - 1)"
+	}
+	return macro + "(" + v.String() + ")"
+}
+
+// stringLiteral returns s as a C string literal. Bytes that are not
+// printable ASCII are octal escapes, which unlike hex escapes end after
+// three digits.
+func stringLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '?':
+			// Avoid trigraphs.
+			b.WriteString(`\?`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (g *fileGen) alias(a *schema.Alias) error {
+	if _, err := g.layouts.of(a.Type); err != nil {
+		return fmt.Errorf("type %s: %v", a.Name, err)
+	}
+	g.printf("\n")
+	g.doc(&a.Info, "")
+	g.printf("typedef %s;\n", g.decl(a.Type, typeName(g.prefix, a.Name)))
+	return nil
+}
+
+func (g *fileGen) structType(s *schema.Struct) error {
+	sl, err := g.layouts.structLayout(s)
+	if err != nil {
+		return err
+	}
+	var attrs []string
+	if isPacked, _ := packed(s); isPacked {
+		attrs = append(attrs, "packed")
+	}
+	if align, _ := alignArg(&s.Info); align != 0 {
+		attrs = append(attrs, fmt.Sprintf("aligned(%d)", align))
+	}
+	attr := ""
+	if len(attrs) > 0 {
+		attr = "__attribute__((" + strings.Join(attrs, ", ") + ")) "
+	}
+	name := typeName(g.prefix, s.Name)
+	g.printf("\n")
+	g.doc(&s.Info, "")
+	g.printf("typedef struct %s%s {\n", attr, name)
+	for _, field := range s.Fields {
+		g.doc(&field.Info, "    ")
+		if field.Optional {
+			g.stdbool = true
+			g.printf("    bool %s;\n", hasName(field.Name))
+		}
+		alignas := ""
+		if align, _ := alignArg(&field.Info); align != 0 {
+			alignas = fmt.Sprintf("_Alignas(%d) ", align)
+		}
+		g.printf("    %s%s;\n", alignas, g.decl(field.Type, fieldName(field.Name)))
+	}
+	g.printf("} %s;\n", name)
+	g.assert(name, sl)
+	return nil
+}
+
+func (g *fileGen) enum(e *schema.Enum) {
+	name := typeName(g.prefix, e.Name)
+	g.stdint = true
+	g.printf("\n")
+	g.doc(&e.Info, "")
+	g.printf("typedef int32_t %s;\n", name)
+	if len(e.Members) == 0 {
+		return
+	}
+	g.printf("enum {\n")
+	for _, m := range e.Members {
+		g.doc(&m.Info, "    ")
+		g.printf("    %s = %d,\n", macroName(g.prefix, e.Name, m.Name), m.Value)
+	}
+	g.printf("};\n")
+}
+
+func (g *fileGen) union(u *schema.Union) error {
+	ul, err := g.layouts.unionLayout(u)
+	if err != nil {
+		return err
+	}
+	tag := fieldName(u.Tag)
+	if tag == "value" {
+		return fmt.Errorf("union %s: the tag %s clashes with the member value", u.Name, u.Tag)
+	}
+	name := typeName(g.prefix, u.Name)
+	g.stdint = true
+	g.printf("\n")
+	g.doc(&u.Info, "")
+	g.printf("typedef struct %s {\n", name)
+	g.printf("    int32_t %s;\n", tag)
+	if len(u.Variants) > 0 {
+		g.printf("    union {\n")
+		for _, variant := range u.Variants {
+			g.doc(&variant.Info, "        ")
+			g.printf("        %s;\n", g.decl(variant.Type, fieldName(variant.Name)))
+		}
+		g.printf("    } value;\n")
+	}
+	g.printf("} %s;\n", name)
+	if len(u.Variants) > 0 {
+		g.printf("enum {\n")
+		for i, variant := range u.Variants {
+			g.printf("    %s = %d,\n", macroName(g.prefix, u.Name, variant.Name), i+1)
+		}
+		g.printf("};\n")
+	}
+	g.assert(name, ul)
+	return nil
+}
+
+// assert adds the layout of a struct to the layout section.
+func (g *fileGen) assert(name string, l *layout) {
+	a := &g.asserts
+	fmt.Fprintf(a, "\n/* %s: %d bytes, aligned to %d. */\n", name, l.size, l.align)
+	fmt.Fprintf(a, "_Static_assert(sizeof(%s) == %d, \"size of %s\");\n", name, l.size, name)
+	fmt.Fprintf(a, "_Static_assert(_Alignof(%s) == %d, \"alignment of %s\");\n", name, l.align, name)
+	for _, m := range l.members {
+		fmt.Fprintf(a, "_Static_assert(offsetof(%s, %s) == %d, \"offset of %s.%s\");\n", name, m.name, m.offset, name, m.name)
+	}
+}
@@ -0,0 +1,123 @@
+package c
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "device.lark", "common/types.lark")
+	if len(files) != 2 || files[0].Name != "device.h" || files[1].Name != "common/types.h" {
+		t.Fatalf("got %d files", len(files))
+	}
+	for _, file := range files {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".h")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+// TestCompile checks the layout assertions of the headers with the C
+// compiler, if there is one.
+func TestCompile(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	for _, file := range gentest.Generate(t, generator{}, gen.Params{"prefix": "lk_"}, "device.lark", "common/types.lark") {
+		name := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, file.Content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := `#include "device.h"
+#include "device.h"
+
+static const uint8_t vendor[16] = LK_VENDOR;
+static const lk_Message message = {LK_MESSAGE_PING};
+static const lk_Mode mode = LK_MODE_POLLING;
+static const lk_Samples samples[LK_MAX_SAMPLES];
+`
+	main := filepath.Join(dir, "main.c")
+	if err := os.WriteFile(main, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(cc, "-std=c11", "-Wall", "-Werror", "-Wno-unused", "-fsyntax-only", "-I", dir, main).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", cc, err, out)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"prefix": "lk_"}, "common/types.lark")
+	src := string(files[0].Content)
+	for _, want := range []string{
+		"#ifndef LK_COMMON_TYPES_H\n",
+		"typedef uint8_t lk_Flags[4];\n",
+		"#define LK_MAGIC UINT32_C(1279349323)\n",
+		"} lk_Ping;\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("header does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"struct S { name: string }", "field name of S: string has no fixed size"},
+		{"struct S { ids: list[int32] }", "field ids of S: list[int32] has no fixed size"},
+		{"type Name = [string; 2]", "type Name: string has no fixed size"},
+		{"struct S {}", "struct S has no fields"},
+		{"struct S { t?: T }\nstruct T { s: [S; 1] }", "field s of T: field t of S: struct T contains itself"},
+		{"@align(3) struct S { x: int8 }", "@align of S takes one power of two"},
+		{"struct S { @align(2) x: int64 }", "@align(2) of x is less than the alignment 8 of int64"},
+		{"@packed(1) struct S { x: int8 }", "@packed of S takes no arguments"},
+		{"@tag(\"value\") union U {}", "union U: the tag value clashes with the member value"},
+		{"const MaxSize = 1\nconst MAX_SIZE = 2", "errors.MaxSize and errors.MAX_SIZE are both named MAX_SIZE in C"},
+		{"enum Role { admin }\nconst RoleAdmin = 1", "errors.Role.admin and errors.RoleAdmin are both named ROLE_ADMIN in C"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, field, macro string
+	}{
+		{"user", "user", "USER"},
+		{"userId", "userId", "USER_ID"},
+		{"HTTPServer", "HTTPServer", "HTTP_SERVER"},
+		{"super_user", "super_user", "SUPER_USER"},
+		{"int", "int_", "INT"},
+		{"bool", "bool_", "BOOL"},
+	}
+	for _, test := range tests {
+		if got := fieldName(test.name); got != test.field {
+			t.Errorf("fieldName(%q) = %q; want %q", test.name, got, test.field)
+		}
+		if got := macroName("", test.name); got != test.macro {
+			t.Errorf("macroName(%q) = %q; want %q", test.name, got, test.macro)
+		}
+	}
+	if got, want := guardName("lk_", "api/v1-users"), "LK_API_V1_USERS_H"; got != want {
+		t.Errorf("guardName = %q; want %q", got, want)
+	}
+}
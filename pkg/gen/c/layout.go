@@ -0,0 +1,181 @@
+package c
+
+import (
+	"fmt"
+
+	"larklang.io/lark/pkg/schema"
+)
+
+// A layout is the memory layout of a C type. Sizes and alignments follow
+// the common ABIs of 32 and 64-bit targets, in which every primitive is
+// aligned to its size; the header checks them with _Static_assert.
+type layout struct {
+	size, align int64
+	members     []member // of structs and unions
+}
+
+// A member is a member of a struct at an offset.
+type member struct {
+	name   string
+	offset int64
+}
+
+// layouts computes and caches the layouts of structs and unions.
+type layouts struct {
+	decls    map[schema.Decl]*layout
+	visiting map[schema.Decl]bool
+}
+
+func newLayouts() *layouts {
+	return &layouts{decls: map[schema.Decl]*layout{}, visiting: map[schema.Decl]bool{}}
+}
+
+// of returns the layout of a type.
+func (l *layouts) of(t *schema.Type) (*layout, error) {
+	u := t.Underlying()
+	switch u.Kind {
+	case schema.PrimitiveType:
+		switch u.Primitive {
+		case schema.Bool, schema.Int8, schema.Uint8:
+			return &layout{size: 1, align: 1}, nil
+		case schema.Int16, schema.Uint16:
+			return &layout{size: 2, align: 2}, nil
+		case schema.Int32, schema.Uint32, schema.Float32:
+			return &layout{size: 4, align: 4}, nil
+		case schema.Int64, schema.Uint64, schema.Float64, schema.Timestamp:
+			return &layout{size: 8, align: 8}, nil
+		case schema.UUID:
+			return &layout{size: 16, align: 1}, nil
+		}
+	case schema.ArrayType:
+		elem, err := l.of(u.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &layout{size: elem.size * u.Len, align: elem.align}, nil
+	case schema.NamedType:
+		switch decl := u.Decl.(type) {
+		case *schema.Enum:
+			return &layout{size: 4, align: 4}, nil
+		case *schema.Struct:
+			return l.structLayout(decl)
+		case *schema.Union:
+			return l.unionLayout(decl)
+		}
+	}
+	return nil, fmt.Errorf("%s has no fixed size", t)
+}
+
+// alignArg returns the argument of the @align annotation of a struct or a
+// field, or 0 if there is none.
+func alignArg(info *schema.Info) (int64, error) {
+	a := info.Annotations.Lookup("align")
+	if a == nil {
+		return 0, nil
+	}
+	n, ok := a.Int(0)
+	if !ok || len(a.Args) != 1 || n < 1 || n&(n-1) != 0 {
+		return 0, fmt.Errorf("@align of %s takes one power of two", info.Name)
+	}
+	return n, nil
+}
+
+// packed reports whether a struct has the @packed annotation.
+func packed(s *schema.Struct) (bool, error) {
+	a := s.Annotations.Lookup("packed")
+	if a != nil && len(a.Args) > 0 {
+		return false, fmt.Errorf("@packed of %s takes no arguments", s.Name)
+	}
+	return a != nil, nil
+}
+
+func (l *layouts) structLayout(s *schema.Struct) (*layout, error) {
+	if sl, ok := l.decls[s]; ok {
+		return sl, nil
+	}
+	if l.visiting[s] {
+		return nil, fmt.Errorf("struct %s contains itself", s.Name)
+	}
+	l.visiting[s] = true
+	defer delete(l.visiting, s)
+
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("struct %s has no fields", s.Name)
+	}
+	isPacked, err := packed(s)
+	if err != nil {
+		return nil, err
+	}
+	sl := &layout{align: 1}
+	add := func(name string, m *layout, align int64) {
+		if isPacked {
+			m = &layout{size: m.size, align: 1}
+		}
+		a := max(m.align, align)
+		offset := roundUp(sl.size, a)
+		sl.members = append(sl.members, member{name, offset})
+		sl.size = offset + m.size
+		sl.align = max(sl.align, a)
+	}
+	for _, field := range s.Fields {
+		m, err := l.of(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", field.Name, s.Name, err)
+		}
+		align, err := alignArg(&field.Info)
+		if err != nil {
+			return nil, err
+		}
+		if align != 0 && align < m.align {
+			return nil, fmt.Errorf("@align(%d) of %s is less than the alignment %d of %s", align, field.Name, m.align, field.Type)
+		}
+		if field.Optional {
+			add(hasName(field.Name), &layout{size: 1, align: 1}, 0)
+		}
+		add(fieldName(field.Name), m, align)
+	}
+	align, err := alignArg(&s.Info)
+	if err != nil {
+		return nil, err
+	}
+	sl.align = max(sl.align, align)
+	sl.size = roundUp(sl.size, sl.align)
+	l.decls[s] = sl
+	return sl, nil
+}
+
+// unionLayout returns the layout of the struct of a union: an int32 tag
+// followed by a C union of the variants.
+func (l *layouts) unionLayout(u *schema.Union) (*layout, error) {
+	if ul, ok := l.decls[u]; ok {
+		return ul, nil
+	}
+	if l.visiting[u] {
+		return nil, fmt.Errorf("union %s contains itself", u.Name)
+	}
+	l.visiting[u] = true
+	defer delete(l.visiting, u)
+
+	ul := &layout{size: 4, align: 4, members: []member{{fieldName(u.Tag), 0}}}
+	if len(u.Variants) > 0 {
+		value := &layout{align: 1}
+		for _, variant := range u.Variants {
+			m, err := l.of(variant.Type)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s of %s: %v", variant.Name, u.Name, err)
+			}
+			value.size = max(value.size, m.size)
+			value.align = max(value.align, m.align)
+		}
+		offset := roundUp(ul.size, value.align)
+		ul.members = append(ul.members, member{"value", offset})
+		ul.align = max(ul.align, value.align)
+		ul.size = roundUp(offset+roundUp(value.size, value.align), ul.align)
+	}
+	l.decls[u] = ul
+	return ul, nil
+}
+
+func roundUp(n, align int64) int64 {
+	return (n + align - 1) / align * align
+}
@@ -0,0 +1,76 @@
+package c
+
+import (
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+)
+
+// keywords are the keywords of C11 and the macros of stdbool.h.
+var keywords = map[string]bool{
+	"auto": true, "break": true, "case": true, "char": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true,
+	"else": true, "enum": true, "extern": true, "float": true, "for": true,
+	"goto": true, "if": true, "inline": true, "int": true, "long": true,
+	"register": true, "restrict": true, "return": true, "short": true,
+	"signed": true, "sizeof": true, "static": true, "struct": true,
+	"switch": true, "typedef": true, "union": true, "unsigned": true,
+	"void": true, "volatile": true, "while": true,
+	"_Alignas": true, "_Alignof": true, "_Atomic": true, "_Bool": true,
+	"_Complex": true, "_Generic": true, "_Imaginary": true,
+	"_Noreturn": true, "_Static_assert": true, "_Thread_local": true,
+	"bool": true, "true": true, "false": true,
+}
+
+// escape appends an underscore to keywords.
+func escape(name string) string {
+	if keywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// typeName returns the C name of a declaration: its Lark name with the
+// prefix of the generated names.
+func typeName(prefix, name string) string {
+	return escape(prefix + name)
+}
+
+// fieldName returns the C name of a field or a variant, which is its Lark
+// name unless that is a keyword.
+func fieldName(name string) string {
+	return escape(name)
+}
+
+// hasName returns the name of the flag that tells whether an optional
+// field is set.
+func hasName(name string) string {
+	return "has_" + name
+}
+
+// macroName returns the name of a macro or an enumeration constant in
+// upper snake case with the upper case prefix: "MaxSamples" becomes
+// "MAX_SAMPLES".
+func macroName(prefix string, names ...string) string {
+	var words []string
+	for _, name := range names {
+		for _, word := range gen.Words(name) {
+			words = append(words, strings.ToUpper(word))
+		}
+	}
+	return strings.ToUpper(prefix) + strings.Join(words, "_")
+}
+
+// guardName returns the name of the include guard of a module: module
+// "common/types" has the guard COMMON_TYPES_H.
+func guardName(prefix, module string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(prefix + module) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String() + "_H"
+}
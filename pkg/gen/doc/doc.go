@@ -0,0 +1,541 @@
+// Package doc implements the "doc" generator, which renders checked Lark
+// schemas as a catalog of Markdown and static HTML pages.
+//
+// Every file of the schema, including the files that the root files
+// import, becomes a page at its module path: module "api/users" becomes
+// api/users.md and api/users.html. An index page, index.md and
+// index.html, lists the modules and all declarations by name. A page
+// holds the doc comment of its file, the modules it imports and the
+// modules that import it, and a section for every declaration:
+//
+//   - Constants show their declaration and, for computed constants,
+//     their value.
+//   - Structs list their fields, enums their members with their values,
+//     unions their variants and interfaces their methods in tables, with
+//     types, annotations and doc comments.
+//   - Every declaration lists the declarations that refer to it.
+//
+// Every name in a type, a constant expression or an annotation argument
+// links to its declaration, and @deprecated annotations become badges and
+// "Deprecated:" notes. Links are relative and the pages have their styles
+// inline, so the catalog can be browsed from the file system.
+//
+// The generator takes these parameters:
+//
+//	format  markdown or html (default: both)
+//	title   title of the index page (default: Schema catalog)
+package doc
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/scanner"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("doc", generator{})
+}
+
+type generator struct{}
+
+// indexPage is the module path of the index page.
+const indexPage = "index"
+
+// Generate returns the pages of every file of s and the index pages.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	var formats []func() renderer
+	switch params["format"] {
+	case "":
+		formats = append(formats, newMarkdown, newHTML)
+	case "markdown":
+		formats = append(formats, newMarkdown)
+	case "html":
+		formats = append(formats, newHTML)
+	default:
+		return nil, fmt.Errorf("unknown format %q: want markdown or html", params["format"])
+	}
+	title := params["title"]
+	if title == "" {
+		title = "Schema catalog"
+	}
+	for _, file := range s.Files {
+		if file.Module == indexPage {
+			return nil, fmt.Errorf("%s: module %s would overwrite the index page", file.Path, file.Module)
+		}
+	}
+	c := newCatalog(s, title)
+
+	var files []gen.File
+	for _, format := range formats {
+		r := format()
+		c.index(r)
+		files = append(files, gen.File{Name: indexPage + r.ext(), Content: r.end()})
+		for _, file := range s.Files {
+			r := format()
+			c.page(r, file)
+			files = append(files, gen.File{Name: file.Module + r.ext(), Content: r.end()})
+		}
+	}
+	return files, nil
+}
+
+// A span is a piece of inline text.
+type span struct {
+	text   string
+	code   bool   // monospace
+	doc    bool   // a doc comment
+	badge  bool   // a badge, such as "deprecated"
+	link   bool   // a link to anchor on the page of module
+	module string // page of a link
+	anchor string // anchor of a link, or "" for the top of the page
+	id     string // anchor of the span itself, or ""
+}
+
+func text(s string) span { return span{text: s} }
+
+func code(s string) span { return span{text: s, code: true} }
+
+// ref returns a link to the section of a declaration or a member of one.
+func ref(label string, d schema.Decl, member string) span {
+	info := d.DeclInfo()
+	anchor := info.Name
+	if member != "" {
+		anchor += "." + member
+	}
+	return span{text: label, code: true, link: true, module: info.File.Module, anchor: anchor}
+}
+
+// moduleRef returns a link to the page of a module.
+func moduleRef(module string) span {
+	return span{text: module, code: true, link: true, module: module}
+}
+
+var deprecatedBadge = span{text: "deprecated", badge: true}
+
+// A renderer writes a page in one format. Blocks are written in order;
+// end returns the page.
+type renderer interface {
+	ext() string
+	begin(title, module string)
+	heading(level int, id string, spans []span)
+	doc(text string)
+	deprecation(msg string)
+	code(spans []span)
+	line(label string, items [][]span)
+	list(items [][]span)
+	table(header []string, rows [][][]span)
+	end() []byte
+}
+
+// relPath returns the path of the page of module to relative to the page
+// of module from, without extension.
+func relPath(from, to string) string {
+	var dir []string
+	if d := path.Dir(from); d != "." {
+		dir = strings.Split(d, "/")
+	}
+	parts := strings.Split(to, "/")
+	i := 0
+	for i < len(dir) && i < len(parts)-1 && dir[i] == parts[i] {
+		i++
+	}
+	return strings.Repeat("../", len(dir)-i) + strings.Join(parts[i:], "/")
+}
+
+// href returns the target of a link on the page of module from.
+func href(from string, s span, ext string) string {
+	var target string
+	if s.module != from {
+		target = relPath(from, s.module) + ext
+	}
+	if s.anchor != "" {
+		target += "#" + s.anchor
+	}
+	return target
+}
+
+// A catalog holds what the pages of a schema refer to.
+type catalog struct {
+	s          *schema.Schema
+	title      string
+	nodes      map[*schema.File]map[scanner.Pos]ast.Node // declarations and members by the position of their names
+	usedBy     map[schema.Decl][]schema.Decl
+	importedBy map[*schema.File][]*schema.File
+}
+
+func newCatalog(s *schema.Schema, title string) *catalog {
+	c := &catalog{
+		s:          s,
+		title:      title,
+		nodes:      make(map[*schema.File]map[scanner.Pos]ast.Node),
+		usedBy:     make(map[schema.Decl][]schema.Decl),
+		importedBy: make(map[*schema.File][]*schema.File),
+	}
+	for _, file := range s.Files {
+		for _, imp := range file.Imports {
+			if !slices.Contains(c.importedBy[imp.File], file) {
+				c.importedBy[imp.File] = append(c.importedBy[imp.File], file)
+			}
+		}
+		nodes := make(map[scanner.Pos]ast.Node)
+		c.nodes[file] = nodes
+		if file.AST == nil {
+			continue
+		}
+		ast.Walk(&indexer{nodes: nodes}, file.AST)
+		for _, decl := range file.Decls {
+			node := nodes[decl.DeclInfo().Pos]
+			if node == nil {
+				continue
+			}
+			seen := map[schema.Decl]bool{decl: true}
+			ast.Walk(&referrer{r: &resolver{file: file}, visit: func(target schema.Decl) {
+				if !seen[target] {
+					seen[target] = true
+					c.usedBy[target] = append(c.usedBy[target], decl)
+				}
+			}}, node)
+		}
+	}
+	return c
+}
+
+// An indexer records the declarations, members and annotations of a file
+// by the positions of their names.
+type indexer struct {
+	nodes map[scanner.Pos]ast.Node
+}
+
+func (v *indexer) Visit(node ast.Node) ast.Visitor {
+	var name *ast.Name
+	switch n := node.(type) {
+	case *ast.ConstSpec:
+		name = n.Name
+	case *ast.TypeAlias:
+		name = n.Name
+	case *ast.Struct:
+		name = n.Name
+	case *ast.Field:
+		name = n.Name
+	case *ast.Enum:
+		name = n.Name
+	case *ast.EnumMember:
+		name = n.Name
+	case *ast.Union:
+		name = n.Name
+	case *ast.Variant:
+		name = n.Name
+	case *ast.Interface:
+		name = n.Name
+	case *ast.Method:
+		name = n.Name
+	case *ast.Annotation:
+		v.nodes[n.At] = n
+		return nil
+	}
+	if name != nil {
+		v.nodes[name.NamePos] = node
+	}
+	return v
+}
+
+func (v *indexer) Exit(ast.Node) {}
+
+// A referrer calls visit with the declarations that the names under a node
+// refer to.
+type referrer struct {
+	r     *resolver
+	visit func(schema.Decl)
+}
+
+func (v *referrer) Visit(node ast.Node) ast.Visitor {
+	if n, ok := node.(*ast.QualName); ok {
+		if d := v.r.resolve(n); d != nil {
+			v.visit(d)
+		}
+		return nil
+	}
+	return v
+}
+
+func (v *referrer) Exit(ast.Node) {}
+
+// kind returns the keyword of a declaration.
+func kind(d schema.Decl) string {
+	switch d.(type) {
+	case *schema.Const:
+		return "const"
+	case *schema.Alias:
+		return "type"
+	case *schema.Struct:
+		return "struct"
+	case *schema.Enum:
+		return "enum"
+	case *schema.Union:
+		return "union"
+	case *schema.Interface:
+		return "interface"
+	}
+	return ""
+}
+
+// synopsis returns the first paragraph of a doc comment on one line.
+func synopsis(doc string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(doc), "\n\n")
+	return strings.Join(strings.Fields(first), " ")
+}
+
+// title returns the heading of a declaration, with a badge if it is
+// deprecated.
+func title(info *schema.Info, spans ...span) []span {
+	if _, ok := info.Deprecated(); ok {
+		spans = append(spans, text(" "), deprecatedBadge)
+	}
+	return spans
+}
+
+// description returns the cell of a table that describes a member: its
+// doc comment and its deprecation.
+func description(info *schema.Info) []span {
+	var spans []span
+	if info.Doc != "" {
+		spans = append(spans, span{text: info.Doc, doc: true})
+	}
+	if msg, ok := info.Deprecated(); ok {
+		note := "Deprecated."
+		if msg != "" {
+			note = "Deprecated: " + msg
+		}
+		if len(spans) > 0 {
+			note = "\n\n" + note
+		}
+		spans = append(spans, span{text: note, doc: true})
+	}
+	return spans
+}
+
+// index writes the index page.
+func (c *catalog) index(r renderer) {
+	r.begin(c.title, indexPage)
+	r.heading(1, "", []span{text(c.title)})
+
+	r.heading(2, "modules", []span{text("Modules")})
+	var rows [][][]span
+	for _, file := range c.s.Files {
+		rows = append(rows, [][]span{
+			{moduleRef(file.Module)},
+			{span{text: synopsis(file.Doc), doc: true}},
+		})
+	}
+	r.table([]string{"Module", "Description"}, rows)
+
+	var decls []schema.Decl
+	for _, file := range c.s.Files {
+		decls = append(decls, file.Decls...)
+	}
+	if len(decls) == 0 {
+		return
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		return decls[i].DeclInfo().Name < decls[j].DeclInfo().Name
+	})
+	r.heading(2, "declarations", []span{text("Declarations")})
+	rows = nil
+	for _, d := range decls {
+		info := d.DeclInfo()
+		rows = append(rows, [][]span{
+			title(info, ref(info.Name, d, "")),
+			{text(kind(d))},
+			{moduleRef(info.File.Module)},
+			{span{text: synopsis(info.Doc), doc: true}},
+		})
+	}
+	r.table([]string{"Name", "Kind", "Module", "Description"}, rows)
+}
+
+// page writes the page of a file.
+func (c *catalog) page(r renderer, file *schema.File) {
+	r.begin("Module "+file.Module+" - "+c.title, file.Module)
+	r.heading(1, "", []span{text("Module "), code(file.Module)})
+	r.doc(file.Doc)
+	if len(file.Imports) > 0 {
+		var items [][]span
+		for _, imp := range file.Imports {
+			item := []span{moduleRef(imp.File.Module)}
+			if imp.Name != path.Base(imp.File.Module) {
+				item = append(item, text(" as "), code(imp.Name))
+			}
+			items = append(items, item)
+		}
+		r.line("Imports", items)
+	}
+	if files := c.importedBy[file]; len(files) > 0 {
+		var items [][]span
+		for _, f := range files {
+			items = append(items, []span{moduleRef(f.Module)})
+		}
+		r.line("Imported by", items)
+	}
+	if len(file.Decls) == 0 {
+		return
+	}
+
+	var items [][]span
+	for _, d := range file.Decls {
+		info := d.DeclInfo()
+		items = append(items, title(info, text(kind(d)+" "), ref(info.Name, d, "")))
+	}
+	r.heading(2, "declarations", []span{text("Declarations")})
+	r.list(items)
+
+	res := &resolver{file: file, nodes: c.nodes[file]}
+	for _, d := range file.Decls {
+		c.decl(r, res, d)
+	}
+}
+
+// decl writes the section of a declaration.
+func (c *catalog) decl(r renderer, res *resolver, d schema.Decl) {
+	info := d.DeclInfo()
+	r.heading(3, info.Name, title(info, text(kind(d)+" "), code(info.Name)))
+	switch d := d.(type) {
+	case *schema.Const:
+		// Constants without a declared type show none, unless there is
+		// no syntax tree to tell.
+		spans := []span{code("const " + d.Name)}
+		spec := res.constSpec(d)
+		if spec != nil && spec.Type != nil || spec == nil && d.Type != nil {
+			spans = append(spans, code(": "))
+			spans = append(spans, res.typ(d.Type, res.constType(d))...)
+		}
+		spans = append(spans, code(" = "))
+		expr := res.constExpr(d)
+		if expr == nil {
+			spans = append(spans, code(d.Value.String()))
+		} else {
+			spans = append(spans, res.expr(expr, 0)...)
+		}
+		r.code(spans)
+		if _, ok := expr.(*ast.BasicLit); expr != nil && !ok {
+			r.line("Value", [][]span{{code(d.Value.String())}})
+		}
+	case *schema.Alias:
+		spans := []span{code("type " + d.Name + " = ")}
+		var node *ast.Type
+		if n, ok := res.nodes[d.Pos].(*ast.TypeAlias); ok {
+			node = n.Type
+		}
+		r.code(append(spans, res.typ(d.Type, node)...))
+	}
+	r.doc(info.Doc)
+	if msg, ok := info.Deprecated(); ok {
+		r.deprecation(msg)
+	}
+	annotations := info.Annotations
+	if u, ok := d.(*schema.Union); ok {
+		// The tag has a line of its own.
+		r.line("Tag", [][]span{{code(strconv.Quote(u.Tag))}})
+		annotations = slices.DeleteFunc(slices.Clone(annotations), func(a *schema.Annotation) bool { return a.Name == "tag" })
+	}
+	if list := res.annotations(annotations); len(list) > 0 {
+		r.line("Annotations", list)
+	}
+
+	switch d := d.(type) {
+	case *schema.Struct:
+		var rows [][][]span
+		for _, f := range d.Fields {
+			name := f.Name
+			if f.Optional {
+				name += "?"
+			}
+			var node *ast.Type
+			if n, ok := res.nodes[f.Pos].(*ast.Field); ok {
+				node = n.Type
+			}
+			rows = append(rows, [][]span{
+				title(&f.Info, span{text: name, code: true, id: d.Name + "." + f.Name}),
+				res.typ(f.Type, node),
+				joinSpans(res.annotations(f.Annotations), " "),
+				description(&f.Info),
+			})
+		}
+		if len(rows) > 0 {
+			r.table([]string{"Field", "Type", "Annotations", "Description"}, rows)
+		}
+	case *schema.Enum:
+		var rows [][][]span
+		for _, m := range d.Members {
+			value := []span{code(strconv.FormatInt(m.Value, 10))}
+			if n, ok := res.nodes[m.Pos].(*ast.EnumMember); ok && n.Value != nil {
+				if _, ok := n.Value.(*ast.BasicLit); !ok {
+					value = append(value, text(" = "))
+					value = append(value, res.expr(n.Value, 0)...)
+				}
+			}
+			rows = append(rows, [][]span{
+				title(&m.Info, span{text: m.Name, code: true, id: d.Name + "." + m.Name}),
+				value,
+				joinSpans(res.annotations(m.Annotations), " "),
+				description(&m.Info),
+			})
+		}
+		r.table([]string{"Member", "Value", "Annotations", "Description"}, rows)
+	case *schema.Union:
+		var rows [][][]span
+		for _, v := range d.Variants {
+			var node *ast.Type
+			if n, ok := res.nodes[v.Pos].(*ast.Variant); ok {
+				node = n.Type
+			}
+			rows = append(rows, [][]span{
+				title(&v.Info, span{text: v.Name, code: true, id: d.Name + "." + v.Name}),
+				res.typ(v.Type, node),
+				joinSpans(res.annotations(v.Annotations), " "),
+				description(&v.Info),
+			})
+		}
+		r.table([]string{"Variant", "Type", "Annotations", "Description"}, rows)
+	case *schema.Interface:
+		var rows [][][]span
+		for _, m := range d.Methods {
+			rows = append(rows, [][]span{
+				title(&m.Info, span{text: m.Name, code: true, id: d.Name + "." + m.Name}),
+				res.signature(m),
+				joinSpans(res.annotations(m.Annotations), " "),
+				description(&m.Info),
+			})
+		}
+		if len(rows) > 0 {
+			r.table([]string{"Method", "Signature", "Annotations", "Description"}, rows)
+		}
+	}
+
+	if users := c.usedBy[d]; len(users) > 0 {
+		var items [][]span
+		for _, u := range users {
+			items = append(items, []span{ref(res.qualify(u), u, "")})
+		}
+		r.line("Used by", items)
+	}
+}
+
+// joinSpans joins lists of spans with a separator.
+func joinSpans(list [][]span, sep string) []span {
+	var spans []span
+	for i, s := range list {
+		if i > 0 {
+			spans = append(spans, text(sep))
+		}
+		spans = append(spans, s...)
+	}
+	return spans
+}
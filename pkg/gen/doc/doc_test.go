@@ -0,0 +1,65 @@
+package doc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"title": "Users catalog"}, "users.lark")
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+		golden := filepath.Join("testdata", "golden", filepath.FromSlash(f.Name))
+		gentest.Compare(t, golden, f.Name, f.Content)
+	}
+	want := "index.md common/types.md users.md index.html common/types.html users.html"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got files %s; want %s", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s := gentest.Check(t, loader.Source{Path: "api/empty.lark", Src: []byte("struct S {}\n")})
+	files, err := generator{}.Generate(s, gen.Params{"format": "html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "index.html" || files[1].Name != "api/empty.html" {
+		t.Fatalf("got files %v", files)
+	}
+	if page := string(files[1].Content); !strings.Contains(page, `<nav><a href="../index.html">Index</a></nav>`) {
+		t.Errorf("page does not link to the index:\n%s", page)
+	}
+
+	if _, err := (generator{}).Generate(s, gen.Params{"format": "pdf"}); err == nil || err.Error() != `unknown format "pdf": want markdown or html` {
+		t.Errorf("got error %v", err)
+	}
+	s = gentest.Check(t, loader.Source{Path: "index.lark", Src: []byte("struct S {}\n")})
+	if _, err := (generator{}).Generate(s, nil); err == nil || err.Error() != "index.lark: module index would overwrite the index page" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		from, to, want string
+	}{
+		{"users", "common/types", "common/types"},
+		{"common/types", "users", "../users"},
+		{"common/types", "common/ids", "ids"},
+		{"a/b/c", "a/d/e", "../d/e"},
+		{"a/b", "index", "../index"},
+		{"users", "users", "users"},
+	}
+	for _, test := range tests {
+		if got := relPath(test.from, test.to); got != test.want {
+			t.Errorf("relPath(%q, %q) = %q; want %q", test.from, test.to, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// style is the style sheet of the HTML pages, which is inline so that the
+// pages need no other files.
+const style = `body { font-family: system-ui, sans-serif; line-height: 1.5; max-width: 60em; margin: 0 auto; padding: 1em; color: #222; }
+nav { margin-bottom: 1em; }
+a { color: #0b5cad; text-decoration: none; }
+a:hover { text-decoration: underline; }
+code, pre { font-family: ui-monospace, monospace; font-size: 0.9em; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+h3 { border-top: 1px solid #ddd; padding-top: 1em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.badge { background: #b60205; color: #fff; border-radius: 0.3em; padding: 0 0.4em; font-size: 0.8em; }
+.deprecation { border-left: 0.3em solid #b60205; padding-left: 0.6em; }`
+
+// htmlPage renders pages in HTML.
+type htmlPage struct {
+	buf    bytes.Buffer
+	module string
+}
+
+func newHTML() renderer { return new(htmlPage) }
+
+func (h *htmlPage) ext() string { return ".html" }
+
+func (h *htmlPage) begin(title, module string) {
+	h.module = module
+	h.buf.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&h.buf, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(title), style)
+	if module != indexPage {
+		fmt.Fprintf(&h.buf, "<nav><a href=\"%s.html\">Index</a></nav>\n", relPath(module, indexPage))
+	}
+}
+
+func (h *htmlPage) heading(level int, id string, spans []span) {
+	if id != "" {
+		fmt.Fprintf(&h.buf, "<h%d id=\"%s\">%s</h%[1]d>\n", level, html.EscapeString(id), h.inline(spans))
+		return
+	}
+	fmt.Fprintf(&h.buf, "<h%d>%s</h%[1]d>\n", level, h.inline(spans))
+}
+
+func (h *htmlPage) doc(text string) {
+	h.buf.WriteString(docHTML(text))
+}
+
+func (h *htmlPage) deprecation(msg string) {
+	if msg == "" {
+		h.buf.WriteString("<p class=\"deprecation\"><strong>Deprecated.</strong></p>\n")
+		return
+	}
+	fmt.Fprintf(&h.buf, "<p class=\"deprecation\"><strong>Deprecated:</strong> %s</p>\n", html.EscapeString(msg))
+}
+
+func (h *htmlPage) code(spans []span) {
+	fmt.Fprintf(&h.buf, "<p>%s</p>\n", h.inline(spans))
+}
+
+func (h *htmlPage) line(label string, items [][]span) {
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = h.inline(item)
+	}
+	fmt.Fprintf(&h.buf, "<p><strong>%s:</strong> %s</p>\n", html.EscapeString(label), strings.Join(list, ", "))
+}
+
+func (h *htmlPage) list(items [][]span) {
+	h.buf.WriteString("<ul>\n")
+	for _, item := range items {
+		fmt.Fprintf(&h.buf, "<li>%s</li>\n", h.inline(item))
+	}
+	h.buf.WriteString("</ul>\n")
+}
+
+func (h *htmlPage) table(header []string, rows [][][]span) {
+	h.buf.WriteString("<table>\n<thead>\n<tr>")
+	for _, name := range header {
+		fmt.Fprintf(&h.buf, "<th>%s</th>", html.EscapeString(name))
+	}
+	h.buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range rows {
+		h.buf.WriteString("<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(&h.buf, "<td>%s</td>", h.inline(cell))
+		}
+		h.buf.WriteString("</tr>\n")
+	}
+	h.buf.WriteString("</tbody>\n</table>\n")
+}
+
+func (h *htmlPage) end() []byte {
+	h.buf.WriteString("</body>\n</html>\n")
+	return h.buf.Bytes()
+}
+
+// inline returns spans as HTML.
+func (h *htmlPage) inline(spans []span) string {
+	var b strings.Builder
+	for _, s := range merge(spans) {
+		text := html.EscapeString(s.text)
+		switch {
+		case s.doc:
+			text = strings.ReplaceAll(html.EscapeString(strings.TrimSpace(s.text)), "\n\n", "<br><br>")
+		case s.badge:
+			text = `<span class="badge">` + text + `</span>`
+		case s.code:
+			text = "<code>" + text + "</code>"
+		}
+		if s.link {
+			text = `<a href="` + html.EscapeString(href(h.module, s, ".html")) + `">` + text + "</a>"
+		}
+		if s.id != "" {
+			text = `<span id="` + html.EscapeString(s.id) + `">` + text + "</span>"
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// docHTML returns a doc comment as HTML: paragraphs separated by blank
+// lines, and indented lines as preformatted text.
+func docHTML(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if para == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		indented := true
+		for _, line := range lines {
+			indented = indented && (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    "))
+		}
+		if indented {
+			for i, line := range lines {
+				lines[i] = strings.TrimPrefix(strings.TrimPrefix(line, "\t"), "    ")
+			}
+			fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+			continue
+		}
+		fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(para))
+	}
+	return b.String()
+}
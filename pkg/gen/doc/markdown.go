@@ -0,0 +1,150 @@
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// markdown renders pages in GitHub Flavored Markdown.
+type markdown struct {
+	buf    bytes.Buffer
+	module string
+}
+
+func newMarkdown() renderer { return new(markdown) }
+
+func (m *markdown) ext() string { return ".md" }
+
+func (m *markdown) begin(title, module string) {
+	m.module = module
+}
+
+func (m *markdown) heading(level int, id string, spans []span) {
+	m.buf.WriteString(strings.Repeat("#", level) + " ")
+	if id != "" {
+		fmt.Fprintf(&m.buf, "<a id=%q></a>", id)
+	}
+	m.buf.WriteString(m.inline(spans, false) + "\n\n")
+}
+
+func (m *markdown) doc(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		m.buf.WriteString(text + "\n\n")
+	}
+}
+
+func (m *markdown) deprecation(msg string) {
+	if msg == "" {
+		m.buf.WriteString("> **Deprecated.**\n\n")
+		return
+	}
+	fmt.Fprintf(&m.buf, "> **Deprecated:** %s\n\n", escapeMarkdown(msg))
+}
+
+func (m *markdown) code(spans []span) {
+	m.buf.WriteString(m.inline(spans, false) + "\n\n")
+}
+
+func (m *markdown) line(label string, items [][]span) {
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = m.inline(item, false)
+	}
+	fmt.Fprintf(&m.buf, "**%s:** %s\n\n", label, strings.Join(list, ", "))
+}
+
+func (m *markdown) list(items [][]span) {
+	for _, item := range items {
+		m.buf.WriteString("- " + m.inline(item, false) + "\n")
+	}
+	m.buf.WriteString("\n")
+}
+
+func (m *markdown) table(header []string, rows [][][]span) {
+	m.buf.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	m.buf.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = m.inline(cell, true)
+		}
+		m.buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	m.buf.WriteString("\n")
+}
+
+func (m *markdown) end() []byte {
+	return append(bytes.TrimRight(m.buf.Bytes(), "\n"), '\n')
+}
+
+// inline returns spans as Markdown. Cells of tables hold no line breaks
+// and escape the bars that separate cells.
+func (m *markdown) inline(spans []span, cell bool) string {
+	var b strings.Builder
+	for _, s := range merge(spans) {
+		var text string
+		switch {
+		case s.doc:
+			text = strings.TrimSpace(s.text)
+			if cell {
+				text = strings.ReplaceAll(text, "\n\n", "<br><br>")
+				text = strings.ReplaceAll(text, "\n", " ")
+			}
+		case s.badge:
+			text = "*(" + s.text + ")*"
+		case s.code:
+			text = codeSpan(s.text)
+		default:
+			text = escapeMarkdown(s.text)
+		}
+		if s.link {
+			text = "[" + text + "](" + href(m.module, s, ".md") + ")"
+		}
+		if s.id != "" {
+			text = fmt.Sprintf("<a id=%q></a>", s.id) + text
+		}
+		if cell {
+			text = strings.ReplaceAll(text, "|", `\|`)
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// codeSpan returns text as a code span, with enough backticks to hold the
+// backticks of the text.
+func codeSpan(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// merge merges neighboring spans that render alike.
+func merge(spans []span) []span {
+	var list []span
+	for _, s := range spans {
+		if n := len(list); n > 0 && s.code && !s.link && s.id == "" && !s.doc && !s.badge {
+			last := &list[n-1]
+			if last.code && !last.link && last.id == "" && !last.doc && !last.badge {
+				last.text += s.text
+				continue
+			}
+		}
+		list = append(list, s)
+	}
+	return list
+}
@@ -0,0 +1,225 @@
+package doc
+
+import (
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/parser"
+	"larklang.io/lark/pkg/scanner"
+	"larklang.io/lark/pkg/schema"
+)
+
+// precPrimary is higher than the precedence of any operator.
+const precPrimary = 100
+
+// A resolver renders the types and expressions of a file, linking the
+// names in them to their declarations. It renders types from the model
+// where the file has no syntax tree.
+type resolver struct {
+	file  *schema.File
+	nodes map[scanner.Pos]ast.Node // of the file, see catalog
+}
+
+// resolve returns the declaration that a name refers to, or nil for the
+// names of built-in types.
+func (r *resolver) resolve(n *ast.QualName) schema.Decl {
+	if n.Module == nil {
+		return r.file.Lookup(n.Name.Name)
+	}
+	for _, imp := range r.file.Imports {
+		if imp.Name == n.Module.Name {
+			return imp.File.Lookup(n.Name.Name)
+		}
+	}
+	return nil
+}
+
+// qualify returns the name of a declaration as the file would write it.
+func (r *resolver) qualify(d schema.Decl) string {
+	info := d.DeclInfo()
+	if info.File == r.file {
+		return info.Name
+	}
+	if imp := r.file.ImportOf(info.File); imp != nil {
+		return imp.Name + "." + info.Name
+	}
+	return info.File.Module + "." + info.Name
+}
+
+// qualName returns a name, linked to its declaration.
+func (r *resolver) qualName(n *ast.QualName) span {
+	name := n.Name.Name
+	if n.Module != nil {
+		name = n.Module.Name + "." + name
+	}
+	if d := r.resolve(n); d != nil {
+		return ref(name, d, "")
+	}
+	return code(name)
+}
+
+// typ returns a type as written by node, or from the model if node is
+// nil.
+func (r *resolver) typ(t *schema.Type, node *ast.Type) []span {
+	if node != nil {
+		return r.astType(node)
+	}
+	switch t.Kind {
+	case schema.PrimitiveType:
+		return []span{code(t.Primitive.String())}
+	case schema.ListType:
+		return join(code("list["), r.typ(t.Elem, nil), code("]"))
+	case schema.MapType:
+		return join(code("map["), r.typ(t.Key, nil), code(", "), r.typ(t.Elem, nil), code("]"))
+	case schema.ArrayType:
+		return join(code("["), r.typ(t.Elem, nil), code("; "+strconv.FormatInt(t.Len, 10)+"]"))
+	}
+	return []span{ref(r.qualify(t.Decl), t.Decl, "")}
+}
+
+func (r *resolver) astType(node *ast.Type) []span {
+	if node.Name == nil {
+		return join(code("["), r.astType(node.Elem), code("; "), r.expr(node.Len, 0), code("]"))
+	}
+	spans := []span{r.qualName(node.Name)}
+	if len(node.Args) == 0 {
+		return spans
+	}
+	spans = append(spans, code("["))
+	for i, arg := range node.Args {
+		if i > 0 {
+			spans = append(spans, code(", "))
+		}
+		if t, ok := arg.(*ast.Type); ok {
+			spans = append(spans, r.astType(t)...)
+		} else {
+			spans = append(spans, r.expr(arg, 0)...)
+		}
+	}
+	return append(spans, code("]"))
+}
+
+// expr returns a constant expression. It is parenthesized if its
+// precedence is lower than prec.
+func (r *resolver) expr(node ast.Node, prec int) []span {
+	var spans []span
+	var own int
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		spans, own = []span{code(n.Value)}, precPrimary
+	case *ast.QualName:
+		spans, own = []span{r.qualName(n)}, precPrimary
+	case *ast.UnaryExpr:
+		own = parser.UnaryPrecedence
+		spans = join([]span{code(n.Op.String())}, r.expr(n.Expr, own))
+	case *ast.BinaryExpr:
+		own = parser.Precedence(n.Op)
+		spans = join(r.expr(n.Lhs, own), code(" "+n.Op.String()+" "), r.expr(n.Rhs, own+1))
+	default:
+		return nil
+	}
+	if own < prec {
+		return join(code("("), spans, code(")"))
+	}
+	return spans
+}
+
+// constSpec returns the syntax of a constant, or nil.
+func (r *resolver) constSpec(c *schema.Const) *ast.ConstSpec {
+	n, _ := r.nodes[c.Pos].(*ast.ConstSpec)
+	return n
+}
+
+// constType returns the declared type of a constant, or nil.
+func (r *resolver) constType(c *schema.Const) *ast.Type {
+	if n := r.constSpec(c); n != nil {
+		return n.Type
+	}
+	return nil
+}
+
+// constExpr returns the expression of a constant, or nil.
+func (r *resolver) constExpr(c *schema.Const) ast.Node {
+	if n := r.constSpec(c); n != nil {
+		return n.Expr
+	}
+	return nil
+}
+
+// annotations returns the annotations of a list other than @deprecated,
+// which is shown as a badge.
+func (r *resolver) annotations(list schema.Annotations) [][]span {
+	var items [][]span
+	for _, a := range list {
+		if a.Name == "deprecated" {
+			continue
+		}
+		spans := []span{code("@" + a.Name)}
+		if n, ok := r.nodes[a.Pos].(*ast.Annotation); ok {
+			if n.Args != nil {
+				spans = append(spans, code("("))
+				for i, arg := range n.Args {
+					if i > 0 {
+						spans = append(spans, code(", "))
+					}
+					spans = append(spans, r.expr(arg, 0)...)
+				}
+				spans = append(spans, code(")"))
+			}
+		} else if len(a.Args) > 0 {
+			args := make([]string, len(a.Args))
+			for i, arg := range a.Args {
+				args[i] = arg.String()
+			}
+			spans = append(spans, code("("+strings.Join(args, ", ")+")"))
+		}
+		items = append(items, spans)
+	}
+	return items
+}
+
+// signature returns the signature of a method.
+func (r *resolver) signature(m *schema.Method) []span {
+	node, _ := r.nodes[m.Pos].(*ast.Method)
+	spans := []span{code("func " + m.Name + "(")}
+	for i, p := range m.Params {
+		if i > 0 {
+			spans = append(spans, code(", "))
+		}
+		for _, a := range r.annotations(p.Annotations) {
+			spans = append(spans, a...)
+			spans = append(spans, code(" "))
+		}
+		var t *ast.Type
+		if node != nil && i < len(node.Params) {
+			t = node.Params[i].Type
+		}
+		spans = append(spans, code(p.Name+": "))
+		spans = append(spans, r.typ(p.Type, t)...)
+	}
+	spans = append(spans, code(")"))
+	if m.Result != nil {
+		var t *ast.Type
+		if node != nil {
+			t = node.Result
+		}
+		spans = append(spans, code(" -> "))
+		spans = append(spans, r.typ(m.Result, t)...)
+	}
+	return spans
+}
+
+// join concatenates spans and lists of spans.
+func join(parts ...any) []span {
+	var spans []span
+	for _, p := range parts {
+		switch p := p.(type) {
+		case span:
+			spans = append(spans, p)
+		case []span:
+			spans = append(spans, p...)
+		}
+	}
+	return spans
+}
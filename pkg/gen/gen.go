@@ -0,0 +1,63 @@
+// Package gen is the registry of the code generators run by "lark gen".
+//
+// A generator registers itself from an init function:
+//
+//	func init() {
+//		gen.Register("go", generator{})
+//	}
+//
+// and is linked into the lark command with a blank import. Generators
+// that live outside this repository are run as plugins; see package
+// plugin.
+package gen
+
+import (
+	"fmt"
+	"sort"
+
+	"larklang.io/lark/pkg/schema"
+)
+
+// A File is a file produced by a generator.
+type File struct {
+	Name    string // slash-separated path relative to the output directory
+	Content []byte
+}
+
+// Params are the generator options given on the command line as
+// name=value pairs.
+type Params map[string]string
+
+// A Generator produces files from a schema. Generators are only run on
+// schemas without errors, and produce code for the root files of the
+// schema.
+type Generator interface {
+	Generate(s *schema.Schema, params Params) ([]File, error)
+}
+
+var generators = map[string]Generator{}
+
+// Register makes a generator available under name. It panics if name is
+// already registered.
+func Register(name string, g Generator) {
+	if _, ok := generators[name]; ok {
+		panic(fmt.Sprintf("gen: generator %q registered twice", name))
+	}
+	generators[name] = g
+}
+
+// Lookup returns the generator registered under name.
+func Lookup(name string) (Generator, bool) {
+	g, ok := generators[name]
+	return g, ok
+}
+
+// Names returns the names of the registered generators in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
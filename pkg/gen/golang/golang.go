@@ -0,0 +1,443 @@
+// Package golang implements the "go" generator, which produces Go source
+// from checked Lark schemas.
+//
+// Every Lark module becomes a Go package in the directory of the module
+// path: module "api/users" becomes the file api/users/users.go of package
+// users. Declarations are translated as follows:
+//
+//   - Structs become structs with exported fields. The json tag of a field
+//     is its Lark name, or the argument of a @json("name") annotation.
+//     Optional fields become pointers and get the omitempty option;
+//     optional lists, maps and bytes stay as they are, since nil already
+//     means absent.
+//   - Arrays [T; N] become Go arrays [N]T.
+//   - Enums become named int32 types with one constant per member and a
+//     String method that returns the Lark name of the member.
+//   - Unions become structs with a pointer field for every variant, of
+//     which exactly one is set. Their MarshalJSON and UnmarshalJSON
+//     methods add and read the tag member.
+//   - Constants become typed constants. Constants of type bytes or
+//     timestamp become variables, and null constants are left out.
+//   - Type aliases become Go type aliases.
+//   - Interfaces become interfaces whose methods take a context.Context
+//     and return an error. Struct parameters and results are passed by
+//     pointer.
+//
+// Doc comments are carried over, and @deprecated annotations become
+// "Deprecated:" paragraphs.
+//
+// The generator takes one parameter:
+//
+//	import_prefix  import path of the output directory (default: none)
+//
+// Packages generated for other modules are imported with the import
+// prefix joined with the module path.
+package golang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	goformat "go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("go", generator{})
+}
+
+type generator struct{}
+
+// Generate returns one Go file for every root file of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	var files []gen.File
+	for _, file := range s.Roots {
+		g := &fileGen{file: file, prefix: params["import_prefix"], imports: map[string]string{}}
+		src, err := g.generate()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, gen.File{Name: path.Join(file.Module, packageName(file)+".go"), Content: src})
+	}
+	return files, nil
+}
+
+// packageName returns the name of the Go package of a file.
+func packageName(file *schema.File) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			return r
+		}
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, path.Base(file.Module))
+	if name == "" || '0' <= name[0] && name[0] <= '9' || keywords[name] {
+		name = "pkg" + name
+	}
+	return name
+}
+
+// fileGen generates the Go file for one Lark file.
+type fileGen struct {
+	file    *schema.File
+	prefix  string
+	imports map[string]string // import path to package name
+	buf     bytes.Buffer
+}
+
+func (g *fileGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *fileGen) generate() ([]byte, error) {
+	for _, decl := range g.file.Decls {
+		g.printf("\n")
+		switch decl := decl.(type) {
+		case *schema.Const:
+			if err := g.constant(decl); err != nil {
+				return nil, err
+			}
+		case *schema.Alias:
+			g.doc(&decl.Info, "")
+			g.printf("type %s = %s\n", exported(decl.Name), g.typ(decl.Type))
+		case *schema.Struct:
+			g.structType(decl)
+		case *schema.Enum:
+			g.enum(decl)
+		case *schema.Union:
+			g.union(decl)
+		case *schema.Interface:
+			g.iface(decl)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by lark gen from %s. DO NOT EDIT.\n\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		writeComment(&out, "", g.file.Doc)
+	}
+	fmt.Fprintf(&out, "package %s\n", packageName(g.file))
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for p := range g.imports {
+			paths = append(paths, p)
+		}
+		// Standard library packages come first, as goimports orders them.
+		sort.Slice(paths, func(i, j int) bool {
+			if std := isStd(paths[i]); std != isStd(paths[j]) {
+				return std
+			}
+			return paths[i] < paths[j]
+		})
+		out.WriteString("\nimport (\n")
+		for i, p := range paths {
+			if i > 0 && isStd(p) != isStd(paths[i-1]) {
+				out.WriteString("\n")
+			}
+			if name := g.imports[p]; name != path.Base(p) {
+				fmt.Fprintf(&out, "\t%s %q\n", name, p)
+			} else {
+				fmt.Fprintf(&out, "\t%q\n", p)
+			}
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(g.buf.Bytes())
+
+	src, err := goformat.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting code for %s: %v", g.file.Module, err)
+	}
+	return src, nil
+}
+
+// isStd reports whether an import path belongs to the standard library,
+// whose paths have no dot in their first element.
+func isStd(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// use records an import and returns the name of the package.
+func (g *fileGen) use(importPath string) string {
+	if name, ok := g.imports[importPath]; ok {
+		return name
+	}
+	name := path.Base(importPath)
+	for taken := true; taken; {
+		taken = false
+		for _, other := range g.imports {
+			if other == name {
+				name += "_"
+				taken = true
+			}
+		}
+	}
+	g.imports[importPath] = name
+	return name
+}
+
+// doc writes the doc comment of a declaration or a member, indented by
+// indent.
+func (g *fileGen) doc(info *schema.Info, indent string) {
+	writeComment(&g.buf, indent, docText(info))
+}
+
+// docText returns the doc comment of a declaration or a member.
+func docText(info *schema.Info) string {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok {
+		if msg == "" {
+			msg = "do not use."
+		}
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Deprecated: " + msg
+	}
+	return text
+}
+
+func writeComment(buf *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			fmt.Fprintf(buf, "%s//\n", indent)
+		} else {
+			fmt.Fprintf(buf, "%s// %s\n", indent, line)
+		}
+	}
+}
+
+// typ returns the Go type of a Lark type.
+func (g *fileGen) typ(t *schema.Type) string {
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch t.Primitive {
+		case schema.Bytes:
+			return "[]byte"
+		case schema.Timestamp:
+			return g.use("time") + ".Time"
+		case schema.UUID:
+			return "string"
+		}
+		return t.Primitive.String()
+	case schema.ListType:
+		return "[]" + g.typ(t.Elem)
+	case schema.ArrayType:
+		return "[" + strconv.FormatInt(t.Len, 10) + "]" + g.typ(t.Elem)
+	case schema.MapType:
+		return "map[" + g.typ(t.Key) + "]" + g.typ(t.Elem)
+	}
+
+	info := t.Decl.DeclInfo()
+	name := exported(info.Name)
+	if info.File == g.file {
+		return name
+	}
+	return g.use(path.Join(g.prefix, info.File.Module)) + "." + name
+}
+
+// nilable reports whether the zero value of t already means absent.
+func nilable(t *schema.Type) bool {
+	u := t.Underlying()
+	return u.Kind == schema.ListType || u.Kind == schema.MapType || u.Kind == schema.PrimitiveType && u.Primitive == schema.Bytes
+}
+
+// isStruct reports whether t is a struct type.
+func isStruct(t *schema.Type) bool {
+	u := t.Underlying()
+	if u.Kind != schema.NamedType {
+		return false
+	}
+	_, ok := u.Decl.(*schema.Struct)
+	return ok
+}
+
+func (g *fileGen) constant(k *schema.Const) error {
+	if k.Value.Kind() == constant.Null {
+		return nil
+	}
+	name := exported(k.Name)
+	typ := g.typ(k.Type)
+
+	switch u := k.Type.Underlying(); {
+	case u.Kind == schema.PrimitiveType && u.Primitive == schema.Bytes:
+		g.doc(&k.Info, "")
+		g.printf("var %s = %s(%s)\n", name, typ, strconv.Quote(k.Value.StringVal()))
+	case u.Kind == schema.PrimitiveType && u.Primitive == schema.Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, k.Value.StringVal())
+		if err != nil {
+			return fmt.Errorf("constant %s: %v", k.Name, err)
+		}
+		t = t.UTC()
+		pkg := g.use("time")
+		value := fmt.Sprintf("%s.Date(%d, %s.%s, %d, %d, %d, %d, %d, %s.UTC)", pkg,
+			t.Year(), pkg, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), pkg)
+		if typ != pkg+".Time" {
+			value = typ + "(" + value + ")"
+		}
+		g.doc(&k.Info, "")
+		g.printf("var %s = %s\n", name, value)
+	default:
+		g.doc(&k.Info, "")
+		g.printf("const %s %s = %s\n", name, typ, k.Value)
+	}
+	return nil
+}
+
+func (g *fileGen) structType(s *schema.Struct) {
+	g.doc(&s.Info, "")
+	g.printf("type %s struct {\n", exported(s.Name))
+	for i, field := range s.Fields {
+		if i > 0 && (field.Doc != "" || field.Annotations.Has("deprecated")) {
+			g.printf("\n")
+		}
+		g.doc(&field.Info, "\t")
+
+		typ := g.typ(field.Type)
+		if field.Optional && !nilable(field.Type) {
+			typ = "*" + typ
+		}
+		tag := field.JSONName()
+		if field.Optional && tag != "-" {
+			tag += ",omitempty"
+		}
+		g.printf("\t%s %s `json:%q`\n", exported(field.Name), typ, tag)
+	}
+	g.printf("}\n")
+}
+
+func (g *fileGen) enum(e *schema.Enum) {
+	name := exported(e.Name)
+	g.doc(&e.Info, "")
+	g.printf("type %s int32\n\n", name)
+
+	if len(e.Members) > 0 {
+		g.printf("const (\n")
+		for _, member := range e.Members {
+			g.doc(&member.Info, "\t")
+			g.printf("\t%s %s = %d\n", name+exported(member.Name), name, member.Value)
+		}
+		g.printf(")\n\n")
+	}
+
+	recv := receiver(name)
+	g.printf("// String returns the name of the member of %s with the value of %s.\n", name, recv)
+	g.printf("func (%s %s) String() string {\n", recv, name)
+	if len(e.Members) > 0 {
+		g.printf("switch %s {\n", recv)
+		for _, member := range e.Members {
+			g.printf("case %s:\nreturn %q\n", name+exported(member.Name), member.Name)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return %q + %s.FormatInt(int64(%s), 10) + \")\"\n}\n", name+"(", g.use("strconv"), recv)
+}
+
+func (g *fileGen) union(u *schema.Union) {
+	name := exported(u.Name)
+	text := docText(&u.Info)
+	if text != "" {
+		text += "\n\n"
+	}
+	text += fmt.Sprintf("Exactly one field of a %s is set. In JSON, the member %q\nholds the name of the variant.", name, u.Tag)
+	writeComment(&g.buf, "", text)
+	g.printf("type %s struct {\n", name)
+	for i, variant := range u.Variants {
+		if i > 0 && (variant.Doc != "" || variant.Annotations.Has("deprecated")) {
+			g.printf("\n")
+		}
+		g.doc(&variant.Info, "\t")
+		g.printf("\t%s *%s\n", exported(variant.Name), g.typ(variant.Type))
+	}
+	g.printf("}\n")
+	if len(u.Variants) == 0 {
+		return
+	}
+
+	recv := receiver(name)
+	jsonPkg, errorsPkg := g.use("encoding/json"), g.use("errors")
+	qualified := path.Base(g.file.Module) + "." + name
+	g.printf("\n// MarshalJSON encodes the variant that is set.\n")
+	g.printf("func (%s %s) MarshalJSON() ([]byte, error) {\n", recv, name)
+	g.printf("var prefix string\nvar v any\nswitch {\n")
+	for _, variant := range u.Variants {
+		// The object of the variant starts with the tag member.
+		prefix := "{" + jsonString(u.Tag) + ":" + jsonString(variant.Name)
+		g.printf("case %s.%s != nil:\nprefix, v = %s, %s.%s\n", recv, exported(variant.Name), quote(prefix), recv, exported(variant.Name))
+	}
+	g.printf("default:\nreturn nil, %s.New(%q)\n}\n", errorsPkg, qualified+": no variant is set")
+	g.printf("data, err := %s.Marshal(v)\nif err != nil {\nreturn nil, err\n}\n", jsonPkg)
+	g.printf("if len(data) == 2 {\nreturn append([]byte(prefix), '}'), nil\n}\n")
+	g.printf("return append(append([]byte(prefix), ','), data[1:]...), nil\n}\n")
+
+	g.printf("\n// UnmarshalJSON decodes the variant named by the tag.\n")
+	g.printf("func (%s *%s) UnmarshalJSON(data []byte) error {\n", recv, name)
+	g.printf("var tag struct {\nName string `json:%q`\n}\n", u.Tag)
+	g.printf("if err := %s.Unmarshal(data, &tag); err != nil {\nreturn err\n}\n", jsonPkg)
+	g.printf("*%s = %s{}\nswitch tag.Name {\n", recv, name)
+	for _, variant := range u.Variants {
+		field := recv + "." + exported(variant.Name)
+		g.printf("case %q:\n%s = new(%s)\nreturn %s.Unmarshal(data, %s)\n", variant.Name, field, g.typ(variant.Type), jsonPkg, field)
+	}
+	g.printf("}\nreturn %s.Errorf(%q, tag.Name)\n}\n", g.use("fmt"), qualified+": unknown variant %q")
+}
+
+// quote returns s as a Go string literal, preferring a raw string.
+func quote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// jsonString returns s as a JSON string.
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func (g *fileGen) iface(i *schema.Interface) {
+	ctx := g.use("context")
+	g.doc(&i.Info, "")
+	g.printf("type %s interface {\n", exported(i.Name))
+	for j, method := range i.Methods {
+		if j > 0 && (method.Doc != "" || method.Annotations.Has("deprecated")) {
+			g.printf("\n")
+		}
+		g.doc(&method.Info, "\t")
+
+		params := []string{"ctx " + ctx + ".Context"}
+		for _, param := range method.Params {
+			params = append(params, paramName(param.Name)+" "+g.ref(param.Type))
+		}
+		result := "error"
+		if method.Result != nil {
+			result = "(" + g.ref(method.Result) + ", error)"
+		}
+		g.printf("\t%s(%s) %s\n", exported(method.Name), strings.Join(params, ", "), result)
+	}
+	g.printf("}\n")
+}
+
+// ref returns the type of a parameter or result, which is a pointer for
+// structs.
+func (g *fileGen) ref(t *schema.Type) string {
+	if isStruct(t) {
+		return "*" + g.typ(t)
+	}
+	return g.typ(t)
+}
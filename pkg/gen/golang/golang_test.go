@@ -0,0 +1,101 @@
+package golang
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+)
+
+const prefix = "example.com/api"
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"import_prefix": prefix}, "users.lark", "common/types.lark")
+	if len(files) != 2 || files[0].Name != "users/users.go" || files[1].Name != "common/types/types.go" {
+		t.Fatalf("got files %v", files)
+	}
+
+	for _, file := range files {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".go")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+// packages is a types.Importer for generated packages and the standard
+// library.
+type packages map[string]*types.Package
+
+func (p packages) Import(path string) (*types.Package, error) {
+	if pkg, ok := p[path]; ok {
+		return pkg, nil
+	}
+	pkg, err := importer.ForCompiler(token.NewFileSet(), "source", nil).Import(path)
+	if err == nil {
+		p[path] = pkg
+	}
+	return pkg, err
+}
+
+func TestTypeCheck(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"import_prefix": prefix}, "users.lark", "common/types.lark")
+
+	// Check the imported package first.
+	fset := token.NewFileSet()
+	imports := packages{}
+	for _, file := range []gen.File{files[1], files[0]} {
+		f, err := parser.ParseFile(fset, file.Name, file.Content, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := types.Config{Importer: imports}
+		pkg, err := conf.Check(prefix+"/"+filepath.ToSlash(filepath.Dir(file.Name)), fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", file.Name, err)
+		}
+		imports[pkg.Path()] = pkg
+	}
+
+	users := imports[prefix+"/users"]
+	role := users.Scope().Lookup("Role")
+	if role == nil || role.Type().Underlying().String() != "int32" {
+		t.Fatalf("got Role %v", role)
+	}
+	if m, _, _ := types.LookupFieldOrMethod(role.Type(), false, users, "String"); m == nil {
+		t.Error("Role has no String method")
+	}
+	method, _, _ := types.LookupFieldOrMethod(users.Scope().Lookup("Users").Type(), false, users, "Get")
+	if got := method.Type().String(); got != "func(ctx context.Context, id example.com/api/common/types.ID) (*example.com/api/users.User, error)" {
+		t.Errorf("got Get of type %s", got)
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, exported, param string
+	}{
+		{"user", "User", "user"},
+		{"user_id", "UserID", "userID"},
+		{"userId", "UserID", "userID"},
+		{"HTTPServer", "HTTPServer", "httpServer"},
+		{"url_list", "URLList", "urlList"},
+		{"x2y", "X2y", "x2y"},
+		{"type_", "Type", "type_"},
+		{"ctx", "Ctx", "ctx_"},
+		{"range", "Range", "range_"},
+	}
+	for _, test := range tests {
+		if got := exported(test.name); got != test.exported {
+			t.Errorf("exported(%q) = %q; want %q", test.name, got, test.exported)
+		}
+		if got := paramName(test.name); got != test.param {
+			t.Errorf("paramName(%q) = %q; want %q", test.name, got, test.param)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package golang
+
+import (
+	"strings"
+	"unicode"
+
+	"larklang.io/lark/pkg/gen"
+)
+
+// initialisms are the words that Go names spell in upper case.
+var initialisms = map[string]bool{
+	"acl": true, "api": true, "ascii": true, "cpu": true, "css": true,
+	"dns": true, "eof": true, "guid": true, "html": true, "http": true,
+	"https": true, "id": true, "ip": true, "json": true, "qps": true,
+	"ram": true, "rpc": true, "sla": true, "smtp": true, "sql": true,
+	"ssh": true, "tcp": true, "tls": true, "ttl": true, "udp": true,
+	"ui": true, "uid": true, "uri": true, "url": true, "utf8": true,
+	"uuid": true, "vm": true, "xml": true, "xsrf": true, "xss": true,
+}
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// exported returns the exported Go name of a Lark name: "user_id" and
+// "userId" become "UserID".
+func exported(name string) string {
+	var b strings.Builder
+	for _, word := range gen.Words(name) {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	if b.Len() == 0 {
+		return "X" + name
+	}
+	return b.String()
+}
+
+// paramName returns the Go name of a parameter: the Lark name in lower
+// camel case, not clashing with keywords or the context parameter.
+func paramName(name string) string {
+	var b strings.Builder
+	for i, word := range gen.Words(name) {
+		if i == 0 {
+			b.WriteString(strings.ToLower(word))
+		} else {
+			b.WriteString(exported(word))
+		}
+	}
+	s := b.String()
+	if s == "" || keywords[s] || s == "ctx" {
+		s += "_"
+	}
+	return s
+}
+
+// receiver returns the receiver name for methods of a type.
+func receiver(typeName string) string {
+	return strings.ToLower(string([]rune(typeName)[:1]))
+}
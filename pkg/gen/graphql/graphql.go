@@ -0,0 +1,548 @@
+// Package graphql implements the "graphql" generator, which produces a
+// GraphQL schema in the schema definition language (SDL) from checked
+// Lark schemas.
+//
+// GraphQL has a single namespace, so the generator writes one document,
+// schema.graphql, with the type declarations of all root files and those
+// they refer to. Declarations are translated as follows:
+//
+//   - Structs become object types. Structs that parameters of operations
+//     refer to, directly or through the fields of other inputs, also
+//     become input types named <Struct>Input. Structs with an @input
+//     annotation become only an input type, under their own name, and
+//     cannot be used in results.
+//   - Optional fields are nullable and all other fields are non-null,
+//     marked with !. Lists and arrays become lists of non-null elements.
+//     GraphQL has no maps, so map types are rejected.
+//   - Enums become enums whose values are the member names in upper
+//     snake case, as in SUPER_USER.
+//   - Unions become unions of the object types of their variants. The
+//     tag is not part of the schema: clients select the variant by
+//     __typename. GraphQL has no input unions, so unions cannot be used
+//     in inputs.
+//   - Integers of up to 32 bits other than uint32 become Int, floats
+//     Float, bool Boolean and string String. The other primitives become
+//     custom scalars: Int64 for int64 and uint32, Uint64, Bytes (base64),
+//     Timestamp (RFC 3339) and UUID.
+//   - Type aliases are replaced by the aliased type, and constants are
+//     left out.
+//
+// Methods of the interfaces of the root files with a @query annotation
+// become fields of the Query type, and those with a @mutation annotation
+// fields of the Mutation type; other methods are left out. Their
+// parameters become arguments, and methods without a result return
+// Boolean, which is always null.
+//
+// Doc comments become descriptions. @deprecated annotations become
+// @deprecated directives on fields, enum values and optional input
+// fields, and "Deprecated:" paragraphs of the descriptions elsewhere.
+package graphql
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("graphql", generator{})
+}
+
+type generator struct{}
+
+// name is the name of the document.
+const name = "schema.graphql"
+
+// A scalar is a custom scalar for a primitive type.
+type scalar struct {
+	name, doc, spec string
+}
+
+// scalars are the custom scalars in the order of their declarations.
+var scalars = []scalar{
+	{"Int64", "A 64-bit signed integer.", ""},
+	{"Uint64", "A 64-bit unsigned integer.", ""},
+	{"Bytes", "A byte string in base64.", ""},
+	{"Timestamp", "An instant in time in RFC 3339 format.", "https://www.rfc-editor.org/rfc/rfc3339"},
+	{"UUID", "A UUID in its canonical textual form.", "https://www.rfc-editor.org/rfc/rfc9562"},
+}
+
+// reserved are the names of types that declarations cannot take.
+var reserved = []string{"Query", "Mutation", "Subscription", "Boolean", "Int", "Float", "String", "ID"}
+
+// Generate returns the GraphQL schema of the root files of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	g := &schemaGen{
+		uses:    make(map[schema.Decl]use),
+		scalars: make(map[string]bool),
+	}
+	if err := g.collect(s); err != nil {
+		return nil, err
+	}
+	if err := g.name(); err != nil {
+		return nil, err
+	}
+	src, err := g.generate(s)
+	if err != nil {
+		return nil, err
+	}
+	return []gen.File{{Name: name, Content: src}}, nil
+}
+
+// A use is a set of the ways in which a declaration is used.
+type use uint8
+
+const (
+	output use = 1 << iota // as the type of a result or of a field of an output
+	input                  // as the type of a parameter or of a field of an input
+)
+
+// schemaGen generates the schema.
+type schemaGen struct {
+	decls   []schema.Decl // in the order of their first use
+	uses    map[schema.Decl]use
+	scalars map[string]bool // custom scalars in use
+	buf     bytes.Buffer
+}
+
+func (g *schemaGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// collect collects the declarations of the root files of s and those they
+// refer to, with their uses.
+func (g *schemaGen) collect(s *schema.Schema) error {
+	for _, file := range s.Roots {
+		for _, d := range file.Decls {
+			var err error
+			switch d := d.(type) {
+			case *schema.Struct:
+				if d.Annotations.Has("input") {
+					err = g.use(d, input)
+				} else {
+					err = g.use(d, output)
+				}
+			case *schema.Enum, *schema.Union:
+				err = g.use(d, output)
+			case *schema.Interface:
+				for _, m := range d.Methods {
+					if !m.Annotations.Has("query") && !m.Annotations.Has("mutation") {
+						continue
+					}
+					for _, p := range m.Params {
+						if err = g.typ(p.Type, input); err != nil {
+							break
+						}
+					}
+					if err == nil && m.Result != nil {
+						err = g.typ(m.Result, output)
+					}
+					if err != nil {
+						break
+					}
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// typ records the uses of the declarations that t refers to.
+func (g *schemaGen) typ(t *schema.Type, u use) error {
+	switch t = t.Underlying(); t.Kind {
+	case schema.ListType, schema.ArrayType, schema.MapType:
+		return g.typ(t.Elem, u)
+	case schema.NamedType:
+		return g.use(t.Decl, u)
+	}
+	return nil
+}
+
+// use records a use of a declaration and of the declarations it refers to.
+func (g *schemaGen) use(d schema.Decl, u use) error {
+	if g.uses[d]&u != 0 {
+		return nil
+	}
+	info := d.DeclInfo()
+	if u == output && info.Annotations.Has("input") {
+		return fmt.Errorf("%s: struct %s has @input but is used in results", info.File.Path, info.Name)
+	}
+	if g.uses[d] == 0 {
+		g.decls = append(g.decls, d)
+	}
+	g.uses[d] |= u
+	switch d := d.(type) {
+	case *schema.Struct:
+		for _, f := range d.Fields {
+			if err := g.typ(f.Type, u); err != nil {
+				return err
+			}
+		}
+	case *schema.Union:
+		for _, v := range d.Variants {
+			if err := g.typ(v.Type, u); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// name checks that the GraphQL types of the declarations have distinct
+// names.
+func (g *schemaGen) name() error {
+	taken := make(map[string]string)
+	for _, name := range reserved {
+		taken[name] = "a GraphQL type"
+	}
+	for _, s := range scalars {
+		taken[s.name] = "the scalar for a primitive type"
+	}
+	for _, d := range g.decls {
+		info := d.DeclInfo()
+		names := []string{info.Name}
+		if isStruct(d) {
+			names = nil
+			if g.uses[d]&output != 0 {
+				names = append(names, info.Name)
+			}
+			if g.uses[d]&input != 0 {
+				names = append(names, inputName(d))
+			}
+		}
+		for _, name := range names {
+			if other, ok := taken[name]; ok {
+				return fmt.Errorf("%s: the GraphQL type %s of %s clashes with %s", info.File.Path, name, info.Name, other)
+			}
+			taken[name] = fmt.Sprintf("that of %s in %s", info.Name, info.File.Path)
+		}
+	}
+	return nil
+}
+
+func isStruct(d schema.Decl) bool {
+	_, ok := d.(*schema.Struct)
+	return ok
+}
+
+// inputName returns the name of the input type of a struct.
+func inputName(d schema.Decl) string {
+	info := d.DeclInfo()
+	if info.Annotations.Has("input") {
+		return info.Name
+	}
+	return info.Name + "Input"
+}
+
+func (g *schemaGen) generate(s *schema.Schema) ([]byte, error) {
+	// Write the declarations first to learn which scalars they use.
+	if err := g.operations(s, "Query", "query"); err != nil {
+		return nil, err
+	}
+	if err := g.operations(s, "Mutation", "mutation"); err != nil {
+		return nil, err
+	}
+	for _, d := range g.decls {
+		var err error
+		switch d := d.(type) {
+		case *schema.Struct:
+			if g.uses[d]&output != 0 {
+				err = g.object(d, "type", d.Name, output)
+			}
+			if err == nil && g.uses[d]&input != 0 {
+				err = g.object(d, "input", inputName(d), input)
+			}
+		case *schema.Enum:
+			err = g.enum(d)
+		case *schema.Union:
+			err = g.union(d)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", d.DeclInfo().File.Path, err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("# Code generated by lark gen. DO NOT EDIT.\n")
+	if len(s.Roots) == 1 && s.Roots[0].Doc != "" {
+		out.WriteString("\n")
+		for _, line := range strings.Split(s.Roots[0].Doc, "\n") {
+			out.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+		}
+	}
+	for _, sc := range scalars {
+		if !g.scalars[sc.name] {
+			continue
+		}
+		out.WriteString("\n")
+		writeDescription(&out, sc.doc, "")
+		out.WriteString("scalar " + sc.name)
+		if sc.spec != "" {
+			fmt.Fprintf(&out, " @specifiedBy(url: %s)", quote(sc.spec))
+		}
+		out.WriteString("\n")
+	}
+	out.Write(g.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// operations writes the type name with the fields for the methods of the
+// interfaces of the root files that have the given annotation.
+func (g *schemaGen) operations(s *schema.Schema, name, annotation string) error {
+	type field struct {
+		iface  *schema.Interface
+		method *schema.Method
+	}
+	var fields []field
+	for _, file := range s.Roots {
+		for _, d := range file.Decls {
+			iface, ok := d.(*schema.Interface)
+			if !ok {
+				continue
+			}
+			for _, m := range iface.Methods {
+				if !m.Annotations.Has(annotation) {
+					continue
+				}
+				if m.Annotations.Has("query") && m.Annotations.Has("mutation") {
+					return fmt.Errorf("%s: method %s.%s has both @query and @mutation", file.Path, iface.Name, m.Name)
+				}
+				for _, other := range fields {
+					if other.method.Name == m.Name {
+						return fmt.Errorf("%s: methods %s.%s and %s.%s are both the field %s of %s", file.Path, other.iface.Name, m.Name, iface.Name, m.Name, m.Name, name)
+					}
+				}
+				fields = append(fields, field{iface, m})
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	g.printf("\ntype %s {\n", name)
+	for _, f := range fields {
+		m := f.method
+		var params []string
+		for _, p := range m.Params {
+			typ, err := g.ref(p.Type, input)
+			if err != nil {
+				return fmt.Errorf("%s: parameter %s of method %s.%s: %v", f.iface.File.Path, p.Name, f.iface.Name, m.Name, err)
+			}
+			params = append(params, p.Name+": "+typ+"!")
+		}
+		result := "Boolean"
+		if m.Result != nil {
+			typ, err := g.ref(m.Result, output)
+			if err != nil {
+				return fmt.Errorf("%s: result of method %s.%s: %v", f.iface.File.Path, f.iface.Name, m.Name, err)
+			}
+			result = typ + "!"
+		}
+		writeDescription(&g.buf, m.Doc, "  ")
+		args := ""
+		if len(params) > 0 {
+			args = "(" + strings.Join(params, ", ") + ")"
+		}
+		g.printf("  %s%s: %s%s\n", m.Name, args, result, deprecated(&m.Info))
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// object writes the object or input type of a struct.
+func (g *schemaGen) object(s *schema.Struct, keyword, name string, u use) error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("struct %s has no fields, which GraphQL does not allow", s.Name)
+	}
+	g.printf("\n")
+	writeDescription(&g.buf, description(&s.Info), "")
+	g.printf("%s %s {\n", keyword, name)
+	for _, f := range s.Fields {
+		typ, err := g.ref(f.Type, u)
+		if err != nil {
+			return fmt.Errorf("field %s of %s: %v", f.Name, s.Name, err)
+		}
+		if !f.Optional {
+			typ += "!"
+		}
+		// Required input fields cannot be deprecated.
+		if u == input && !f.Optional {
+			writeDescription(&g.buf, description(&f.Info), "  ")
+			g.printf("  %s: %s\n", f.Name, typ)
+		} else {
+			writeDescription(&g.buf, f.Doc, "  ")
+			g.printf("  %s: %s%s\n", f.Name, typ, deprecated(&f.Info))
+		}
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *schemaGen) enum(e *schema.Enum) error {
+	if len(e.Members) == 0 {
+		return fmt.Errorf("enum %s has no members, which GraphQL does not allow", e.Name)
+	}
+	g.printf("\n")
+	writeDescription(&g.buf, description(&e.Info), "")
+	g.printf("enum %s {\n", e.Name)
+	for _, m := range e.Members {
+		writeDescription(&g.buf, m.Doc, "  ")
+		g.printf("  %s%s\n", valueName(m.Name), deprecated(&m.Info))
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *schemaGen) union(u *schema.Union) error {
+	if g.uses[u]&input != 0 {
+		return fmt.Errorf("union %s is used in inputs, but GraphQL has no input unions", u.Name)
+	}
+	if len(u.Variants) == 0 {
+		return fmt.Errorf("union %s has no variants, which GraphQL does not allow", u.Name)
+	}
+	var types []string
+	for _, v := range u.Variants {
+		typ := v.Type.Underlying().Decl.DeclInfo().Name
+		for _, other := range types {
+			if other == typ {
+				return fmt.Errorf("union %s has several variants of type %s, which GraphQL cannot tell apart", u.Name, typ)
+			}
+		}
+		types = append(types, typ)
+	}
+	g.printf("\n")
+	writeDescription(&g.buf, description(&u.Info), "")
+	g.printf("union %s = %s\n", u.Name, strings.Join(types, " | "))
+	return nil
+}
+
+// ref returns the GraphQL type of t in the given use, without the !
+// that marks it as non-null.
+func (g *schemaGen) ref(t *schema.Type, u use) (string, error) {
+	switch t = t.Underlying(); t.Kind {
+	case schema.PrimitiveType:
+		switch p := t.Primitive; {
+		case p == schema.Bool:
+			return "Boolean", nil
+		case p == schema.Uint32 || p == schema.Int64:
+			g.scalars["Int64"] = true
+			return "Int64", nil
+		case p == schema.Uint64:
+			g.scalars["Uint64"] = true
+			return "Uint64", nil
+		case p.IsInteger():
+			return "Int", nil
+		case p.IsFloat():
+			return "Float", nil
+		case p == schema.String:
+			return "String", nil
+		case p == schema.Bytes:
+			g.scalars["Bytes"] = true
+			return "Bytes", nil
+		case p == schema.Timestamp:
+			g.scalars["Timestamp"] = true
+			return "Timestamp", nil
+		case p == schema.UUID:
+			g.scalars["UUID"] = true
+			return "UUID", nil
+		}
+	case schema.ListType, schema.ArrayType:
+		elem, err := g.ref(t.Elem, u)
+		if err != nil {
+			return "", err
+		}
+		return "[" + elem + "!]", nil
+	case schema.MapType:
+		return "", fmt.Errorf("GraphQL has no type for %s", t)
+	case schema.NamedType:
+		if u == input && isStruct(t.Decl) {
+			return inputName(t.Decl), nil
+		}
+		return t.Decl.DeclInfo().Name, nil
+	}
+	panic(fmt.Sprintf("graphql: invalid type %s", t))
+}
+
+// description returns the doc comment of a declaration or a member, with
+// a "Deprecated:" paragraph if it has a @deprecated annotation.
+func description(info *schema.Info) string {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok {
+		if text != "" {
+			text += "\n\n"
+		}
+		if msg == "" {
+			text += "Deprecated."
+		} else {
+			text += "Deprecated: " + msg
+		}
+	}
+	return text
+}
+
+// deprecated returns the @deprecated directive of a field or an enum
+// value, after a space, or "".
+func deprecated(info *schema.Info) string {
+	msg, ok := info.Deprecated()
+	switch {
+	case !ok:
+		return ""
+	case msg == "":
+		return " @deprecated"
+	}
+	return " @deprecated(reason: " + quote(msg) + ")"
+}
+
+// writeDescription writes text as a block string on its own lines.
+func writeDescription(buf *bytes.Buffer, text, indent string) {
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(text, `"""`, `\"""`)
+	if !strings.Contains(text, "\n") && !strings.HasSuffix(text, `"`) {
+		buf.WriteString(indent + `"""` + text + `"""` + "\n")
+		return
+	}
+	buf.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(strings.TrimRight(indent+line, " ") + "\n")
+	}
+	buf.WriteString(indent + `"""` + "\n")
+}
+
+// quote returns s as a GraphQL string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r < ' ':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// valueName returns the name of an enum value in upper snake case: member
+// superUser becomes SUPER_USER.
+func valueName(member string) string {
+	words := gen.Words(member)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word)
+	}
+	return strings.Join(words, "_")
+}
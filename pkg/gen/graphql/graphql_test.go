@@ -0,0 +1,41 @@
+package graphql
+
+import (
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark")
+	if len(files) != 1 || files[0].Name != "schema.graphql" {
+		t.Fatalf("got files %v", files)
+	}
+	gentest.Compare(t, "testdata/schema.golden", "output", files[0].Content)
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"struct S { m: map[string, int32] }", "field m of S: GraphQL has no type for map[string, int32]"},
+		{"struct S {}", "struct S has no fields, which GraphQL does not allow"},
+		{"enum E {}", "enum E has no members, which GraphQL does not allow"},
+		{"@input struct S { n: int32 }\ninterface I { @query func f() -> S }", "struct S has @input but is used in results"},
+		{"struct S { n: int32 }\nunion U { s: S }\ninterface I { @query func f(u: U) }", "union U is used in inputs, but GraphQL has no input unions"},
+		{"struct S { n: int32 }\nunion U { a: S\n b: S }", "union U has several variants of type S, which GraphQL cannot tell apart"},
+		{"struct Query { n: int32 }", "the GraphQL type Query of Query clashes with a GraphQL type"},
+		{"struct S { n: int32 }\nstruct SInput { n: int32 }\ninterface I { @query func f(s: S) }", "the GraphQL type SInput of SInput clashes with that of S in errors.lark"},
+		{"interface I { @query @mutation func f() }", "method I.f has both @query and @mutation"},
+		{"interface I { @query func f() }\ninterface J { @query func f() }", "methods I.f and J.f are both the field f of Query"},
+		{"interface I { @query func f(m: map[string, string]) }", "parameter m of method I.f: GraphQL has no type for map[string, string]"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package jsonschema implements the "jsonschema" generator, which produces
+// JSON Schema (draft 2020-12) documents that validate the JSON encoding of
+// checked Lark schemas.
+//
+// Every Lark file becomes a schema document at its module path: module
+// "api/users" becomes api/users.schema.json. The document has one
+// definition under $defs for every type declaration of the file, so that
+// a value of type User is validated by api/users.schema.json#/$defs/User.
+// Definitions refer to each other with $ref, and to definitions of other
+// modules by relative references. Declarations are translated as follows:
+//
+//   - Structs become objects whose properties have the JSON names of the
+//     fields. Fields that are not optional are required.
+//   - Integers become integers with the bounds of their type, except for
+//     64-bit integers. Floats become numbers, and strings, bytes (base64),
+//     timestamps (date-time) and UUIDs (uuid) become strings.
+//   - Lists become arrays, and arrays [T; N] become arrays of exactly N
+//     items. Maps become objects with additionalProperties; the property
+//     names of maps with integer or enum keys are constrained to them.
+//   - Enums become integers with an enum of the member values.
+//   - Unions become a oneOf of their variants, each of which requires the
+//     tag member with the name of the variant as its const, and an OpenAPI
+//     style discriminator that maps tag values to variants.
+//   - Type aliases become definitions of the aliased type.
+//   - Constants and interfaces are left out.
+//
+// The annotations @min(n) and @max(n) of fields and aliases become
+// minimum and maximum for numbers, minLength and maxLength for strings,
+// minItems and maxItems for lists and arrays, and minProperties and
+// maxProperties for maps. @pattern("regexp") becomes the pattern of a
+// string. Doc comments become descriptions, and @deprecated annotations
+// set deprecated. Generators of formats that embed JSON Schema build their
+// schemas with a [Builder].
+//
+// The generator takes one parameter:
+//
+//	base_uri  URI of the output directory, used for $id (default: none)
+//
+// Without a base URI, the documents have no $id and references are
+// resolved relative to the location they are read from.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/internal/jsonobj"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("jsonschema", generator{})
+}
+
+type generator struct{}
+
+// draft is the URI of the meta-schema of the documents.
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+// ext is the extension of the documents.
+const ext = ".schema.json"
+
+// Generate returns one schema document for every root file of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	base := params["base_uri"]
+	if base != "" && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	var files []gen.File
+	for _, file := range s.Roots {
+		g := &fileGen{file: file}
+		doc, err := g.generate(base)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, gen.File{Name: file.Module + ext, Content: doc})
+	}
+	return files, nil
+}
+
+// fileGen generates the schema document for one Lark file.
+type fileGen struct {
+	file *schema.File
+}
+
+func (g *fileGen) generate(base string) ([]byte, error) {
+	var doc Object
+	doc.Set("$schema", draft)
+	if base != "" {
+		doc.Set("$id", base+g.file.Module+ext)
+	}
+	if g.file.Doc != "" {
+		doc.Set("description", g.file.Doc)
+	}
+	b := &Builder{Ref: g.ref}
+	var defs Object
+	for _, decl := range g.file.Decls {
+		def, ok, err := b.Def(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g.file.Path, err)
+		}
+		if ok {
+			defs.Set(decl.DeclInfo().Name, def)
+		}
+	}
+	if len(defs) > 0 {
+		doc.Set("$defs", defs)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A Builder builds the schemas of declarations and types. Generators of
+// formats that embed JSON Schema, such as OpenAPI, use it with their own
+// references.
+type Builder struct {
+	// Ref returns the reference to the definition of a declaration.
+	Ref func(decl schema.Decl) string
+}
+
+// Def returns the definition of a type declaration, with its description,
+// and false for constants and interfaces, which have none.
+func (b *Builder) Def(decl schema.Decl) (Object, bool, error) {
+	var def Object
+	var err error
+	switch decl := decl.(type) {
+	case *schema.Alias:
+		def = b.Type(decl.Type)
+		err = Constrain(&def, decl.Type, &decl.Info)
+	case *schema.Struct:
+		def, err = b.structType(decl)
+	case *schema.Enum:
+		def = enum(decl)
+	case *schema.Union:
+		def = b.union(decl)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return Annotate(def, decl.DeclInfo()), true, nil
+}
+
+// Annotate adds the description and the deprecation of a declaration or a
+// member to its schema.
+func Annotate(s Object, info *schema.Info) Object {
+	var head Object
+	if info.Doc != "" {
+		head.Set("description", info.Doc)
+	}
+	if _, ok := info.Deprecated(); ok {
+		head.Set("deprecated", true)
+	}
+	// A reference comes first.
+	if len(s) > 0 && s[0].Key == "$ref" {
+		return append(append(Object{s[0]}, head...), s[1:]...)
+	}
+	return append(head, s...)
+}
+
+// ref returns the reference to the definition of a declaration.
+func (g *fileGen) ref(decl schema.Decl) string {
+	info := decl.DeclInfo()
+	ptr := "#/$defs/" + pointerEscape(info.Name)
+	if info.File.Module == g.file.Module {
+		return ptr
+	}
+	return relPath(g.file.Module, info.File.Module) + ext + ptr
+}
+
+// pointerEscape escapes a reference token of a JSON pointer.
+func pointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// relPath returns the path of module to relative to the directory of
+// module from.
+func relPath(from, to string) string {
+	var dir []string
+	if d := path.Dir(from); d != "." {
+		dir = strings.Split(d, "/")
+	}
+	target := strings.Split(to, "/")
+	common := 0
+	for common < len(dir) && common < len(target)-1 && dir[common] == target[common] {
+		common++
+	}
+	return strings.Repeat("../", len(dir)-common) + strings.Join(target[common:], "/")
+}
+
+// Type returns the schema of values of a type.
+func (b *Builder) Type(t *schema.Type) Object {
+	var s Object
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch p := t.Primitive; {
+		case p == schema.Bool:
+			s.Set("type", "boolean")
+		case p.IsInteger():
+			s.Set("type", "integer")
+			if p.Bits() < 64 {
+				lo, hi := bounds(p)
+				s.Set("minimum", lo)
+				s.Set("maximum", hi)
+			}
+		case p.IsFloat():
+			s.Set("type", "number")
+		case p == schema.String:
+			s.Set("type", "string")
+		case p == schema.Bytes:
+			s.Set("type", "string")
+			s.Set("contentEncoding", "base64")
+		case p == schema.Timestamp:
+			s.Set("type", "string")
+			s.Set("format", "date-time")
+		case p == schema.UUID:
+			s.Set("type", "string")
+			s.Set("format", "uuid")
+		}
+	case schema.ListType:
+		s.Set("type", "array")
+		s.Set("items", b.Type(t.Elem))
+	case schema.ArrayType:
+		s.Set("type", "array")
+		s.Set("items", b.Type(t.Elem))
+		s.Set("minItems", t.Len)
+		s.Set("maxItems", t.Len)
+	case schema.MapType:
+		s.Set("type", "object")
+		if names := keyNames(t.Key); names != nil {
+			s.Set("propertyNames", names)
+		}
+		s.Set("additionalProperties", b.Type(t.Elem))
+	case schema.NamedType:
+		s.Set("$ref", b.Ref(t.Decl))
+	}
+	return s
+}
+
+// bounds returns the range of an integer type of less than 64 bits.
+func bounds(p schema.Primitive) (lo, hi int64) {
+	if p.IsUnsigned() {
+		return 0, 1<<p.Bits() - 1
+	}
+	return -1 << (p.Bits() - 1), 1<<(p.Bits()-1) - 1
+}
+
+// keyNames returns the schema of the property names of maps with integer
+// or enum keys, which are numbers in strings, or nil for other keys.
+func keyNames(key *schema.Type) Object {
+	var s Object
+	u := key.Underlying()
+	switch {
+	case u.Kind == schema.NamedType:
+		values := []string{}
+		for _, m := range u.Decl.(*schema.Enum).Members {
+			values = append(values, strconv.FormatInt(m.Value, 10))
+		}
+		s.Set("enum", values)
+	case u.Primitive.IsUnsigned():
+		s.Set("pattern", "^(0|[1-9][0-9]*)$")
+	case u.Primitive.IsInteger():
+		s.Set("pattern", "^(0|-?[1-9][0-9]*)$")
+	default:
+		return nil
+	}
+	return s
+}
+
+func (b *Builder) structType(st *schema.Struct) (Object, error) {
+	var s, props Object
+	required := []string{}
+	for _, field := range st.Fields {
+		prop := b.Type(field.Type)
+		if err := Constrain(&prop, field.Type, &field.Info); err != nil {
+			return nil, err
+		}
+		props.Set(field.JSONName(), Annotate(prop, &field.Info))
+		if !field.Optional {
+			required = append(required, field.JSONName())
+		}
+	}
+	s.Set("type", "object")
+	if len(props) > 0 {
+		s.Set("properties", props)
+	}
+	if len(required) > 0 {
+		s.Set("required", required)
+	}
+	return s, nil
+}
+
+func enum(e *schema.Enum) Object {
+	var s Object
+	values := []int64{}
+	for _, m := range e.Members {
+		values = append(values, m.Value)
+	}
+	s.Set("type", "integer")
+	s.Set("enum", values)
+	return s
+}
+
+func (b *Builder) union(u *schema.Union) Object {
+	var s Object
+	if len(u.Variants) == 0 {
+		// No value has a variant.
+		s.Set("not", Object{})
+		return s
+	}
+	var variants []Object
+	var mapping Object
+	for _, variant := range u.Variants {
+		var tag, props Object
+		tag.Set("const", variant.Name)
+		props.Set(u.Tag, tag)
+		v := b.Type(variant.Type)
+		v.Set("properties", props)
+		v.Set("required", []string{u.Tag})
+		variants = append(variants, Annotate(v, &variant.Info))
+		mapping.Set(variant.Name, b.Ref(variant.Type.Decl))
+	}
+	var disc Object
+	disc.Set("propertyName", u.Tag)
+	disc.Set("mapping", mapping)
+	s.Set("oneOf", variants)
+	s.Set("discriminator", disc)
+	return s
+}
+
+// Constrain adds the keywords of the @min, @max and @pattern annotations
+// of a field or an alias to the schema s of its type t.
+func Constrain(s *Object, t *schema.Type, info *schema.Info) error {
+	u := t.Underlying()
+	var kind string // of the constraint keywords
+	switch {
+	case u.Kind == schema.PrimitiveType && (u.Primitive.IsInteger() || u.Primitive.IsFloat()):
+		kind = "number"
+	case u.Kind == schema.PrimitiveType && u.Primitive == schema.String:
+		kind = "string"
+	case u.Kind == schema.ListType || u.Kind == schema.ArrayType:
+		kind = "array"
+	case u.Kind == schema.MapType:
+		kind = "map"
+	}
+	keywords := map[string][2]string{
+		"number": {"minimum", "maximum"},
+		"string": {"minLength", "maxLength"},
+		"array":  {"minItems", "maxItems"},
+		"map":    {"minProperties", "maxProperties"},
+	}[kind]
+
+	for i, name := range []string{"min", "max"} {
+		a := info.Annotations.Lookup(name)
+		if a == nil {
+			continue
+		}
+		if kind == "" {
+			return fmt.Errorf("@%s of %s does not apply to %s", name, info.Name, t)
+		}
+		if len(a.Args) != 1 {
+			return fmt.Errorf("@%s of %s takes one number", name, info.Name)
+		}
+		v := a.Args[0]
+		switch {
+		case kind == "number" && (v.Kind() == constant.Int || v.Kind() == constant.Float && !math.IsInf(v.Float64(), 0)):
+			s.Set(keywords[i], v)
+		case kind != "number" && v.Kind() == constant.Int && v.Int().Sign() >= 0:
+			n, ok := v.Int64()
+			if !ok {
+				return fmt.Errorf("@%s of %s is too large", name, info.Name)
+			}
+			s.Set(keywords[i], n)
+		case kind == "number":
+			return fmt.Errorf("@%s of %s takes one number", name, info.Name)
+		default:
+			return fmt.Errorf("@%s of %s takes one non-negative integer", name, info.Name)
+		}
+	}
+	if a := info.Annotations.Lookup("pattern"); a != nil {
+		if kind != "string" {
+			return fmt.Errorf("@pattern of %s does not apply to %s", info.Name, t)
+		}
+		pattern, ok := a.String(0)
+		if !ok || len(a.Args) != 1 {
+			return fmt.Errorf("@pattern of %s takes one string", info.Name)
+		}
+		s.Set("pattern", pattern)
+	}
+	return nil
+}
+
+// An Object is a JSON object whose members keep their order.
+type Object = jsonobj.Object
+
+// A Member is a member of an object.
+type Member = jsonobj.Member
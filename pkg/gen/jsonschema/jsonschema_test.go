@@ -0,0 +1,73 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark", "common/types.lark")
+	if len(files) != 2 || files[0].Name != "users.schema.json" || files[1].Name != "common/types.schema.json" {
+		t.Fatalf("got files %v", files)
+	}
+	for _, file := range files {
+		if !json.Valid(file.Content) {
+			t.Errorf("%s is not valid JSON", file.Name)
+		}
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ext)+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+func TestBaseURI(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"base_uri": "https://example.com/schemas"}, "common/types.lark")
+	want := `"$id": "https://example.com/schemas/common/types.schema.json",`
+	if !strings.Contains(string(files[0].Content), want) {
+		t.Errorf("document does not contain %s:\n%s", want, files[0].Content)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"struct S { @min(1) b: bool }", "@min of b does not apply to bool"},
+		{"struct S { @pattern(\"a\") n: int32 }", "@pattern of n does not apply to int32"},
+		{"struct S { @pattern(1) n: string }", "@pattern of n takes one string"},
+		{"struct S { @max(\"1\") n: int32 }", "@max of n takes one number"},
+		{"struct S { @max(1, 2) n: int32 }", "@max of n takes one number"},
+		{"struct S { @min(-1) n: string }", "@min of n takes one non-negative integer"},
+		{"struct S { @min(0.5) n: list[int32] }", "@min of n takes one non-negative integer"},
+		{"@max(3) type T = bytes", "@max of T does not apply to bytes"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		from, to, want string
+	}{
+		{"users", "common/types", "common/types"},
+		{"api/users", "api/types", "types"},
+		{"api/users", "common/types", "../common/types"},
+		{"a/b/c", "a/d", "../d"},
+		{"common/types/x", "common/types", "../types"},
+	}
+	for _, test := range tests {
+		if got := relPath(test.from, test.to); got != test.want {
+			t.Errorf("relPath(%q, %q) = %q; want %q", test.from, test.to, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package gen
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words splits a Lark name into the words that generators join in the
+// naming style of their language. Names are split at underscores and at
+// changes from lower to upper case. A run of upper case letters is one
+// word, except for its last letter if a lower case letter follows:
+// "HTTPServer" is "HTTP" "Server" and "user_id" is "user" "id".
+func Words(name string) []string {
+	var list []string
+	for _, part := range strings.Split(name, "_") {
+		runes := []rune(part)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, r := runes[i-1], runes[i]
+			lowerNext := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsUpper(r) && (!unicode.IsUpper(prev) || lowerNext) {
+				list = append(list, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			list = append(list, string(runes[start:]))
+		}
+	}
+	return list
+}
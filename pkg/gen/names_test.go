@@ -0,0 +1,26 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"user", "user"},
+		{"user_id", "user id"},
+		{"userId", "user Id"},
+		{"UserID", "User ID"},
+		{"HTTPServer", "HTTP Server"},
+		{"x2y", "x2y"},
+		{"_private__x_", "private x"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := strings.Join(Words(test.name), " "); got != test.want {
+			t.Errorf("Words(%q) = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
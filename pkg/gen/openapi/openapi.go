@@ -0,0 +1,417 @@
+// Package openapi implements the "openapi" generator, which produces an
+// OpenAPI 3.1 document for the HTTP APIs described by the interfaces of
+// checked Lark schemas.
+//
+// The document, openapi.json, describes the methods of the interfaces of
+// all root files that have an @http("METHOD", "/path") annotation; other
+// methods are left out. Every such method becomes the operation METHOD of
+// the path, tagged with the name of its interface and identified as
+// Interface_method:
+//
+//   - Parameters named in the path, as in "/users/{id}", become path
+//     parameters. Their types must be scalars: primitives other than
+//     bytes, and enums.
+//   - The other parameters are query parameters for GET, HEAD, DELETE,
+//     OPTIONS and TRACE, and the request body for the other methods. The
+//     annotations @query and @body choose explicitly. Query parameters
+//     must be scalars or lists of scalars. A single body parameter is the
+//     body; several are the members of a body object.
+//   - The result is the JSON content of the response 200, and methods
+//     without a result respond 204.
+//
+// The type declarations of the root files, and those they refer to, are
+// the schemas of components/schemas, built as by the jsonschema generator.
+// They are named after their declarations, qualified with the module path
+// where two share a name, as in common.types.ID. Doc comments become
+// descriptions, and @deprecated annotations mark deprecated operations
+// and schemas.
+//
+// The generator takes these parameters:
+//
+//	title    title of the API (default: module path of the first root file)
+//	version  version of the API (default: 0.0.0)
+//	server   URL of the server of the API (default: none)
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/gen/jsonschema"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("openapi", generator{})
+}
+
+type generator struct{}
+
+// version is the OpenAPI version of the document.
+const version = "3.1.0"
+
+// name is the name of the document.
+const name = "openapi.json"
+
+// Generate returns the OpenAPI document for the root files of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	g := &docGen{names: make(map[schema.Decl]string)}
+	g.collect(s)
+	g.b = &jsonschema.Builder{Ref: g.ref}
+
+	var info jsonschema.Object
+	title := params["title"]
+	if title == "" && len(s.Roots) > 0 {
+		title = s.Roots[0].Module
+	}
+	info.Set("title", title)
+	if v := params["version"]; v != "" {
+		info.Set("version", v)
+	} else {
+		info.Set("version", "0.0.0")
+	}
+	if len(s.Roots) == 1 && s.Roots[0].Doc != "" {
+		info.Set("description", s.Roots[0].Doc)
+	}
+
+	var doc jsonschema.Object
+	doc.Set("openapi", version)
+	doc.Set("info", info)
+	if url := params["server"]; url != "" {
+		var server jsonschema.Object
+		server.Set("url", url)
+		doc.Set("servers", []jsonschema.Object{server})
+	}
+	paths, tags, err := g.paths(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		doc.Set("tags", tags)
+	}
+	doc.Set("paths", paths)
+	var schemas jsonschema.Object
+	for _, decl := range g.decls {
+		def, _, err := g.b.Def(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", decl.DeclInfo().File.Path, err)
+		}
+		schemas.Set(g.names[decl], def)
+	}
+	if len(schemas) > 0 {
+		var components jsonschema.Object
+		components.Set("schemas", schemas)
+		doc.Set("components", components)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return []gen.File{{Name: name, Content: buf.Bytes()}}, nil
+}
+
+// docGen generates the document.
+type docGen struct {
+	b     *jsonschema.Builder
+	decls []schema.Decl          // of the schemas in order
+	names map[schema.Decl]string // of the schemas
+}
+
+// collect collects the type declarations of the root files of s and the
+// declarations they refer to, and names their schemas.
+func (g *docGen) collect(s *schema.Schema) {
+	seen := make(map[schema.Decl]bool)
+	var decl func(d schema.Decl)
+	var typ func(t *schema.Type)
+	decl = func(d schema.Decl) {
+		if seen[d] {
+			return
+		}
+		seen[d] = true
+		g.decls = append(g.decls, d)
+		switch d := d.(type) {
+		case *schema.Alias:
+			typ(d.Type)
+		case *schema.Struct:
+			for _, f := range d.Fields {
+				typ(f.Type)
+			}
+		case *schema.Union:
+			for _, v := range d.Variants {
+				typ(v.Type)
+			}
+		}
+	}
+	typ = func(t *schema.Type) {
+		switch t.Kind {
+		case schema.ListType, schema.ArrayType:
+			typ(t.Elem)
+		case schema.MapType:
+			typ(t.Key)
+			typ(t.Elem)
+		case schema.NamedType:
+			decl(t.Decl)
+		}
+	}
+	for _, file := range s.Roots {
+		for _, d := range file.Decls {
+			switch d := d.(type) {
+			case *schema.Alias, *schema.Struct, *schema.Enum, *schema.Union:
+				decl(d)
+			case *schema.Interface:
+				for _, m := range d.Methods {
+					for _, p := range m.Params {
+						typ(p.Type)
+					}
+					if m.Result != nil {
+						typ(m.Result)
+					}
+				}
+			}
+		}
+	}
+
+	count := make(map[string]int)
+	for _, d := range g.decls {
+		count[d.DeclInfo().Name]++
+	}
+	for _, d := range g.decls {
+		info := d.DeclInfo()
+		g.names[d] = info.Name
+		if count[info.Name] > 1 {
+			g.names[d] = strings.ReplaceAll(info.File.Module, "/", ".") + "." + info.Name
+		}
+	}
+}
+
+// ref returns the reference to the schema of a declaration.
+func (g *docGen) ref(decl schema.Decl) string {
+	return "#/components/schemas/" + g.names[decl]
+}
+
+// An httpMethod is an HTTP method of operations.
+type httpMethod struct {
+	name  string // in lower case
+	query bool   // whether parameters are in the query by default
+}
+
+// methods are the HTTP methods in the order of the fields of path items.
+var methods = []httpMethod{
+	{"get", true},
+	{"put", false},
+	{"post", false},
+	{"delete", true},
+	{"options", true},
+	{"head", true},
+	{"patch", false},
+	{"trace", true},
+}
+
+// template matches the parameters of path templates.
+var template = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// An operation is an operation of a path.
+type operation struct {
+	method string
+	op     jsonschema.Object
+}
+
+// paths returns the paths of the document and the tags of the interfaces.
+func (g *docGen) paths(s *schema.Schema) (jsonschema.Object, []jsonschema.Object, error) {
+	var paths []string
+	ops := make(map[string][]operation)
+	var tags []jsonschema.Object
+	for _, file := range s.Roots {
+		for _, d := range file.Decls {
+			iface, ok := d.(*schema.Interface)
+			if !ok {
+				continue
+			}
+			tagged := false
+			for _, m := range iface.Methods {
+				a := m.Annotations.Lookup("http")
+				if a == nil {
+					continue
+				}
+				method, path, op, err := g.operation(iface, m, a)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: method %s.%s: %v", file.Path, iface.Name, m.Name, err)
+				}
+				if _, ok := ops[path]; !ok {
+					paths = append(paths, path)
+				}
+				for _, other := range ops[path] {
+					if other.method == method {
+						return nil, nil, fmt.Errorf("%s: method %s.%s: %s %s is described twice", file.Path, iface.Name, m.Name, strings.ToUpper(method), path)
+					}
+				}
+				ops[path] = append(ops[path], operation{method, op})
+				tagged = true
+			}
+			if tagged {
+				var tag jsonschema.Object
+				tag.Set("name", iface.Name)
+				if iface.Doc != "" {
+					tag.Set("description", iface.Doc)
+				}
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	result := jsonschema.Object{}
+	for _, path := range paths {
+		var item jsonschema.Object
+		for _, m := range methods {
+			for _, op := range ops[path] {
+				if op.method == m.name {
+					item.Set(m.name, op.op)
+				}
+			}
+		}
+		result.Set(path, item)
+	}
+	return result, tags, nil
+}
+
+// operation returns the method, the path and the operation of a method
+// with the annotation @http(method, path).
+func (g *docGen) operation(iface *schema.Interface, m *schema.Method, a *schema.Annotation) (string, string, jsonschema.Object, error) {
+	method, ok1 := a.String(0)
+	path, ok2 := a.String(1)
+	if !ok1 || !ok2 || len(a.Args) != 2 {
+		return "", "", nil, fmt.Errorf(`@http takes a method and a path, as in @http("GET", "/users/{id}")`)
+	}
+	method = strings.ToLower(method)
+	i := slices.IndexFunc(methods, func(m httpMethod) bool { return m.name == method })
+	if i < 0 {
+		return "", "", nil, fmt.Errorf("unknown HTTP method %q", a.Args[0].StringVal())
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", "", nil, fmt.Errorf("path %q does not start with /", path)
+	}
+
+	var op jsonschema.Object
+	op.Set("tags", []string{iface.Name})
+	op.Set("operationId", iface.Name+"_"+m.Name)
+	if m.Doc != "" {
+		op.Set("description", m.Doc)
+	}
+	if _, ok := m.Deprecated(); ok {
+		op.Set("deprecated", true)
+	}
+
+	// Parameters named in the path are path parameters.
+	inPath := make(map[string]bool)
+	for _, match := range template.FindAllStringSubmatch(path, -1) {
+		name := match[1]
+		if !slices.ContainsFunc(m.Params, func(p *schema.Param) bool { return p.Name == name }) {
+			return "", "", nil, fmt.Errorf("path parameter {%s} is not a parameter of the method", name)
+		}
+		if inPath[name] {
+			return "", "", nil, fmt.Errorf("path parameter {%s} appears twice", name)
+		}
+		inPath[name] = true
+	}
+
+	var params []jsonschema.Object
+	var body []*schema.Param
+	for _, p := range m.Params {
+		query := p.Annotations.Lookup("query") != nil
+		isBody := p.Annotations.Lookup("body") != nil
+		in := ""
+		switch {
+		case query && isBody:
+			return "", "", nil, fmt.Errorf("parameter %s has both @query and @body", p.Name)
+		case inPath[p.Name]:
+			if query || isBody {
+				return "", "", nil, fmt.Errorf("parameter %s is in the path and cannot have @query or @body", p.Name)
+			}
+			if !scalar(p.Type) {
+				return "", "", nil, fmt.Errorf("path parameter %s has type %s, which is not a scalar", p.Name, p.Type)
+			}
+			in = "path"
+		case query || !isBody && methods[i].query:
+			if u := p.Type.Underlying(); !scalar(p.Type) && !(u.Kind == schema.ListType && scalar(u.Elem)) {
+				return "", "", nil, fmt.Errorf("query parameter %s has type %s, which is not a scalar or a list of scalars", p.Name, p.Type)
+			}
+			in = "query"
+		default:
+			body = append(body, p)
+			continue
+		}
+		var param jsonschema.Object
+		param.Set("name", p.Name)
+		param.Set("in", in)
+		param.Set("required", true)
+		param.Set("schema", g.b.Type(p.Type))
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op.Set("parameters", params)
+	}
+	if len(body) > 0 {
+		var s jsonschema.Object
+		if len(body) == 1 {
+			s = g.b.Type(body[0].Type)
+		} else {
+			var props jsonschema.Object
+			var required []string
+			for _, p := range body {
+				props.Set(p.Name, g.b.Type(p.Type))
+				required = append(required, p.Name)
+			}
+			s.Set("type", "object")
+			s.Set("properties", props)
+			s.Set("required", required)
+		}
+		var req jsonschema.Object
+		req.Set("required", true)
+		req.Set("content", content(s))
+		op.Set("requestBody", req)
+	}
+
+	var resp, responses jsonschema.Object
+	if m.Result != nil {
+		resp.Set("description", "OK")
+		resp.Set("content", content(g.b.Type(m.Result)))
+		responses.Set("200", resp)
+	} else {
+		resp.Set("description", "No Content")
+		responses.Set("204", resp)
+	}
+	op.Set("responses", responses)
+	return method, path, op, nil
+}
+
+// content returns the content of a request body or a response with the
+// JSON encoding of values of the given schema.
+func content(s jsonschema.Object) jsonschema.Object {
+	var media, c jsonschema.Object
+	media.Set("schema", s)
+	c.Set("application/json", media)
+	return c
+}
+
+// scalar reports whether values of a type can be written in a path or a
+// query: primitives other than bytes, and enums.
+func scalar(t *schema.Type) bool {
+	u := t.Underlying()
+	switch u.Kind {
+	case schema.PrimitiveType:
+		return u.Primitive != schema.Bytes
+	case schema.NamedType:
+		_, ok := u.Decl.(*schema.Enum)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"version": "1.2.0", "server": "https://api.example.com"}, "users.lark")
+	if len(files) != 1 || files[0].Name != "openapi.json" {
+		t.Fatalf("got files %v", files)
+	}
+	got := files[0].Content
+	if !json.Valid(got) {
+		t.Errorf("%s is not valid JSON", files[0].Name)
+	}
+	gentest.Compare(t, "testdata/openapi.golden", "output", got)
+}
+
+func TestErrors(t *testing.T) {
+	const prefix = "struct S { n: int32 }\n\ninterface I {\n"
+	tests := []struct {
+		src, err string
+	}{
+		{`@http("GET") func f()`, `method I.f: @http takes a method and a path, as in @http("GET", "/users/{id}")`},
+		{`@http("FETCH", "/f") func f()`, `method I.f: unknown HTTP method "FETCH"`},
+		{`@http("GET", "f") func f()`, `method I.f: path "f" does not start with /`},
+		{`@http("GET", "/f/{id}") func f()`, `method I.f: path parameter {id} is not a parameter of the method`},
+		{`@http("GET", "/f/{id}/{id}") func f(id: int32)`, `method I.f: path parameter {id} appears twice`},
+		{`@http("GET", "/f/{id}") func f(id: bytes)`, `method I.f: path parameter id has type bytes, which is not a scalar`},
+		{`@http("GET", "/f/{id}") func f(@body id: int32)`, `method I.f: parameter id is in the path and cannot have @query or @body`},
+		{`@http("GET", "/f") func f(s: S)`, `method I.f: query parameter s has type errors.S, which is not a scalar or a list of scalars`},
+		{`@http("POST", "/f") func f(@query s: list[S])`, `method I.f: query parameter s has type list[errors.S], which is not a scalar or a list of scalars`},
+		{`@http("POST", "/f") func f(@query @body s: S)`, `method I.f: parameter s has both @query and @body`},
+		{"@http(\"GET\", \"/f\") func f()\n@http(\"get\", \"/f\") func g()", `method I.g: GET /f is described twice`},
+	}
+	for _, test := range tests {
+		src := prefix + test.src + "\n}\n"
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(src)})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	s := gentest.Check(t, loader.Source{Path: "api/empty.lark", Src: []byte("interface I {\n    func f()\n}\n")})
+	files, err := generator{}.Generate(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"title": "api/empty"`, `"version": "0.0.0"`, `"paths": {}`} {
+		if !strings.Contains(string(files[0].Content), want) {
+			t.Errorf("document does not contain %s:\n%s", want, files[0].Content)
+		}
+	}
+}
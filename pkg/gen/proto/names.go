@@ -0,0 +1,84 @@
+package proto
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/gen"
+)
+
+// fieldName returns the name of a field, a oneof or a oneof member in
+// snake case: "userId" becomes "user_id".
+func fieldName(name string) string {
+	words := gen.Words(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// messageName returns the name of a generated message or rpc in Pascal
+// case: "list_by_role" becomes "ListByRole".
+func messageName(name string) string {
+	var b strings.Builder
+	for _, word := range gen.Words(name) {
+		r, size := utf8.DecodeRuneInString(word)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(word[size:])
+	}
+	return b.String()
+}
+
+// valueName returns the name of an enum value, which is prefixed with the
+// name of its enum since enum values share the scope of their enum:
+// member admin of Role becomes ROLE_ADMIN.
+func valueName(enum, member string) string {
+	var words []string
+	for _, name := range []string{enum, member} {
+		for _, word := range gen.Words(name) {
+			words = append(words, strings.ToUpper(word))
+		}
+	}
+	return strings.Join(words, "_")
+}
+
+// jsonName returns the JSON name that protobuf derives from a field name:
+// "user_id" becomes "userId".
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// packageName returns the protobuf package of a module: module
+// "api/users" is package api.users, after the package prefix if there is
+// one.
+func packageName(prefix, module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		switch {
+		case r == '/':
+			b.WriteByte('.')
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if prefix == "" {
+		return b.String()
+	}
+	return strings.TrimSuffix(prefix, ".") + "." + b.String()
+}
@@ -0,0 +1,506 @@
+// Package proto implements the "proto" generator, which produces proto3
+// files from checked Lark schemas.
+//
+// Every Lark file becomes a .proto file at its module path: module
+// "api/users" becomes api/users.proto in package api.users, and Lark
+// imports become imports of the files generated for them. The output
+// directory is the root of the import path. Declarations are translated
+// as follows:
+//
+//   - Structs become messages with fields in snake case. Optional fields
+//     are optional, except for lists and maps, which are empty when
+//     absent. Fields whose JSON name differs from their protobuf name get
+//     the json_name option.
+//   - Lists and arrays become repeated fields and maps become map fields.
+//     Protobuf has no lists of lists and no maps with float, bytes or
+//     timestamp keys; such types are rejected. 8 and 16-bit integers
+//     widen to 32 bits, timestamps become google.protobuf.Timestamp and
+//     UUIDs become strings.
+//   - Enums become enums whose values are prefixed with the enum name, as
+//     in ROLE_ADMIN. The value 0 comes first, and enums without a member
+//     of value 0 get a value <ENUM>_UNSPECIFIED = 0.
+//   - Unions become messages with a oneof named like the tag member.
+//   - Interfaces become services with one rpc per method. An rpc takes a
+//     message <Method>Request with a field per parameter, or
+//     google.protobuf.Empty if there are none. It returns the struct or
+//     union that the method returns, google.protobuf.Empty if it returns
+//     nothing, or else a message <Method>Response with the field value.
+//   - Type aliases are replaced by the aliased type, and constants are
+//     left out.
+//
+// Fields, variants and parameters are numbered by their @id(n)
+// annotations. Without the ordinals parameter, every one of them needs
+// one, so that reordering declarations cannot change the wire format.
+// With ordinals=true, those without @id are numbered by their position,
+// starting at 1.
+//
+// Doc comments are carried over, and @deprecated annotations become the
+// deprecated option.
+//
+// The generator takes these parameters:
+//
+//	package   prefix of the packages, such as "acme.api" (default: none)
+//	ordinals  "true" to number members by position (default: false)
+package proto
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("proto", generator{})
+}
+
+type generator struct{}
+
+const (
+	timestampProto = "google/protobuf/timestamp.proto"
+	emptyProto     = "google/protobuf/empty.proto"
+)
+
+// Field numbers are between 1 and maxNumber, except for the range that
+// protobuf reserves for itself.
+const (
+	maxNumber     = 1<<29 - 1
+	reservedFirst = 19000
+	reservedLast  = 19999
+)
+
+// Generate returns one .proto file for every root file of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	ordinals := false
+	switch params["ordinals"] {
+	case "", "false":
+	case "true":
+		ordinals = true
+	default:
+		return nil, fmt.Errorf("invalid value %q of ordinals: want true or false", params["ordinals"])
+	}
+
+	var files []gen.File
+	for _, file := range s.Roots {
+		g := &fileGen{file: file, prefix: params["package"], ordinals: ordinals, imports: map[string]bool{}}
+		src, err := g.generate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Path, err)
+		}
+		files = append(files, gen.File{Name: file.Module + ".proto", Content: src})
+	}
+	return files, nil
+}
+
+// fileGen generates the .proto file for one Lark file.
+type fileGen struct {
+	file     *schema.File
+	prefix   string
+	ordinals bool
+	imports  map[string]bool // imported .proto files
+	messages []string        // names of the messages of rpcs
+	buf      bytes.Buffer
+	w        *bytes.Buffer // buf, or the buffer of the messages of rpcs
+}
+
+func (g *fileGen) printf(format string, args ...any) {
+	fmt.Fprintf(g.w, format, args...)
+}
+
+func (g *fileGen) generate() ([]byte, error) {
+	g.w = &g.buf
+	for _, decl := range g.file.Decls {
+		var err error
+		switch decl := decl.(type) {
+		case *schema.Struct:
+			err = g.message(decl.Name, &decl.Info, "field", decl.Name, decl.Fields)
+		case *schema.Enum:
+			err = g.enum(decl)
+		case *schema.Union:
+			err = g.union(decl)
+		case *schema.Interface:
+			err = g.service(decl)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by lark gen from %s. DO NOT EDIT.\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		out.WriteString("\n")
+		writeComment(&out, g.file.Doc, "")
+	}
+	out.WriteString("\nsyntax = \"proto3\";\n")
+	fmt.Fprintf(&out, "\npackage %s;\n", packageName(g.prefix, g.file.Module))
+
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	if len(imports) > 0 {
+		out.WriteString("\n")
+		for _, imp := range imports {
+			fmt.Fprintf(&out, "import %q;\n", imp)
+		}
+	}
+
+	out.Write(g.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+func writeComment(buf *bytes.Buffer, text, indent string) {
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(strings.TrimRight(indent+"// "+line, " ") + "\n")
+	}
+}
+
+// doc writes the doc comment of an item, with a "Deprecated:" paragraph
+// for the message of a @deprecated annotation.
+func (g *fileGen) doc(info *schema.Info, indent string) {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok && msg != "" {
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Deprecated: " + msg
+	}
+	if text != "" {
+		writeComment(g.w, text, indent)
+	}
+}
+
+// deprecated writes the deprecated option of a message, an enum or a
+// service.
+func (g *fileGen) deprecated(info *schema.Info, indent string) {
+	if _, ok := info.Deprecated(); ok {
+		g.printf("%soption deprecated = true;\n", indent)
+	}
+}
+
+// options returns the options of a field or an enum value.
+func options(opts ...string) string {
+	var list []string
+	for _, opt := range opts {
+		if opt != "" {
+			list = append(list, opt)
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(list, ", ") + "]"
+}
+
+func deprecatedOpt(info *schema.Info) string {
+	if _, ok := info.Deprecated(); ok {
+		return "deprecated = true"
+	}
+	return ""
+}
+
+// numbers returns the field numbers of the members of owner, from their
+// @id annotations or their positions.
+func (g *fileGen) numbers(kind, owner string, members []*schema.Info) ([]int64, error) {
+	numbers := make([]int64, len(members))
+	by := map[int64]string{}
+	for i, m := range members {
+		n := int64(i + 1)
+		if a := m.Annotations.Lookup("id"); a != nil {
+			var ok bool
+			if n, ok = a.Int(0); !ok || len(a.Args) != 1 {
+				return nil, fmt.Errorf("@id of %s %s of %s takes one integer", kind, m.Name, owner)
+			}
+		} else if !g.ordinals {
+			return nil, fmt.Errorf("%s %s of %s has no field number: add @id(n) or generate with ordinals=true", kind, m.Name, owner)
+		}
+		switch {
+		case n < 1 || n > maxNumber:
+			return nil, fmt.Errorf("field number %d of %s %s of %s is out of range", n, kind, m.Name, owner)
+		case n >= reservedFirst && n <= reservedLast:
+			return nil, fmt.Errorf("field number %d of %s %s of %s is reserved by protobuf", n, kind, m.Name, owner)
+		}
+		if other, ok := by[n]; ok {
+			return nil, fmt.Errorf("%ss %s and %s of %s have the same field number %d", kind, other, m.Name, owner, n)
+		}
+		by[n] = m.Name
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// field returns the type of a field with its label, or an error if
+// protobuf cannot represent it.
+func (g *fileGen) field(t *schema.Type, optional bool) (string, error) {
+	switch u := t.Underlying(); u.Kind {
+	case schema.ListType, schema.ArrayType:
+		elem, err := g.scalar(u.Elem)
+		if err != nil {
+			return "", err
+		}
+		return "repeated " + elem, nil
+	case schema.MapType:
+		k := u.Key.Underlying()
+		if k.Kind == schema.PrimitiveType && (k.Primitive.IsFloat() || k.Primitive == schema.Bytes || k.Primitive == schema.Timestamp) {
+			return "", fmt.Errorf("protobuf maps cannot have %s keys", u.Key)
+		}
+		key := "int32"
+		if k.Kind == schema.PrimitiveType {
+			key = g.typ(k)
+		}
+		value, err := g.scalar(u.Elem)
+		if err != nil {
+			return "", err
+		}
+		return "map<" + key + ", " + value + ">", nil
+	}
+	typ := g.typ(t)
+	if optional {
+		return "optional " + typ, nil
+	}
+	return typ, nil
+}
+
+// scalar returns the type of an element of a repeated field or the value
+// of a map, which cannot be repeated itself.
+func (g *fileGen) scalar(t *schema.Type) (string, error) {
+	switch t.Underlying().Kind {
+	case schema.ListType, schema.ArrayType, schema.MapType:
+		return "", fmt.Errorf("protobuf has no type for %s inside a list or a map", t)
+	}
+	return g.typ(t), nil
+}
+
+// typ returns the protobuf type of a type that is not a list, an array or
+// a map.
+func (g *fileGen) typ(t *schema.Type) string {
+	t = t.Underlying()
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch p := t.Primitive; p {
+		case schema.Int8, schema.Int16:
+			return "int32"
+		case schema.Uint8, schema.Uint16:
+			return "uint32"
+		case schema.Float32:
+			return "float"
+		case schema.Float64:
+			return "double"
+		case schema.Timestamp:
+			g.imports[timestampProto] = true
+			return "google.protobuf.Timestamp"
+		case schema.UUID:
+			return "string"
+		default:
+			return p.String()
+		}
+	case schema.NamedType:
+		return g.ref(t.Decl)
+	}
+	panic(fmt.Sprintf("proto: %s is not a scalar or message type", t))
+}
+
+// ref returns the name by which the file refers to a message or an enum.
+func (g *fileGen) ref(decl schema.Decl) string {
+	info := decl.DeclInfo()
+	if info.File.Module == g.file.Module {
+		return info.Name
+	}
+	g.imports[info.File.Module+".proto"] = true
+	return packageName(g.prefix, info.File.Module) + "." + info.Name
+}
+
+// message writes a message with the given fields, which are the members
+// of the given kind of owner.
+func (g *fileGen) message(name string, info *schema.Info, kind, owner string, fields []*schema.Field) error {
+	infos := make([]*schema.Info, len(fields))
+	for i, field := range fields {
+		infos[i] = &field.Info
+	}
+	numbers, err := g.numbers(kind, owner, infos)
+	if err != nil {
+		return err
+	}
+	g.printf("\n")
+	g.doc(info, "")
+	g.printf("message %s {", name)
+	if len(fields) == 0 && !info.Annotations.Has("deprecated") {
+		g.printf("}\n")
+		return nil
+	}
+	g.printf("\n")
+	g.deprecated(info, "  ")
+	for i, field := range fields {
+		typ, err := g.field(field.Type, field.Optional)
+		if err != nil {
+			return fmt.Errorf("%s %s of %s: %v", kind, field.Name, owner, err)
+		}
+		fname := fieldName(field.Name)
+		jsonOpt := ""
+		if json := field.JSONName(); json != fname && json != jsonName(fname) {
+			jsonOpt = fmt.Sprintf("json_name = %q", json)
+		}
+		g.doc(&field.Info, "  ")
+		g.printf("  %s %s = %d%s;\n", typ, fname, numbers[i], options(deprecatedOpt(&field.Info), jsonOpt))
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *fileGen) enum(e *schema.Enum) error {
+	// The first value must be 0.
+	members := append([]*schema.EnumMember(nil), e.Members...)
+	sort.SliceStable(members, func(i, j int) bool { return members[i].Value == 0 && members[j].Value != 0 })
+	unspecified := len(members) == 0 || members[0].Value != 0
+	if unspecified {
+		for _, m := range members {
+			if valueName(e.Name, m.Name) == valueName(e.Name, "unspecified") {
+				return fmt.Errorf("enum %s has no member of value 0, and its member %s cannot take the name of the value 0", e.Name, m.Name)
+			}
+		}
+	}
+	g.printf("\n")
+	g.doc(&e.Info, "")
+	g.printf("enum %s {\n", e.Name)
+	g.deprecated(&e.Info, "  ")
+	if unspecified {
+		g.printf("  %s = 0;\n", valueName(e.Name, "unspecified"))
+	}
+	for _, m := range members {
+		g.doc(&m.Info, "  ")
+		g.printf("  %s = %d%s;\n", valueName(e.Name, m.Name), m.Value, options(deprecatedOpt(&m.Info)))
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *fileGen) union(u *schema.Union) error {
+	infos := make([]*schema.Info, len(u.Variants))
+	for i, variant := range u.Variants {
+		infos[i] = &variant.Info
+	}
+	numbers, err := g.numbers("variant", u.Name, infos)
+	if err != nil {
+		return err
+	}
+	g.printf("\n")
+	g.doc(&u.Info, "")
+	g.printf("message %s {", u.Name)
+	if len(u.Variants) == 0 && !u.Annotations.Has("deprecated") {
+		g.printf("}\n")
+		return nil
+	}
+	g.printf("\n")
+	g.deprecated(&u.Info, "  ")
+	if len(u.Variants) > 0 {
+		g.printf("  oneof %s {\n", fieldName(u.Tag))
+		for i, variant := range u.Variants {
+			g.doc(&variant.Info, "    ")
+			g.printf("    %s %s = %d%s;\n", g.typ(variant.Type), fieldName(variant.Name), numbers[i], options(deprecatedOpt(&variant.Info)))
+		}
+		g.printf("  }\n")
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func (g *fileGen) service(i *schema.Interface) error {
+	// The messages of the rpcs follow the service.
+	var messages bytes.Buffer
+	g.w = &messages
+	defer func() { g.w = &g.buf }()
+	var rpcs []string
+	for _, method := range i.Methods {
+		name := messageName(method.Name)
+		request, err := g.request(method, name)
+		if err != nil {
+			return err
+		}
+		response, err := g.response(method, name)
+		if err != nil {
+			return err
+		}
+		rpcs = append(rpcs, fmt.Sprintf("rpc %s(%s) returns (%s)", name, request, response))
+	}
+	g.w = &g.buf
+
+	g.printf("\n")
+	g.doc(&i.Info, "")
+	g.printf("service %s {", i.Name)
+	if len(i.Methods) == 0 && !i.Annotations.Has("deprecated") {
+		g.printf("}\n")
+	} else {
+		g.printf("\n")
+		g.deprecated(&i.Info, "  ")
+		for j, method := range i.Methods {
+			g.doc(&method.Info, "  ")
+			if _, ok := method.Deprecated(); ok {
+				g.printf("  %s {\n    option deprecated = true;\n  }\n", rpcs[j])
+			} else {
+				g.printf("  %s;\n", rpcs[j])
+			}
+		}
+		g.printf("}\n")
+	}
+	g.w.Write(messages.Bytes())
+	return nil
+}
+
+// newMessage reserves the name of a message of an rpc.
+func (g *fileGen) newMessage(name string) error {
+	taken := g.file.Lookup(name) != nil
+	for _, other := range g.messages {
+		taken = taken || other == name
+	}
+	if taken {
+		return fmt.Errorf("the message %s of an rpc clashes with another declaration", name)
+	}
+	g.messages = append(g.messages, name)
+	return nil
+}
+
+// request writes the request message of a method and returns its name.
+func (g *fileGen) request(method *schema.Method, name string) (string, error) {
+	if len(method.Params) == 0 {
+		g.imports[emptyProto] = true
+		return "google.protobuf.Empty", nil
+	}
+	name += "Request"
+	if err := g.newMessage(name); err != nil {
+		return "", err
+	}
+	fields := make([]*schema.Field, len(method.Params))
+	for i, param := range method.Params {
+		fields[i] = &schema.Field{Info: schema.Info{Name: param.Name, Annotations: param.Annotations}, Type: param.Type}
+	}
+	return name, g.message(name, &schema.Info{Doc: "The parameters of " + method.Name + "."}, "parameter", method.Name, fields)
+}
+
+// response writes the response message of a method if it needs one, and
+// returns the message that the method returns.
+func (g *fileGen) response(method *schema.Method, name string) (string, error) {
+	if method.Result == nil {
+		g.imports[emptyProto] = true
+		return "google.protobuf.Empty", nil
+	}
+	if u := method.Result.Underlying(); u.Kind == schema.NamedType {
+		switch u.Decl.(type) {
+		case *schema.Struct, *schema.Union:
+			return g.typ(u), nil
+		}
+	}
+	name += "Response"
+	if err := g.newMessage(name); err != nil {
+		return "", err
+	}
+	id := &schema.Annotation{Name: "id", Args: []constant.Value{constant.MakeInt64(1)}}
+	value := &schema.Field{Info: schema.Info{Name: "value", Annotations: schema.Annotations{id}}, Type: method.Result}
+	return name, g.message(name, &schema.Info{Doc: "The result of " + method.Name + "."}, "result", method.Name, []*schema.Field{value})
+}
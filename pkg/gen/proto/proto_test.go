@@ -0,0 +1,98 @@
+package proto
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark", "common/types.lark")
+	if len(files) != 2 || files[0].Name != "users.proto" || files[1].Name != "common/types.proto" {
+		t.Fatalf("got files %v", files)
+	}
+	for _, file := range files {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".proto")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+func TestParams(t *testing.T) {
+	src := "struct Point {\n    x: int32\n    @id(5)\n    y: int32\n    z: int32\n}\n"
+	s := gentest.Check(t, loader.Source{Path: "geo/point.lark", Src: []byte(src)})
+	files, err := generator{}.Generate(s, gen.Params{"package": "acme.api", "ordinals": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(files[0].Content)
+	for _, want := range []string{
+		"\npackage acme.api.geo.point;\n",
+		"  int32 x = 1;\n  int32 y = 5;\n  int32 z = 3;\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	if _, err := (generator{}).Generate(&schema.Schema{}, gen.Params{"ordinals": "yes"}); err == nil {
+		t.Error("got no error for an invalid ordinals")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"struct S { x: int32 }", "field x of S has no field number: add @id(n) or generate with ordinals=true"},
+		{"struct S { @id(1) x: int32\n @id(1) y: int32 }", "fields x and y of S have the same field number 1"},
+		{"struct S { @id(0) x: int32 }", "field number 0 of field x of S is out of range"},
+		{"struct S { @id(19000) x: int32 }", "field number 19000 of field x of S is reserved by protobuf"},
+		{"struct S { @id(\"1\") x: int32 }", "@id of field x of S takes one integer"},
+		{"struct S { @id(1) m: list[list[int32]] }", "field m of S: protobuf has no type for list[int32] inside a list or a map"},
+		{"struct S { @id(1) m: map[float64, int32] }", "field m of S: protobuf maps cannot have float64 keys"},
+		{"struct S { @id(1) a: int32 }\nunion U { s: S }", "variant s of U has no field number: add @id(n) or generate with ordinals=true"},
+		{"interface I { func get(x: int32) }", "parameter x of get has no field number: add @id(n) or generate with ordinals=true"},
+		{"struct GetRequest { @id(1) a: int32 }\ninterface I { func get(@id(1) x: int32) }", "the message GetRequest of an rpc clashes with another declaration"},
+		{"enum E { unspecified = 1 }", "enum E has no member of value 0, and its member unspecified cannot take the name of the value 0"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, field, message, json string
+	}{
+		{"user", "user", "User", "user"},
+		{"userId", "user_id", "UserId", "userId"},
+		{"HTTPServer", "http_server", "HTTPServer", "httpServer"},
+		{"list_by_role", "list_by_role", "ListByRole", "listByRole"},
+	}
+	for _, test := range tests {
+		if got := fieldName(test.name); got != test.field {
+			t.Errorf("fieldName(%q) = %q; want %q", test.name, got, test.field)
+		}
+		if got := messageName(test.name); got != test.message {
+			t.Errorf("messageName(%q) = %q; want %q", test.name, got, test.message)
+		}
+		if got := jsonName(fieldName(test.name)); got != test.json {
+			t.Errorf("jsonName(%q) = %q; want %q", fieldName(test.name), got, test.json)
+		}
+	}
+	if got, want := valueName("HTTPMethod", "get_all"), "HTTP_METHOD_GET_ALL"; got != want {
+		t.Errorf("valueName = %q; want %q", got, want)
+	}
+	if got, want := packageName("", "api/v1-users"), "api.v1_users"; got != want {
+		t.Errorf("packageName = %q; want %q", got, want)
+	}
+}
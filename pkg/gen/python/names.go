@@ -0,0 +1,64 @@
+package python
+
+import (
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+)
+
+// keywords are the keywords of Python and the names that generated code
+// imports from typing.
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true,
+	"Any":   true, "Optional": true, "Protocol": true,
+}
+
+// escape appends an underscore to keywords and the names of the standard
+// modules that generated code imports.
+func escape(name string) string {
+	if keywords[name] || stdModules[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// className returns the Python name of a declaration, which is its Lark
+// name unless that is reserved.
+func className(name string) string {
+	return escape(name)
+}
+
+// fieldName returns the Python name of a field, a variant, a method or a
+// parameter in snake case: "userId" becomes "user_id". Names that would
+// hide the methods of generated classes get an underscore, too.
+func fieldName(name string) string {
+	s := snake(name)
+	if s == "to_dict" || s == "from_dict" || s == "self" {
+		return s + "_"
+	}
+	return escape(s)
+}
+
+// constName returns the Python name of a constant or an enum member in
+// upper snake case: "MaxUsers" becomes "MAX_USERS".
+func constName(name string) string {
+	return escape(strings.ToUpper(snake(name)))
+}
+
+func snake(name string) string {
+	words := gen.Words(name)
+	if len(words) == 0 {
+		return name
+	}
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
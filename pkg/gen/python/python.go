@@ -0,0 +1,724 @@
+// Package python implements the "python" generator, which produces Python
+// modules of dataclasses from checked Lark schemas.
+//
+// Every Lark file becomes a Python module at its module path: module
+// "api/users" becomes api/users.py, imported as api.users, and every
+// directory gets an __init__.py. Declarations are translated as follows:
+//
+//   - Structs become dataclasses with keyword-only fields in snake case.
+//     Optional fields have the type Optional[T] and default to None.
+//   - Lists and arrays become list[T] and maps become dict[K, V]. Bytes,
+//     timestamps and UUIDs become bytes, datetime.datetime and uuid.UUID.
+//   - Enums become enum.IntEnum classes with members in upper case.
+//   - Unions become dataclasses with an optional field for every variant,
+//     of which exactly one is set.
+//   - Constants become annotated module-level constants in upper case.
+//   - Type aliases become module-level aliases. They come after the
+//     classes, so that they can refer to classes declared later.
+//   - Interfaces become typing.Protocol classes.
+//
+// Structs and unions have a to_dict method and a from_dict class method
+// that convert to and from the JSON encoding: members have the JSON names
+// of the fields, bytes are base64 encoded, timestamps are RFC 3339 strings
+// and unions have the tag member. Doc comments become docstrings.
+//
+// The generated code needs Python 3.10 or later. The generator takes one
+// parameter:
+//
+//	package  Python package of the output directory (default: none)
+//
+// Modules generated for other Lark modules are imported from the package
+// joined with the module path.
+package python
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("python", generator{})
+}
+
+type generator struct{}
+
+// Generate returns a Python module for every root file of s, and an
+// __init__.py for every directory of the modules.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	var files []gen.File
+	inits := map[string]bool{}
+	for _, file := range s.Roots {
+		for dir := path.Dir(file.Module); dir != "."; dir = path.Dir(dir) {
+			inits[dir] = true
+		}
+		g := &fileGen{file: file, pkg: params["package"], imports: map[string]string{}, std: map[string]bool{}}
+		src, err := g.generate()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, gen.File{Name: file.Module + ".py", Content: src})
+	}
+
+	dirs := make([]string, 0, len(inits))
+	for dir := range inits {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		files = append(files, gen.File{Name: dir + "/__init__.py", Content: []byte{}})
+	}
+	return files, nil
+}
+
+// fileGen generates the Python module for one Lark file.
+type fileGen struct {
+	file    *schema.File
+	pkg     string
+	imports map[string]string // module path to name
+	std     map[string]bool   // imported standard modules
+	typing  []string          // names imported from typing
+	buf     bytes.Buffer
+	indent  string
+}
+
+// line writes a line at the current indentation.
+func (g *fileGen) line(format string, args ...any) {
+	if format == "" {
+		g.buf.WriteString("\n")
+		return
+	}
+	g.buf.WriteString(g.indent)
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteString("\n")
+}
+
+func (g *fileGen) generate() ([]byte, error) {
+	// Lark imports keep their names unless they clash.
+	for _, imp := range g.file.Imports {
+		g.module(imp.File.Module, imp.Name)
+	}
+
+	var aliases []*schema.Alias
+	for _, decl := range g.file.Decls {
+		switch decl := decl.(type) {
+		case *schema.Const:
+			if err := g.constant(decl); err != nil {
+				return nil, err
+			}
+		case *schema.Alias:
+			aliases = append(aliases, decl)
+		case *schema.Struct:
+			g.structType(decl)
+		case *schema.Enum:
+			g.enum(decl)
+		case *schema.Union:
+			g.union(decl)
+		case *schema.Interface:
+			g.iface(decl)
+		}
+	}
+	for i, alias := range sortAliases(g.file, aliases) {
+		if i == 0 {
+			g.line("")
+		}
+		g.line("")
+		g.line("%s = %s", className(alias.Name), g.typ(alias.Type))
+		g.docstring(&alias.Info, "")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Code generated by lark gen from %s. DO NOT EDIT.\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		out.WriteString(docstring(g.file.Doc, ""))
+	}
+	out.WriteString("\nfrom __future__ import annotations\n")
+
+	std := make([]string, 0, len(g.std))
+	for name := range g.std {
+		std = append(std, name)
+	}
+	sort.Strings(std)
+	if len(std) > 0 || len(g.typing) > 0 {
+		out.WriteString("\n")
+		for _, name := range std {
+			fmt.Fprintf(&out, "import %s\n", name)
+		}
+		if len(g.typing) > 0 {
+			sort.Strings(g.typing)
+			fmt.Fprintf(&out, "from typing import %s\n", strings.Join(g.typing, ", "))
+		}
+	}
+
+	modules := make([]string, 0, len(g.imports))
+	for module := range g.imports {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	if len(modules) > 0 {
+		out.WriteString("\n")
+		for _, module := range modules {
+			out.WriteString(g.importStmt(module) + "\n")
+		}
+	}
+
+	out.Write(g.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// importStmt returns the import statement of a generated module.
+func (g *fileGen) importStmt(module string) string {
+	name := g.imports[module]
+	parts := strings.Split(module, "/")
+	if g.pkg != "" {
+		parts = append(strings.Split(g.pkg, "."), parts...)
+	}
+	stmt := "import " + strings.Join(parts, ".")
+	if len(parts) > 1 {
+		stmt = "from " + strings.Join(parts[:len(parts)-1], ".") + " import " + parts[len(parts)-1]
+	}
+	if name != parts[len(parts)-1] {
+		stmt += " as " + name
+	}
+	return stmt
+}
+
+// module returns the name under which a generated module is imported,
+// choosing name for new imports unless it is taken.
+func (g *fileGen) module(module, name string) string {
+	if n, ok := g.imports[module]; ok {
+		return n
+	}
+	name = escape(name)
+	for taken := true; taken; {
+		taken = stdModules[name] || g.file.Lookup(name) != nil
+		for _, other := range g.imports {
+			if other == name {
+				taken = true
+			}
+		}
+		if taken {
+			name += "_"
+		}
+	}
+	g.imports[module] = name
+	return name
+}
+
+// stdModules are the standard modules that generated code may import.
+var stdModules = map[string]bool{
+	"base64": true, "dataclasses": true, "datetime": true, "enum": true,
+	"typing": true, "uuid": true,
+}
+
+// use records an import of a standard module and returns its name.
+func (g *fileGen) use(name string) string {
+	g.std[name] = true
+	return name
+}
+
+// useTyping records an import from typing and returns the name.
+func (g *fileGen) useTyping(name string) string {
+	for _, n := range g.typing {
+		if n == name {
+			return name
+		}
+	}
+	g.typing = append(g.typing, name)
+	return name
+}
+
+// sortAliases orders the aliases of a file so that aliases come after
+// the aliases of the same file that they refer to.
+func sortAliases(file *schema.File, aliases []*schema.Alias) []*schema.Alias {
+	var sorted []*schema.Alias
+	done := map[*schema.Alias]bool{}
+	var visit func(a *schema.Alias)
+	var refs func(t *schema.Type)
+	refs = func(t *schema.Type) {
+		switch t.Kind {
+		case schema.ListType, schema.ArrayType:
+			refs(t.Elem)
+		case schema.MapType:
+			refs(t.Key)
+			refs(t.Elem)
+		case schema.NamedType:
+			if a, ok := t.Decl.(*schema.Alias); ok && a.File == file {
+				visit(a)
+			}
+		}
+	}
+	visit = func(a *schema.Alias) {
+		if done[a] {
+			return
+		}
+		done[a] = true
+		refs(a.Type)
+		sorted = append(sorted, a)
+	}
+	for _, a := range aliases {
+		visit(a)
+	}
+	return sorted
+}
+
+// docText returns the doc comment of a declaration or a member with a
+// deprecation notice.
+func docText(info *schema.Info) string {
+	text := info.Doc
+	if msg, ok := info.Deprecated(); ok {
+		if msg == "" {
+			msg = "do not use."
+		}
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Deprecated: " + msg
+	}
+	return text
+}
+
+// docstring writes the docstring of a declaration or a member, if it has
+// one, with extra appended as a paragraph.
+func (g *fileGen) docstring(info *schema.Info, extra string) bool {
+	text := docText(info)
+	if extra != "" {
+		if text != "" {
+			text += "\n\n"
+		}
+		text += extra
+	}
+	if text == "" {
+		return false
+	}
+	g.buf.WriteString(docstring(text, g.indent))
+	return true
+}
+
+// docstring returns text as a docstring indented by indent.
+func docstring(text, indent string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"""`, `\"\"\"`)
+	lines := strings.Split(text, "\n")
+	if strings.HasSuffix(text, `"`) {
+		lines[len(lines)-1] += " "
+	}
+	if len(lines) == 1 {
+		return indent + `"""` + lines[0] + `"""` + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(indent + `"""` + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if line == "" {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(indent + line + "\n")
+		}
+	}
+	b.WriteString(indent + `"""` + "\n")
+	return b.String()
+}
+
+// typ returns the annotation of a Lark type.
+func (g *fileGen) typ(t *schema.Type) string {
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch {
+		case t.Primitive == schema.Bool:
+			return "bool"
+		case t.Primitive.IsInteger():
+			return "int"
+		case t.Primitive.IsFloat():
+			return "float"
+		case t.Primitive == schema.Bytes:
+			return "bytes"
+		case t.Primitive == schema.Timestamp:
+			return g.use("datetime") + ".datetime"
+		case t.Primitive == schema.UUID:
+			return g.use("uuid") + ".UUID"
+		}
+		return "str"
+	case schema.ListType, schema.ArrayType:
+		return "list[" + g.typ(t.Elem) + "]"
+	case schema.MapType:
+		return "dict[" + g.typ(t.Key) + ", " + g.typ(t.Elem) + "]"
+	}
+	return g.ref(t.Decl)
+}
+
+// ref returns the name by which the file refers to a declaration.
+func (g *fileGen) ref(decl schema.Decl) string {
+	info := decl.DeclInfo()
+	if info.File == g.file {
+		return className(info.Name)
+	}
+	return g.module(info.File.Module, path.Base(info.File.Module)) + "." + className(info.Name)
+}
+
+// encode returns the expression that converts the value of expr of type t
+// to its JSON value. Variables of comprehensions are numbered by depth.
+func (g *fileGen) encode(expr string, t *schema.Type, depth int) string {
+	u := t.Underlying()
+	switch u.Kind {
+	case schema.PrimitiveType:
+		switch u.Primitive {
+		case schema.Bytes:
+			return g.use("base64") + ".b64encode(" + expr + ").decode()"
+		case schema.Timestamp:
+			return expr + ".isoformat()"
+		case schema.UUID:
+			return "str(" + expr + ")"
+		}
+		return expr
+	case schema.ListType, schema.ArrayType:
+		v := fmt.Sprintf("v%d", depth)
+		elem := g.encode(v, u.Elem, depth+1)
+		if elem == v {
+			return "list(" + expr + ")"
+		}
+		return "[" + elem + " for " + v + " in " + expr + "]"
+	case schema.MapType:
+		k, v := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		key, elem := g.encodeKey(k, u.Key), g.encode(v, u.Elem, depth+1)
+		if key == k && elem == v {
+			return "dict(" + expr + ")"
+		}
+		return "{" + key + ": " + elem + " for " + k + ", " + v + " in " + expr + ".items()}"
+	}
+	if _, ok := u.Decl.(*schema.Enum); ok {
+		return "int(" + expr + ")"
+	}
+	return expr + ".to_dict()"
+}
+
+// encodeKey returns the expression that converts the map key expr of
+// type t to a string.
+func (g *fileGen) encodeKey(expr string, t *schema.Type) string {
+	u := t.Underlying()
+	if u.Kind == schema.NamedType {
+		return "str(int(" + expr + "))"
+	}
+	switch {
+	case u.Primitive == schema.String:
+		return expr
+	case u.Primitive == schema.Bool:
+		return `("true" if ` + expr + ` else "false")`
+	case u.Primitive.IsInteger() || u.Primitive.IsFloat() || u.Primitive == schema.UUID:
+		return "str(" + expr + ")"
+	}
+	return g.encode(expr, t, 0)
+}
+
+// decode returns the expression that converts the JSON value expr to a
+// value of type t.
+func (g *fileGen) decode(expr string, t *schema.Type, depth int) string {
+	u := t.Underlying()
+	switch u.Kind {
+	case schema.PrimitiveType:
+		switch {
+		case u.Primitive.IsFloat():
+			return "float(" + expr + ")"
+		case u.Primitive == schema.Bytes:
+			return g.use("base64") + ".b64decode(" + expr + ")"
+		case u.Primitive == schema.Timestamp:
+			// fromisoformat accepts "Z" only from Python 3.11 on.
+			return g.use("datetime") + `.datetime.fromisoformat(` + expr + `.replace("Z", "+00:00"))`
+		case u.Primitive == schema.UUID:
+			return g.use("uuid") + ".UUID(" + expr + ")"
+		}
+		return expr
+	case schema.ListType, schema.ArrayType:
+		v := fmt.Sprintf("v%d", depth)
+		elem := g.decode(v, u.Elem, depth+1)
+		if elem == v {
+			return "list(" + expr + ")"
+		}
+		return "[" + elem + " for " + v + " in " + expr + "]"
+	case schema.MapType:
+		k, v := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		key, elem := g.decodeKey(k, u.Key), g.decode(v, u.Elem, depth+1)
+		if key == k && elem == v {
+			return "dict(" + expr + ")"
+		}
+		return "{" + key + ": " + elem + " for " + k + ", " + v + " in " + expr + ".items()}"
+	}
+	if _, ok := u.Decl.(*schema.Enum); ok {
+		return g.ref(u.Decl) + "(" + expr + ")"
+	}
+	return g.ref(u.Decl) + ".from_dict(" + expr + ")"
+}
+
+// decodeKey returns the expression that converts the JSON object key
+// expr to a map key of type t.
+func (g *fileGen) decodeKey(expr string, t *schema.Type) string {
+	u := t.Underlying()
+	if u.Kind == schema.NamedType {
+		return g.ref(u.Decl) + "(int(" + expr + "))"
+	}
+	switch {
+	case u.Primitive == schema.Bool:
+		return "(" + expr + ` == "true")`
+	case u.Primitive.IsInteger():
+		return "int(" + expr + ")"
+	}
+	return g.decode(expr, t, 0)
+}
+
+func (g *fileGen) constant(k *schema.Const) error {
+	name := constName(k.Name)
+	g.line("")
+	if k.Value.Kind() == constant.Null {
+		g.line("%s = None", name)
+		g.docstring(&k.Info, "")
+		return nil
+	}
+
+	var value string
+	switch u := k.Type.Underlying(); {
+	case u.Primitive == schema.Bytes:
+		value = bytesLiteral(k.Value.StringVal())
+	case u.Primitive == schema.Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, k.Value.StringVal())
+		if err != nil {
+			return fmt.Errorf("constant %s: %v", k.Name, err)
+		}
+		t = t.UTC()
+		dt := g.use("datetime")
+		value = fmt.Sprintf("%s.datetime(%d, %d, %d, %d, %d, %d, %d, tzinfo=%s.timezone.utc)", dt,
+			t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/1000, dt)
+	case u.Primitive == schema.UUID:
+		value = g.use("uuid") + ".UUID(" + strconv.Quote(k.Value.StringVal()) + ")"
+	default:
+		value = literal(k.Value)
+	}
+	g.line("%s: %s = %s", name, g.typ(k.Type), value)
+	g.docstring(&k.Info, "")
+	return nil
+}
+
+// literal returns a constant value in Python syntax.
+func literal(v constant.Value) string {
+	switch v.Kind() {
+	case constant.Bool:
+		if v.BoolVal() {
+			return "True"
+		}
+		return "False"
+	case constant.String:
+		return stringLiteral(v.StringVal())
+	case constant.Float:
+		s := v.String()
+		if !strings.ContainsAny(s, ".en") {
+			s += ".0"
+		}
+		return s
+	}
+	return v.String()
+}
+
+// stringLiteral returns s as a Python string literal.
+func stringLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// bytesLiteral returns s as a Python bytes literal.
+func bytesLiteral(s string) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (g *fileGen) structType(s *schema.Struct) {
+	name := className(s.Name)
+	g.line("")
+	g.line("")
+	g.line("@%s.dataclass(kw_only=True)", g.use("dataclasses"))
+	g.line("class %s:", name)
+	g.indent = "    "
+	documented := g.docstring(&s.Info, "")
+	for i, field := range s.Fields {
+		if i == 0 && documented {
+			g.line("")
+		}
+		typ := g.typ(field.Type)
+		if field.Optional {
+			g.line("%s: %s[%s] = None", fieldName(field.Name), g.useTyping("Optional"), typ)
+		} else {
+			g.line("%s: %s", fieldName(field.Name), typ)
+		}
+		g.docstring(&field.Info, "")
+	}
+
+	anyType := g.useTyping("Any")
+	if documented || len(s.Fields) > 0 {
+		g.line("")
+	}
+	g.line("def to_dict(self) -> dict[str, %s]:", anyType)
+	g.indent = "        "
+	g.line(`"""Returns the JSON object of the %s."""`, name)
+	g.line("d: dict[str, %s] = {}", anyType)
+	for _, field := range s.Fields {
+		attr := "self." + fieldName(field.Name)
+		if field.Optional {
+			g.line("if %s is not None:", attr)
+			g.indent = "            "
+		}
+		g.line("d[%s] = %s", stringLiteral(field.JSONName()), g.encode(attr, field.Type, 0))
+		g.indent = "        "
+	}
+	g.line("return d")
+
+	g.indent = "    "
+	g.line("")
+	g.line("@classmethod")
+	g.line("def from_dict(cls, d: dict[str, %s]) -> %s:", anyType, name)
+	g.indent = "        "
+	g.line(`"""Returns the %s of a JSON object."""`, name)
+	if len(s.Fields) == 0 {
+		g.line("return cls()")
+	} else {
+		g.line("return cls(")
+		for _, field := range s.Fields {
+			key := stringLiteral(field.JSONName())
+			value := g.decode("d["+key+"]", field.Type, 0)
+			if field.Optional {
+				value = "None if d.get(" + key + ") is None else " + value
+			}
+			g.line("    %s=%s,", fieldName(field.Name), value)
+		}
+		g.line(")")
+	}
+	g.indent = ""
+}
+
+func (g *fileGen) enum(e *schema.Enum) {
+	g.line("")
+	g.line("")
+	g.line("class %s(%s.IntEnum):", className(e.Name), g.use("enum"))
+	g.indent = "    "
+	documented := g.docstring(&e.Info, "")
+	if len(e.Members) == 0 && !documented {
+		g.line("pass")
+	}
+	for i, member := range e.Members {
+		if i == 0 && documented {
+			g.line("")
+		}
+		g.line("%s = %d", constName(member.Name), member.Value)
+		g.docstring(&member.Info, "")
+	}
+	g.indent = ""
+}
+
+func (g *fileGen) union(u *schema.Union) {
+	name := className(u.Name)
+	g.line("")
+	g.line("")
+	g.line("@%s.dataclass(kw_only=True)", g.use("dataclasses"))
+	g.line("class %s:", name)
+	g.indent = "    "
+	g.docstring(&u.Info, "Exactly one field is set. In JSON, the member "+stringLiteral(u.Tag)+" holds the\nname of the variant.")
+	g.line("")
+	for _, variant := range u.Variants {
+		g.line("%s: %s[%s] = None", fieldName(variant.Name), g.useTyping("Optional"), g.typ(variant.Type))
+		g.docstring(&variant.Info, "")
+	}
+	if len(u.Variants) > 0 {
+		g.line("")
+	}
+
+	anyType := g.useTyping("Any")
+	tag := stringLiteral(u.Tag)
+	g.line("def to_dict(self) -> dict[str, %s]:", anyType)
+	g.indent = "        "
+	g.line(`"""Returns the JSON object of the variant that is set."""`)
+	for _, variant := range u.Variants {
+		attr := "self." + fieldName(variant.Name)
+		g.line("if %s is not None:", attr)
+		g.line("    return {%s: %s, **%s}", tag, stringLiteral(variant.Name), g.encode(attr, variant.Type, 0))
+	}
+	g.line("raise ValueError(%s)", stringLiteral("no variant of "+name+" is set"))
+
+	g.indent = "    "
+	g.line("")
+	g.line("@classmethod")
+	g.line("def from_dict(cls, d: dict[str, %s]) -> %s:", anyType, name)
+	g.indent = "        "
+	g.line(`"""Returns the %s of a JSON object with the variant named by the tag."""`, name)
+	g.line("tag = d.get(%s)", tag)
+	for _, variant := range u.Variants {
+		g.line("if tag == %s:", stringLiteral(variant.Name))
+		g.line("    return cls(%s=%s)", fieldName(variant.Name), g.decode("d", variant.Type, 0))
+	}
+	g.line("raise ValueError(f%s)", stringLiteral("unknown variant {tag!r} of "+name))
+	g.indent = ""
+}
+
+func (g *fileGen) iface(i *schema.Interface) {
+	g.line("")
+	g.line("")
+	g.line("class %s(%s):", className(i.Name), g.useTyping("Protocol"))
+	g.indent = "    "
+	documented := g.docstring(&i.Info, "")
+	if len(i.Methods) == 0 && !documented {
+		g.line("pass")
+	}
+	for j, method := range i.Methods {
+		if j > 0 || documented {
+			g.line("")
+		}
+		params := []string{"self"}
+		for _, param := range method.Params {
+			params = append(params, fieldName(param.Name)+": "+g.typ(param.Type))
+		}
+		result := "None"
+		if method.Result != nil {
+			result = g.typ(method.Result)
+		}
+		g.line("def %s(%s) -> %s:", fieldName(method.Name), strings.Join(params, ", "), result)
+		g.indent = "        "
+		if !g.docstring(&method.Info, "") {
+			g.line("...")
+		}
+		g.indent = "    "
+	}
+	g.indent = ""
+}
@@ -0,0 +1,51 @@
+package python
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark", "common/types.lark")
+	if len(files) != 3 || files[0].Name != "users.py" || files[1].Name != "common/types.py" || files[2].Name != "common/__init__.py" {
+		t.Fatalf("got files %v", files)
+	}
+
+	for _, file := range files[:2] {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".py")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+func TestPackage(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"package": "app.schema"}, "users.lark")
+	if want := "\nfrom app.schema.common import types\n"; !strings.Contains(string(files[0].Content), want) {
+		t.Errorf("output does not contain %q:\n%s", want, files[0].Content)
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, field, constant string
+	}{
+		{"user", "user", "USER"},
+		{"userId", "user_id", "USER_ID"},
+		{"MaxUsers", "max_users", "MAX_USERS"},
+		{"HTTPServer", "http_server", "HTTP_SERVER"},
+		{"class", "class_", "CLASS"},
+		{"to_dict", "to_dict_", "TO_DICT"},
+		{"uuid", "uuid_", "UUID"},
+	}
+	for _, test := range tests {
+		if got := fieldName(test.name); got != test.field {
+			t.Errorf("fieldName(%q) = %q; want %q", test.name, got, test.field)
+		}
+		if got := constName(test.name); got != test.constant {
+			t.Errorf("constName(%q) = %q; want %q", test.name, got, test.constant)
+		}
+	}
+}
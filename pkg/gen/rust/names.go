@@ -0,0 +1,88 @@
+package rust
+
+import (
+	"strings"
+	"unicode"
+
+	"larklang.io/lark/pkg/gen"
+)
+
+// keywords are the strict and reserved keywords of Rust.
+var keywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true,
+	"continue": true, "crate": true, "dyn": true, "else": true, "enum": true,
+	"extern": true, "false": true, "fn": true, "for": true, "gen": true,
+	"if": true, "impl": true, "in": true, "let": true, "loop": true,
+	"match": true, "mod": true, "move": true, "mut": true, "pub": true,
+	"ref": true, "return": true, "self": true, "Self": true, "static": true,
+	"struct": true, "super": true, "trait": true, "true": true, "type": true,
+	"unsafe": true, "use": true, "where": true, "while": true,
+	"abstract": true, "become": true, "box": true, "do": true, "final": true,
+	"macro": true, "override": true, "priv": true, "try": true,
+	"typeof": true, "unsized": true, "virtual": true, "yield": true,
+}
+
+// reserved are the type names that generated code uses unqualified.
+var reserved = map[string]bool{
+	"Box": true, "Deserialize": true, "HashMap": true, "Option": true,
+	"Result": true, "Serialize": true, "String": true, "Vec": true,
+}
+
+// ident escapes keywords as raw identifiers: "type" becomes "r#type". The
+// keywords that cannot be raw identifiers get an underscore.
+func ident(name string) string {
+	switch {
+	case name == "crate" || name == "self" || name == "Self" || name == "super":
+		return name + "_"
+	case keywords[name]:
+		return "r#" + name
+	}
+	return name
+}
+
+// typeName returns the Rust name of a declaration, which is its Lark name
+// unless that is reserved.
+func typeName(name string) string {
+	if reserved[name] {
+		return name + "_"
+	}
+	return ident(name)
+}
+
+// memberName returns the Rust name of an enum member or a union variant
+// in camel case: "super_user" becomes "SuperUser".
+func memberName(name string) string {
+	words := gen.Words(name)
+	if len(words) == 0 {
+		return typeName(name)
+	}
+	for i, word := range words {
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return typeName(strings.Join(words, ""))
+}
+
+// fieldName returns the Rust name of a field, a method or a parameter in
+// snake case: "userId" becomes "user_id".
+func fieldName(name string) string {
+	return ident(snake(name))
+}
+
+// constName returns the Rust name of a constant in upper snake case:
+// "MaxUsers" becomes "MAX_USERS".
+func constName(name string) string {
+	return strings.ToUpper(snake(name))
+}
+
+func snake(name string) string {
+	words := gen.Words(name)
+	if len(words) == 0 {
+		return name
+	}
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
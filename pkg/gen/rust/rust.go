@@ -0,0 +1,614 @@
+// Package rust implements the "rust" generator, which produces Rust
+// modules of serde types from checked Lark schemas.
+//
+// Every Lark file becomes a Rust module at its module path: module
+// "api/users" becomes api/users.rs. Every directory gets a mod.rs that
+// declares its modules, so that the output directory is a module of the
+// crate itself. Modules refer to each other by paths relative to that
+// module, so it can be mounted anywhere in the crate. Declarations are
+// translated as follows:
+//
+//   - Structs become structs that derive Serialize, Deserialize, Debug,
+//     Clone and PartialEq, with fields in snake case. Optional fields
+//     have the type Option<T> and are left out of JSON when they are
+//     None. Fields that would contain their own struct are boxed.
+//   - Lists become Vec<T> and maps become HashMap<K, V>. Arrays [T; N]
+//     stay arrays, unless they are longer than the 32 elements that serde
+//     supports, in which case they become Vec<T>. Timestamps and UUIDs
+//     become chrono::DateTime<chrono::Utc> and uuid::Uuid.
+//   - Enums become fieldless enums with i32 discriminants, encoded as
+//     numbers.
+//   - Unions become enums with a newtype variant for every Lark variant,
+//     internally tagged with serde's tag attribute.
+//   - Constants become pub consts in upper case.
+//   - Type aliases become type aliases.
+//   - Interfaces become traits whose methods return a Result with the
+//     associated Error type of the trait.
+//
+// The JSON names of fields, the names of variants and the names of Rust
+// keywords such as r#type are renamed with serde's rename attribute.
+// Doc comments become doc comments, and @deprecated becomes the
+// deprecated attribute.
+//
+// Bytes become lark::Bytes, a Vec<u8> that is base64 encoded in JSON.
+// The lark module is generated next to the root mod.rs when a module
+// needs it. The generated code depends on the crates serde with the
+// "derive" feature, chrono with the "serde" feature and uuid with the
+// "serde" feature. The generator has no parameters.
+package rust
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("rust", generator{})
+}
+
+type generator struct{}
+
+// maxArray is the length of the longest arrays that serde can encode.
+const maxArray = 32
+
+// support is the name of the module of the support code.
+const support = "lark"
+
+// Generate returns a Rust module for every root file of s, a mod.rs for
+// every directory of the modules, and the support module if the modules
+// use it.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	var files []gen.File
+	modules := map[string]int{} // module path to index in files
+	children := map[string]map[string]bool{}
+	needSupport := false
+	for _, file := range s.Roots {
+		if first, _, _ := strings.Cut(file.Module, "/"); first == support {
+			return nil, fmt.Errorf("module %s: the name %s is taken by the support module", file.Module, support)
+		}
+		for module := file.Module; module != "."; module = path.Dir(module) {
+			dir := path.Dir(module)
+			if children[dir] == nil {
+				children[dir] = map[string]bool{}
+			}
+			children[dir][path.Base(module)] = true
+		}
+		g := &fileGen{file: file, imports: map[string]string{}, used: map[string]bool{}}
+		src, err := g.generate()
+		if err != nil {
+			return nil, err
+		}
+		needSupport = needSupport || g.support
+		modules[file.Module] = len(files)
+		files = append(files, gen.File{Name: file.Module + ".rs", Content: src})
+	}
+	if needSupport {
+		children["."][support] = true
+		files = append(files, gen.File{Name: support + ".rs", Content: []byte(supportSrc)})
+	}
+
+	dirs := make([]string, 0, len(children))
+	for dir := range children {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		names := make([]string, 0, len(children[dir]))
+		for name := range children[dir] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var decls bytes.Buffer
+		for _, name := range names {
+			fmt.Fprintf(&decls, "pub mod %s;\n", ident(name))
+		}
+
+		// A module that is also a directory declares the modules of the
+		// directory itself.
+		if i, ok := modules[dir]; ok {
+			content := append(files[i].Content, '\n')
+			files[i].Content = append(content, decls.Bytes()...)
+			continue
+		}
+		name := "mod.rs"
+		if dir != "." {
+			name = dir + "/mod.rs"
+		}
+		content := append([]byte("// Code generated by lark gen. DO NOT EDIT.\n\n"), decls.Bytes()...)
+		files = append(files, gen.File{Name: name, Content: content})
+	}
+	return files, nil
+}
+
+// fileGen generates the Rust module for one Lark file.
+type fileGen struct {
+	file       *schema.File
+	imports    map[string]string // module path to name
+	used       map[string]bool   // modules that are used
+	hashMap    bool              // HashMap is used
+	serde      bool              // Serialize and Deserialize are used
+	support    bool              // the support module is used
+	deprecated bool              // deprecated items are declared or used
+	err        error             // first error of a type
+	buf        bytes.Buffer
+}
+
+func (g *fileGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *fileGen) generate() ([]byte, error) {
+	// Lark imports keep their names unless they clash.
+	for _, imp := range g.file.Imports {
+		g.module(imp.File.Module, imp.Name)
+	}
+
+	for _, decl := range g.file.Decls {
+		switch decl := decl.(type) {
+		case *schema.Const:
+			if err := g.constant(decl); err != nil {
+				return nil, err
+			}
+		case *schema.Alias:
+			g.printf("\n")
+			g.doc(&decl.Info, "")
+			g.printf("pub type %s = %s;\n", typeName(decl.Name), g.typ(decl.Type))
+		case *schema.Struct:
+			g.structType(decl)
+		case *schema.Enum:
+			g.enum(decl)
+		case *schema.Union:
+			g.union(decl)
+		case *schema.Interface:
+			g.iface(decl)
+		}
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by lark gen from %s. DO NOT EDIT.\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		out.WriteString("\n")
+		for _, line := range strings.Split(g.file.Doc, "\n") {
+			out.WriteString(strings.TrimRight("//! "+line, " ") + "\n")
+		}
+	}
+	if g.deprecated {
+		out.WriteString("\n#![allow(deprecated)]\n")
+	}
+	if g.hashMap {
+		out.WriteString("\nuse std::collections::HashMap;\n")
+	}
+	if g.serde {
+		out.WriteString("\nuse serde::{Deserialize, Serialize};\n")
+	}
+
+	modules := make([]string, 0, len(g.used))
+	for module := range g.used {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	if len(modules) > 0 {
+		out.WriteString("\n")
+		for _, module := range modules {
+			out.WriteString(g.useDecl(module) + "\n")
+		}
+	}
+
+	out.Write(g.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// useDecl returns the use declaration of a generated module, by a path
+// relative to the module of the file.
+func (g *fileGen) useDecl(module string) string {
+	name := g.imports[module]
+	var segments []string
+	for range strings.Split(g.file.Module, "/") {
+		segments = append(segments, "super")
+	}
+	parts := strings.Split(module, "/")
+	for _, part := range parts {
+		segments = append(segments, ident(part))
+	}
+	decl := "use " + strings.Join(segments, "::")
+	if name != ident(parts[len(parts)-1]) {
+		decl += " as " + name
+	}
+	return decl + ";"
+}
+
+// module returns the name under which a generated module is used,
+// choosing name for new imports unless it is taken. Only the modules that
+// are used get a use declaration.
+func (g *fileGen) module(module, name string) string {
+	if n, ok := g.imports[module]; ok {
+		return n
+	}
+	name = ident(name)
+	for taken := true; taken; {
+		taken = crates[name] || name == support || g.file.Lookup(name) != nil
+		for _, other := range g.imports {
+			if other == name {
+				taken = true
+			}
+		}
+		if taken {
+			name += "_"
+		}
+	}
+	g.imports[module] = name
+	return name
+}
+
+// crates are the crates that generated code refers to.
+var crates = map[string]bool{
+	"chrono": true, "serde": true, "std": true, "uuid": true,
+}
+
+// useSupport records a use of the support module and returns its name.
+func (g *fileGen) useSupport() string {
+	g.support = true
+	g.used[support] = true
+	g.imports[support] = support
+	return support
+}
+
+// doc writes the doc comment of an item, if it has one, and its
+// deprecated attribute.
+func (g *fileGen) doc(info *schema.Info, indent string) {
+	if info.Doc != "" {
+		for _, line := range strings.Split(info.Doc, "\n") {
+			g.printf("%s\n", strings.TrimRight(indent+"/// "+line, " "))
+		}
+	}
+	if msg, ok := info.Deprecated(); ok {
+		g.deprecated = true
+		if msg == "" {
+			g.printf("%s#[deprecated]\n", indent)
+		} else {
+			g.printf("%s#[deprecated(note = %s)]\n", indent, stringLiteral(msg))
+		}
+	}
+}
+
+// typ returns the Rust type of a Lark type.
+func (g *fileGen) typ(t *schema.Type) string {
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch t.Primitive {
+		case schema.Bool:
+			return "bool"
+		case schema.Int8, schema.Int16, schema.Int32, schema.Int64:
+			return "i" + strings.TrimPrefix(t.Primitive.String(), "int")
+		case schema.Uint8, schema.Uint16, schema.Uint32, schema.Uint64:
+			return "u" + strings.TrimPrefix(t.Primitive.String(), "uint")
+		case schema.Float32:
+			return "f32"
+		case schema.Float64:
+			return "f64"
+		case schema.Bytes:
+			return g.useSupport() + "::Bytes"
+		case schema.Timestamp:
+			return "chrono::DateTime<chrono::Utc>"
+		case schema.UUID:
+			return "uuid::Uuid"
+		}
+		return "String"
+	case schema.ListType:
+		return "Vec<" + g.typ(t.Elem) + ">"
+	case schema.ArrayType:
+		if t.Len > maxArray {
+			return "Vec<" + g.typ(t.Elem) + ">"
+		}
+		return fmt.Sprintf("[%s; %d]", g.typ(t.Elem), t.Len)
+	case schema.MapType:
+		if u := t.Key.Underlying(); u.Kind == schema.PrimitiveType && u.Primitive.IsFloat() && g.err == nil {
+			g.err = fmt.Errorf("%s: Rust maps cannot have %s keys", g.file.Path, t.Key)
+		}
+		g.hashMap = true
+		return "HashMap<" + g.typ(t.Key) + ", " + g.typ(t.Elem) + ">"
+	}
+	return g.ref(t.Decl)
+}
+
+// ref returns the path by which the file refers to a declaration.
+func (g *fileGen) ref(decl schema.Decl) string {
+	info := decl.DeclInfo()
+	if _, ok := info.Deprecated(); ok {
+		g.deprecated = true
+	}
+	if info.File == g.file {
+		return typeName(info.Name)
+	}
+	g.used[info.File.Module] = true
+	return g.module(info.File.Module, path.Base(info.File.Module)) + "::" + typeName(info.Name)
+}
+
+// boxed returns the Rust type of t in a field or a variant of decl, which
+// is boxed if a value of t contains a decl.
+func (g *fileGen) boxed(t *schema.Type, decl schema.Decl) string {
+	if contains(t, decl, map[schema.Decl]bool{}) {
+		return "Box<" + g.typ(t) + ">"
+	}
+	return g.typ(t)
+}
+
+// contains reports whether a value of type t contains a value of decl,
+// other than through a list or a map.
+func contains(t *schema.Type, decl schema.Decl, seen map[schema.Decl]bool) bool {
+	u := t.Underlying()
+	if u.Kind == schema.ArrayType {
+		return contains(u.Elem, decl, seen)
+	}
+	if u.Kind != schema.NamedType {
+		return false
+	}
+	if u.Decl == decl {
+		return true
+	}
+	if seen[u.Decl] {
+		return false
+	}
+	seen[u.Decl] = true
+	switch d := u.Decl.(type) {
+	case *schema.Struct:
+		for _, field := range d.Fields {
+			if contains(field.Type, decl, seen) {
+				return true
+			}
+		}
+	case *schema.Union:
+		for _, variant := range d.Variants {
+			if contains(variant.Type, decl, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *fileGen) constant(k *schema.Const) error {
+	if k.Value.Kind() == constant.Null {
+		return nil
+	}
+	name := constName(k.Name)
+	typ := g.typ(k.Type)
+	value := literal(k.Value)
+
+	switch u := k.Type.Underlying(); {
+	case u.Primitive == schema.String:
+		typ = "&str"
+	case u.Primitive == schema.Bytes:
+		typ, value = "&[u8]", bytesLiteral(k.Value.StringVal())
+	case u.Primitive == schema.Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, k.Value.StringVal())
+		if err != nil {
+			return fmt.Errorf("constant %s: %v", k.Name, err)
+		}
+		if t.Before(time.Unix(0, math.MinInt64)) || t.After(time.Unix(0, math.MaxInt64)) {
+			return fmt.Errorf("constant %s: %s is out of the range of Rust timestamp constants", k.Name, k.Value.StringVal())
+		}
+		value = fmt.Sprintf("chrono::DateTime::from_timestamp_nanos(%d); // %s", t.UnixNano(), t.UTC().Format(time.RFC3339Nano))
+	case u.Primitive == schema.UUID:
+		id := strings.ToLower(k.Value.StringVal())
+		value = fmt.Sprintf("uuid::Uuid::from_u128(0x%s); // %s", strings.ReplaceAll(id, "-", ""), id)
+	}
+	if !strings.Contains(value, ";") {
+		value += ";"
+	}
+	g.printf("\n")
+	g.doc(&k.Info, "")
+	g.printf("pub const %s: %s = %s\n", name, typ, value)
+	return nil
+}
+
+// literal returns a constant value in Rust syntax.
+func literal(v constant.Value) string {
+	switch v.Kind() {
+	case constant.String:
+		return stringLiteral(v.StringVal())
+	case constant.Float:
+		s := v.String()
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
+	return v.String()
+}
+
+// stringLiteral returns s as a Rust string literal.
+func stringLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// bytesLiteral returns s as a Rust byte string literal.
+func bytesLiteral(s string) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// serdeAttr returns the serde attribute of a field or a variant with the
+// given arguments, if there are any.
+func serdeAttr(args ...string) string {
+	var list []string
+	for _, arg := range args {
+		if arg != "" {
+			list = append(list, arg)
+		}
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return "#[serde(" + strings.Join(list, ", ") + ")]"
+}
+
+// rename returns the rename argument of a serde attribute if the Rust name
+// of an item differs from its name in JSON.
+func rename(rustName, jsonName string) string {
+	if strings.TrimPrefix(rustName, "r#") == jsonName {
+		return ""
+	}
+	return "rename = " + stringLiteral(jsonName)
+}
+
+func (g *fileGen) structType(s *schema.Struct) {
+	g.serde = true
+	g.printf("\n")
+	g.doc(&s.Info, "")
+	g.printf("#[derive(Serialize, Deserialize, Debug, Clone, PartialEq)]\n")
+	if len(s.Fields) == 0 {
+		g.printf("pub struct %s {}\n", typeName(s.Name))
+		return
+	}
+	g.printf("pub struct %s {\n", typeName(s.Name))
+	for _, field := range s.Fields {
+		g.doc(&field.Info, "    ")
+		name := fieldName(field.Name)
+		typ := g.boxed(field.Type, s)
+		var optional string
+		if field.Optional {
+			typ = "Option<" + typ + ">"
+			optional = `default, skip_serializing_if = "Option::is_none"`
+		}
+		if attr := serdeAttr(rename(name, field.JSONName()), optional); attr != "" {
+			g.printf("    %s\n", attr)
+		}
+		g.printf("    pub %s: %s,\n", name, typ)
+	}
+	g.printf("}\n")
+}
+
+func (g *fileGen) enum(e *schema.Enum) {
+	name := typeName(e.Name)
+	g.printf("\n")
+	g.doc(&e.Info, "")
+	g.printf("#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]\n")
+	if len(e.Members) > 0 {
+		g.printf("#[repr(i32)]\n")
+	}
+	if len(e.Members) == 0 {
+		g.printf("pub enum %s {}\n", name)
+	} else {
+		g.printf("pub enum %s {\n", name)
+		for _, member := range e.Members {
+			g.doc(&member.Info, "    ")
+			g.printf("    %s = %d,\n", memberName(member.Name), member.Value)
+		}
+		g.printf("}\n")
+	}
+
+	// Enums are encoded as numbers, which the derived implementations do
+	// not support.
+	g.serde = true
+	param, value := "serializer", "serializer.serialize_i32(*self as i32)"
+	if len(e.Members) == 0 {
+		param, value = "_serializer", "match *self {}"
+	}
+	g.printf("\nimpl Serialize for %s {\n", name)
+	g.printf("    fn serialize<S: serde::Serializer>(&self, %s: S) -> Result<S::Ok, S::Error> {\n", param)
+	g.printf("        %s\n", value)
+	g.printf("    }\n")
+	g.printf("}\n")
+	g.printf("\nimpl<'de> Deserialize<'de> for %s {\n", name)
+	g.printf("    fn deserialize<D: serde::Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {\n")
+	g.printf("        match %s::deserialize_enum(deserializer)? {\n", g.useSupport())
+	for _, member := range e.Members {
+		g.printf("            %d => Ok(%s::%s),\n", member.Value, name, memberName(member.Name))
+	}
+	g.printf("            v => Err(serde::de::Error::custom(format_args!(%s, v))),\n", stringLiteral("unknown value {} of "+name))
+	g.printf("        }\n")
+	g.printf("    }\n")
+	g.printf("}\n")
+}
+
+func (g *fileGen) union(u *schema.Union) {
+	g.serde = true
+	g.printf("\n")
+	g.doc(&u.Info, "")
+	g.printf("#[derive(Serialize, Deserialize, Debug, Clone, PartialEq)]\n")
+	g.printf("#[serde(tag = %s)]\n", stringLiteral(u.Tag))
+	if len(u.Variants) == 0 {
+		g.printf("pub enum %s {}\n", typeName(u.Name))
+		return
+	}
+	g.printf("pub enum %s {\n", typeName(u.Name))
+	for _, variant := range u.Variants {
+		g.doc(&variant.Info, "    ")
+		name := memberName(variant.Name)
+		if attr := serdeAttr(rename(name, variant.Name)); attr != "" {
+			g.printf("    %s\n", attr)
+		}
+		g.printf("    %s(%s),\n", name, g.boxed(variant.Type, u))
+	}
+	g.printf("}\n")
+}
+
+func (g *fileGen) iface(i *schema.Interface) {
+	g.printf("\n")
+	g.doc(&i.Info, "")
+	if len(i.Methods) == 0 {
+		g.printf("pub trait %s {}\n", typeName(i.Name))
+		return
+	}
+	g.printf("pub trait %s {\n", typeName(i.Name))
+	g.printf("    /// Error is the error of the methods.\n")
+	g.printf("    type Error;\n")
+	for _, method := range i.Methods {
+		g.printf("\n")
+		g.doc(&method.Info, "    ")
+		params := []string{"&self"}
+		for _, param := range method.Params {
+			params = append(params, fieldName(param.Name)+": "+g.typ(param.Type))
+		}
+		result := "()"
+		if method.Result != nil {
+			result = g.typ(method.Result)
+		}
+		g.printf("    fn %s(%s) -> Result<%s, Self::Error>;\n", fieldName(method.Name), strings.Join(params, ", "), result)
+	}
+	g.printf("}\n")
+}
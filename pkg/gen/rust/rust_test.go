@@ -0,0 +1,90 @@
+package rust
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/loader"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark", "common/types.lark")
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if got, want := strings.Join(names, " "), "users.rs common/types.rs lark.rs mod.rs common/mod.rs"; got != want {
+		t.Fatalf("got files %s; want %s", got, want)
+	}
+
+	for _, file := range files[:2] {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".rs")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+
+	if want := "\npub mod common;\npub mod lark;\npub mod users;\n"; !strings.HasSuffix(string(files[3].Content), want) {
+		t.Errorf("mod.rs does not end with %q:\n%s", want, files[3].Content)
+	}
+	if want := "\npub mod types;\n"; !strings.HasSuffix(string(files[4].Content), want) {
+		t.Errorf("common/mod.rs does not end with %q:\n%s", want, files[4].Content)
+	}
+}
+
+func TestModules(t *testing.T) {
+	s := gentest.Check(t,
+		loader.Source{Path: "common.lark", Src: []byte("struct Page { size: int32 }\n")},
+		loader.Source{Path: "common/types.lark", Src: []byte("struct Group { name: string }\n")},
+	)
+	files, err := generator{}.Generate(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The module common declares the modules of its directory, and no
+	// module needs the support module.
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if got, want := strings.Join(names, " "), "common.rs common/types.rs mod.rs"; got != want {
+		t.Fatalf("got files %s; want %s", got, want)
+	}
+	if want := "}\n\npub mod types;\n"; !strings.HasSuffix(string(files[0].Content), want) {
+		t.Errorf("common.rs does not end with %q:\n%s", want, files[0].Content)
+	}
+}
+
+func TestFloatKeys(t *testing.T) {
+	s := gentest.Check(t, loader.Source{Path: "weights.lark", Src: []byte("struct W { m: map[float64, string] }\n")})
+	_, err := generator{}.Generate(s, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot have float64 keys") {
+		t.Errorf("got error %v; want an error about float64 keys", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, field, member, constant string
+	}{
+		{"user", "user", "User", "USER"},
+		{"userId", "user_id", "UserId", "USER_ID"},
+		{"HTTPServer", "http_server", "HttpServer", "HTTP_SERVER"},
+		{"super_user", "super_user", "SuperUser", "SUPER_USER"},
+		{"type", "r#type", "Type", "TYPE"},
+		{"self", "self_", "Self_", "SELF"},
+		{"result", "result", "Result_", "RESULT"},
+	}
+	for _, test := range tests {
+		if got := fieldName(test.name); got != test.field {
+			t.Errorf("fieldName(%q) = %q; want %q", test.name, got, test.field)
+		}
+		if got := memberName(test.name); got != test.member {
+			t.Errorf("memberName(%q) = %q; want %q", test.name, got, test.member)
+		}
+		if got := constName(test.name); got != test.constant {
+			t.Errorf("constName(%q) = %q; want %q", test.name, got, test.constant)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package rust
+
+// supportSrc is the source of the support module of the generated
+// modules.
+const supportSrc = `// Code generated by lark gen. DO NOT EDIT.
+
+//! Support code of the modules generated by lark gen.
+
+use std::fmt;
+use std::ops::{Deref, DerefMut};
+
+use serde::{de, Deserialize, Deserializer, Serialize, Serializer};
+
+/// Bytes is a byte string that is base64 encoded in JSON.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Hash, PartialOrd, Ord)]
+pub struct Bytes(pub Vec<u8>);
+
+impl Deref for Bytes {
+    type Target = Vec<u8>;
+
+    fn deref(&self) -> &Vec<u8> {
+        &self.0
+    }
+}
+
+impl DerefMut for Bytes {
+    fn deref_mut(&mut self) -> &mut Vec<u8> {
+        &mut self.0
+    }
+}
+
+impl From<Vec<u8>> for Bytes {
+    fn from(v: Vec<u8>) -> Self {
+        Bytes(v)
+    }
+}
+
+impl From<&[u8]> for Bytes {
+    fn from(v: &[u8]) -> Self {
+        Bytes(v.to_vec())
+    }
+}
+
+const ALPHABET: &[u8; 64] = b"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+
+impl Serialize for Bytes {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        let mut s = String::with_capacity(self.0.len().div_ceil(3) * 4);
+        for chunk in self.0.chunks(3) {
+            let n = chunk
+                .iter()
+                .enumerate()
+                .fold(0u32, |n, (i, &b)| n | (b as u32) << (16 - 8 * i));
+            for i in 0..4 {
+                if i <= chunk.len() {
+                    s.push(ALPHABET[(n >> (18 - 6 * i) & 63) as usize] as char);
+                } else {
+                    s.push('=');
+                }
+            }
+        }
+        serializer.serialize_str(&s)
+    }
+}
+
+impl<'de> Deserialize<'de> for Bytes {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        let s = String::deserialize(deserializer)?;
+        match decode_base64(&s) {
+            Some(v) => Ok(Bytes(v)),
+            None => Err(de::Error::invalid_value(de::Unexpected::Str(&s), &"a base64 string")),
+        }
+    }
+}
+
+fn decode_base64(s: &str) -> Option<Vec<u8>> {
+    if s.len() % 4 != 0 {
+        return None;
+    }
+    let data = s.trim_end_matches('=');
+    if s.len() - data.len() > 2 {
+        return None;
+    }
+    let mut v = Vec::with_capacity(data.len() * 3 / 4);
+    let (mut n, mut bits) = (0u32, 0);
+    for c in data.bytes() {
+        let digit = ALPHABET.iter().position(|&a| a == c)? as u32;
+        n = (n << 6 | digit) & 0x3fff;
+        bits += 6;
+        if bits >= 8 {
+            bits -= 8;
+            v.push((n >> bits) as u8);
+        }
+    }
+    Some(v)
+}
+
+/// Deserializes the number of an enum member. Numbers in strings are
+/// accepted, too, since map keys are strings in JSON.
+pub fn deserialize_enum<'de, D: Deserializer<'de>>(deserializer: D) -> Result<i32, D::Error> {
+    struct Visitor;
+
+    impl de::Visitor<'_> for Visitor {
+        type Value = i32;
+
+        fn expecting(&self, f: &mut fmt::Formatter) -> fmt::Result {
+            f.write_str("an enum value")
+        }
+
+        fn visit_i64<E: de::Error>(self, v: i64) -> Result<i32, E> {
+            i32::try_from(v).map_err(|_| E::invalid_value(de::Unexpected::Signed(v), &self))
+        }
+
+        fn visit_u64<E: de::Error>(self, v: u64) -> Result<i32, E> {
+            i32::try_from(v).map_err(|_| E::invalid_value(de::Unexpected::Unsigned(v), &self))
+        }
+
+        fn visit_str<E: de::Error>(self, v: &str) -> Result<i32, E> {
+            v.parse().map_err(|_| E::invalid_value(de::Unexpected::Str(v), &self))
+        }
+    }
+
+    deserializer.deserialize_any(Visitor)
+}
+`
@@ -0,0 +1,191 @@
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+// A dialect is a dialect of SQL.
+type dialect struct {
+	name  string
+	types map[schema.Primitive]string // column types of primitives
+	enum  string                      // column type of enums
+	json  string                      // column type of JSON values
+}
+
+var postgres = &dialect{
+	name: "postgres",
+	types: map[schema.Primitive]string{
+		schema.Bool:      "BOOLEAN",
+		schema.Int8:      "SMALLINT",
+		schema.Int16:     "SMALLINT",
+		schema.Int32:     "INTEGER",
+		schema.Int64:     "BIGINT",
+		schema.Uint8:     "SMALLINT",
+		schema.Uint16:    "INTEGER",
+		schema.Uint32:    "BIGINT",
+		schema.Uint64:    "NUMERIC(20)",
+		schema.Float32:   "REAL",
+		schema.Float64:   "DOUBLE PRECISION",
+		schema.String:    "TEXT",
+		schema.Bytes:     "BYTEA",
+		schema.Timestamp: "TIMESTAMPTZ",
+		schema.UUID:      "UUID",
+	},
+	enum: "INTEGER",
+	json: "JSONB",
+}
+
+var sqlite = &dialect{
+	name: "sqlite",
+	types: map[schema.Primitive]string{
+		schema.Bool:      "INTEGER",
+		schema.Int8:      "INTEGER",
+		schema.Int16:     "INTEGER",
+		schema.Int32:     "INTEGER",
+		schema.Int64:     "INTEGER",
+		schema.Uint8:     "INTEGER",
+		schema.Uint16:    "INTEGER",
+		schema.Uint32:    "INTEGER",
+		schema.Uint64:    "INTEGER",
+		schema.Float32:   "REAL",
+		schema.Float64:   "REAL",
+		schema.String:    "TEXT",
+		schema.Bytes:     "BLOB",
+		schema.Timestamp: "TEXT",
+		schema.UUID:      "TEXT",
+	},
+	enum: "INTEGER",
+	json: "TEXT",
+}
+
+// lookupDialect returns the dialect with the given name; "" is postgres.
+func lookupDialect(name string) (*dialect, error) {
+	switch name {
+	case "", "postgres":
+		return postgres, nil
+	case "sqlite":
+		return sqlite, nil
+	}
+	return nil, fmt.Errorf("unknown dialect %q: want postgres or sqlite", name)
+}
+
+// create writes the CREATE TABLE statement of t under the given name.
+func (d *dialect) create(buf *bytes.Buffer, t *table, name string) {
+	if t.doc != "" {
+		writeComment(buf, t.doc, "")
+	}
+	fmt.Fprintf(buf, "CREATE TABLE %s (\n", ident(name))
+	var lines []string
+	var docs []string
+	for _, c := range t.columns {
+		lines = append(lines, def(c))
+		docs = append(docs, c.doc)
+	}
+	if len(t.pk) > 0 {
+		lines = append(lines, "PRIMARY KEY ("+idents(t.pk)+")")
+		docs = append(docs, "")
+	}
+	for _, cols := range t.uniques {
+		lines = append(lines, "UNIQUE ("+idents(cols)+")")
+		docs = append(docs, "")
+	}
+	for i, line := range lines {
+		if docs[i] != "" {
+			writeComment(buf, docs[i], "    ")
+		}
+		sep := ","
+		if i == len(lines)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(buf, "    %s%s\n", line, sep)
+	}
+	buf.WriteString(");\n")
+}
+
+func createIndex(buf *bytes.Buffer, table string, idx *index) {
+	fmt.Fprintf(buf, "CREATE INDEX %s ON %s (%s);\n", ident(idx.name), ident(table), idents(idx.columns))
+}
+
+// def returns the definition of a column.
+func def(c *column) string {
+	def := ident(c.name) + " " + c.typ
+	if c.notNull {
+		def += " NOT NULL"
+	}
+	if c.unique {
+		def += " UNIQUE"
+	}
+	if c.values != nil {
+		def += " " + checkConstraint(c)
+	}
+	if c.ref != nil {
+		def += " " + references(c.ref)
+	}
+	return def
+}
+
+// checkConstraint returns the CHECK constraint of an enum column.
+func checkConstraint(c *column) string {
+	values := make([]string, len(c.values))
+	for i, v := range c.values {
+		values[i] = strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprintf("CHECK (%s IN (%s))", ident(c.name), strings.Join(values, ", "))
+}
+
+func references(ref *reference) string {
+	return fmt.Sprintf("REFERENCES %s (%s)", ident(ref.table), ident(ref.column))
+}
+
+// keywords are the SQL keywords that identifiers are quoted to avoid.
+var keywords = map[string]bool{
+	"all": true, "and": true, "any": true, "as": true, "asc": true,
+	"between": true, "by": true, "case": true, "check": true, "column": true,
+	"constraint": true, "create": true, "default": true, "delete": true,
+	"desc": true, "distinct": true, "drop": true, "else": true, "end": true,
+	"exists": true, "foreign": true, "from": true, "grant": true,
+	"group": true, "having": true, "in": true, "index": true, "insert": true,
+	"into": true, "is": true, "join": true, "key": true, "like": true,
+	"limit": true, "not": true, "null": true, "offset": true, "on": true,
+	"or": true, "order": true, "primary": true, "references": true,
+	"select": true, "set": true, "table": true, "then": true, "to": true,
+	"union": true, "unique": true, "update": true, "user": true,
+	"using": true, "values": true, "when": true, "where": true, "with": true,
+}
+
+// ident returns an identifier, quoted if it is a keyword or not in lower
+// snake case.
+func ident(name string) string {
+	plain := name != "" && !keywords[name] && !('0' <= name[0] && name[0] <= '9')
+	for _, r := range name {
+		plain = plain && (r == '_' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}
+	if plain {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// idents returns a list of identifiers separated by commas.
+func idents(names []string) string {
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = ident(name)
+	}
+	return strings.Join(list, ", ")
+}
+
+// snake returns a name in snake case: "createdAt" becomes "created_at".
+func snake(name string) string {
+	words := gen.Words(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
@@ -0,0 +1,319 @@
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"larklang.io/lark/pkg/schema"
+)
+
+// Migrate returns the script in the given dialect, postgres or sqlite,
+// that migrates a database with the tables of the root files of from to
+// the tables of the root files of to. The script runs in a transaction.
+//
+// Tables and columns are matched by name, so a renamed table or column is
+// dropped and created anew. New tables are created, tables that are gone
+// are dropped, and the columns, constraints and indexes of the other
+// tables are altered. PostgreSQL alters columns in place and finds
+// constraints by the names it gives them by default, such as users_pkey
+// and users_email_key. SQLite cannot alter columns, so tables with
+// changes other than new nullable columns and indexes are rebuilt: the
+// rows are copied to a new table that replaces the old one.
+//
+// New NOT NULL columns have no default, so adding them fails for tables
+// with rows, and columns that become NOT NULL cannot take rows with NULL
+// values. Migrate returns a warning for each such column; scripts with
+// warnings need editing before they are applied to tables with rows.
+func Migrate(from, to *schema.Schema, dialect string) ([]byte, []Warning, error) {
+	d, err := lookupDialect(dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	old, err := schemaTables(from, d)
+	if err != nil {
+		return nil, nil, err
+	}
+	tables, err := schemaTables(to, d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := &migration{d: d}
+	oldByName := make(map[string]*table)
+	for _, t := range old {
+		oldByName[t.name] = t
+	}
+	for _, t := range tables {
+		if o := oldByName[t.name]; o != nil {
+			m.alter(o, t)
+			continue
+		}
+		m.section()
+		d.create(&m.buf, t, t.name)
+		for _, idx := range t.indexes {
+			createIndex(&m.buf, t.name, idx)
+		}
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		if !slices.ContainsFunc(tables, func(t *table) bool { return t.name == old[i].name }) {
+			m.section()
+			fmt.Fprintf(&m.buf, "DROP TABLE %s;\n", ident(old[i].name))
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "-- Migration generated by lark migrate for %s.\n", d.name)
+	if m.buf.Len() == 0 {
+		out.WriteString("\n-- The tables are unchanged.\n")
+		return out.Bytes(), nil, nil
+	}
+	if m.rebuilt {
+		out.WriteString("\nPRAGMA foreign_keys = OFF;\n")
+	}
+	out.WriteString("\nBEGIN;\n")
+	out.Write(m.buf.Bytes())
+	out.WriteString("\nCOMMIT;\n")
+	if m.rebuilt {
+		out.WriteString("\nPRAGMA foreign_keys = ON;\n")
+	}
+	return out.Bytes(), m.warnings, nil
+}
+
+// A Warning is a change of a column that makes the migration fail for
+// some tables with rows.
+type Warning struct {
+	Field   *schema.Field // field of the column in the new version
+	Message string
+}
+
+// schemaTables returns the tables of the root files of s, each after the
+// tables it refers to.
+func schemaTables(s *schema.Schema, d *dialect) ([]*table, error) {
+	b := newBuilder(d)
+	var tables []*table
+	files := make(map[string]string) // of the tables by name
+	for _, file := range s.Roots {
+		list, err := b.fileTables(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range list {
+			if other, ok := files[t.name]; ok {
+				return nil, fmt.Errorf("%s: table %s is also declared in %s", file.Path, t.name, other)
+			}
+			files[t.name] = file.Path
+		}
+		tables = append(tables, list...)
+	}
+	return sortTables(tables)
+}
+
+// A migration builds the statements of a migration.
+type migration struct {
+	d        *dialect
+	buf      bytes.Buffer
+	rebuilt  bool // whether a SQLite table is rebuilt
+	warnings []Warning
+}
+
+// section starts the statements for the next table.
+func (m *migration) section() {
+	m.buf.WriteString("\n")
+}
+
+// alter writes the statements that alter table o into t, if any.
+func (m *migration) alter(o, t *table) {
+	m.checkNotNull(o, t)
+	var stmts []string
+	if m.d == sqlite {
+		stmts = m.alterSQLite(o, t)
+	} else {
+		stmts = alterPostgres(o, t)
+	}
+	if len(stmts) == 0 {
+		return
+	}
+	m.section()
+	for _, stmt := range stmts {
+		m.buf.WriteString(stmt + ";\n")
+	}
+}
+
+// checkNotNull adds warnings for the NOT NULL columns of t that o does
+// not have and for the columns that become NOT NULL.
+func (m *migration) checkNotNull(o, t *table) {
+	for _, c := range t.columns {
+		if !c.notNull {
+			continue
+		}
+		switch oc := o.lookup(c.name); {
+		case oc == nil:
+			m.warn(c, "new column %s.%s is NOT NULL without a default, so the migration fails if %[1]s has rows", t.name, c.name)
+		case !oc.notNull:
+			m.warn(c, "column %s.%s becomes NOT NULL, so the migration fails if it holds NULL values", t.name, c.name)
+		}
+	}
+}
+
+func (m *migration) warn(c *column, format string, args ...any) {
+	m.warnings = append(m.warnings, Warning{Field: c.field, Message: fmt.Sprintf(format, args...)})
+}
+
+// droppedIndexes returns the DROP INDEX statements for the indexes of o
+// that t does not have.
+func droppedIndexes(o, t *table) []string {
+	var stmts []string
+	for _, idx := range o.indexes {
+		if !hasIndex(t, idx) {
+			stmts = append(stmts, "DROP INDEX "+ident(idx.name))
+		}
+	}
+	return stmts
+}
+
+// createdIndexes returns the CREATE INDEX statements for the indexes of t
+// that o does not have.
+func createdIndexes(o, t *table) []string {
+	var stmts []string
+	for _, idx := range t.indexes {
+		if !hasIndex(o, idx) {
+			var buf bytes.Buffer
+			createIndex(&buf, t.name, idx)
+			stmts = append(stmts, strings.TrimSuffix(buf.String(), ";\n"))
+		}
+	}
+	return stmts
+}
+
+func hasIndex(t *table, idx *index) bool {
+	return slices.ContainsFunc(t.indexes, func(other *index) bool {
+		return other.name == idx.name && slices.Equal(other.columns, idx.columns)
+	})
+}
+
+func hasUnique(t *table, cols []string) bool {
+	return slices.ContainsFunc(t.uniques, func(other []string) bool { return slices.Equal(other, cols) })
+}
+
+// alterPostgres returns the statements that alter table o into t in
+// PostgreSQL.
+func alterPostgres(o, t *table) []string {
+	alter := "ALTER TABLE " + ident(t.name) + " "
+	constraint := func(parts ...string) string {
+		return ident(t.name + "_" + strings.Join(parts, "_"))
+	}
+	var stmts, adds []string
+
+	samePK := slices.Equal(o.pk, t.pk)
+	if !samePK && len(o.pk) > 0 {
+		stmts = append(stmts, alter+"DROP CONSTRAINT "+constraint("pkey"))
+	}
+	for _, cols := range o.uniques {
+		if !hasUnique(t, cols) {
+			stmts = append(stmts, alter+"DROP CONSTRAINT "+constraint(strings.Join(cols, "_"), "key"))
+		}
+	}
+	stmts = append(stmts, droppedIndexes(o, t)...)
+	for _, c := range o.columns {
+		if t.lookup(c.name) == nil {
+			stmts = append(stmts, alter+"DROP COLUMN "+ident(c.name))
+		}
+	}
+	for _, c := range t.columns {
+		oc := o.lookup(c.name)
+		if oc == nil {
+			stmts = append(stmts, alter+"ADD COLUMN "+def(c))
+			continue
+		}
+		name := ident(c.name)
+		if oc.unique && !c.unique {
+			stmts = append(stmts, alter+"DROP CONSTRAINT "+constraint(c.name, "key"))
+		}
+		sameValues := slices.Equal(oc.values, c.values)
+		if !sameValues && oc.values != nil {
+			stmts = append(stmts, alter+"DROP CONSTRAINT "+constraint(c.name, "check"))
+		}
+		sameRef := oc.ref == nil && c.ref == nil || oc.ref != nil && c.ref != nil && *oc.ref == *c.ref
+		if !sameRef && oc.ref != nil {
+			stmts = append(stmts, alter+"DROP CONSTRAINT "+constraint(c.name, "fkey"))
+		}
+		if oc.typ != c.typ {
+			stmts = append(stmts, alter+"ALTER COLUMN "+name+" TYPE "+c.typ)
+		}
+		switch {
+		case !oc.notNull && c.notNull:
+			stmts = append(stmts, alter+"ALTER COLUMN "+name+" SET NOT NULL")
+		case oc.notNull && !c.notNull:
+			stmts = append(stmts, alter+"ALTER COLUMN "+name+" DROP NOT NULL")
+		}
+		if !oc.unique && c.unique {
+			adds = append(adds, alter+"ADD CONSTRAINT "+constraint(c.name, "key")+" UNIQUE ("+name+")")
+		}
+		if !sameValues && c.values != nil {
+			adds = append(adds, alter+"ADD CONSTRAINT "+constraint(c.name, "check")+" "+checkConstraint(c))
+		}
+		if !sameRef && c.ref != nil {
+			adds = append(adds, alter+"ADD CONSTRAINT "+constraint(c.name, "fkey")+" FOREIGN KEY ("+name+") "+references(c.ref))
+		}
+	}
+	stmts = append(stmts, adds...)
+	if !samePK && len(t.pk) > 0 {
+		stmts = append(stmts, alter+"ADD PRIMARY KEY ("+idents(t.pk)+")")
+	}
+	for _, cols := range t.uniques {
+		if !hasUnique(o, cols) {
+			stmts = append(stmts, alter+"ADD UNIQUE ("+idents(cols)+")")
+		}
+	}
+	return append(stmts, createdIndexes(o, t)...)
+}
+
+// alterSQLite returns the statements that alter table o into t in
+// SQLite, which rebuild the table unless the changes are limited to new
+// nullable columns and indexes.
+func (m *migration) alterSQLite(o, t *table) []string {
+	rebuild := !slices.Equal(o.pk, t.pk) || !slices.EqualFunc(o.uniques, t.uniques, slices.Equal)
+	var added []*column
+	for _, c := range o.columns {
+		rebuild = rebuild || t.lookup(c.name) == nil
+	}
+	for _, c := range t.columns {
+		oc := o.lookup(c.name)
+		switch {
+		case oc == nil:
+			rebuild = rebuild || c.notNull || c.unique
+			added = append(added, c)
+		case def(oc) != def(c):
+			rebuild = true
+		}
+	}
+	if !rebuild {
+		stmts := droppedIndexes(o, t)
+		for _, c := range added {
+			stmts = append(stmts, "ALTER TABLE "+ident(t.name)+" ADD COLUMN "+def(c))
+		}
+		return append(stmts, createdIndexes(o, t)...)
+	}
+
+	m.rebuilt = true
+	tmp := t.name + "_new"
+	var buf bytes.Buffer
+	m.d.create(&buf, t, tmp)
+	stmts := []string{strings.TrimSuffix(buf.String(), ";\n")}
+	var cols []string
+	for _, c := range t.columns {
+		if o.lookup(c.name) != nil {
+			cols = append(cols, c.name)
+		}
+	}
+	if len(cols) > 0 {
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s) SELECT %[2]s FROM %s", ident(tmp), idents(cols), ident(t.name)))
+	}
+	stmts = append(stmts,
+		"DROP TABLE "+ident(t.name),
+		"ALTER TABLE "+ident(tmp)+" RENAME TO "+ident(t.name),
+	)
+	return append(stmts, createdIndexes(&table{}, t)...)
+}
@@ -0,0 +1,363 @@
+// Package sql implements the "sql" generator, which produces CREATE TABLE
+// statements for the structs of checked Lark schemas that have a @table
+// annotation, and [Migrate], which produces the statements that migrate a
+// database from one version of a schema to another.
+//
+// Every root file with tables becomes a script at its module path: module
+// "api/users" becomes api/users.sql. Tables are named by the argument of
+// @table("name"), or after their struct in snake case, and columns after
+// their fields in snake case. Tables that refer to each other come after
+// the tables they refer to. Fields are translated as follows:
+//
+//   - Primitive types become the column types of the dialect, such as
+//     BIGINT for int64 and TIMESTAMPTZ for timestamp in PostgreSQL, and
+//     INTEGER, REAL, TEXT or BLOB in SQLite.
+//   - Optional fields become columns that may be NULL; all others are
+//     NOT NULL.
+//   - Enums become integer columns with a CHECK constraint that allows
+//     the values of their members.
+//   - Structs with @table become foreign keys to the primary key of their
+//     table, which must be a single column. The column is named after the
+//     field and the key, as in manager_id.
+//   - Lists, arrays, maps and other structs and unions hold their JSON
+//     encoding, in JSONB in PostgreSQL and TEXT in SQLite. Lists of
+//     tables are rejected: the foreign key belongs into the elements.
+//
+// Fields with @pk form the primary key, fields with @unique are unique
+// and fields with @index are indexed. On structs, @unique("a", "b") and
+// @index("a", "b") apply to several fields at once. Doc comments become
+// SQL comments.
+//
+// The generator takes this parameter:
+//
+//	dialect  postgres or sqlite (default: postgres)
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("sql", generator{})
+}
+
+type generator struct{}
+
+// Generate returns one script for every root file of s that has tables.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	d, err := lookupDialect(params["dialect"])
+	if err != nil {
+		return nil, err
+	}
+	b := newBuilder(d)
+	var files []gen.File
+	for _, file := range s.Roots {
+		tables, err := b.fileTables(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 {
+			continue
+		}
+		if tables, err = sortTables(tables); err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Path, err)
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "-- Code generated by lark gen from %s. DO NOT EDIT.\n", path.Base(file.Module)+".lark")
+		if file.Doc != "" {
+			buf.WriteString("\n")
+			writeComment(&buf, file.Doc, "")
+		}
+		for _, t := range tables {
+			buf.WriteString("\n")
+			d.create(&buf, t, t.name)
+			for _, idx := range t.indexes {
+				createIndex(&buf, t.name, idx)
+			}
+		}
+		files = append(files, gen.File{Name: file.Module + ".sql", Content: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// A table is a table of a struct with @table.
+type table struct {
+	name    string
+	doc     string
+	columns []*column
+	pk      []string   // columns of the primary key
+	uniques [][]string // unique sets of several columns
+	indexes []*index
+}
+
+// A column is a column of a table.
+type column struct {
+	name    string
+	typ     string
+	doc     string
+	notNull bool
+	unique  bool
+	values  []int64    // allowed values of an enum column, or nil
+	ref     *reference // or nil
+	field   *schema.Field
+}
+
+// A reference is the target of a foreign key.
+type reference struct {
+	table, column string
+}
+
+// An index is an index of a table.
+type index struct {
+	name    string
+	columns []string
+}
+
+// lookup returns the column of a table with the given name, or nil.
+func (t *table) lookup(name string) *column {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// refs returns the tables that the columns of t refer to.
+func (t *table) refs() []string {
+	var list []string
+	for _, c := range t.columns {
+		if c.ref != nil && c.ref.table != t.name {
+			list = append(list, c.ref.table)
+		}
+	}
+	return list
+}
+
+// sortTables orders tables after the tables they refer to, keeping their
+// order otherwise.
+func sortTables(tables []*table) ([]*table, error) {
+	byName := make(map[string]*table)
+	for _, t := range tables {
+		byName[t.name] = t
+	}
+	var sorted []*table
+	state := make(map[*table]int) // 1: visiting, 2: done
+	var visit func(t *table, from *table) error
+	visit = func(t *table, from *table) error {
+		switch state[t] {
+		case 1:
+			return fmt.Errorf("tables %s and %s refer to each other, so neither can be created first", from.name, t.name)
+		case 2:
+			return nil
+		}
+		state[t] = 1
+		for _, name := range t.refs() {
+			if ref := byName[name]; ref != nil {
+				if err := visit(ref, t); err != nil {
+					return err
+				}
+			}
+		}
+		state[t] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, t := range tables {
+		if err := visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// A builder builds the tables of structs.
+type builder struct {
+	d      *dialect
+	tables map[*schema.Struct]*table
+	keys   map[*schema.Struct]*column // primary keys, nil while being built
+}
+
+func newBuilder(d *dialect) *builder {
+	return &builder{d: d, tables: make(map[*schema.Struct]*table), keys: make(map[*schema.Struct]*column)}
+}
+
+// isTable reports whether a struct has a table.
+func isTable(s *schema.Struct) bool {
+	return s.Annotations.Has("table")
+}
+
+// tableStruct returns the struct of t if it has a table.
+func tableStruct(t *schema.Type) (*schema.Struct, bool) {
+	if t = t.Underlying(); t.Kind != schema.NamedType {
+		return nil, false
+	}
+	s, ok := t.Decl.(*schema.Struct)
+	return s, ok && isTable(s)
+}
+
+// tableName returns the name of the table of a struct.
+func tableName(s *schema.Struct) string {
+	if name, ok := s.Annotations.Lookup("table").String(0); ok {
+		return name
+	}
+	return snake(s.Name)
+}
+
+// fileTables returns the tables of the structs of a file.
+func (b *builder) fileTables(file *schema.File) ([]*table, error) {
+	var tables []*table
+	for _, decl := range file.Decls {
+		s, ok := decl.(*schema.Struct)
+		if !ok || !isTable(s) {
+			continue
+		}
+		t, err := b.table(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Path, err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// table returns the table of a struct with @table.
+func (b *builder) table(s *schema.Struct) (*table, error) {
+	if t := b.tables[s]; t != nil {
+		return t, nil
+	}
+	a := s.Annotations.Lookup("table")
+	if _, ok := a.String(0); len(a.Args) > 1 || len(a.Args) == 1 && !ok {
+		return nil, fmt.Errorf("@table of %s takes at most one string, the table name", s.Name)
+	}
+	t := &table{name: tableName(s), doc: s.Doc}
+	columns := make(map[string]string) // by field name
+	for _, f := range s.Fields {
+		c, err := b.column(f)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", f.Name, s.Name, err)
+		}
+		if t.lookup(c.name) != nil {
+			return nil, fmt.Errorf("field %s of %s: column %s appears twice", f.Name, s.Name, c.name)
+		}
+		columns[f.Name] = c.name
+		if f.Annotations.Has("pk") {
+			if f.Optional {
+				return nil, fmt.Errorf("field %s of %s is optional and cannot be part of the primary key", f.Name, s.Name)
+			}
+			t.pk = append(t.pk, c.name)
+		}
+		c.unique = f.Annotations.Has("unique")
+		if f.Annotations.Has("index") {
+			t.indexes = append(t.indexes, newIndex(t.name, []string{c.name}))
+		}
+		t.columns = append(t.columns, c)
+	}
+	for _, a := range s.Annotations {
+		if a.Name != "unique" && a.Name != "index" {
+			continue
+		}
+		var cols []string
+		for i := range a.Args {
+			name, ok := a.String(i)
+			if !ok {
+				return nil, fmt.Errorf("@%s of %s takes the names of fields", a.Name, s.Name)
+			}
+			col, ok := columns[name]
+			if !ok {
+				return nil, fmt.Errorf("@%s of %s names %s, which is not a field", a.Name, s.Name, name)
+			}
+			cols = append(cols, col)
+		}
+		if len(cols) == 0 {
+			return nil, fmt.Errorf("@%s of %s takes the names of fields", a.Name, s.Name)
+		}
+		if a.Name == "unique" {
+			t.uniques = append(t.uniques, cols)
+		} else {
+			t.indexes = append(t.indexes, newIndex(t.name, cols))
+		}
+	}
+	b.tables[s] = t
+	return t, nil
+}
+
+func newIndex(table string, columns []string) *index {
+	return &index{name: table + "_" + strings.Join(columns, "_") + "_idx", columns: columns}
+}
+
+// column returns the column of a field.
+func (b *builder) column(f *schema.Field) (*column, error) {
+	c := &column{name: snake(f.Name), doc: f.Doc, notNull: !f.Optional, field: f}
+	switch t := f.Type.Underlying(); t.Kind {
+	case schema.PrimitiveType:
+		c.typ = b.d.types[t.Primitive]
+	case schema.NamedType:
+		switch decl := t.Decl.(type) {
+		case *schema.Enum:
+			c.typ = b.d.enum
+			for _, m := range decl.Members {
+				c.values = append(c.values, m.Value)
+			}
+		case *schema.Struct:
+			if !isTable(decl) {
+				c.typ = b.d.json
+				break
+			}
+			key, err := b.key(decl)
+			if err != nil {
+				return nil, err
+			}
+			c.name += "_" + key.name
+			c.typ = key.typ
+			c.ref = &reference{table: tableName(decl), column: key.name}
+		default:
+			c.typ = b.d.json
+		}
+	default:
+		if s, ok := tableStruct(t.Elem); ok {
+			return nil, fmt.Errorf("%s of table %s cannot be a column; add a foreign key to %s instead", f.Type, tableName(s), s.Name)
+		}
+		c.typ = b.d.json
+	}
+	return c, nil
+}
+
+// key returns the primary key column of the table of a struct that a
+// foreign key refers to.
+func (b *builder) key(s *schema.Struct) (*column, error) {
+	if c, ok := b.keys[s]; ok {
+		if c == nil {
+			return nil, fmt.Errorf("the primary key of table %s refers to itself", tableName(s))
+		}
+		return c, nil
+	}
+	var pk []*schema.Field
+	for _, f := range s.Fields {
+		if f.Annotations.Has("pk") {
+			pk = append(pk, f)
+		}
+	}
+	if len(pk) != 1 {
+		return nil, fmt.Errorf("table %s is referred to, but its primary key is not a single column", tableName(s))
+	}
+	b.keys[s] = nil
+	c, err := b.column(pk[0])
+	if err != nil {
+		return nil, fmt.Errorf("field %s of %s: %v", pk[0].Name, s.Name, err)
+	}
+	b.keys[s] = c
+	return c, nil
+}
+
+func writeComment(buf *bytes.Buffer, text, indent string) {
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(strings.TrimRight(indent+"-- "+line, " ") + "\n")
+	}
+}
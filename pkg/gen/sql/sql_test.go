@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+func TestGolden(t *testing.T) {
+	s := gentest.Load(t, "users.lark")
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		files, err := generator{}.Generate(s, gen.Params{"dialect": dialect})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Name != "users.sql" {
+			t.Fatalf("got files %v", files)
+		}
+		gentest.Compare(t, filepath.Join("testdata", "users."+dialect+".golden"), "output", files[0].Content)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	from, to := gentest.Load(t, "v1/shop.lark"), gentest.Load(t, "v2/shop.lark")
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		got, warnings, err := Migrate(from, to, dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gentest.Compare(t, filepath.Join("testdata", "migrate."+dialect+".golden"), "output", got)
+		if len(warnings) != 1 || warnings[0].Field.Name != "note" {
+			t.Errorf("%s: got warnings %v", dialect, warnings)
+		}
+	}
+	got, warnings, err := Migrate(to, to, "")
+	if err != nil || len(warnings) > 0 {
+		t.Fatal(err, warnings)
+	}
+	if !strings.Contains(string(got), "The tables are unchanged.") {
+		t.Errorf("migration between equal versions:\n%s", got)
+	}
+}
+
+func TestMigrateWarnings(t *testing.T) {
+	from := gentest.Check(t, loader.Source{Path: "orders.lark", Src: []byte("@table struct Order {\n @pk id: int64\n note?: string\n}\n")})
+	to := gentest.Check(t, loader.Source{Path: "orders.lark", Src: []byte("@table struct Order {\n @pk id: int64\n note: string\n total: int64\n}\n")})
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		_, warnings, err := Migrate(from, to, dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, w := range warnings {
+			got = append(got, fmt.Sprintf("%s: %s", w.Field.Pos, w.Message))
+		}
+		want := []string{
+			"3:2: column order.note becomes NOT NULL, so the migration fails if it holds NULL values",
+			"4:2: new column order.total is NOT NULL without a default, so the migration fails if order has rows",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: got warnings %q; want %q", dialect, got, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"@table(1) struct S { n: int32 }", "@table of S takes at most one string, the table name"},
+		{"@table struct S { @pk n?: int32 }", "field n of S is optional and cannot be part of the primary key"},
+		{"@table struct S { userId: int32\n user_id: int32 }", "field user_id of S: column user_id appears twice"},
+		{"@table @unique(\"m\") struct S { n: int32 }", "@unique of S names m, which is not a field"},
+		{"@table @index struct S { n: int32 }", "@index of S takes the names of fields"},
+		{"@table struct T { n: int32 }\n@table struct S { t: T }", "field t of S: table t is referred to, but its primary key is not a single column"},
+		{"@table struct T { @pk n: int32 }\n@table struct S { ts: list[T] }", "field ts of S: list[errors.T] of table t cannot be a column; add a foreign key to T instead"},
+		{"@table struct A { @pk n: int32\n b?: B }\n@table struct B { @pk n: int32\n a?: A }", "tables b and a refer to each other, so neither can be created first"},
+	}
+	for _, test := range tests {
+		s := gentest.Check(t, loader.Source{Path: "errors.lark", Src: []byte(test.src + "\n")})
+		_, err := generator{}.Generate(s, nil)
+		if err == nil || err.Error() != "errors.lark: "+test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+	if _, err := (generator{}).Generate(&schema.Schema{}, gen.Params{"dialect": "mysql"}); err == nil || err.Error() != `unknown dialect "mysql": want postgres or sqlite` {
+		t.Errorf("got error %v for dialect mysql", err)
+	}
+}
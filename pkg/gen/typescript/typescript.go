@@ -0,0 +1,410 @@
+// Package typescript implements the "typescript" generator, which produces
+// TypeScript type definitions from checked Lark schemas.
+//
+// Every Lark file becomes a TypeScript module at its module path: module
+// "api/users" becomes api/users.ts. Every import of the Lark file becomes
+// a namespace import under the same name. The types describe the JSON
+// encoding of values:
+//
+//   - Structs become interfaces whose properties have the JSON names of
+//     the fields. Optional fields become optional properties.
+//   - Lists and arrays become arrays and maps become records. Maps with
+//     enum keys become partial records, since not every member needs to
+//     be a key.
+//   - Numbers become number; integers beyond 2^53 lose precision in
+//     JavaScript. Strings, bytes (base64), timestamps (RFC 3339) and UUIDs
+//     become string.
+//   - Enums become const enums with the same values.
+//   - Unions become discriminated unions: every variant is its struct
+//     with the tag member added.
+//   - Constants become exported constants and type aliases become type
+//     aliases. Integer constants beyond 2^53 become bigint constants.
+//   - Interfaces become client interfaces whose methods return promises.
+//
+// Doc comments become JSDoc comments, and @deprecated annotations become
+// @deprecated tags.
+//
+// The generator takes these parameters:
+//
+//	const_enums       "false" for enums that exist at run time (default: true)
+//	import_extension  extension of import paths, such as ".js" (default: none)
+package typescript
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"regexp"
+	"strings"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func init() {
+	gen.Register("typescript", generator{})
+}
+
+type generator struct{}
+
+// Generate returns one TypeScript module for every root file of s.
+func (generator) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	constEnums := true
+	switch params["const_enums"] {
+	case "", "true":
+	case "false":
+		constEnums = false
+	default:
+		return nil, fmt.Errorf("invalid value %q of const_enums: want true or false", params["const_enums"])
+	}
+
+	var files []gen.File
+	for _, file := range s.Roots {
+		g := &fileGen{file: file, constEnums: constEnums, extension: params["import_extension"]}
+		files = append(files, gen.File{Name: file.Module + ".ts", Content: g.generate()})
+	}
+	return files, nil
+}
+
+// fileGen generates the TypeScript module for one Lark file.
+type fileGen struct {
+	file       *schema.File
+	constEnums bool
+	extension  string
+	buf        bytes.Buffer
+}
+
+func (g *fileGen) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *fileGen) generate() []byte {
+	g.printf("// Code generated by lark gen from %s. DO NOT EDIT.\n", path.Base(g.file.Module)+".lark")
+	if g.file.Doc != "" {
+		g.printf("\n")
+		for _, line := range strings.Split(g.file.Doc, "\n") {
+			g.printf("%s\n", strings.TrimRight("// "+line, " "))
+		}
+	}
+	if len(g.file.Imports) > 0 {
+		g.printf("\n")
+		for _, imp := range g.file.Imports {
+			g.printf("import * as %s from %s;\n", ident(imp.Name), jsonString(importPath(g.file.Module, imp.File.Module)+g.extension))
+		}
+	}
+
+	for _, decl := range g.file.Decls {
+		switch decl := decl.(type) {
+		case *schema.Const:
+			g.printf("\n")
+			g.doc(&decl.Info, "")
+			if decl.Type == nil {
+				g.printf("export const %s = null;\n", ident(decl.Name))
+			} else if isBigInt(decl.Value) {
+				g.printf("export const %s: bigint = %sn;\n", ident(decl.Name), decl.Value)
+			} else {
+				g.printf("export const %s: %s = %s;\n", ident(decl.Name), g.typ(decl.Type), literal(decl))
+			}
+		case *schema.Alias:
+			g.printf("\n")
+			g.doc(&decl.Info, "")
+			g.printf("export type %s = %s;\n", ident(decl.Name), g.typ(decl.Type))
+		case *schema.Struct:
+			g.structType(decl)
+		case *schema.Enum:
+			g.enum(decl)
+		case *schema.Union:
+			g.union(decl)
+		case *schema.Interface:
+			g.iface(decl)
+		}
+	}
+	return g.buf.Bytes()
+}
+
+// importPath returns the relative path by which module from imports
+// module to.
+func importPath(from, to string) string {
+	var dir []string
+	if d := path.Dir(from); d != "." {
+		dir = strings.Split(d, "/")
+	}
+	target := strings.Split(to, "/")
+	common := 0
+	for common < len(dir) && common < len(target)-1 && dir[common] == target[common] {
+		common++
+	}
+	up := len(dir) - common
+	rest := strings.Join(target[common:], "/")
+	if up == 0 {
+		return "./" + rest
+	}
+	return strings.Repeat("../", up) + rest
+}
+
+// doc writes the JSDoc comment of a declaration or a member, indented by
+// indent.
+func (g *fileGen) doc(info *schema.Info, indent string) {
+	var lines []string
+	if info.Doc != "" {
+		lines = strings.Split(info.Doc, "\n")
+	}
+	if msg, ok := info.Deprecated(); ok {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, strings.TrimSpace("@deprecated "+msg))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "*/", "*\\/")
+	}
+
+	if len(lines) == 1 {
+		g.printf("%s/** %s */\n", indent, lines[0])
+		return
+	}
+	g.printf("%s/**\n", indent)
+	for _, line := range lines {
+		g.printf("%s\n", strings.TrimRight(indent+" * "+line, " "))
+	}
+	g.printf("%s */\n", indent)
+}
+
+// typ returns the TypeScript type of a Lark type.
+func (g *fileGen) typ(t *schema.Type) string {
+	switch t.Kind {
+	case schema.PrimitiveType:
+		switch {
+		case t.Primitive == schema.Bool:
+			return "boolean"
+		case t.Primitive.IsInteger() || t.Primitive.IsFloat():
+			return "number"
+		}
+		return "string"
+	case schema.ListType, schema.ArrayType:
+		return g.typ(t.Elem) + "[]"
+	case schema.MapType:
+		record := "Record<" + g.key(t.Key) + ", " + g.typ(t.Elem) + ">"
+		if isEnum(t.Key) {
+			return "Partial<" + record + ">"
+		}
+		return record
+	}
+
+	info := t.Decl.DeclInfo()
+	if info.File == g.file {
+		return ident(info.Name)
+	}
+	for _, imp := range g.file.Imports {
+		if imp.File == info.File {
+			return ident(imp.Name) + "." + ident(info.Name)
+		}
+	}
+	panic(fmt.Sprintf("typescript: %s is not imported by %s", info.File.Module, g.file.Module))
+}
+
+// key returns the type of a map key. JSON object keys are strings, but
+// records with number keys can be indexed with numbers.
+func (g *fileGen) key(t *schema.Type) string {
+	if u := t.Underlying(); u.Kind == schema.PrimitiveType && u.Primitive == schema.Bool {
+		return "string"
+	}
+	return g.typ(t)
+}
+
+// isEnum reports whether the underlying type of t is an enum.
+func isEnum(t *schema.Type) bool {
+	u := t.Underlying()
+	if u.Kind != schema.NamedType {
+		return false
+	}
+	_, ok := u.Decl.(*schema.Enum)
+	return ok
+}
+
+// maxSafeInteger is the largest integer that a JavaScript number holds
+// exactly, 2^53 - 1.
+var maxSafeInteger = big.NewInt(1<<53 - 1)
+
+// isBigInt reports whether v is an integer that a JavaScript number cannot
+// hold exactly.
+func isBigInt(v constant.Value) bool {
+	return v.Kind() == constant.Int && v.Int().CmpAbs(maxSafeInteger) > 0
+}
+
+// literal returns the value of a constant in TypeScript syntax. Bytes are
+// base64 encoded, as in JSON.
+func literal(k *schema.Const) string {
+	if u := k.Type.Underlying(); u.Kind == schema.PrimitiveType && u.Primitive == schema.Bytes {
+		return jsonString(base64.StdEncoding.EncodeToString([]byte(k.Value.StringVal())))
+	}
+	if k.Value.Kind() == constant.String {
+		return jsonString(k.Value.StringVal())
+	}
+	return k.Value.String()
+}
+
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func (g *fileGen) structType(s *schema.Struct) {
+	g.printf("\n")
+	g.doc(&s.Info, "")
+	if len(s.Fields) == 0 {
+		g.printf("export interface %s {}\n", ident(s.Name))
+		return
+	}
+	g.printf("export interface %s {\n", ident(s.Name))
+	for _, field := range s.Fields {
+		g.doc(&field.Info, "  ")
+		optional := ""
+		if field.Optional {
+			optional = "?"
+		}
+		g.printf("  %s%s: %s;\n", property(field.JSONName()), optional, g.typ(field.Type))
+	}
+	g.printf("}\n")
+}
+
+func (g *fileGen) enum(e *schema.Enum) {
+	g.printf("\n")
+	g.doc(&e.Info, "")
+	keyword := "enum"
+	if g.constEnums {
+		keyword = "const enum"
+	}
+	if len(e.Members) == 0 {
+		g.printf("export %s %s {}\n", keyword, ident(e.Name))
+		return
+	}
+	g.printf("export %s %s {\n", keyword, ident(e.Name))
+	for _, member := range e.Members {
+		g.doc(&member.Info, "  ")
+		g.printf("  %s = %d,\n", pascal(member.Name), member.Value)
+	}
+	g.printf("}\n")
+}
+
+func (g *fileGen) union(u *schema.Union) {
+	g.printf("\n")
+	g.doc(&u.Info, "")
+	if len(u.Variants) == 0 {
+		g.printf("export type %s = never;\n", ident(u.Name))
+		return
+	}
+	g.printf("export type %s =\n", ident(u.Name))
+	for i, variant := range u.Variants {
+		g.doc(&variant.Info, "  ")
+		end := ""
+		if i == len(u.Variants)-1 {
+			end = ";"
+		}
+		g.printf("  | ({ %s: %s } & %s)%s\n", property(u.Tag), jsonString(variant.Name), g.typ(variant.Type), end)
+	}
+}
+
+func (g *fileGen) iface(i *schema.Interface) {
+	g.printf("\n")
+	g.doc(&i.Info, "")
+	if len(i.Methods) == 0 {
+		g.printf("export interface %s {}\n", ident(i.Name))
+		return
+	}
+	g.printf("export interface %s {\n", ident(i.Name))
+	for _, method := range i.Methods {
+		g.doc(&method.Info, "  ")
+		params := make([]string, len(method.Params))
+		for j, param := range method.Params {
+			params[j] = ident(camel(param.Name)) + ": " + g.typ(param.Type)
+		}
+		result := "void"
+		if method.Result != nil {
+			result = g.typ(method.Result)
+		}
+		g.printf("  %s(%s): Promise<%s>;\n", camel(method.Name), strings.Join(params, ", "), result)
+	}
+	g.printf("}\n")
+}
+
+// reserved are the reserved words of TypeScript that cannot name
+// declarations, parameters or namespace imports, and the global types that
+// generated code refers to.
+var reserved = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true,
+	"do": true, "else": true, "enum": true, "export": true, "extends": true,
+	"false": true, "finally": true, "for": true, "function": true, "if": true,
+	"import": true, "in": true, "instanceof": true, "new": true, "null": true,
+	"return": true, "super": true, "switch": true, "this": true, "throw": true,
+	"true": true, "try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "implements": true, "interface": true,
+	"let": true, "package": true, "private": true, "protected": true,
+	"public": true, "static": true, "yield": true, "any": true, "boolean": true,
+	"number": true, "string": true, "symbol": true, "never": true,
+	"unknown": true, "object": true, "undefined": true,
+	"Partial": true, "Promise": true, "Record": true,
+}
+
+// ident returns name, followed by an underscore if it is reserved.
+func ident(name string) string {
+	if reserved[name] {
+		return name + "_"
+	}
+	return name
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// property returns a property name, quoted unless it is an identifier.
+func property(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	return jsonString(name)
+}
+
+// camel returns a name in lower camel case: "list_by_role" becomes
+// "listByRole".
+func camel(name string) string {
+	words := gen.Words(name)
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			words[i] = upperFirst(strings.ToLower(word))
+		}
+	}
+	if len(words) == 0 {
+		return name
+	}
+	return strings.Join(words, "")
+}
+
+// pascal returns a name in upper camel case: "super_user" becomes
+// "SuperUser".
+func pascal(name string) string {
+	words := gen.Words(name)
+	for i, word := range words {
+		words[i] = upperFirst(word)
+	}
+	if len(words) == 0 {
+		return name
+	}
+	return strings.Join(words, "")
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
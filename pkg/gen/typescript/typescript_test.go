@@ -0,0 +1,75 @@
+package typescript
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/internal/gentest"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+func TestGolden(t *testing.T) {
+	files := gentest.Generate(t, generator{}, nil, "users.lark", "common/types.lark")
+	if len(files) != 2 || files[0].Name != "users.ts" || files[1].Name != "common/types.ts" {
+		t.Fatalf("got files %v", files)
+	}
+
+	for _, file := range files {
+		golden := filepath.Join("testdata", filepath.FromSlash(strings.TrimSuffix(file.Name, ".ts")+".golden"))
+		gentest.Compare(t, golden, file.Name, file.Content)
+	}
+}
+
+func TestParams(t *testing.T) {
+	files := gentest.Generate(t, generator{}, gen.Params{"const_enums": "false", "import_extension": ".js"}, "users.lark")
+	src := string(files[0].Content)
+	for _, want := range []string{"export enum Role {\n", `import * as types from "./common/types.js";`} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output does not contain %q:\n%s", want, src)
+		}
+	}
+
+	if _, err := (generator{}).Generate(&schema.Schema{}, gen.Params{"const_enums": "yes"}); err == nil {
+		t.Error("got no error for an invalid const_enums")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, camel, pascal string
+	}{
+		{"user", "user", "User"},
+		{"list_by_role", "listByRole", "ListByRole"},
+		{"userID", "userId", "UserID"},
+		{"HTTPServer", "httpServer", "HTTPServer"},
+		{"_", "_", "_"},
+	}
+	for _, test := range tests {
+		if got := camel(test.name); got != test.camel {
+			t.Errorf("camel(%q) = %q; want %q", test.name, got, test.camel)
+		}
+		if got := pascal(test.name); got != test.pascal {
+			t.Errorf("pascal(%q) = %q; want %q", test.name, got, test.pascal)
+		}
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	tests := []struct {
+		from, to, want string
+	}{
+		{"users", "common/types", "./common/types"},
+		{"api/users", "api/types", "./types"},
+		{"api/users", "common/types", "../common/types"},
+		{"a/b/c", "a/d", "../d"},
+		{"common/types/x", "common/types", "../types"},
+		{"a", "b", "./b"},
+	}
+	for _, test := range tests {
+		if got := importPath(test.from, test.to); got != test.want {
+			t.Errorf("importPath(%q, %q) = %q; want %q", test.from, test.to, got, test.want)
+		}
+	}
+}
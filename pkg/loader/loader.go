@@ -0,0 +1,313 @@
+// Package loader reads Lark source files together with the modules they
+// import.
+//
+// An import path is resolved relative to the directory of the importing
+// file first and then relative to each directory of [Config.Path]. The
+// ".lark" extension may be omitted from import paths.
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/parser"
+)
+
+// A Config controls how files are loaded.
+type Config struct {
+	// Path lists the directories searched for imported modules after the
+	// directory of the importing file.
+	Path []string
+
+	// MaxErrors is passed to the parser for every file; zero means no limit.
+	MaxErrors int
+
+	// ReadFile reads a file; nil means os.ReadFile.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// A Source names a file to load. If Src is nil, the file is read from Path.
+type Source struct {
+	Path string
+	Src  []byte
+}
+
+// A File is a loaded and parsed source file.
+type File struct {
+	Path string // path as given or as resolved from an import
+	Src  []byte
+
+	// Module is the slash-separated path of the file without the ".lark"
+	// extension, relative to the directory it was found through: the
+	// current directory for the sources passed to Load, the directory of
+	// the importing file or a directory of Config.Path for imports. The
+	// leading ".." elements of sources outside the current directory are
+	// dropped.
+	Module string
+
+	parser.ParsedFile
+
+	// Imports holds one entry per import declaration, in source order.
+	Imports []*Import
+}
+
+// An Import is an import declaration and the file it refers to.
+type Import struct {
+	Spec *ast.ImportSpec
+	Name string // name under which the module is visible, see parser.ImportName
+	Path string // unquoted import path
+	File *File  // imported file, or nil if it was not found
+}
+
+// Lookup returns the import visible under name, or nil.
+func (f *File) Lookup(name string) *Import {
+	for _, imp := range f.Imports {
+		if imp.Name == name {
+			return imp
+		}
+	}
+	return nil
+}
+
+// A Program is a set of files that is closed under imports.
+type Program struct {
+	// Roots are the files passed to Load, in order.
+	Roots []*File
+
+	// Files holds all loaded files. Every file comes after the files it
+	// imports, except where imports form a cycle.
+	Files []*File
+}
+
+// Diagnostics returns the diagnostics of every file of the program that
+// has any, in the order of Files.
+func (prog *Program) Diagnostics() []diag.File {
+	var files []diag.File
+	for _, file := range prog.Files {
+		if len(file.Diagnostics) > 0 {
+			files = append(files, diag.File{Name: file.Path, Lines: file.Lines, Diagnostics: file.Diagnostics})
+		}
+	}
+	return files
+}
+
+// HasErrors reports whether any file of the program has an error.
+func (prog *Program) HasErrors() bool {
+	for _, file := range prog.Files {
+		if diag.HasErrors(file.Diagnostics) {
+			return true
+		}
+	}
+	return false
+}
+
+type loader struct {
+	config *Config
+	prog   *Program
+	files  map[string]*File // by absolute path
+	state  map[*File]int    // visiting or done, see visit
+}
+
+const (
+	visiting = iota + 1
+	done
+)
+
+// Load parses the given sources and, transitively, every module they
+// import. Only failures to read one of the sources are returned as errors;
+// missing modules and import cycles are reported as diagnostics of the
+// importing file.
+func (c *Config) Load(sources ...Source) (*Program, error) {
+	l := &loader{
+		config: c,
+		prog:   &Program{},
+		files:  map[string]*File{},
+		state:  map[*File]int{},
+	}
+
+	for _, source := range sources {
+		src := source.Src
+		if src == nil {
+			var err error
+			if src, err = l.config.read(source.Path); err != nil {
+				return nil, err
+			}
+		}
+		file := l.file(source.Path, rootModule(source.Path), src)
+		l.prog.Roots = append(l.prog.Roots, file)
+	}
+
+	for _, file := range l.prog.Roots {
+		l.visit(file, nil)
+	}
+	return l.prog, nil
+}
+
+func (c *Config) read(name string) ([]byte, error) {
+	if c.ReadFile != nil {
+		return c.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// rootModule returns the module path of a source passed to Load: its path
+// relative to the current directory, without leading ".." elements, so
+// that both /work/api/users.lark in /work and ../api/users.lark in
+// /work/cmd are module api/users.
+func rootModule(path string) string {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				path = rel
+			}
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	for strings.HasPrefix(path, "../") {
+		path = path[len("../"):]
+	}
+	return moduleOf(filepath.FromSlash(path))
+}
+
+// moduleOf returns the module path of a file found at path relative to
+// the directory it was searched in.
+func moduleOf(path string) string {
+	path = filepath.Clean(path)
+	if !filepath.IsLocal(path) {
+		path = filepath.Base(path)
+	}
+	return strings.TrimSuffix(filepath.ToSlash(path), ".lark")
+}
+
+// file parses src unless a file with the same path was already loaded.
+func (l *loader) file(path, module string, src []byte) *File {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	if file, ok := l.files[key]; ok {
+		return file
+	}
+
+	file := &File{
+		Path:       path,
+		Module:     module,
+		Src:        src,
+		ParsedFile: parser.ParseWithOptions(src, parser.Options{MaxErrors: l.config.MaxErrors}),
+	}
+	l.files[key] = file
+
+	for _, spec := range file.ParsedFile.Imports {
+		value, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || value == "" {
+			continue
+		}
+		file.Imports = append(file.Imports, &Import{Spec: spec, Name: parser.ImportName(spec), Path: value})
+	}
+	return file
+}
+
+// Resolve searches the module imported under the unquoted import path
+// importPath by the file at from, as Load does, and returns the path and
+// the contents of the file found. It returns false if there is none.
+func (c *Config) Resolve(from, importPath string) (string, []byte, bool) {
+	candidates, _ := c.candidates(from, "", importPath)
+	for _, candidate := range candidates {
+		if src, err := c.read(candidate); err == nil {
+			return candidate, src, true
+		}
+	}
+	return "", nil, false
+}
+
+// candidates returns the paths searched for the module imported under
+// importPath by the file at from, whose module path is module, and the
+// module paths of the files found there.
+func (c *Config) candidates(from, module, importPath string) ([]string, []string) {
+	name := filepath.FromSlash(importPath)
+	if filepath.Ext(name) == "" {
+		name += ".lark"
+	}
+
+	if filepath.IsAbs(name) {
+		return []string{name}, []string{moduleOf(name)}
+	}
+	candidates := []string{filepath.Join(filepath.Dir(from), name)}
+	modules := []string{path.Join(path.Dir(module), moduleOf(name))}
+	for _, dir := range c.Path {
+		candidates = append(candidates, filepath.Join(dir, name))
+		modules = append(modules, moduleOf(name))
+	}
+	return candidates, modules
+}
+
+// resolve returns the file imported by imp from file, loading it if needed.
+func (l *loader) resolve(file *File, imp *Import) *File {
+	candidates, modules := l.config.candidates(file.Path, file.Module, imp.Path)
+	for i, candidate := range candidates {
+		if src, err := l.config.read(candidate); err == nil {
+			return l.file(candidate, modules[i], src)
+		}
+	}
+
+	file.Diagnostics = append(file.Diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.ModuleNotFound,
+		Range:    pathRange(imp.Spec),
+		Message:  fmt.Sprintf("cannot find module %q in %s", imp.Path, strings.Join(candidates, ", ")),
+	})
+	return nil
+}
+
+// visit resolves the imports of file and appends it to the program after
+// its dependencies. stack holds the files that led to file.
+func (l *loader) visit(file *File, stack []*File) {
+	l.state[file] = visiting
+	stack = append(stack, file)
+	for _, imp := range file.Imports {
+		imp.File = l.resolve(file, imp)
+		if imp.File == nil {
+			continue
+		}
+
+		switch l.state[imp.File] {
+		case visiting:
+			cycle(file, imp, stack)
+		case 0:
+			l.visit(imp.File, stack)
+		}
+	}
+	l.state[file] = done
+	l.prog.Files = append(l.prog.Files, file)
+}
+
+// cycle reports the import imp of file, which closes a cycle through the
+// files of stack.
+func cycle(file *File, imp *Import, stack []*File) {
+	var path []string
+	for i := len(stack) - 1; i >= 0; i-- {
+		path = append([]string{stack[i].Path}, path...)
+		if stack[i] == imp.File {
+			break
+		}
+	}
+	path = append(path, imp.File.Path)
+
+	file.Diagnostics = append(file.Diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     diag.ImportCycle,
+		Range:    pathRange(imp.Spec),
+		Message:  "import cycle not allowed: " + strings.Join(path, " -> "),
+	})
+}
+
+func pathRange(spec *ast.ImportSpec) diag.Range {
+	return diag.Span(spec.Path.Pos(), utf8.RuneCountInString(spec.Path.Value))
+}
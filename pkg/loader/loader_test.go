@@ -0,0 +1,166 @@
+package loader
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+func config(files map[string]string) *Config {
+	return &Config{
+		Path: []string{"lib", "."},
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := files[filepath.ToSlash(name)]; ok {
+				return []byte(src), nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+}
+
+func paths(files []*File) string {
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.ToSlash(file.Path))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestLoad(t *testing.T) {
+	c := config(map[string]string{
+		"api/users.lark":   "import \"common\"\nimport \"api/ids.lark\" as ids\nconst x = common.a + ids.b\n",
+		"api/common.lark":  "import \"/abs/base\"\nconst a = base.c\n",
+		"lib/api/ids.lark": "import \"api/common\"\nconst b = common.a\n",
+		"/abs/base.lark":   "const c = 1\n",
+	})
+
+	prog, err := c.Load(Source{Path: "api/users.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := paths(prog.Files), "/abs/base.lark api/common.lark lib/api/ids.lark api/users.lark"; got != want {
+		t.Errorf("got files %q; want %q", got, want)
+	}
+	if prog.HasErrors() {
+		t.Errorf("got diagnostics %v", prog.Diagnostics())
+	}
+
+	var modules []string
+	for _, file := range prog.Files {
+		modules = append(modules, file.Module)
+	}
+	if got, want := strings.Join(modules, " "), "base api/common api/ids api/users"; got != want {
+		t.Errorf("got modules %q; want %q", got, want)
+	}
+
+	users := prog.Roots[0]
+	if imp := users.Lookup("ids"); imp == nil || imp.Path != "api/ids.lark" || imp.File != prog.Files[2] {
+		t.Errorf("got import %+v", imp)
+	}
+	if imp := prog.Files[2].Lookup("common"); imp == nil || imp.File != prog.Files[1] {
+		t.Errorf("got import %+v; want the file shared with users", imp)
+	}
+}
+
+func TestLoadSource(t *testing.T) {
+	c := config(map[string]string{"common.lark": "const a = 1\n"})
+
+	prog, err := c.Load(Source{Path: "<stdin>", Src: []byte("import \"common\"\nconst b = common.a\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(prog.Files); got != "common.lark <stdin>" {
+		t.Errorf("got files %q", got)
+	}
+
+	if _, err := c.Load(Source{Path: "missing.lark"}); err == nil {
+		t.Error("got no error for a missing source")
+	}
+}
+
+func TestLoadAbsolute(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := filepath.Join(wd, "dev", "device.lark")
+	other := filepath.Join(filepath.Dir(wd), "other", "device.lark")
+	c := config(map[string]string{
+		filepath.ToSlash(device): "import \"common/types\"\nconst a = types.b\n",
+		filepath.ToSlash(filepath.Join(wd, "dev/common/types.lark")): "const b = 1\n",
+		filepath.ToSlash(other): "const c = 1\n",
+	})
+
+	prog, err := c.Load(Source{Path: device}, Source{Path: other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prog.HasErrors() {
+		t.Errorf("got diagnostics %v", prog.Diagnostics())
+	}
+	var modules []string
+	for _, file := range prog.Files {
+		modules = append(modules, file.Module)
+	}
+	if got, want := strings.Join(modules, " "), "dev/common/types dev/device other/device"; got != want {
+		t.Errorf("got modules %q; want %q", got, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := config(map[string]string{
+		"api/common.lark":  "",
+		"lib/api/ids.lark": "",
+		"/abs/base.lark":   "",
+	})
+
+	tests := []struct {
+		from, path, want string
+	}{
+		{"api/users.lark", "common", "api/common.lark"},
+		{"api/users.lark", "api/ids.lark", "lib/api/ids.lark"},
+		{"api/users.lark", "/abs/base", "/abs/base.lark"},
+		{"api/users.lark", "missing", ""},
+	}
+	for _, test := range tests {
+		got, _, ok := c.Resolve(test.from, test.path)
+		if filepath.ToSlash(got) != test.want || ok != (test.want != "") {
+			t.Errorf("Resolve(%q, %q) = %q, %v; want %q", test.from, test.path, got, ok, test.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	c := config(map[string]string{
+		"a.lark": "import \"b\"\nimport \"missing\"\nconst x = b.y + missing.z\n",
+		"b.lark": "import \"c\"\nconst y = c.z\n",
+		"c.lark": "import \"a\"\nconst z = a.x\n",
+	})
+
+	prog, err := c.Load(Source{Path: "a.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := prog.Diagnostics()
+	if len(files) != 2 {
+		t.Fatalf("got diagnostics %+v; want diagnostics for c.lark and a.lark", files)
+	}
+
+	d := files[0].Diagnostics[0]
+	if files[0].Name != "c.lark" || d.Code != diag.ImportCycle || d.Message != "import cycle not allowed: a.lark -> b.lark -> c.lark -> a.lark" {
+		t.Errorf("got %s: %v", files[0].Name, d)
+	}
+	if d.Range != (diag.Range{Start: diag.Pos{Line: 0, Column: 7}, End: diag.Pos{Line: 0, Column: 10}}) {
+		t.Errorf("got range %v", d.Range)
+	}
+
+	d = files[1].Diagnostics[0]
+	if files[1].Name != "a.lark" || d.Code != diag.ModuleNotFound || !strings.HasPrefix(d.Message, `cannot find module "missing" in missing.lark, lib`) {
+		t.Errorf("got %s: %v", files[1].Name, d)
+	}
+}
@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/parser"
+)
+
+// A document is a parsed source file, either open in the editor or read
+// from disk to resolve an import.
+type document struct {
+	uri     string
+	path    string // file system path, or "" if uri is not a file URI
+	version int
+	text    string
+	lines   []string
+	parsed  parser.ParsedFile
+}
+
+func newDocument(uri string, version int, text string) *document {
+	doc := &document{uri: uri, path: uriToPath(uri), version: version, text: text}
+	doc.lines = strings.Split(text, "\n")
+	doc.parsed = parser.ParseWithOptions([]byte(text), parser.Options{})
+	return doc
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (doc *document) line(n int) string {
+	if 0 <= n && n < len(doc.lines) {
+		return strings.TrimSuffix(doc.lines[n], "\r")
+	}
+	return ""
+}
+
+// toPosition converts a source position, whose column counts runes, into
+// an LSP position, whose character counts UTF-16 code units.
+func (doc *document) toPosition(pos diag.Pos) Position {
+	character := 0
+	column := 0
+	for _, r := range doc.line(pos.Line) {
+		if column == pos.Column {
+			break
+		}
+		character += utf16.RuneLen(r)
+		column++
+	}
+	return Position{Line: pos.Line, Character: character + pos.Column - column}
+}
+
+// fromPosition is the inverse of toPosition.
+func (doc *document) fromPosition(pos Position) diag.Pos {
+	character := 0
+	column := 0
+	for _, r := range doc.line(pos.Line) {
+		if character >= pos.Character {
+			break
+		}
+		character += utf16.RuneLen(r)
+		column++
+	}
+	return diag.Pos{Line: pos.Line, Column: column}
+}
+
+func (doc *document) toRange(rng diag.Range) Range {
+	return Range{doc.toPosition(rng.Start), doc.toPosition(rng.End)}
+}
+
+// nameRange returns the range of a name.
+func (doc *document) nameRange(name *ast.Name) Range {
+	return doc.toRange(diag.Span(name.Pos(), utf8.RuneCountInString(name.Name)))
+}
+
+// lineEnd returns the position at the end of a line.
+func (doc *document) lineEnd(line int) diag.Pos {
+	return diag.Pos{Line: line, Column: utf8.RuneCountInString(doc.line(line))}
+}
+
+// declRange returns the range of a declaration, from its start to the end
+// of its last line.
+func (doc *document) declRange(node ast.Node) Range {
+	switch n := node.(type) {
+	case *ast.Struct:
+		return doc.toRange(diag.Range{Start: n.Pos(), End: diag.Pos{Line: n.Rbrace.Line, Column: n.Rbrace.Column + 1}})
+	case *ast.Interface:
+		return doc.toRange(diag.Range{Start: n.Pos(), End: diag.Pos{Line: n.Rbrace.Line, Column: n.Rbrace.Column + 1}})
+	case *ast.Enum:
+		return doc.toRange(diag.Range{Start: n.Pos(), End: diag.Pos{Line: n.Rbrace.Line, Column: n.Rbrace.Column + 1}})
+	case *ast.Union:
+		return doc.toRange(diag.Range{Start: n.Pos(), End: diag.Pos{Line: n.Rbrace.Line, Column: n.Rbrace.Column + 1}})
+	}
+	return doc.toRange(diag.Range{Start: node.Pos(), End: doc.lineEnd(node.Pos().Line)})
+}
+
+// symbol returns the top-level symbol declared with the given name.
+func (doc *document) symbol(name string) (parser.Symbol, bool) {
+	for _, sym := range doc.parsed.Symtab {
+		if sym.Name.Name == name {
+			return sym, true
+		}
+	}
+	return parser.Symbol{}, false
+}
+
+// importSpec returns the import that is visible under the given name.
+func (doc *document) importSpec(name string) *ast.ImportSpec {
+	for _, spec := range doc.parsed.Imports {
+		if parser.ImportName(spec) == name {
+			return spec
+		}
+	}
+	return nil
+}
+
+// contains reports whether pos lies on name or right after it.
+func contains(name *ast.Name, pos diag.Pos) bool {
+	start := name.Pos()
+	return start.Line == pos.Line && start.Column <= pos.Column &&
+		pos.Column <= start.Column+utf8.RuneCountInString(name.Name)
+}
+
+// nameAt returns the name at pos and the qualified name it belongs to, if
+// any.
+func (doc *document) nameAt(pos diag.Pos) (name *ast.Name, qual *ast.QualName) {
+	ast.Inspect(doc.parsed.File, func(node ast.Node) bool {
+		if name != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.QualName:
+			if n.Module != nil && contains(n.Module, pos) {
+				name, qual = n.Module, n
+			} else if contains(n.Name, pos) {
+				name, qual = n.Name, n
+			}
+			return false
+		case *ast.Name:
+			if contains(n, pos) {
+				name = n
+			}
+		case *ast.ImportSpec:
+			if n.Alias != nil && contains(n.Alias, pos) {
+				name = n.Alias
+			}
+			return false
+		}
+		return true
+	})
+	return name, qual
+}
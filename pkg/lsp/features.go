@@ -0,0 +1,668 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/format"
+	"larklang.io/lark/pkg/parser"
+	"larklang.io/lark/pkg/scanner"
+)
+
+// A target is the declaration a name refers to.
+type target struct {
+	doc  *document
+	name *ast.Name // declared name
+	decl ast.Node  // declaration, or nil for a module
+}
+
+// member returns the field, method, parameter, enum member or union
+// variant declared with name.
+func member(doc *document, name *ast.Name) (ast.Node, bool) {
+	for _, node := range doc.parsed.File.Nodes {
+		switch n := node.(type) {
+		case *ast.Struct:
+			for _, field := range n.Fields {
+				if field.Name == name {
+					return field, true
+				}
+			}
+		case *ast.Interface:
+			for _, method := range n.Methods {
+				if method.Name == name {
+					return method, true
+				}
+				for _, param := range method.Params {
+					if param.Name == name {
+						return param, true
+					}
+				}
+			}
+		case *ast.Enum:
+			for _, m := range n.Members {
+				if m.Name == name {
+					return m, true
+				}
+			}
+		case *ast.Union:
+			for _, v := range n.Variants {
+				if v.Name == name {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// same reports whether t and u name the same declaration. Modules read
+// from disk are parsed on every lookup, so nodes are compared by location.
+func (t target) same(u target) bool {
+	return t.name != nil && u.name != nil && t.doc.uri == u.doc.uri && t.name.Pos() == u.name.Pos()
+}
+
+// resolve returns the declaration of the name at pos.
+func (s *Server) resolve(doc *document, pos diag.Pos) (target, bool) {
+	name, qual := doc.nameAt(pos)
+	if name == nil {
+		return target{}, false
+	}
+
+	switch {
+	case qual != nil && qual.Module == name:
+		if module := s.module(doc, name.Name); module != nil {
+			return target{doc: module}, true
+		}
+	case qual != nil && qual.Module != nil:
+		if module := s.module(doc, qual.Module.Name); module != nil {
+			if sym, ok := module.symbol(name.Name); ok {
+				return target{module, sym.Name, sym.Decl}, true
+			}
+		}
+	default:
+		if sym, ok := doc.symbol(name.Name); ok {
+			return target{doc, sym.Name, sym.Decl}, true
+		}
+		if decl, ok := member(doc, name); ok {
+			return target{doc, name, decl}, true
+		}
+		if spec := doc.importSpec(name.Name); spec != nil && spec.Alias == name {
+			if module := s.module(doc, name.Name); module != nil {
+				return target{doc: module}, true
+			}
+		}
+	}
+	return target{}, false
+}
+
+// signature returns the canonical source of a declaration without its
+// doc comment.
+func signature(decl ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, decl); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func declDoc(decl ast.Node) *ast.CommentGroup {
+	switch n := decl.(type) {
+	case *ast.ConstSpec:
+		return n.Doc
+	case *ast.TypeAlias:
+		return n.Doc
+	case *ast.Struct:
+		return n.Doc
+	case *ast.Interface:
+		return n.Doc
+	case *ast.Enum:
+		return n.Doc
+	case *ast.EnumMember:
+		return n.Doc
+	case *ast.Union:
+		return n.Doc
+	case *ast.Variant:
+		return n.Doc
+	case *ast.Field:
+		return n.Doc
+	case *ast.Method:
+		return n.Doc
+	}
+	return nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := doc.fromPosition(p.Position)
+	t, ok := s.resolve(doc, pos)
+	if !ok {
+		return nil, nil
+	}
+	name, _ := doc.nameAt(pos)
+
+	var value string
+	if t.decl == nil {
+		value = "```lark\nmodule " + strings.TrimSuffix(t.doc.uri[strings.LastIndex(t.doc.uri, "/")+1:], ".lark") + "\n```"
+	} else {
+		value = "```lark\n" + signature(t.decl) + "\n```"
+		if text := docText(declDoc(t.decl)); text != "" {
+			value += "\n\n" + text
+		}
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    doc.nameRange(name),
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := s.resolve(doc, doc.fromPosition(p.Position))
+	if !ok {
+		return nil, nil
+	}
+	if t.name == nil {
+		return Location{URI: t.doc.uri}, nil
+	}
+	return Location{URI: t.doc.uri, Range: t.doc.nameRange(t.name)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (any, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := s.resolve(doc, doc.fromPosition(p.Position))
+	if !ok || t.name == nil {
+		return nil, nil
+	}
+
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: t.doc.uri, Range: t.doc.nameRange(t.name)})
+	}
+
+	// search the open documents for names that resolve to the target
+	uris := make([]string, 0, len(s.docs))
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		other := s.docs[uri]
+		ast.Inspect(other.parsed.File, func(node ast.Node) bool {
+			qual, ok := node.(*ast.QualName)
+			if !ok {
+				return true
+			}
+			if qual.Name.Name == t.name.Name {
+				if r, ok := s.resolve(other, qual.Name.Pos()); ok && r.same(t) {
+					locations = append(locations, Location{URI: other.uri, Range: other.nameRange(qual.Name)})
+				}
+			}
+			return false
+		})
+	}
+	return locations, nil
+}
+
+func symbolKind(typ parser.SymbolType) int {
+	switch typ {
+	case parser.ConstSym:
+		return CompletionConstant
+	case parser.StructSym, parser.UnionSym:
+		return CompletionStruct
+	case parser.InterfaceSym:
+		return CompletionInterface
+	case parser.AliasSym:
+		return CompletionTypeParam
+	case parser.EnumSym:
+		return CompletionEnum
+	}
+	return CompletionMethod
+}
+
+func symbolItems(doc *document) []CompletionItem {
+	var items []CompletionItem
+	for _, sym := range doc.parsed.Symtab {
+		items = append(items, CompletionItem{
+			Label:         sym.Name.Name,
+			Kind:          symbolKind(sym.Type),
+			Detail:        signature(sym.Decl),
+			Documentation: docText(declDoc(sym.Decl)),
+		})
+	}
+	return items
+}
+
+// qualifier returns the module name before the dot that precedes the
+// identifier being completed at pos, or "".
+func qualifier(doc *document, pos diag.Pos) string {
+	line := []rune(doc.line(pos.Line))
+	i := min(pos.Column, len(line))
+	for i > 0 && isIdentRune(line[i-1]) {
+		i--
+	}
+	if i == 0 || line[i-1] != '.' {
+		return ""
+	}
+	end := i - 1
+	start := end
+	for start > 0 && isIdentRune(line[start-1]) {
+		start--
+	}
+	return string(line[start:end])
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	if name := qualifier(doc, doc.fromPosition(p.Position)); name != "" {
+		if module := s.module(doc, name); module != nil {
+			items = append(items, symbolItems(module)...)
+		}
+		return CompletionList{Items: items}, nil
+	}
+
+	items = append(items, symbolItems(doc)...)
+	for _, spec := range doc.parsed.Imports {
+		if name := parser.ImportName(spec); name != "" {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionModule, Detail: "import " + spec.Path.Value})
+		}
+	}
+	return CompletionList{Items: items}, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []DocumentSymbol{}
+	for _, node := range doc.parsed.File.Nodes {
+		switch n := node.(type) {
+		case *ast.ImportSpec:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           n.Path.Value,
+				Kind:           SymbolModule,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.toRange(diag.Span(n.Path.Pos(), len([]rune(n.Path.Value)))),
+			})
+		case *ast.ConstSpec:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           n.Name.Name,
+				Detail:         signature(n.Expr),
+				Kind:           SymbolConstant,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			})
+		case *ast.TypeAlias:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           n.Name.Name,
+				Detail:         signature(n.Type),
+				Kind:           SymbolTypeParam,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			})
+		case *ast.Struct:
+			symbol := DocumentSymbol{
+				Name:           n.Name.Name,
+				Kind:           SymbolStruct,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			}
+			for _, field := range n.Fields {
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name:           field.Name.Name,
+					Detail:         signature(field.Type),
+					Kind:           SymbolField,
+					Range:          doc.declRange(field),
+					SelectionRange: doc.nameRange(field.Name),
+				})
+			}
+			symbols = append(symbols, symbol)
+		case *ast.Enum:
+			symbol := DocumentSymbol{
+				Name:           n.Name.Name,
+				Kind:           SymbolEnum,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			}
+			for _, member := range n.Members {
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name:           member.Name.Name,
+					Detail:         signature(member),
+					Kind:           SymbolEnumMember,
+					Range:          doc.declRange(member),
+					SelectionRange: doc.nameRange(member.Name),
+				})
+			}
+			symbols = append(symbols, symbol)
+		case *ast.Union:
+			symbol := DocumentSymbol{
+				Name:           n.Name.Name,
+				Kind:           SymbolStruct,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			}
+			for _, variant := range n.Variants {
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name:           variant.Name.Name,
+					Detail:         signature(variant.Type),
+					Kind:           SymbolField,
+					Range:          doc.declRange(variant),
+					SelectionRange: doc.nameRange(variant.Name),
+				})
+			}
+			symbols = append(symbols, symbol)
+		case *ast.Interface:
+			symbol := DocumentSymbol{
+				Name:           n.Name.Name,
+				Kind:           SymbolInterface,
+				Range:          doc.declRange(n),
+				SelectionRange: doc.nameRange(n.Name),
+			}
+			for _, method := range n.Methods {
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name:           method.Name.Name,
+					Detail:         signature(method),
+					Kind:           SymbolMethod,
+					Range:          doc.declRange(method),
+					SelectionRange: doc.nameRange(method.Name),
+				})
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (any, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source([]byte(doc.text))
+	if err != nil {
+		// the document has syntax errors, which are already reported
+		return nil, nil
+	}
+	if string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	last := len(doc.lines) - 1
+	end := doc.toPosition(doc.lineEnd(last))
+	return []TextEdit{{Range: Range{End: end}, NewText: string(formatted)}}, nil
+}
+
+// Indices into semanticTokenTypes.
+const (
+	tokenNamespace = iota
+	tokenType
+	tokenStruct
+	tokenInterface
+	tokenParameter
+	tokenVariable
+	tokenProperty
+	tokenMethod
+	tokenKeyword
+	tokenComment
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenDecorator
+	tokenEnum
+	tokenEnumMember
+)
+
+// Bits of the semantic token modifiers.
+const (
+	modDeclaration = 1 << iota
+	modReadonly
+)
+
+type tokenClass struct {
+	typ, modifiers int
+}
+
+// classify assigns semantic token classes to the identifiers of a file.
+func classify(doc *document) map[diag.Pos]tokenClass {
+	classes := map[diag.Pos]tokenClass{}
+	kinds := map[string]int{}
+	for _, sym := range doc.parsed.Symtab {
+		switch sym.Type {
+		case parser.StructSym, parser.UnionSym:
+			kinds[sym.Name.Name] = tokenStruct
+		case parser.InterfaceSym:
+			kinds[sym.Name.Name] = tokenInterface
+		case parser.ConstSym:
+			kinds[sym.Name.Name] = tokenVariable
+		case parser.EnumSym:
+			kinds[sym.Name.Name] = tokenEnum
+		default:
+			kinds[sym.Name.Name] = tokenType
+		}
+	}
+
+	var typ func(t *ast.Type)
+	qual := func(q *ast.QualName, kind int) {
+		if q.Module != nil {
+			classes[q.Module.Pos()] = tokenClass{tokenNamespace, 0}
+		} else if k, ok := kinds[q.Name.Name]; ok {
+			kind = k
+		}
+		mods := 0
+		if kind == tokenVariable {
+			mods = modReadonly
+		}
+		classes[q.Name.Pos()] = tokenClass{kind, mods}
+	}
+	decl := func(name *ast.Name, kind, mods int) {
+		classes[name.Pos()] = tokenClass{kind, mods | modDeclaration}
+	}
+	expr := func(x ast.Node) {
+		ast.Inspect(x, func(node ast.Node) bool {
+			if q, ok := node.(*ast.QualName); ok {
+				qual(q, tokenVariable)
+				return false
+			}
+			return true
+		})
+	}
+	typ = func(t *ast.Type) {
+		if t.Name == nil {
+			typ(t.Elem)
+			expr(t.Len)
+			return
+		}
+		qual(t.Name, tokenType)
+		for _, arg := range t.Args {
+			if a, ok := arg.(*ast.Type); ok {
+				typ(a)
+			}
+		}
+	}
+	annotations := func(list []*ast.Annotation) {
+		for _, a := range list {
+			classes[a.Name.Pos()] = tokenClass{tokenDecorator, 0}
+			for _, arg := range a.Args {
+				expr(arg)
+			}
+		}
+	}
+
+	for _, node := range doc.parsed.File.Nodes {
+		switch n := node.(type) {
+		case *ast.ImportSpec:
+			if n.Alias != nil {
+				decl(n.Alias, tokenNamespace, 0)
+			}
+		case *ast.ConstSpec:
+			annotations(n.Annotations)
+			decl(n.Name, tokenVariable, modReadonly)
+			if n.Type != nil {
+				typ(n.Type)
+			}
+			expr(n.Expr)
+		case *ast.TypeAlias:
+			annotations(n.Annotations)
+			decl(n.Name, tokenType, 0)
+			typ(n.Type)
+		case *ast.Struct:
+			annotations(n.Annotations)
+			decl(n.Name, tokenStruct, 0)
+			for _, field := range n.Fields {
+				annotations(field.Annotations)
+				decl(field.Name, tokenProperty, 0)
+				typ(field.Type)
+			}
+		case *ast.Enum:
+			annotations(n.Annotations)
+			decl(n.Name, tokenEnum, 0)
+			for _, member := range n.Members {
+				annotations(member.Annotations)
+				decl(member.Name, tokenEnumMember, modReadonly)
+				if member.Value != nil {
+					expr(member.Value)
+				}
+			}
+		case *ast.Union:
+			annotations(n.Annotations)
+			decl(n.Name, tokenStruct, 0)
+			for _, variant := range n.Variants {
+				annotations(variant.Annotations)
+				decl(variant.Name, tokenProperty, 0)
+				typ(variant.Type)
+			}
+		case *ast.Interface:
+			annotations(n.Annotations)
+			decl(n.Name, tokenInterface, 0)
+			for _, method := range n.Methods {
+				annotations(method.Annotations)
+				decl(method.Name, tokenMethod, 0)
+				for _, param := range method.Params {
+					annotations(param.Annotations)
+					decl(param.Name, tokenParameter, 0)
+					typ(param.Type)
+				}
+				if method.Result != nil {
+					typ(method.Result)
+				}
+			}
+		}
+	}
+	return classes
+}
+
+func (s *Server) semanticTokens(params json.RawMessage) (any, error) {
+	var p SemanticTokensParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	classes := classify(doc)
+	data := []int{}
+	var prev Position
+
+	sc := scanner.New([]byte(doc.text), nil)
+	for !sc.Done() {
+		token := sc.Scan()
+
+		var class tokenClass
+		switch kind := token.Kind; {
+		case kind == scanner.NEWLINE || kind == scanner.ENDMARKER || kind == scanner.ILLEGAL:
+			continue
+		case kind == scanner.IDENTIFIER:
+			c, ok := classes[token.Pos]
+			if !ok {
+				continue
+			}
+			class = c
+		case kind == scanner.AT:
+			class.typ = tokenDecorator
+		case kind == scanner.COMMENT:
+			class.typ = tokenComment
+		case kind == scanner.STRING:
+			class.typ = tokenString
+		case kind == scanner.INTEGER || kind == scanner.FLOAT:
+			class.typ = tokenNumber
+		case kind.IsKeyword():
+			class.typ = tokenKeyword
+		case parser.Precedence(kind) > 0 || kind == scanner.NOT || kind == scanner.ARROW || kind == scanner.ASSIGN:
+			class.typ = tokenOperator
+		default:
+			continue
+		}
+
+		start := doc.toPosition(token.Pos)
+		length := 0
+		for _, r := range strings.TrimRight(token.Value, "\r") {
+			length += utf16.RuneLen(r)
+		}
+
+		deltaLine := start.Line - prev.Line
+		deltaStart := start.Character
+		if deltaLine == 0 {
+			deltaStart -= prev.Character
+		}
+		data = append(data, deltaLine, deltaStart, length, class.typ, class.modifiers)
+		prev = start
+	}
+
+	return SemanticTokens{Data: data}, nil
+}
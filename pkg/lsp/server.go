@@ -0,0 +1,327 @@
+// Package lsp implements a Language Server Protocol server for Lark.
+//
+// The server keeps open documents in memory and parses them again on every
+// change. It supports diagnostics, hover, go to definition, references,
+// completion, document symbols, formatting and semantic tokens. Imports are
+// resolved like the loader does, with the workspace root as the search path.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+)
+
+// ErrNoShutdown is returned by [Server.Run] if the client sent the exit
+// notification without requesting a shutdown first.
+var ErrNoShutdown = errors.New("lsp: exit without shutdown")
+
+type Server struct {
+	conn     *conn
+	root     string               // workspace root directory, or ""
+	docs     map[string]*document // open documents by URI
+	shutdown bool
+}
+
+// NewServer returns a server that reads client messages from r and writes
+// its messages to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: newConn(r, w), docs: map[string]*document{}}
+}
+
+type handler func(s *Server, params json.RawMessage) (any, error)
+
+var handlers = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"initialized":                      nop,
+	"shutdown":                         (*Server).shutdownRequest,
+	"textDocument/didOpen":             (*Server).didOpen,
+	"textDocument/didChange":           (*Server).didChange,
+	"textDocument/didClose":            (*Server).didClose,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/references":          (*Server).references,
+	"textDocument/completion":          (*Server).completion,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/formatting":          (*Server).formatting,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+}
+
+func nop(s *Server, params json.RawMessage) (any, error) {
+	return nil, nil
+}
+
+// Run serves client messages until the client sends the exit notification
+// or the connection is closed.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rerr *responseError
+			if errors.As(err, &rerr) {
+				s.conn.reply(nil, nil, rerr)
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// notifications have no response
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (any, *responseError) {
+	h, ok := handlers[msg.Method]
+	if !ok {
+		return nil, &responseError{codeMethodNotFound, "method not found: " + msg.Method}
+	}
+	if s.shutdown && msg.ID != nil {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+
+	result, err := h(s, msg.Params)
+	if err != nil {
+		var rerr *responseError
+		if errors.As(err, &rerr) {
+			return nil, rerr
+		}
+		return nil, &responseError{codeInternalError, err.Error()}
+	}
+	return result, nil
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// semanticTokenTypes is the legend of the semantic token types.
+var semanticTokenTypes = []string{
+	"namespace", "type", "struct", "interface", "parameter", "variable",
+	"property", "method", "keyword", "comment", "string", "number", "operator",
+	"decorator", "enum", "enumMember",
+}
+
+var semanticTokenModifiers = []string{"declaration", "readonly"}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p InitializeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.root = uriToPath(p.RootURI)
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           SyncFull,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+			CompletionProvider:         &CompletionOptions{TriggerCharacters: []string{"."}},
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: SemanticTokensLegend{semanticTokenTypes, semanticTokenModifiers},
+				Full:   true,
+			},
+		},
+		ServerInfo: ServerInfo{Name: "lark-lsp"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (any, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+}
+
+func (s *Server) didChange(params json.RawMessage) (any, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+	}
+
+	text := doc.text
+	for _, change := range p.ContentChanges {
+		if change.Range == nil {
+			text = change.Text
+			continue
+		}
+		// tolerate incremental changes from clients that ignore
+		// the announced synchronization kind
+		current := newDocument(doc.uri, doc.version, text)
+		text = current.offset(change.Range.Start) + change.Text + current.rest(change.Range.End)
+	}
+
+	return nil, s.update(newDocument(doc.uri, p.TextDocument.Version, text))
+}
+
+func (s *Server) didClose(params json.RawMessage) (any, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+
+	// clear the diagnostics of the closed document
+	return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update stores a new version of a document and publishes its
+// diagnostics.
+func (s *Server) update(doc *document) error {
+	s.docs[doc.uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, d := range doc.parsed.Diagnostics {
+		diagnostic := Diagnostic{
+			Range:    doc.toRange(d.Range),
+			Severity: toSeverity(d.Severity),
+			Code:     string(d.Code),
+			Source:   "lark",
+			Message:  d.Message,
+		}
+		for _, related := range d.Related {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, DiagnosticRelatedInformation{
+				Location: Location{URI: doc.uri, Range: doc.toRange(related.Range)},
+				Message:  related.Label,
+			})
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: diagnostics,
+	})
+}
+
+func toSeverity(severity diag.Severity) int {
+	switch severity {
+	case diag.Error:
+		return SeverityError
+	case diag.Warning:
+		return SeverityWarning
+	}
+	return SeverityInformation
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{codeInvalidParams, "document is not open: " + uri}
+	}
+	return doc, nil
+}
+
+// module returns the document imported by doc under the given name, or nil
+// if the import cannot be resolved. Imports are resolved as by the loader,
+// with the workspace root as the search path. Open documents take
+// precedence over files on disk.
+func (s *Server) module(doc *document, name string) *document {
+	spec := doc.importSpec(name)
+	if spec == nil {
+		return nil
+	}
+
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil || importPath == "" {
+		return nil
+	}
+
+	config := &loader.Config{ReadFile: s.readFile}
+	if s.root != "" {
+		config.Path = []string{s.root}
+	}
+	path, text, ok := config.Resolve(doc.path, importPath)
+	if !ok {
+		return nil
+	}
+	uri := pathToURI(path)
+	if open, ok := s.docs[uri]; ok {
+		return open
+	}
+	return newDocument(uri, 0, string(text))
+}
+
+// readFile returns the text of the open document at path, or else the
+// contents of the file.
+func (s *Server) readFile(path string) ([]byte, error) {
+	if open, ok := s.docs[pathToURI(path)]; ok {
+		return []byte(open.text), nil
+	}
+	return os.ReadFile(path)
+}
+
+// offset returns the text of the document before pos.
+func (doc *document) offset(pos Position) string {
+	rest := doc.rest(pos)
+	return doc.text[:len(doc.text)-len(rest)]
+}
+
+// rest returns the text of the document from pos on.
+func (doc *document) rest(pos Position) string {
+	if pos.Line >= len(doc.lines) {
+		return ""
+	}
+	offset := 0
+	for _, line := range doc.lines[:pos.Line] {
+		offset += len(line) + 1
+	}
+
+	column := doc.fromPosition(pos).Column
+	line := doc.lines[pos.Line]
+	for i := range line {
+		if column == 0 {
+			return doc.text[offset+i:]
+		}
+		column--
+	}
+	return doc.text[min(offset+len(line), len(doc.text)):]
+}
+
+// docText returns the text of a doc comment, or "".
+func docText(doc *ast.CommentGroup) string {
+	return strings.TrimSpace(doc.Text())
+}
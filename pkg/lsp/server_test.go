@@ -0,0 +1,422 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is an in-process LSP client connected to a server by pipes.
+type client struct {
+	t        *testing.T
+	conn     *conn
+	id       int
+	messages chan *message
+	pending  []*message // notifications received while waiting for a response
+	done     chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:        t,
+		conn:     newConn(clientIn, clientOut),
+		messages: make(chan *message, 100),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() {
+		clientOut.Close()
+	})
+	return c
+}
+
+func (c *client) receive() *message {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout waiting for a message")
+	}
+	return nil
+}
+
+func (c *client) call(method string, params, result any) *responseError {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.id))))
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("got response to %s; want %s", *msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics waits for the next diagnostics published for uri.
+func (c *client) diagnostics(uri string) PublishDiagnosticsParams {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.pending) > 0 {
+			msg, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			msg = c.receive()
+		}
+
+		var params PublishDiagnosticsParams
+		if msg.Method == "textDocument/publishDiagnostics" {
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				c.t.Fatal(err)
+			}
+			if params.URI == uri {
+				return params
+			}
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+const typesSource = `// Unique identifier.
+type Uuid = string
+
+const Version = 3
+`
+
+const mainSource = `import "common/types"
+
+// A user of the system.
+struct User {
+    // Primary key.
+    id: types.Uuid
+    name?: string
+}
+
+const Answer = types.Version + 1
+
+interface Users {
+    func get(id: types.Uuid) -> User
+}
+`
+
+// setup starts a server in a workspace with a module on disk and opens
+// the main document.
+func setup(t *testing.T) (*client, string) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "common"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "common", "types.lark"), []byte(typesSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	var result InitializeResult
+	if err := c.call("initialize", InitializeParams{RootURI: pathToURI(root)}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync != SyncFull {
+		t.Fatalf("got capabilities %+v", result.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	uri := pathToURI(filepath.Join(root, "main.lark"))
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "lark", Version: 1, Text: mainSource},
+	})
+	return c, uri
+}
+
+func position(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, uri := setup(t)
+	if got := c.diagnostics(uri); len(got.Diagnostics) != 0 {
+		t.Fatalf("got diagnostics %+v; want none", got.Diagnostics)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "import \"a\"\nconst x = \n"}},
+	})
+	got := c.diagnostics(uri)
+	if got.Version != 2 || len(got.Diagnostics) != 2 {
+		t.Fatalf("got diagnostics %+v; want 2 for version 2", got)
+	}
+	if d := got.Diagnostics[0]; d.Severity != SeverityError || d.Code != "E0100" || d.Range.Start.Line != 2 {
+		t.Errorf("got diagnostic %+v", d)
+	}
+	if d := got.Diagnostics[1]; d.Severity != SeverityWarning || d.Code != "W0001" {
+		t.Errorf("got diagnostic %+v", d)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if got := c.diagnostics(uri); len(got.Diagnostics) != 0 {
+		t.Errorf("got diagnostics %+v after close; want none", got.Diagnostics)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c, uri := setup(t)
+
+	var hover Hover
+	if err := c.call("textDocument/hover", position(uri, 12, 33), &hover); err != nil {
+		t.Fatal(err)
+	}
+	if want := "```lark\nstruct User {\n    id:    types.Uuid\n    name?: string\n}\n```\n\nA user of the system."; hover.Contents.Value != want {
+		t.Errorf("got hover %q; want %q", hover.Contents.Value, want)
+	}
+	if hover.Range != (Range{Position{12, 32}, Position{12, 36}}) {
+		t.Errorf("got hover range %+v", hover.Range)
+	}
+
+	if err := c.call("textDocument/hover", position(uri, 5, 16), &hover); err != nil {
+		t.Fatal(err)
+	}
+	if want := "```lark\ntype Uuid = string\n```\n\nUnique identifier."; hover.Contents.Value != want {
+		t.Errorf("got hover %q; want %q", hover.Contents.Value, want)
+	}
+
+	if err := c.call("textDocument/hover", position(uri, 5, 5), &hover); err != nil {
+		t.Fatal(err)
+	}
+	if want := "```lark\nid: types.Uuid\n```\n\nPrimary key."; hover.Contents.Value != want {
+		t.Errorf("got hover %q; want %q", hover.Contents.Value, want)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c, uri := setup(t)
+
+	var location Location
+	if err := c.call("textDocument/definition", position(uri, 12, 34), &location); err != nil {
+		t.Fatal(err)
+	}
+	if location.URI != uri || location.Range != (Range{Position{3, 7}, Position{3, 11}}) {
+		t.Errorf("got definition %+v", location)
+	}
+
+	if err := c.call("textDocument/definition", position(uri, 9, 22), &location); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(location.URI, "/common/types.lark") || location.Range.Start != (Position{3, 6}) {
+		t.Errorf("got definition %+v", location)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	c, uri := setup(t)
+
+	params := ReferenceParams{TextDocumentPositionParams: position(uri, 5, 14)}
+	params.Context.IncludeDeclaration = true
+
+	var locations []Location
+	if err := c.call("textDocument/references", params, &locations); err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 3 {
+		t.Fatalf("got references %+v; want declaration and 2 uses", locations)
+	}
+	if !strings.HasSuffix(locations[0].URI, "/common/types.lark") {
+		t.Errorf("got declaration %+v", locations[0])
+	}
+	if locations[1].Range.Start != (Position{5, 14}) || locations[2].Range.Start != (Position{12, 23}) {
+		t.Errorf("got references %+v", locations[1:])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c, uri := setup(t)
+
+	labels := func(list CompletionList) string {
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return strings.Join(labels, " ")
+	}
+
+	var list CompletionList
+	if err := c.call("textDocument/completion", position(uri, 9, 15), &list); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(list); got != "User Answer Users types" {
+		t.Errorf("got completions %q", got)
+	}
+
+	if err := c.call("textDocument/completion", position(uri, 9, 21), &list); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(list); got != "Uuid Version" {
+		t.Errorf("got module completions %q", got)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c, uri := setup(t)
+
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", position(uri, 0, 0), &symbols); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+		for _, child := range symbol.Children {
+			names = append(names, symbol.Name+"."+child.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != `"common/types" User User.id User.name Answer Users Users.get` {
+		t.Errorf("got symbols %q", got)
+	}
+	if symbols[1].Range != (Range{Position{3, 0}, Position{7, 1}}) {
+		t.Errorf("got struct range %+v", symbols[1].Range)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c, uri := setup(t)
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "const  x=1+2"}},
+	})
+
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits); err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].NewText != "const x = 1 + 2\n" || edits[0].Range.End != (Position{0, 12}) {
+		t.Errorf("got edits %+v", edits)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	c, uri := setup(t)
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "struct S {\n  x: S // c\n}"}},
+	})
+
+	var tokens SemanticTokens
+	if err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &tokens); err != nil {
+		t.Fatal(err)
+	}
+	want := []int{
+		0, 0, 6, tokenKeyword, 0,
+		0, 7, 1, tokenStruct, modDeclaration,
+		1, 2, 1, tokenProperty, modDeclaration,
+		0, 3, 1, tokenStruct, 0,
+		0, 2, 4, tokenComment, 0,
+	}
+	if len(tokens.Data) != len(want) {
+		t.Fatalf("got tokens %v; want %v", tokens.Data, want)
+	}
+	for i := range want {
+		if tokens.Data[i] != want[i] {
+			t.Fatalf("got tokens %v; want %v", tokens.Data, want)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call("textDocument/hover", position("file:///x.lark", 0, 0), nil); err == nil || err.Code != codeInvalidRequest {
+		t.Errorf("got %v after shutdown; want invalid request", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("got %v; want clean exit", err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var out bytes.Buffer
+	c := newConn(strings.NewReader("Content-Length: 5\r\n\r\n{oops"), &out)
+	_, err := c.read()
+	var rerr *responseError
+	if !errors.As(err, &rerr) || rerr.Code != codeParseError {
+		t.Fatalf("got %v; want parse error", err)
+	}
+	if err := c.reply(nil, nil, rerr); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, `"id":null`) {
+		t.Errorf("got response %q; want null id", got)
+	}
+}
+
+func TestUTF16Positions(t *testing.T) {
+	doc := newDocument("file:///x.lark", 1, "const 😀 = \"é\"\n")
+	pos := doc.toPosition(doc.lineEnd(0))
+	if pos.Character != 14 {
+		t.Errorf("got character %d; want 14", pos.Character)
+	}
+	if back := doc.fromPosition(pos); back.Column != 13 {
+		t.Errorf("got column %d; want 13", back.Column)
+	}
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"testing"
+
+	"larklang.io/lark/pkg/ast"
+)
+
+func TestComments(t *testing.T) {
+	text := `// File comment.
+
+// Doc of A
+// on two lines.
+const A = 1 // trailing A
+const B = 2
+
+// Doc of S.
+struct S {
+    // Doc of x.
+    x: int
+    y: int // trailing y
+}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+
+	var groups []string
+	for _, group := range parsed.File.Comments {
+		groups = append(groups, group.Text())
+	}
+	want := []string{"File comment.", "Doc of A\non two lines.", "trailing A", "Doc of S.", "Doc of x.", "trailing y"}
+	if len(groups) != len(want) {
+		t.Fatalf("got comment groups %q; want %q", groups, want)
+	}
+	for i := range want {
+		if groups[i] != want[i] {
+			t.Errorf("got comment group %q; want %q", groups[i], want[i])
+		}
+	}
+
+	nodes := parsed.File.Nodes
+	if got := nodes[0].(*ast.ConstSpec).Doc.Text(); got != "Doc of A\non two lines." {
+		t.Errorf("got doc of A %q", got)
+	}
+	if doc := nodes[1].(*ast.ConstSpec).Doc; doc != nil {
+		t.Errorf("got doc of B %q; want none", doc.Text())
+	}
+
+	s := nodes[2].(*ast.Struct)
+	if got := s.Doc.Text(); got != "Doc of S." {
+		t.Errorf("got doc of S %q", got)
+	}
+	if got := s.Fields[0].Doc.Text(); got != "Doc of x." {
+		t.Errorf("got doc of x %q", got)
+	}
+	if doc := s.Fields[1].Doc; doc != nil {
+		t.Errorf("got doc of y %q; want none", doc.Text())
+	}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+// errorComment matches the expected error annotations of the corpus files:
+// an error whose message matches the quoted regular expression must be
+// reported on the line of the comment.
+var errorComment = regexp.MustCompile(`// ERROR "((?:[^"\\]|\\.)*)"`)
+
+func TestErrorCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "errors", "*.lark"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range files {
+		text, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[int]*regexp.Regexp{}
+		for i, line := range strings.Split(string(text), "\n") {
+			if m := errorComment.FindStringSubmatch(line); m != nil {
+				expected[i] = regexp.MustCompile(strings.ReplaceAll(m[1], `\"`, `"`))
+			}
+		}
+
+		parsed := ParseWithOptions(text, Options{})
+		for _, d := range parsed.Diagnostics {
+			if d.Severity != diag.Error {
+				continue
+			}
+
+			line := d.Pos().Line
+			want, ok := expected[line]
+			switch {
+			case !ok:
+				t.Errorf("%s:%d: unexpected error %q", filename, line+1, d.Message)
+			case !want.MatchString(d.Message):
+				t.Errorf("%s:%d: got error %q; want %q", filename, line+1, d.Message, want)
+			}
+			delete(expected, line)
+		}
+
+		for line, want := range expected {
+			t.Errorf("%s:%d: missing error %q", filename, line+1, want)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	text := strings.Repeat("const = 1\n", 50)
+
+	type testCase struct {
+		maxErrors int
+		errors    int
+	}
+
+	tests := []testCase{
+		{0, 50},
+		{1, 1},
+		{10, 10},
+		{DefaultMaxErrors, DefaultMaxErrors},
+	}
+
+	for _, test := range tests {
+		parsed := ParseWithOptions([]byte(text), Options{MaxErrors: test.maxErrors})
+
+		errors, notes := 0, 0
+		for _, d := range parsed.Diagnostics {
+			switch d.Severity {
+			case diag.Error:
+				errors++
+			case diag.Note:
+				notes++
+			}
+		}
+
+		if errors != test.errors {
+			t.Errorf("MaxErrors=%d: got %d errors; want %d", test.maxErrors, errors, test.errors)
+		}
+		if wantNotes := min(test.maxErrors, 1); notes != wantNotes {
+			t.Errorf("MaxErrors=%d: got %d notes; want %d", test.maxErrors, notes, wantNotes)
+		}
+	}
+}
+
+func TestMaxErrorsIllegal(t *testing.T) {
+	tests := []string{
+		"$\n$\n$\n",
+		"\x00\n\x00\n",
+		strings.Repeat("$\n", 25),
+	}
+
+	for _, text := range tests {
+		parsed := ParseWithOptions([]byte(text), Options{MaxErrors: 1})
+
+		errors := 0
+		for _, d := range parsed.Diagnostics {
+			if d.Severity == diag.Error {
+				errors++
+			}
+		}
+		if errors != 1 {
+			t.Errorf("%q: got %d errors; want 1", text, errors)
+		}
+		if parsed.File == nil {
+			t.Errorf("%q: got nil file", text)
+		}
+		if got, want := len(parsed.Lines), strings.Count(text, "\n"); got != want {
+			t.Errorf("%q: got %d lines; want %d", text, got, want)
+		}
+	}
+}
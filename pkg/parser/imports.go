@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/scanner"
+)
+
+// ImportName returns the name under which an import is visible in the file:
+// its alias if one is given, otherwise the last element of the import path
+// without the ".lark" extension.
+func ImportName(spec *ast.ImportSpec) string {
+	if spec.Alias != nil {
+		return spec.Alias.Name
+	}
+	value, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(path.Base(value), ".lark")
+}
+
+func nameRange(name *ast.Name) diag.Range {
+	return diag.Span(name.Pos(), utf8.RuneCountInString(name.Name))
+}
+
+func pathRange(spec *ast.ImportSpec) diag.Range {
+	return diag.Span(spec.Path.Pos(), utf8.RuneCountInString(spec.Path.Value))
+}
+
+// deleteLine returns a fix that removes the source line holding pos.
+func deleteLine(msg string, pos scanner.Pos) diag.Fix {
+	return diag.Fix{
+		Message: msg,
+		Edits: []diag.TextEdit{{
+			Range: diag.Range{
+				Start: scanner.Pos{Line: pos.Line, Column: 0},
+				End:   scanner.Pos{Line: pos.Line + 1, Column: 0},
+			},
+		}},
+	}
+}
+
+func (p *parser) warn(code diag.Code, rng diag.Range, msg string, related []diag.Related, fix diag.Fix) {
+	p.report(diag.Diagnostic{
+		Severity: diag.Warning,
+		Code:     code,
+		Range:    rng,
+		Message:  msg,
+		Related:  related,
+		Fixes:    []diag.Fix{fix},
+	})
+}
+
+// checkImports reports unused imports, imports of the same path and aliases
+// that collide with declarations of the file.
+func (p *parser) checkImports(file *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if name, ok := node.(*ast.QualName); ok && name.Module != nil {
+			used[name.Module.Name] = true
+		}
+		return true
+	})
+
+	declared := map[string]*ast.Name{}
+	for _, sym := range p.symtab {
+		if _, ok := declared[sym.Name.Name]; !ok {
+			declared[sym.Name.Name] = sym.Name
+		}
+	}
+
+	paths := map[string]*ast.ImportSpec{}
+	for _, spec := range p.imports {
+		value, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			// bad import path, already reported
+			continue
+		}
+
+		if first, ok := paths[value]; ok {
+			p.warn(diag.DuplicateImport, pathRange(spec),
+				strconv.Quote(value)+" imported more than once",
+				[]diag.Related{{Range: pathRange(first), Label: "first imported here"}},
+				deleteLine("remove duplicate import", spec.Pos()))
+			continue
+		}
+		paths[value] = spec
+
+		name := ImportName(spec)
+		if name == "" {
+			continue
+		}
+
+		if decl, ok := declared[name]; ok {
+			rng := pathRange(spec)
+			if spec.Alias != nil {
+				rng = nameRange(spec.Alias)
+			}
+			p.warn(diag.ShadowedImport, rng,
+				"import name "+name+" collides with a declaration",
+				[]diag.Related{{Range: nameRange(decl), Label: name + " declared here"}},
+				p.renameAlias(spec, name, declared))
+		}
+
+		if !used[name] {
+			p.warn(diag.UnusedImport, pathRange(spec),
+				strconv.Quote(value)+" imported and not used", nil,
+				deleteLine("remove unused import", spec.Pos()))
+		}
+	}
+}
+
+// renameAlias returns a fix that gives an import a fresh alias that does not
+// collide with any declaration or other import of the file.
+func (p *parser) renameAlias(spec *ast.ImportSpec, name string, declared map[string]*ast.Name) diag.Fix {
+	taken := func(candidate string) bool {
+		if _, ok := declared[candidate]; ok {
+			return true
+		}
+		for _, other := range p.imports {
+			if ImportName(other) == candidate {
+				return true
+			}
+		}
+		return false
+	}
+
+	alias := name
+	for i := 2; taken(alias); i++ {
+		alias = name + strconv.Itoa(i)
+	}
+
+	if spec.Alias != nil {
+		return diag.Fix{
+			Message: "rename import alias to " + alias,
+			Edits:   []diag.TextEdit{{Range: nameRange(spec.Alias), NewText: alias}},
+		}
+	}
+
+	// insert an alias right after the import path
+	return diag.Fix{
+		Message: "import as " + alias,
+		Edits:   []diag.TextEdit{{Range: diag.At(pathRange(spec).End), NewText: " as " + alias}},
+	}
+}
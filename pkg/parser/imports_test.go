@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/diag"
+)
+
+func pos(line, column int) diag.Pos {
+	return diag.Pos{Line: line, Column: column}
+}
+
+func TestImportWarnings(t *testing.T) {
+	type testCase struct {
+		input    string
+		messages []string
+	}
+
+	tests := []testCase{
+		{`import "a/b"; const x = b.y`, nil},
+		{`import "a/b" as c; const x = c.y`, nil},
+		{`import "a/b.lark"; const x = b.y`, nil},
+		{`import "a/b"`, []string{`W0001 "a/b" imported and not used`}},
+		{`import "a/b" as c; const x = b.y`, []string{`W0001 "a/b" imported and not used`}},
+		{
+			`import "a/b"; import "a/b"; const x = b.y`,
+			[]string{`W0002 "a/b" imported more than once`},
+		},
+		{
+			`import "a/b"; import "a\x2fb"; const x = b.y`,
+			[]string{`W0002 "a/b" imported more than once`},
+		},
+		{`import "a\x2fb"`, []string{`W0001 "a/b" imported and not used`}},
+		{
+			`import "a/b" as x; const x = x.y`,
+			[]string{"W0003 import name x collides with a declaration"},
+		},
+		{
+			"import \"a/b\"\nconst b = 1\nconst c = b.y",
+			[]string{"W0003 import name b collides with a declaration"},
+		},
+	}
+
+	for _, test := range tests {
+		parsed := Parse([]byte(test.input))
+
+		var messages []string
+		for _, d := range parsed.Diagnostics {
+			if d.Severity != diag.Warning {
+				t.Errorf("%q: unexpected error %q", test.input, d.Message)
+				continue
+			}
+			if len(d.Fixes) == 0 {
+				t.Errorf("%q: warning %q has no suggested fix", test.input, d.Message)
+			}
+			messages = append(messages, string(d.Code)+" "+d.Message)
+		}
+
+		got, want := strings.Join(messages, "; "), strings.Join(test.messages, "; ")
+		if got != want {
+			t.Errorf("%q: got warnings %q; want %q", test.input, got, want)
+		}
+	}
+}
+
+func TestImportFixes(t *testing.T) {
+	type testCase struct {
+		input string
+		want  diag.Fix
+	}
+
+	edit := func(msg string, from, to diag.Pos, text string) diag.Fix {
+		return diag.Fix{Message: msg, Edits: []diag.TextEdit{{Range: diag.Range{Start: from, End: to}, NewText: text}}}
+	}
+
+	tests := []testCase{
+		{
+			"import \"a/b\"\n",
+			edit("remove unused import", pos(0, 0), pos(1, 0), ""),
+		},
+		{
+			"import \"a/b\" as x\nconst x = x.y\n",
+			edit("rename import alias to x2", pos(0, 16), pos(0, 17), "x2"),
+		},
+		{
+			"import \"a/b\"\nconst b = b.y\n",
+			edit("import as b2", pos(0, 12), pos(0, 12), " as b2"),
+		},
+	}
+
+	for _, test := range tests {
+		parsed := Parse([]byte(test.input))
+		if len(parsed.Diagnostics) == 0 || len(parsed.Diagnostics[0].Fixes) == 0 {
+			t.Errorf("%q: got no fix; want %v", test.input, test.want)
+			continue
+		}
+		if got := parsed.Diagnostics[0].Fixes[0]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got fix %v; want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestImportRelated(t *testing.T) {
+	parsed := Parse([]byte("import \"a/b\"\nimport \"a/b\"\nconst x = b.y\n"))
+	if len(parsed.Diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(parsed.Diagnostics))
+	}
+
+	d := parsed.Diagnostics[0]
+	want := []diag.Related{{Range: diag.Range{Start: pos(0, 7), End: pos(0, 12)}, Label: "first imported here"}}
+	if !reflect.DeepEqual(d.Related, want) {
+		t.Errorf("got related %v; want %v", d.Related, want)
+	}
+}
@@ -4,23 +4,29 @@ import (
 	"fmt"
 
 	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/diag"
 	"larklang.io/lark/pkg/scanner"
 )
 
-type ErrorInfo struct {
-	Pos     scanner.Pos
-	Message string
+// DefaultMaxErrors is the number of errors after which [Parse] gives up.
+const DefaultMaxErrors = 20
+
+type Options struct {
+	// MaxErrors is the number of errors after which parsing stops;
+	// zero means no limit.
+	MaxErrors int
 }
 
 type ParsedFile struct {
-	File    *ast.File
-	Imports []*ast.ImportSpec
-	Symtab  []Symbol
-	Lines   []string
-	Errors  []ErrorInfo
+	File        *ast.File
+	Imports     []*ast.ImportSpec
+	Symtab      []Symbol
+	Lines       []string
+	Diagnostics []diag.Diagnostic
 }
 
 type nudFn func() ast.Node
+type declFn func(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node
 type ledFn func(lhs ast.Node, prec int) ast.Node
 type parseExprRule struct {
 	nud  nudFn
@@ -39,6 +45,34 @@ const (
 	precPrimary  // bool, string, float, integer
 )
 
+// binaryPrec is the precedence of binary operators.
+var binaryPrec = map[scanner.TokenKind]int{
+	scanner.PLUS:  precTerm,
+	scanner.MINUS: precTerm,
+	scanner.MULT:  precFactor,
+	scanner.DIV:   precFactor,
+	scanner.MOD:   precFactor,
+	scanner.AND:   precLogicAnd,
+	scanner.OR:    precLogicOr,
+	scanner.EQ:    precCmp,
+	scanner.GE:    precCmp,
+	scanner.GT:    precCmp,
+	scanner.LE:    precCmp,
+	scanner.LT:    precCmp,
+	scanner.NEQ:   precCmp,
+}
+
+// UnaryPrecedence is the precedence of prefix operators, which bind tighter
+// than any binary operator.
+const UnaryPrecedence = precUnary
+
+// Precedence returns the precedence of the binary operator op, or 0 if op
+// is not a binary operator. Operators of higher precedence bind tighter;
+// operators of the same precedence associate to the left.
+func Precedence(op scanner.TokenKind) int {
+	return binaryPrec[op]
+}
+
 type SymbolType int
 
 const (
@@ -47,6 +81,8 @@ const (
 	InterfaceSym
 	StructSym
 	AliasSym
+	EnumSym
+	UnionSym
 )
 
 type Symbol struct {
@@ -59,7 +95,7 @@ type parser struct {
 	scanner       *scanner.Scanner
 	current       scanner.Token
 	exprRuleTable map[scanner.TokenKind]parseExprRule
-	errors        []ErrorInfo
+	diagnostics   []diag.Diagnostic
 
 	// Error recovery
 	// (used to limit the number of calls to parser.advance
@@ -68,12 +104,26 @@ type parser struct {
 	syncPos scanner.Pos // last synchronization position
 	syncCnt int         // number of parser.advance calls without progress
 
+	// Error reporting
+	maxErrors int  // see Options.MaxErrors
+	errCnt    int  // number of reported errors
+	errLine   int  // line of the last reported error
+	bailed    bool // whether the error limit stopped parsing
+
+	// Comments
+	comments []*ast.CommentGroup
+	lastLine int               // line of the last non-comment token
+	lastDoc  *ast.CommentGroup // last comment group returned by leadComment
+
 	imports []*ast.ImportSpec
 	symtab  []Symbol
 }
 
-func (p *parser) init(text []byte) {
-	p.scanner = scanner.New(text, p.err)
+func (p *parser) init(text []byte, opts Options) {
+	p.maxErrors = opts.MaxErrors
+	p.errLine = -1
+	p.lastLine = -1
+	p.scanner = scanner.New(text, p.report)
 
 	p.exprRuleTable = map[scanner.TokenKind]parseExprRule{
 		scanner.NULL:       {p.parseBasicLit, nil, precNone},
@@ -83,20 +133,14 @@ func (p *parser) init(text []byte) {
 		scanner.INTEGER:    {p.parseBasicLit, nil, precNone},
 		scanner.IDENTIFIER: {p.parseQualNameExpr, nil, precNone},
 		scanner.FLOAT:      {p.parseBasicLit, nil, precNone},
-		scanner.MINUS:      {p.parseUnaryExpr, p.parseBinaryExpr, precTerm},
+		scanner.LEFT_PAREN: {p.parseParenExpr, nil, precNone},
+		scanner.MINUS:      {p.parseUnaryExpr, nil, precNone},
 		scanner.NOT:        {p.parseUnaryExpr, nil, precNone},
-		scanner.PLUS:       {nil, p.parseBinaryExpr, precTerm},
-		scanner.MULT:       {nil, p.parseBinaryExpr, precFactor},
-		scanner.DIV:        {nil, p.parseBinaryExpr, precFactor},
-		scanner.MOD:        {nil, p.parseBinaryExpr, precFactor},
-		scanner.AND:        {nil, p.parseBinaryExpr, precLogicAnd},
-		scanner.OR:         {nil, p.parseBinaryExpr, precLogicOr},
-		scanner.EQ:         {nil, p.parseBinaryExpr, precCmp},
-		scanner.GE:         {nil, p.parseBinaryExpr, precCmp},
-		scanner.GT:         {nil, p.parseBinaryExpr, precCmp},
-		scanner.LE:         {nil, p.parseBinaryExpr, precCmp},
-		scanner.LT:         {nil, p.parseBinaryExpr, precCmp},
-		scanner.NEQ:        {nil, p.parseBinaryExpr, precCmp},
+	}
+	for op, prec := range binaryPrec {
+		rule := p.exprRuleTable[op]
+		rule.led, rule.prec = p.parseBinaryExpr, prec
+		p.exprRuleTable[op] = rule
 	}
 
 	p.next()
@@ -106,18 +150,52 @@ func (p *parser) scan(newline bool) scanner.Token {
 	for {
 		token := p.scanner.Scan()
 		switch token.Kind {
-		case scanner.COMMENT, scanner.ILLEGAL:
+		case scanner.COMMENT:
+			p.addComment(token)
+		case scanner.ILLEGAL:
 			continue
 		case scanner.NEWLINE:
 			if newline {
 				return token
 			}
 		default:
+			p.lastLine = token.End.Line
 			return token
 		}
 	}
 }
 
+// addComment adds a comment to the last comment group if it continues the
+// group on the next line, or starts a new group.
+func (p *parser) addComment(token scanner.Token) {
+	comment := &ast.Comment{Slash: token.Pos, Text: token.Value}
+	trailing := token.Pos.Line == p.lastLine
+
+	if n := len(p.comments); n > 0 && !trailing {
+		last := p.comments[n-1]
+		if !last.Trailing && last.EndLine() == token.Pos.Line-1 {
+			last.List = append(last.List, comment)
+			return
+		}
+	}
+
+	p.comments = append(p.comments, &ast.CommentGroup{List: []*ast.Comment{comment}, Trailing: trailing})
+}
+
+// leadComment returns the doc comment of the construct starting at the
+// current token: the comment group that ends on the line before it. A
+// group documents one construct at most.
+func (p *parser) leadComment() *ast.CommentGroup {
+	if n := len(p.comments); n > 0 {
+		last := p.comments[n-1]
+		if !last.Trailing && last.EndLine() == p.current.Pos.Line-1 && last != p.lastDoc {
+			p.lastDoc = last
+			return last
+		}
+	}
+	return nil
+}
+
 var insert_semi = [...]bool{
 	scanner.RIGHT_BRACE: true,
 	scanner.RIGHT_BRACK: true,
@@ -142,29 +220,99 @@ func (p *parser) next() {
 	}
 
 	p.current = token
+	if p.bailed {
+		panic(bailout{})
+	}
+}
+
+// bailout is raised by next to stop parsing once the error limit is
+// reached. It is not raised by report, which the scanner calls in the
+// middle of a token.
+type bailout struct{}
+
+// report records a diagnostic. Of several errors on the same line only the
+// first one is kept: the rest are usually caused by it.
+func (p *parser) report(d diag.Diagnostic) {
+	if d.Severity != diag.Error {
+		p.diagnostics = append(p.diagnostics, d)
+		return
+	}
+
+	if p.bailed || d.Range.Start.Line == p.errLine {
+		return
+	}
+	p.errLine = d.Range.Start.Line
+
+	if p.maxErrors > 0 && p.errCnt == p.maxErrors {
+		p.diagnostics = append(p.diagnostics, diag.Diagnostic{
+			Severity: diag.Note,
+			Range:    d.Range,
+			Message:  fmt.Sprintf("too many errors, stopped after %d", p.maxErrors),
+		})
+		p.bailed = true
+		return
+	}
+
+	p.errCnt++
+	p.diagnostics = append(p.diagnostics, d)
 }
 
-func (p *parser) err(pos scanner.Pos, msg string) {
-	p.errors = append(p.errors, ErrorInfo{pos, msg})
+func (p *parser) err(code diag.Code, rng diag.Range, msg string) {
+	p.report(diag.Diagnostic{Severity: diag.Error, Code: code, Range: rng, Message: msg})
 }
 
-func (p *parser) errf(pos scanner.Pos, format string, args ...any) {
-	p.err(pos, fmt.Sprintf(format, args...))
+func (p *parser) errf(code diag.Code, rng diag.Range, format string, args ...any) {
+	p.err(code, rng, fmt.Sprintf(format, args...))
+}
+
+// tokenRange returns the range of the current token. Inserted semicolons
+// and the endmarker get an empty range.
+func (p *parser) tokenRange() diag.Range {
+	token := p.current
+	if token.End.Line != token.Pos.Line || token.Kind == scanner.SEMICOLON && token.Value != ";" {
+		return diag.At(token.Pos)
+	}
+	return diag.Range{Start: token.Pos, End: token.End}
 }
 
 func (p *parser) expectMsg(msg string) {
-	p.err(p.current.Pos, fmt.Sprintf("expected %s, found '%s'", msg, p.current.Value))
+	p.errf(diag.UnexpectedToken, p.tokenRange(), "expected %s, found '%s'", msg, p.current.Value)
+}
+
+// closing lists tokens that end a construct. expect does not skip them
+// on a mismatch, so that the enclosing construct can still see them.
+var closing = map[scanner.TokenKind]bool{
+	scanner.SEMICOLON:   true,
+	scanner.RIGHT_PAREN: true,
+	scanner.RIGHT_BRACK: true,
+	scanner.RIGHT_BRACE: true,
+	scanner.ENDMARKER:   true,
 }
 
 func (p *parser) expect(kind scanner.TokenKind) scanner.Token {
 	token := p.current
 	if token.Kind != kind {
 		p.expectMsg("'" + kind.String() + "'")
+		if closing[token.Kind] {
+			return token
+		}
 	}
 	p.next() // make progress
 	return token
 }
 
+// expectSemi consumes the semicolon that terminates a declaration or a
+// member. If it is missing, the tokens up to the next token in the 'to' set
+// are skipped.
+func (p *parser) expectSemi(to map[scanner.TokenKind]bool) {
+	if p.accept(scanner.SEMICOLON) {
+		return
+	}
+	p.expectMsg("';'")
+	p.sync(to)
+	p.accept(scanner.SEMICOLON)
+}
+
 func (p *parser) accept(kind scanner.TokenKind) bool {
 	if p.current.Kind == kind {
 		p.next()
@@ -179,11 +327,53 @@ var semiOnly = map[scanner.TokenKind]bool{
 
 var declStart = map[scanner.TokenKind]bool{
 	scanner.CONST:     true,
+	scanner.ENUM:      true,
 	scanner.FUNC:      true,
 	scanner.IMPORT:    true,
 	scanner.INTERFACE: true,
 	scanner.STRUCT:    true,
 	scanner.TYPE:      true,
+	scanner.UNION:     true,
+}
+
+// declEnd is where the remainder of a broken declaration ends.
+var declEnd = union(declStart, semiOnly)
+
+// structEnd and interfaceEnd are where the remainder of a broken member
+// ends: at its semicolon, at the closing brace of the body, or at the
+// start of the next declaration if the closing brace is missing.
+var structEnd = union(declStart, semiOnly, map[scanner.TokenKind]bool{
+	scanner.RIGHT_BRACE: true,
+})
+
+var interfaceEnd = structEnd
+
+var enumEnd = structEnd
+
+var unionEnd = structEnd
+
+// bodyEnd reports whether the current token ends a struct or an
+// interface body. Except for 'func' in an interface, a declaration keyword
+// means that the closing brace is missing.
+func (p *parser) bodyEnd(methods bool) bool {
+	switch kind := p.current.Kind; {
+	case kind == scanner.RIGHT_BRACE, kind == scanner.ENDMARKER:
+		return true
+	case kind == scanner.FUNC:
+		return !methods
+	default:
+		return declStart[kind]
+	}
+}
+
+func union(sets ...map[scanner.TokenKind]bool) map[scanner.TokenKind]bool {
+	result := map[scanner.TokenKind]bool{}
+	for _, set := range sets {
+		for kind := range set {
+			result[kind] = true
+		}
+	}
+	return result
 }
 
 // sync consumes tokens until the current token is in the 'to' set, or
@@ -215,7 +405,9 @@ func (p *parser) parseExpr(prec int) ast.Node {
 	prefRule := p.exprRuleTable[token.Kind]
 	if prefRule.nud == nil {
 		p.expectMsg("expression")
-		p.next()
+		if !closing[token.Kind] && !declStart[token.Kind] {
+			p.next()
+		}
 		return &ast.BadNode{From: token.Pos, To: p.current.Pos}
 	}
 
@@ -264,6 +456,13 @@ func (p *parser) parseQualNameExpr() ast.Node {
 	return p.parseQualName()
 }
 
+func (p *parser) parseParenExpr() ast.Node {
+	p.next()
+	expr := p.parseExpr(precNone)
+	p.expect(scanner.RIGHT_PAREN)
+	return expr
+}
+
 func (p *parser) parseUnaryExpr() ast.Node {
 	op := p.current
 	p.next()
@@ -277,14 +476,14 @@ func (p *parser) parseBinaryExpr(lhs ast.Node, prec int) ast.Node {
 	return &ast.BinaryExpr{Op: op.Kind, Lhs: lhs, Rhs: p.parseExpr(prec)}
 }
 
-func (p *parser) parseImportSpec() ast.Node {
+func (p *parser) parseImportSpec(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
 	token := p.current
 	var path string
 	if token.Kind == scanner.STRING {
 		path = token.Value
 		p.next()
 	} else {
-		p.err(token.Pos, "import path must be a string")
+		p.err(diag.InvalidImportPath, p.tokenRange(), "import path must be a string")
 		p.sync(semiOnly)
 	}
 
@@ -294,6 +493,7 @@ func (p *parser) parseImportSpec() ast.Node {
 	}
 
 	spec := &ast.ImportSpec{
+		Doc:   doc,
 		Path:  &ast.BasicLit{ValuePos: token.Pos, Kind: scanner.STRING, Value: path},
 		Alias: alias,
 	}
@@ -302,57 +502,365 @@ func (p *parser) parseImportSpec() ast.Node {
 	return spec
 }
 
-func (p *parser) parseConstSpec() ast.Node {
+func (p *parser) parseConstSpec(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
 	name := p.parseName()
+	var typ *ast.Type
+	if p.accept(scanner.COLON) {
+		typ = p.parseType()
+	}
 	p.expect(scanner.ASSIGN)
 	expr := p.parseExpr(precNone)
 
-	spec := &ast.ConstSpec{Name: name, Expr: expr}
+	spec := &ast.ConstSpec{Doc: doc, Annotations: annotations, Name: name, Type: typ, Expr: expr}
 	p.symtab = append(p.symtab, Symbol{Type: ConstSym, Name: name, Decl: spec})
 
 	return spec
 }
 
+// parseAnnotations parses a possibly empty list of annotations. Each
+// annotation may be followed by a newline.
+func (p *parser) parseAnnotations() []*ast.Annotation {
+	var annotations []*ast.Annotation
+	for p.current.Kind == scanner.AT {
+		pos := p.current.Pos
+		p.next()
+		annotation := &ast.Annotation{At: pos, Name: p.parseName()}
+
+		if p.accept(scanner.LEFT_PAREN) {
+			annotation.Args = []ast.Node{}
+			for p.current.Kind != scanner.RIGHT_PAREN && !closing[p.current.Kind] {
+				annotation.Args = append(annotation.Args, p.parseExpr(precNone))
+				if !p.accept(scanner.COMMA) {
+					break
+				}
+			}
+			p.expect(scanner.RIGHT_PAREN)
+		}
+
+		annotations = append(annotations, annotation)
+		if p.current.Kind == scanner.SEMICOLON && p.current.Value != ";" {
+			p.next()
+		}
+	}
+	return annotations
+}
+
+// parseLead parses the doc comment and the annotations that precede a
+// declaration or a member. The doc comment may also follow the
+// annotations.
+func (p *parser) parseLead() (*ast.CommentGroup, []*ast.Annotation) {
+	doc := p.leadComment()
+	annotations := p.parseAnnotations()
+	if doc == nil && annotations != nil {
+		doc = p.leadComment()
+	}
+	return doc, annotations
+}
+
+func (p *parser) parseType() *ast.Type {
+	if p.current.Kind == scanner.LEFT_BRACK {
+		return p.parseArrayType()
+	}
+	if p.current.Kind != scanner.IDENTIFIER {
+		p.expectMsg("type")
+	}
+	name := p.parseQualName()
+
+	var args []ast.Node
+	if p.accept(scanner.LEFT_BRACK) {
+		for {
+			args = append(args, p.parseType())
+			if !p.accept(scanner.COMMA) || p.current.Kind == scanner.RIGHT_BRACK {
+				break
+			}
+		}
+		p.expect(scanner.RIGHT_BRACK)
+	}
+
+	return &ast.Type{Name: name, Args: args}
+}
+
+// parseArrayType parses an array type: [T; N].
+func (p *parser) parseArrayType() *ast.Type {
+	lbrack := p.current.Pos
+	p.next()
+	elem := p.parseType()
+	p.expect(scanner.SEMICOLON)
+	length := p.parseExpr(precNone)
+	p.expect(scanner.RIGHT_BRACK)
+
+	return &ast.Type{Lbrack: lbrack, Elem: elem, Len: length}
+}
+
+func (p *parser) parseTypeAlias(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
+	name := p.parseName()
+	p.expect(scanner.ASSIGN)
+	typ := p.parseType()
+
+	alias := &ast.TypeAlias{Doc: doc, Annotations: annotations, TypePos: pos, Name: name, Type: typ}
+	p.symtab = append(p.symtab, Symbol{Type: AliasSym, Name: name, Decl: alias})
+
+	return alias
+}
+
+func (p *parser) parseField() *ast.Field {
+	doc, annotations := p.parseLead()
+	if p.current.Kind != scanner.IDENTIFIER {
+		p.expectMsg("field name")
+		p.sync(structEnd)
+		return nil
+	}
+
+	name := p.parseName()
+	optional := p.accept(scanner.QMARK)
+	p.expect(scanner.COLON)
+
+	return &ast.Field{Doc: doc, Annotations: annotations, Name: name, Optional: optional, Type: p.parseType()}
+}
+
+func (p *parser) parseStruct(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
+	name := p.parseName()
+	p.expect(scanner.LEFT_BRACE)
+
+	var fields []*ast.Field
+	for !p.bodyEnd(false) {
+		if field := p.parseField(); field != nil {
+			fields = append(fields, field)
+		}
+		if p.current.Kind != scanner.RIGHT_BRACE {
+			p.expectSemi(structEnd)
+		}
+	}
+
+	rbrace := p.current.Pos
+	if !p.accept(scanner.RIGHT_BRACE) {
+		p.expectMsg("'}'")
+	}
+
+	decl := &ast.Struct{Doc: doc, Annotations: annotations, StructPos: pos, Name: name, Fields: fields, Rbrace: rbrace}
+	p.symtab = append(p.symtab, Symbol{Type: StructSym, Name: name, Decl: decl})
+
+	return decl
+}
+
+func (p *parser) parseEnumMember() *ast.EnumMember {
+	doc, annotations := p.parseLead()
+	if p.current.Kind != scanner.IDENTIFIER {
+		p.expectMsg("enum member")
+		p.sync(enumEnd)
+		return nil
+	}
+
+	member := &ast.EnumMember{Doc: doc, Annotations: annotations, Name: p.parseName()}
+	if p.accept(scanner.ASSIGN) {
+		member.Value = p.parseExpr(precNone)
+	}
+	return member
+}
+
+func (p *parser) parseEnum(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
+	name := p.parseName()
+	p.expect(scanner.LEFT_BRACE)
+
+	var members []*ast.EnumMember
+	for !p.bodyEnd(false) {
+		if member := p.parseEnumMember(); member != nil {
+			members = append(members, member)
+		}
+		if p.current.Kind != scanner.RIGHT_BRACE {
+			p.expectSemi(enumEnd)
+		}
+	}
+
+	rbrace := p.current.Pos
+	if !p.accept(scanner.RIGHT_BRACE) {
+		p.expectMsg("'}'")
+	}
+
+	decl := &ast.Enum{Doc: doc, Annotations: annotations, EnumPos: pos, Name: name, Members: members, Rbrace: rbrace}
+	p.symtab = append(p.symtab, Symbol{Type: EnumSym, Name: name, Decl: decl})
+
+	return decl
+}
+
+func (p *parser) parseVariant() *ast.Variant {
+	doc, annotations := p.parseLead()
+	if p.current.Kind != scanner.IDENTIFIER {
+		p.expectMsg("union variant")
+		p.sync(unionEnd)
+		return nil
+	}
+
+	name := p.parseName()
+	p.expect(scanner.COLON)
+
+	return &ast.Variant{Doc: doc, Annotations: annotations, Name: name, Type: p.parseType()}
+}
+
+func (p *parser) parseUnion(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
+	name := p.parseName()
+	p.expect(scanner.LEFT_BRACE)
+
+	var variants []*ast.Variant
+	for !p.bodyEnd(false) {
+		if variant := p.parseVariant(); variant != nil {
+			variants = append(variants, variant)
+		}
+		if p.current.Kind != scanner.RIGHT_BRACE {
+			p.expectSemi(unionEnd)
+		}
+	}
+
+	rbrace := p.current.Pos
+	if !p.accept(scanner.RIGHT_BRACE) {
+		p.expectMsg("'}'")
+	}
+
+	decl := &ast.Union{Doc: doc, Annotations: annotations, UnionPos: pos, Name: name, Variants: variants, Rbrace: rbrace}
+	p.symtab = append(p.symtab, Symbol{Type: UnionSym, Name: name, Decl: decl})
+
+	return decl
+}
+
+func (p *parser) parseParam() *ast.Param {
+	annotations := p.parseAnnotations()
+	name := p.parseName()
+	p.expect(scanner.COLON)
+
+	return &ast.Param{Annotations: annotations, Name: name, Type: p.parseType()}
+}
+
+func (p *parser) parseMethod() *ast.Method {
+	doc, annotations := p.parseLead()
+	if p.current.Kind != scanner.FUNC {
+		p.expectMsg("method")
+		p.sync(interfaceEnd)
+		return nil
+	}
+
+	pos := p.current.Pos
+	p.next()
+	name := p.parseName()
+
+	var params []*ast.Param
+	p.expect(scanner.LEFT_PAREN)
+	for p.current.Kind != scanner.RIGHT_PAREN && !closing[p.current.Kind] {
+		params = append(params, p.parseParam())
+		if !p.accept(scanner.COMMA) {
+			break
+		}
+	}
+	p.expect(scanner.RIGHT_PAREN)
+
+	var result *ast.Type
+	if p.accept(scanner.ARROW) {
+		result = p.parseType()
+	}
+
+	return &ast.Method{Doc: doc, Annotations: annotations, FuncPos: pos, Name: name, Params: params, Result: result}
+}
+
+func (p *parser) parseInterface(doc *ast.CommentGroup, annotations []*ast.Annotation, pos scanner.Pos) ast.Node {
+	name := p.parseName()
+	p.expect(scanner.LEFT_BRACE)
+
+	var methods []*ast.Method
+	for !p.bodyEnd(true) {
+		if method := p.parseMethod(); method != nil {
+			methods = append(methods, method)
+		}
+		if p.current.Kind != scanner.RIGHT_BRACE {
+			p.expectSemi(interfaceEnd)
+		}
+	}
+
+	rbrace := p.current.Pos
+	if !p.accept(scanner.RIGHT_BRACE) {
+		p.expectMsg("'}'")
+	}
+
+	decl := &ast.Interface{Doc: doc, Annotations: annotations, InterfacePos: pos, Name: name, Methods: methods, Rbrace: rbrace}
+	p.symtab = append(p.symtab, Symbol{Type: InterfaceSym, Name: name, Decl: decl})
+
+	return decl
+}
+
 func (p *parser) parseDecl() ast.Node {
-	var parse nudFn
+	var parse declFn
+	doc, annotations := p.parseLead()
 	token := p.current
 	switch token.Kind {
 	case scanner.IMPORT:
 		parse = p.parseImportSpec
 	case scanner.CONST:
 		parse = p.parseConstSpec
+	case scanner.TYPE:
+		parse = p.parseTypeAlias
+	case scanner.STRUCT:
+		parse = p.parseStruct
+	case scanner.ENUM:
+		parse = p.parseEnum
+	case scanner.UNION:
+		parse = p.parseUnion
+	case scanner.INTERFACE:
+		parse = p.parseInterface
 	default:
 		p.expectMsg("declaration")
 		p.sync(declStart)
 		return &ast.BadNode{From: token.Pos, To: p.current.Pos}
 	}
 
+	if token.Kind == scanner.IMPORT && annotations != nil {
+		p.err(diag.UnexpectedToken, diag.At(annotations[0].At), "imports cannot be annotated")
+	}
+
 	// consume keyword
 	p.next()
-	decl := parse()
-	p.expect(scanner.SEMICOLON)
+	decl := parse(doc, annotations, token.Pos)
+	p.expectSemi(declEnd)
 
 	return decl
 }
 
-func (p *parser) parse() *ast.File {
+// parse initializes the parser and parses the whole file. Reaching the
+// error limit stops parsing, possibly before the first token is read.
+func (p *parser) parse(text []byte, opts Options) (file *ast.File) {
 	var nodes []ast.Node
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			// Scan the rest of the text so that all its lines are known.
+			for p.scanner.Scan().Kind != scanner.ENDMARKER {
+			}
+		}
+		file = &ast.File{Nodes: nodes, Comments: p.comments}
+	}()
+
+	p.init(text, opts)
 	for p.current.Kind != scanner.ENDMARKER {
 		nodes = append(nodes, p.parseDecl())
 	}
 
-	return &ast.File{Nodes: nodes}
+	return
 }
 
+// Parse parses text with the default options.
 func Parse(text []byte) ParsedFile {
+	return ParseWithOptions(text, Options{MaxErrors: DefaultMaxErrors})
+}
+
+func ParseWithOptions(text []byte, opts Options) ParsedFile {
 	p := &parser{}
-	p.init(text)
+	file := p.parse(text, opts)
+	p.checkImports(file)
 
 	return ParsedFile{
-		File:    p.parse(),
-		Imports: p.imports,
-		Symtab:  p.symtab,
-		Lines:   p.scanner.Lines(),
-		Errors:  p.errors,
+		File:        file,
+		Imports:     p.imports,
+		Symtab:      p.symtab,
+		Lines:       p.scanner.Lines(),
+		Diagnostics: p.diagnostics,
 	}
 }
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/ast"
+)
+
+func TestDeclarations(t *testing.T) {
+	text := `
+type Id = string
+
+struct User {
+    id: Id
+    name?: string
+    tags: map[string, list[int]]
+}
+
+interface Users {
+    func get(id: Id) -> User
+    func ping()
+}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+
+	var names []string
+	for _, sym := range parsed.Symtab {
+		names = append(names, sym.Name.Name)
+	}
+	if got := strings.Join(names, " "); got != "Id User Users" {
+		t.Errorf("got symbols %q; want %q", got, "Id User Users")
+	}
+
+	user := parsed.Symtab[1].Decl.(*ast.Struct)
+	if len(user.Fields) != 3 || !user.Fields[1].Optional || len(user.Fields[2].Type.Args) != 2 {
+		t.Errorf("got struct %+v", user)
+	}
+
+	users := parsed.Symtab[2].Decl.(*ast.Interface)
+	if len(users.Methods) != 2 || len(users.Methods[0].Params) != 1 || users.Methods[1].Result != nil {
+		t.Errorf("got interface %+v", users)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	text := `
+@version(1 + 1)
+const Max: int32 = 10
+
+// Doc of S.
+@table("s") @deprecated
+struct S {
+    @json("x_coord")
+    // Doc of x.
+    x: float
+}
+
+interface I {
+    @http("GET", "/s/{id}")
+    func get(@path id: string) -> S
+}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+
+	c := parsed.File.Nodes[0].(*ast.ConstSpec)
+	if len(c.Annotations) != 1 || c.Annotations[0].Name.Name != "version" || len(c.Annotations[0].Args) != 1 {
+		t.Errorf("got annotations of Max %+v", c.Annotations)
+	}
+	if c.Type == nil || c.Type.Name.Name.Name != "int32" {
+		t.Errorf("got type of Max %+v", c.Type)
+	}
+
+	s := parsed.File.Nodes[1].(*ast.Struct)
+	if len(s.Annotations) != 2 || s.Annotations[1].Args != nil {
+		t.Errorf("got annotations of S %+v", s.Annotations)
+	}
+	if got := s.Doc.Text(); got != "Doc of S." {
+		t.Errorf("got doc of S %q", got)
+	}
+	x := s.Fields[0]
+	if len(x.Annotations) != 1 || x.Doc.Text() != "Doc of x." {
+		t.Errorf("got field %+v", x)
+	}
+
+	get := parsed.File.Nodes[2].(*ast.Interface).Methods[0]
+	if len(get.Annotations) != 1 || len(get.Annotations[0].Args) != 2 {
+		t.Errorf("got annotations of get %+v", get.Annotations)
+	}
+	if p := get.Params[0]; len(p.Annotations) != 1 || p.Annotations[0].Name.Name != "path" {
+		t.Errorf("got parameter %+v", p)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	text := `
+enum Role { guest; admin = 1 + 1 }
+
+enum Empty {}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+	if len(parsed.Symtab) != 2 || parsed.Symtab[0].Type != EnumSym {
+		t.Fatalf("got symbols %+v", parsed.Symtab)
+	}
+
+	role := parsed.Symtab[0].Decl.(*ast.Enum)
+	if len(role.Members) != 2 || role.Members[0].Value != nil || role.Members[1].Value == nil {
+		t.Errorf("got enum %+v", role)
+	}
+	if empty := parsed.Symtab[1].Decl.(*ast.Enum); len(empty.Members) != 0 {
+		t.Errorf("got enum %+v", empty)
+	}
+}
+
+func TestUnions(t *testing.T) {
+	text := `
+union Subject {
+    user: User
+    id: t.Id
+}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+	if len(parsed.Symtab) != 1 || parsed.Symtab[0].Type != UnionSym {
+		t.Fatalf("got symbols %+v", parsed.Symtab)
+	}
+
+	subject := parsed.Symtab[0].Decl.(*ast.Union)
+	if len(subject.Variants) != 2 || subject.Variants[0].Name.Name != "user" || subject.Variants[0].Type.Name.Name.Name != "User" {
+		t.Errorf("got union %+v", subject)
+	}
+	if id := subject.Variants[1].Type.Name; id.Module == nil || id.Module.Name != "t" || id.Name.Name != "Id" {
+		t.Errorf("got variant type %+v", id)
+	}
+}
+
+func TestArrays(t *testing.T) {
+	text := `
+struct S {
+    a: [uint8; 4]
+    b: list[[int32; N * 2]]
+}
+`
+	parsed := Parse([]byte(text))
+	if len(parsed.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostic %q", parsed.Diagnostics[0].Message)
+	}
+
+	s := parsed.File.Nodes[0].(*ast.Struct)
+	a := s.Fields[0].Type
+	if a.Name != nil || a.Elem == nil || a.Elem.Name.Name.Name != "uint8" {
+		t.Errorf("got type %+v", a)
+	}
+	if n, ok := a.Len.(*ast.BasicLit); !ok || n.Value != "4" {
+		t.Errorf("got length %+v", a.Len)
+	}
+
+	b := s.Fields[1].Type.Args[0].(*ast.Type)
+	if b.Elem == nil || b.Elem.Name.Name.Name != "int32" {
+		t.Errorf("got type %+v", b)
+	}
+	if _, ok := b.Len.(*ast.BinaryExpr); !ok {
+		t.Errorf("got length %+v", b.Len)
+	}
+}
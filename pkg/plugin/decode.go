@@ -0,0 +1,240 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/scanner"
+	"larklang.io/lark/pkg/schema"
+)
+
+// Schema rebuilds the schema that a request was made from. The files of
+// the schema have no syntax trees.
+func (req *Request) Schema() (*schema.Schema, error) {
+	if req.Version != Version {
+		return nil, fmt.Errorf("plugin: unsupported request version %d (want %d)", req.Version, Version)
+	}
+
+	d := &decoder{modules: map[string]*schema.File{}}
+	s := &schema.Schema{}
+
+	// Declare everything first: named types may refer to files that come
+	// later in the request when the request was not written by lark.
+	for _, f := range req.Files {
+		if _, ok := d.modules[f.Module]; ok {
+			return nil, fmt.Errorf("plugin: module %q appears twice", f.Module)
+		}
+		file := &schema.File{Path: f.Path, Module: f.Module, Doc: f.Doc}
+		for _, decl := range f.Decls {
+			file.Decls = append(file.Decls, d.declare(file, decl))
+		}
+		d.modules[f.Module] = file
+		s.Files = append(s.Files, file)
+	}
+
+	for i, f := range req.Files {
+		file := s.Files[i]
+		for _, imp := range f.Imports {
+			target, ok := d.modules[imp.Module]
+			if !ok {
+				return nil, fmt.Errorf("plugin: %s imports unknown module %q", f.Module, imp.Module)
+			}
+			file.Imports = append(file.Imports, &schema.Import{Name: imp.Name, File: target})
+		}
+		for j, decl := range f.Decls {
+			if err := d.resolve(file.Decls[j], decl); err != nil {
+				return nil, fmt.Errorf("plugin: %s.%s: %v", f.Module, decl.Name, err)
+			}
+		}
+	}
+
+	for _, module := range req.FilesToGenerate {
+		file, ok := d.modules[module]
+		if !ok {
+			return nil, fmt.Errorf("plugin: unknown module %q to generate", module)
+		}
+		s.Roots = append(s.Roots, file)
+	}
+	return s, nil
+}
+
+type decoder struct {
+	modules map[string]*schema.File
+}
+
+func decodeInfo(file *schema.File, name, doc string, annotations []*Annotation, pos Pos) schema.Info {
+	return schema.Info{
+		Name:        name,
+		Doc:         doc,
+		Annotations: decodeAnnotations(annotations),
+		File:        file,
+		Pos:         decodePos(pos),
+	}
+}
+
+func decodeAnnotations(list []*Annotation) schema.Annotations {
+	var annotations schema.Annotations
+	for _, a := range list {
+		annotations = append(annotations, &schema.Annotation{Name: a.Name, Args: a.Args, Pos: decodePos(a.Pos)})
+	}
+	return annotations
+}
+
+func decodePos(pos Pos) scanner.Pos {
+	return scanner.Pos{Line: pos.Line - 1, Column: pos.Column - 1}
+}
+
+// declare creates a declaration without its types.
+func (d *decoder) declare(file *schema.File, decl *Decl) schema.Decl {
+	info := decodeInfo(file, decl.Name, decl.Doc, decl.Annotations, decl.Pos)
+	switch decl.Kind {
+	case ConstDecl:
+		return &schema.Const{Info: info}
+	case AliasDecl:
+		return &schema.Alias{Info: info}
+	case StructDecl:
+		s := &schema.Struct{Info: info}
+		for _, field := range decl.Fields {
+			s.Fields = append(s.Fields, &schema.Field{
+				Info:     decodeInfo(file, field.Name, field.Doc, field.Annotations, field.Pos),
+				Optional: field.Optional,
+			})
+		}
+		return s
+	case EnumDecl:
+		enum := &schema.Enum{Info: info}
+		for _, member := range decl.Members {
+			enum.Members = append(enum.Members, &schema.EnumMember{
+				Info:  decodeInfo(file, member.Name, member.Doc, member.Annotations, member.Pos),
+				Value: member.Value,
+			})
+		}
+		return enum
+	case UnionDecl:
+		union := &schema.Union{Info: info, Tag: decl.Tag}
+		if union.Tag == "" {
+			union.Tag = schema.DefaultTag
+		}
+		for _, variant := range decl.Variants {
+			union.Variants = append(union.Variants, &schema.Variant{
+				Info: decodeInfo(file, variant.Name, variant.Doc, variant.Annotations, variant.Pos),
+			})
+		}
+		return union
+	case InterfaceDecl:
+		iface := &schema.Interface{Info: info}
+		for _, method := range decl.Methods {
+			m := &schema.Method{Info: decodeInfo(file, method.Name, method.Doc, method.Annotations, method.Pos)}
+			for _, param := range method.Params {
+				m.Params = append(m.Params, &schema.Param{Name: param.Name, Annotations: decodeAnnotations(param.Annotations)})
+			}
+			iface.Methods = append(iface.Methods, m)
+		}
+		return iface
+	}
+	return &unknownDecl{Info: info, kind: decl.Kind}
+}
+
+// unknownDecl stands for a declaration of an unknown kind until resolve
+// reports it.
+type unknownDecl struct {
+	schema.Info
+	kind DeclKind
+}
+
+// resolve sets the types and values of a declaration.
+func (d *decoder) resolve(decl schema.Decl, wire *Decl) error {
+	var err error
+	switch decl := decl.(type) {
+	case *schema.Const:
+		decl.Value = constant.MakeNull()
+		if wire.Value != nil {
+			decl.Value = *wire.Value
+		}
+		if wire.Type != nil {
+			decl.Type, err = d.typ(wire.Type)
+		}
+	case *schema.Alias:
+		decl.Type, err = d.typ(wire.Type)
+	case *schema.Struct:
+		for i, field := range decl.Fields {
+			if field.Type, err = d.typ(wire.Fields[i].Type); err != nil {
+				return fmt.Errorf("field %s: %v", field.Name, err)
+			}
+		}
+	case *schema.Union:
+		for i, variant := range decl.Variants {
+			if variant.Type, err = d.typ(wire.Variants[i].Type); err != nil {
+				return fmt.Errorf("variant %s: %v", variant.Name, err)
+			}
+		}
+	case *schema.Interface:
+		for i, method := range decl.Methods {
+			for j, param := range method.Params {
+				if param.Type, err = d.typ(wire.Methods[i].Params[j].Type); err != nil {
+					return fmt.Errorf("method %s: parameter %s: %v", method.Name, param.Name, err)
+				}
+			}
+			if wire.Methods[i].Result != nil {
+				if method.Result, err = d.typ(wire.Methods[i].Result); err != nil {
+					return fmt.Errorf("method %s: %v", method.Name, err)
+				}
+			}
+		}
+	case *unknownDecl:
+		return fmt.Errorf("unknown declaration kind %q", decl.kind)
+	}
+	return err
+}
+
+func (d *decoder) typ(t *Type) (*schema.Type, error) {
+	if t == nil {
+		return nil, errors.New("missing type")
+	}
+	switch t.Kind {
+	case PrimitiveType:
+		p, ok := schema.LookupPrimitive(t.Primitive)
+		if !ok {
+			return nil, fmt.Errorf("unknown primitive type %q", t.Primitive)
+		}
+		return &schema.Type{Kind: schema.PrimitiveType, Primitive: p}, nil
+	case ListType:
+		elem, err := d.typ(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.ListType, Elem: elem}, nil
+	case MapType:
+		key, err := d.typ(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := d.typ(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.MapType, Key: key, Elem: elem}, nil
+	case ArrayType:
+		if t.Len < 1 {
+			return nil, fmt.Errorf("invalid array length %d", t.Len)
+		}
+		elem, err := d.typ(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.ArrayType, Elem: elem, Len: t.Len}, nil
+	case NamedType:
+		file, ok := d.modules[t.Module]
+		if !ok {
+			return nil, fmt.Errorf("unknown module %q", t.Module)
+		}
+		decl := file.Lookup(t.Name)
+		switch decl.(type) {
+		case *schema.Alias, *schema.Struct, *schema.Enum, *schema.Union:
+			return &schema.Type{Kind: schema.NamedType, Decl: decl}, nil
+		}
+		return nil, fmt.Errorf("%s.%s is not a type", t.Module, t.Name)
+	}
+	return nil, fmt.Errorf("unknown type kind %q", t.Kind)
+}
@@ -0,0 +1,255 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/loader"
+	"larklang.io/lark/pkg/schema"
+)
+
+var files = map[string]string{
+	"common/types.lark": `
+// Max is the limit.
+const Max: int32 = 2 * 8
+const Ratio = 0.5
+const Nothing = null
+
+type Id = uuid
+`,
+	"main.lark": `// Users.
+
+import "common/types" as t
+
+// A user.
+@table("users") @version(2)
+struct User {
+    @pk
+    id: t.Id
+    name?: string
+    tags: map[string, list[Tag]]
+    key: [uint8; 2 * 16]
+}
+
+type Tag = string
+
+// A role.
+enum Role {
+    guest
+    admin = 10
+}
+
+@tag("type")
+union Subject {
+    user: User
+}
+
+interface Users {
+    @http("GET", "/users/{id}")
+    func get(@path id: t.Id) -> User
+    func ping()
+}
+`,
+}
+
+func load(t *testing.T) *schema.Schema {
+	t.Helper()
+	c := &loader.Config{
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := files[filepath.ToSlash(name)]; ok {
+				return []byte(src), nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+	prog, err := c.Load(loader.Source{Path: "main.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := schema.Check(prog)
+	if prog.HasErrors() {
+		t.Fatalf("unexpected diagnostics %v", prog.Diagnostics())
+	}
+	return s
+}
+
+func TestRoundTrip(t *testing.T) {
+	req := NewRequest(load(t), gen.Params{"package": "users"})
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Request
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	s, err := decoded.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Encoding the decoded schema must give the same request.
+	if again := NewRequest(s, decoded.Parameters); !reflect.DeepEqual(again, req) {
+		a, _ := json.MarshalIndent(again, "", "  ")
+		b, _ := json.MarshalIndent(req, "", "  ")
+		t.Errorf("got request\n%s\nwant\n%s", a, b)
+	}
+
+	main := s.Roots[0]
+	types := main.Imports[0].File
+	if main.Module != "main" || main.Imports[0].Name != "t" || types.Module != "common/types" {
+		t.Fatalf("got files %+v", s.Files)
+	}
+	if max := types.Lookup("Max").(*schema.Const); max.Value.String() != "16" || max.Type.Primitive != schema.Int32 || max.Doc != "Max is the limit." {
+		t.Errorf("got Max %+v", max)
+	}
+	if ratio := types.Lookup("Ratio").(*schema.Const); ratio.Value.String() != "0.5" {
+		t.Errorf("got Ratio = %s", ratio.Value)
+	}
+	if nothing := types.Lookup("Nothing").(*schema.Const); nothing.Type != nil || nothing.Value.String() != "null" {
+		t.Errorf("got Nothing = %s of type %s", nothing.Value, nothing.Type)
+	}
+
+	user := main.Lookup("User").(*schema.Struct)
+	if v, ok := user.Annotations.Lookup("version").Int(0); !ok || v != 2 {
+		t.Errorf("got version %d", v)
+	}
+	if id := user.Fields[0]; id.Type.Decl != types.Lookup("Id") || !id.Annotations.Has("pk") {
+		t.Errorf("got field %+v", id)
+	}
+	if got := user.Fields[2].Type.String(); got != "map[string, list[main.Tag]]" {
+		t.Errorf("got type %s", got)
+	}
+	if got := user.Fields[3].Type.String(); got != "[uint8; 32]" {
+		t.Errorf("got type %s", got)
+	}
+	if pos := user.Fields[1].Pos; pos.Line != 9 || pos.Column != 4 {
+		t.Errorf("got position %v of name", pos)
+	}
+
+	role := main.Lookup("Role").(*schema.Enum)
+	if len(role.Members) != 2 || role.Members[1].Name != "admin" || role.Members[1].Value != 10 || role.Doc != "A role." {
+		t.Errorf("got enum %+v", role)
+	}
+
+	subject := main.Lookup("Subject").(*schema.Union)
+	if subject.Tag != "type" || subject.Variants[0].Name != "user" || subject.Variants[0].Type.Decl != user {
+		t.Errorf("got union %+v", subject)
+	}
+
+	get := main.Lookup("Users").(*schema.Interface).Methods[0]
+	if get.Result.Decl != user || get.Params[0].Type.Underlying().Primitive != schema.UUID {
+		t.Errorf("got method %+v", get)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	tests := []struct {
+		req  string
+		want string
+	}{
+		{`{"version":2}`, "unsupported request version 2"},
+		{`{"version":1,"filesToGenerate":["x"]}`, `unknown module "x" to generate`},
+		{`{"version":1,"files":[{"module":"a","imports":[{"name":"b","module":"b"}]}]}`, `a imports unknown module "b"`},
+		{`{"version":1,"files":[{"module":"a"},{"module":"a"}]}`, `module "a" appears twice`},
+		{`{"version":1,"files":[{"module":"a","decls":[{"kind":"class","name":"C"}]}]}`, `a.C: unknown declaration kind "class"`},
+		{`{"version":1,"files":[{"module":"a","decls":[{"kind":"alias","name":"T","type":{"kind":"primitive","primitive":"int128"}}]}]}`, `unknown primitive type "int128"`},
+		{`{"version":1,"files":[{"module":"a","decls":[{"kind":"alias","name":"T","type":{"kind":"named","module":"a","name":"U"}}]}]}`, "a.U is not a type"},
+		{`{"version":1,"files":[{"module":"a","decls":[{"kind":"struct","name":"S","fields":[{"name":"x"}]}]}]}`, "field x: missing type"},
+	}
+	for _, test := range tests {
+		var req Request
+		if err := json.Unmarshal([]byte(test.req), &req); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := req.Schema(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v; want %q", test.req, err, test.want)
+		}
+	}
+}
+
+// listing generates a file that lists the declarations of the root files.
+type listing struct{}
+
+func (listing) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	if params["fail"] != "" {
+		return nil, errors.New(params["fail"])
+	}
+	var buf bytes.Buffer
+	for _, file := range s.Roots {
+		for _, decl := range file.Decls {
+			fmt.Fprintf(&buf, "%s.%s\n", file.Module, decl.DeclInfo().Name)
+		}
+	}
+	return []gen.File{{Name: "decls.txt", Content: buf.Bytes()}}, nil
+}
+
+func TestServe(t *testing.T) {
+	data, err := json.Marshal(NewRequest(load(t), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Serve(bytes.NewReader(data), &out, listing{}); err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := Response{Files: []*OutputFile{{Name: "decls.txt", Content: "main.User\nmain.Tag\nmain.Role\nmain.Subject\nmain.Users\n"}}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got response %+v", resp)
+	}
+
+	if err := Serve(strings.NewReader("{"), &out, listing{}); err == nil {
+		t.Error("got no error for a truncated request")
+	}
+}
+
+// TestMain runs the test binary as the listing plugin when it is started
+// by a Command.
+func TestMain(m *testing.M) {
+	if os.Getenv("LARK_PLUGIN_TEST") != "" {
+		Run(listing{})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCommand(t *testing.T) {
+	t.Setenv("LARK_PLUGIN_TEST", "1")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	cmd := &Command{Path: exe}
+	s := load(t)
+
+	files, err := cmd.Generate(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "decls.txt" || string(files[0].Content) != "main.User\nmain.Tag\nmain.Role\nmain.Subject\nmain.Users\n" {
+		t.Errorf("got files %+v", files)
+	}
+
+	if _, err := cmd.Generate(s, gen.Params{"fail": "no luck"}); err == nil || err.Error() != "no luck" {
+		t.Errorf("got error %v; want the error of the plugin", err)
+	}
+
+	if _, err := Lookup("surely-not-installed"); err == nil {
+		t.Error("got no error for a missing plugin")
+	}
+}
@@ -0,0 +1,341 @@
+// Package plugin implements the protocol between "lark gen" and external
+// code generators.
+//
+// A plugin for the generator name is an executable called lark-gen-name
+// that is found in the PATH. "lark gen -plugin name" checks the input
+// files, writes a [Request] as JSON to the standard input of the plugin
+// and reads a [Response] as JSON from its standard output. The plugin's
+// standard error is passed through. The request carries the schema of all
+// loaded files: declarations with their resolved types, the values of
+// constants, annotations and doc comments.
+//
+// Plugins written in Go implement [gen.Generator] like the generators
+// that are built into lark and call [Run] from their main function:
+//
+//	func main() {
+//		plugin.Run(generator{})
+//	}
+//
+// Run decodes the request back into a [schema.Schema], so the same
+// generator works either way.
+package plugin
+
+import (
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+// Version is the version of the protocol. It changes only when requests
+// change in a way that existing plugins cannot ignore.
+const Version = 1
+
+// A Request is the input of a plugin.
+type Request struct {
+	Version    int               `json:"version"`
+	Parameters map[string]string `json:"parameters"`
+
+	// FilesToGenerate holds the module paths of the files named on the
+	// command line. Code is generated for these files only; the other
+	// files are there because they are imported.
+	FilesToGenerate []string `json:"filesToGenerate"`
+
+	// Files holds all files, each after the files it imports.
+	Files []*File `json:"files"`
+}
+
+// A File is a checked source file.
+type File struct {
+	Path    string    `json:"path"`
+	Module  string    `json:"module"`
+	Doc     string    `json:"doc,omitempty"`
+	Imports []*Import `json:"imports"`
+	Decls   []*Decl   `json:"decls"`
+}
+
+// An Import is an import of a module under a name.
+type Import struct {
+	Name   string `json:"name"`
+	Module string `json:"module"`
+}
+
+// DeclKind is the kind of a declaration: "const", "alias", "struct",
+// "enum", "union" or "interface".
+type DeclKind string
+
+const (
+	ConstDecl     DeclKind = "const"
+	AliasDecl     DeclKind = "alias"
+	StructDecl    DeclKind = "struct"
+	EnumDecl      DeclKind = "enum"
+	UnionDecl     DeclKind = "union"
+	InterfaceDecl DeclKind = "interface"
+)
+
+// A Decl is a declaration. Which of the optional members are set depends
+// on the kind.
+type Decl struct {
+	Kind        DeclKind      `json:"kind"`
+	Name        string        `json:"name"`
+	Doc         string        `json:"doc,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Pos         Pos           `json:"pos"`
+
+	Type     *Type           `json:"type,omitempty"`  // const and alias; absent for null constants
+	Value    *constant.Value `json:"value,omitempty"` // const; absent for null
+	Fields   []*Field        `json:"fields,omitempty"`
+	Members  []*EnumMember   `json:"members,omitempty"`
+	Tag      string          `json:"tag,omitempty"` // union
+	Variants []*Variant      `json:"variants,omitempty"`
+	Methods  []*Method       `json:"methods,omitempty"`
+}
+
+// A Field is a field of a struct.
+type Field struct {
+	Name        string        `json:"name"`
+	Doc         string        `json:"doc,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Pos         Pos           `json:"pos"`
+	Optional    bool          `json:"optional,omitempty"`
+	Type        *Type         `json:"type"`
+}
+
+// An EnumMember is a member of an enum.
+type EnumMember struct {
+	Name        string        `json:"name"`
+	Doc         string        `json:"doc,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Pos         Pos           `json:"pos"`
+	Value       int64         `json:"value"`
+}
+
+// A Variant is a variant of a union.
+type Variant struct {
+	Name        string        `json:"name"`
+	Doc         string        `json:"doc,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Pos         Pos           `json:"pos"`
+	Type        *Type         `json:"type"`
+}
+
+// A Method is a method of an interface.
+type Method struct {
+	Name        string        `json:"name"`
+	Doc         string        `json:"doc,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Pos         Pos           `json:"pos"`
+	Params      []*Param      `json:"params"`
+	Result      *Type         `json:"result,omitempty"`
+}
+
+// A Param is a parameter of a method.
+type Param struct {
+	Name        string        `json:"name"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Type        *Type         `json:"type"`
+}
+
+// An Annotation is an annotation with evaluated arguments. Integers are
+// JSON numbers without a fraction or an exponent; floats always have one.
+type Annotation struct {
+	Name string           `json:"name"`
+	Args []constant.Value `json:"args,omitempty"`
+	Pos  Pos              `json:"pos"`
+}
+
+// TypeKind is the kind of a type: "primitive", "list", "map", "array" or
+// "named".
+type TypeKind string
+
+const (
+	PrimitiveType TypeKind = "primitive"
+	ListType      TypeKind = "list"
+	MapType       TypeKind = "map"
+	ArrayType     TypeKind = "array"
+	NamedType     TypeKind = "named"
+)
+
+// A Type is a reference to a type. Named types refer to a declaration by
+// its name and the module path of its file.
+type Type struct {
+	Kind      TypeKind `json:"kind"`
+	Primitive string   `json:"primitive,omitempty"` // primitive; "int64", "string", ...
+	Key       *Type    `json:"key,omitempty"`       // map
+	Elem      *Type    `json:"elem,omitempty"`      // list, map and array
+	Len       int64    `json:"len,omitempty"`       // array
+	Module    string   `json:"module,omitempty"`    // named
+	Name      string   `json:"name,omitempty"`      // named
+}
+
+// Pos is a one-based position in a file as shown to users.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// A Response is the output of a plugin. If Error is set, the plugin
+// failed and Files is ignored.
+type Response struct {
+	Files []*OutputFile `json:"files"`
+	Error string        `json:"error,omitempty"`
+}
+
+// An OutputFile is a generated file.
+type OutputFile struct {
+	Name    string `json:"name"` // slash-separated path relative to the output directory
+	Content string `json:"content"`
+}
+
+// NewRequest returns the request for generating code from a schema. The
+// schema must have been checked without errors.
+func NewRequest(s *schema.Schema, params gen.Params) *Request {
+	req := &Request{
+		Version:         Version,
+		Parameters:      map[string]string{},
+		FilesToGenerate: []string{},
+		Files:           []*File{},
+	}
+	for name, value := range params {
+		req.Parameters[name] = value
+	}
+	for _, file := range s.Roots {
+		req.FilesToGenerate = append(req.FilesToGenerate, file.Module)
+	}
+	for _, file := range s.Files {
+		req.Files = append(req.Files, encodeFile(file))
+	}
+	return req
+}
+
+func encodeFile(file *schema.File) *File {
+	f := &File{
+		Path:    file.Path,
+		Module:  file.Module,
+		Doc:     file.Doc,
+		Imports: []*Import{},
+		Decls:   []*Decl{},
+	}
+	for _, imp := range file.Imports {
+		f.Imports = append(f.Imports, &Import{Name: imp.Name, Module: imp.File.Module})
+	}
+	for _, decl := range file.Decls {
+		f.Decls = append(f.Decls, encodeDecl(decl))
+	}
+	return f
+}
+
+func encodeDecl(decl schema.Decl) *Decl {
+	info := decl.DeclInfo()
+	d := &Decl{
+		Name:        info.Name,
+		Doc:         info.Doc,
+		Annotations: encodeAnnotations(info.Annotations),
+		Pos:         encodePos(info),
+	}
+	switch decl := decl.(type) {
+	case *schema.Const:
+		d.Kind = ConstDecl
+		d.Type = encodeType(decl.Type)
+		if decl.Value.Kind() != constant.Null {
+			v := decl.Value
+			d.Value = &v
+		}
+	case *schema.Alias:
+		d.Kind = AliasDecl
+		d.Type = encodeType(decl.Type)
+	case *schema.Struct:
+		d.Kind = StructDecl
+		d.Fields = []*Field{}
+		for _, field := range decl.Fields {
+			d.Fields = append(d.Fields, &Field{
+				Name:        field.Name,
+				Doc:         field.Doc,
+				Annotations: encodeAnnotations(field.Annotations),
+				Pos:         encodePos(&field.Info),
+				Optional:    field.Optional,
+				Type:        encodeType(field.Type),
+			})
+		}
+	case *schema.Enum:
+		d.Kind = EnumDecl
+		d.Members = []*EnumMember{}
+		for _, member := range decl.Members {
+			d.Members = append(d.Members, &EnumMember{
+				Name:        member.Name,
+				Doc:         member.Doc,
+				Annotations: encodeAnnotations(member.Annotations),
+				Pos:         encodePos(&member.Info),
+				Value:       member.Value,
+			})
+		}
+	case *schema.Union:
+		d.Kind = UnionDecl
+		d.Tag = decl.Tag
+		d.Variants = []*Variant{}
+		for _, variant := range decl.Variants {
+			d.Variants = append(d.Variants, &Variant{
+				Name:        variant.Name,
+				Doc:         variant.Doc,
+				Annotations: encodeAnnotations(variant.Annotations),
+				Pos:         encodePos(&variant.Info),
+				Type:        encodeType(variant.Type),
+			})
+		}
+	case *schema.Interface:
+		d.Kind = InterfaceDecl
+		d.Methods = []*Method{}
+		for _, method := range decl.Methods {
+			m := &Method{
+				Name:        method.Name,
+				Doc:         method.Doc,
+				Annotations: encodeAnnotations(method.Annotations),
+				Pos:         encodePos(&method.Info),
+				Params:      []*Param{},
+				Result:      encodeType(method.Result),
+			}
+			for _, param := range method.Params {
+				m.Params = append(m.Params, &Param{
+					Name:        param.Name,
+					Annotations: encodeAnnotations(param.Annotations),
+					Type:        encodeType(param.Type),
+				})
+			}
+			d.Methods = append(d.Methods, m)
+		}
+	}
+	return d
+}
+
+func encodeAnnotations(list schema.Annotations) []*Annotation {
+	var annotations []*Annotation
+	for _, a := range list {
+		annotations = append(annotations, &Annotation{
+			Name: a.Name,
+			Args: a.Args,
+			Pos:  Pos{Line: a.Pos.Line + 1, Column: a.Pos.Column + 1},
+		})
+	}
+	return annotations
+}
+
+func encodePos(info *schema.Info) Pos {
+	return Pos{Line: info.Pos.Line + 1, Column: info.Pos.Column + 1}
+}
+
+func encodeType(t *schema.Type) *Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind {
+	case schema.PrimitiveType:
+		return &Type{Kind: PrimitiveType, Primitive: t.Primitive.String()}
+	case schema.ListType:
+		return &Type{Kind: ListType, Elem: encodeType(t.Elem)}
+	case schema.MapType:
+		return &Type{Kind: MapType, Key: encodeType(t.Key), Elem: encodeType(t.Elem)}
+	case schema.ArrayType:
+		return &Type{Kind: ArrayType, Elem: encodeType(t.Elem), Len: t.Len}
+	}
+	info := t.Decl.DeclInfo()
+	return &Type{Kind: NamedType, Module: info.File.Module, Name: info.Name}
+}
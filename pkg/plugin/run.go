@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"larklang.io/lark/pkg/gen"
+	"larklang.io/lark/pkg/schema"
+)
+
+// Prefix is the prefix of the executable names of plugins.
+const Prefix = "lark-gen-"
+
+// Run runs g as a plugin: it reads a request from the standard input and
+// writes the response to the standard output. Errors of the generator are
+// sent to lark in the response; Run exits with status 1 only if the
+// request cannot be read or the response cannot be written.
+func Run(g gen.Generator) {
+	if err := Serve(os.Stdin, os.Stdout, g); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+// Serve reads a request from r, runs g and writes the response to w.
+func Serve(r io.Reader, w io.Writer, g gen.Generator) error {
+	var req Request
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return fmt.Errorf("reading request: %v", err)
+	}
+
+	resp := &Response{Files: []*OutputFile{}}
+	s, err := req.Schema()
+	if err == nil {
+		var files []gen.File
+		files, err = g.Generate(s, req.Parameters)
+		for _, file := range files {
+			resp.Files = append(resp.Files, &OutputFile{Name: file.Name, Content: string(file.Content)})
+		}
+	}
+	if err != nil {
+		resp.Files = []*OutputFile{}
+		resp.Error = err.Error()
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return fmt.Errorf("writing response: %v", err)
+	}
+	return nil
+}
+
+// Command is a generator that runs a plugin executable.
+type Command struct {
+	Path string // path of the executable
+}
+
+// Lookup returns the command for the plugin called name, which must be in
+// the PATH.
+func Lookup(name string) (*Command, error) {
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %v", name, err)
+	}
+	return &Command{Path: path}, nil
+}
+
+// Generate sends the request for s to the plugin and returns the files in
+// its response.
+func (c *Command) Generate(s *schema.Schema, params gen.Params) ([]gen.File, error) {
+	data, err := json.Marshal(NewRequest(s, params))
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(c.Path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.Path, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %v", c.Path, err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	files := make([]gen.File, 0, len(resp.Files))
+	for _, file := range resp.Files {
+		files = append(files, gen.File{Name: file.Name, Content: []byte(file.Content)})
+	}
+	return files, nil
+}
@@ -4,13 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"unicode/utf8"
+
+	"larklang.io/lark/pkg/diag"
 )
 
 // An ErrorHandler may be provided to [Scanner.New]. If a syntax error is
 // encountered and a handler was installed, the handler is called with a
-// position and an error message. The position points to the beginning of
-// the offending token.
-type ErrorHandler func(pos Pos, msg string)
+// diagnostic describing it. The diagnostic range starts at the beginning of
+// the offending token or escape sequence.
+type ErrorHandler = diag.Handler
 
 type Scanner struct {
 	text       []byte        // source text
@@ -35,8 +37,8 @@ func New(text []byte, errHandler ErrorHandler) *Scanner {
 		text,
 		0,
 		endmarker,
-		Pos{0, 0},
-		Pos{0, 0},
+		Pos{Line: 0, Column: 0},
+		Pos{Line: 0, Column: 0},
 		bytes.NewBuffer(nil),
 		errHandler,
 		bytes.NewBuffer(nil),
@@ -68,14 +70,14 @@ func (s *Scanner) load() {
 		r, w := rune(s.text[s.rdoffset]), 1
 		switch {
 		case r == 0:
-			s.err(s.end, "illegal character NUL")
+			s.err(diag.IllegalCharacter, diag.Span(s.end, 1), "illegal character NUL")
 		case r >= utf8.RuneSelf:
 			// not ASCII
 			r, w = utf8.DecodeRune(s.text[s.rdoffset:])
 			if r == utf8.RuneError && w == 1 {
-				s.err(s.end, "illegal UTF-8 encoding")
+				s.err(diag.InvalidEncoding, diag.Span(s.end, 1), "illegal UTF-8 encoding")
 			} else if r == bom && s.rdoffset > 0 {
-				s.err(s.pos, "illegal byte order mark")
+				s.err(diag.IllegalByteOrderMark, diag.Span(s.end, 1), "illegal byte order mark")
 			}
 		}
 
@@ -100,24 +102,32 @@ func (s *Scanner) next() {
 	case '\n':
 		s.lines = append(s.lines, s.line.String())
 		s.line.Reset()
-		s.end = Pos{s.end.Line + 1, 0}
+		s.end = Pos{Line: s.end.Line + 1, Column: 0}
 	default:
 		s.line.WriteRune(s.current)
-		s.end = Pos{s.end.Line, s.end.Column + 1}
+		s.end = Pos{Line: s.end.Line, Column: s.end.Column + 1}
 	}
 
 	s.val.WriteRune(s.current)
 	s.load()
 }
 
-func (s *Scanner) err(pos Pos, msg string) {
+func (s *Scanner) err(code diag.Code, rng diag.Range, msg string) {
 	if s.errHandler != nil {
-		s.errHandler(pos, msg)
+		s.errHandler(diag.Diagnostic{Severity: diag.Error, Code: code, Range: rng, Message: msg})
 	}
 }
 
-func (s *Scanner) errf(pos Pos, format string, args ...any) {
-	s.err(pos, fmt.Sprintf(format, args...))
+func (s *Scanner) errf(code diag.Code, rng diag.Range, format string, args ...any) {
+	s.err(code, rng, fmt.Sprintf(format, args...))
+}
+
+// scanned returns the range from pos to the current scanning position.
+func (s *Scanner) scanned(pos Pos) diag.Range {
+	if s.end.Greater(pos) {
+		return diag.Range{Start: pos, End: s.end}
+	}
+	return diag.At(pos)
 }
 
 func (s *Scanner) makeToken(kind TokenKind) Token {
@@ -131,7 +141,7 @@ func (s *Scanner) makeToken(kind TokenKind) Token {
 		value = s.val.String()
 	}
 
-	return Token{kind, s.pos, value}
+	return Token{kind, s.pos, s.end, value}
 }
 
 func (s *Scanner) skipWhitespace() {
@@ -185,6 +195,7 @@ var keywords = map[string]TokenKind{
 	"as":        AS,
 	"const":     CONST,
 	"embed":     EMBED,
+	"enum":      ENUM,
 	"false":     FALSE,
 	"import":    IMPORT,
 	"null":      NULL,
@@ -192,6 +203,7 @@ var keywords = map[string]TokenKind{
 	"struct":    STRUCT,
 	"true":      TRUE,
 	"type":      TYPE,
+	"union":     UNION,
 	"func":      FUNC,
 }
 
@@ -293,13 +305,13 @@ func (s *Scanner) scanNumber() Token {
 		if f := s.digits(base, true); f&invalidDigitSep != 0 {
 			flags |= invalidDigitSep
 		} else if f&noDigits != 0 && base != decimal {
-			s.errf(s.pos, "%s literal has no digits", litname(base))
+			s.errf(diag.MissingDigits, s.scanned(s.pos), "%s literal has no digits", litname(base))
 		}
 	}
 
 	if s.current == '.' {
 		if base != decimal {
-			s.errf(s.end, "invalid radix point in %s literal", litname(base))
+			s.errf(diag.InvalidRadixPoint, diag.Span(s.end, 1), "invalid radix point in %s literal", litname(base))
 		}
 		s.next()
 		kind = FLOAT
@@ -318,16 +330,16 @@ func (s *Scanner) scanNumber() Token {
 		if f := s.digits(base, false); f&invalidDigitSep != 0 {
 			flags |= invalidDigitSep
 		} else if f&noDigits != 0 {
-			s.err(s.pos, "exponent has no digits")
+			s.err(diag.MissingExponent, s.scanned(s.pos), "exponent has no digits")
 		}
 	}
 
 	if flags&leadingZero != 0 && kind == INTEGER {
-		s.err(s.pos, "leading zeros in decimal integer literals are not permitted")
+		s.err(diag.LeadingZeros, s.scanned(s.pos), "leading zeros in decimal integer literals are not permitted")
 	}
 
 	if flags&invalidDigitSep != 0 {
-		s.err(s.pos, "'_' must separate successive digits")
+		s.err(diag.InvalidDigitSeparator, s.scanned(s.pos), "'_' must separate successive digits")
 	}
 
 	return s.makeToken(kind)
@@ -348,7 +360,7 @@ func (s *Scanner) escape() {
 	case 'U':
 		n, max = 8, utf8.MaxRune
 	default:
-		s.err(pos, "unknown escape sequence")
+		s.err(diag.UnknownEscape, s.scanned(pos), "unknown escape sequence")
 		return
 	}
 
@@ -358,7 +370,7 @@ func (s *Scanner) escape() {
 		s.next()
 		d := digitValue(current)
 		if d == 16 {
-			s.errf(pos, "illegal hexadecimal digit %#U in escape sequence", current)
+			s.errf(diag.InvalidEscapeDigit, s.scanned(pos), "illegal hexadecimal digit %#U in escape sequence", current)
 			return
 		}
 
@@ -366,7 +378,7 @@ func (s *Scanner) escape() {
 	}
 
 	if x > max || x >= 0xD800 && x < 0xE000 {
-		s.err(pos, "escape sequence is invalid unicode code point")
+		s.err(diag.InvalidCodePoint, s.scanned(pos), "escape sequence is invalid unicode code point")
 	}
 }
 
@@ -382,7 +394,7 @@ func (s *Scanner) scanString() Token {
 	if s.current == '"' {
 		s.next()
 	} else {
-		s.err(s.pos, "unterminated string")
+		s.err(diag.UnterminatedString, s.scanned(s.pos), "unterminated string")
 	}
 
 	return s.makeToken(STRING)
@@ -466,7 +478,7 @@ func (s *Scanner) Scan() Token {
 		}
 	}
 
-	s.err(s.pos, fmt.Sprintf("illegal character %#U", current))
+	s.errf(diag.IllegalCharacter, s.scanned(s.pos), "illegal character %#U", current)
 
 	return s.makeToken(ILLEGAL)
 }
@@ -3,6 +3,8 @@ package scanner
 import (
 	"strings"
 	"testing"
+
+	"larklang.io/lark/pkg/diag"
 )
 
 func TestNonLiteral(t *testing.T) {
@@ -55,8 +57,8 @@ func TestNonLiteral(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		s := New([]byte(test.input), func(pos Pos, msg string) {
-			t.Errorf("%q: got error %q", test.input, msg)
+		s := New([]byte(test.input), func(d diag.Diagnostic) {
+			t.Errorf("%q: got error %q", test.input, d.Message)
 		})
 
 		token := s.Scan()
@@ -74,8 +76,8 @@ func TestIdentifier(t *testing.T) {
 	}
 
 	for _, input := range tests {
-		s := New([]byte(input), func(pos Pos, msg string) {
-			t.Errorf("%q: got error %q", input, msg)
+		s := New([]byte(input), func(d diag.Diagnostic) {
+			t.Errorf("%q: got error %q", input, d.Message)
 		})
 
 		token := s.Scan()
@@ -116,9 +118,9 @@ func TestString(t *testing.T) {
 
 	for _, test := range tests {
 		errMsg := ""
-		s := New([]byte(test.input), func(pos Pos, msg string) {
+		s := New([]byte(test.input), func(d diag.Diagnostic) {
 			if errMsg == "" {
-				errMsg = msg
+				errMsg = d.Message
 			}
 		})
 
@@ -245,9 +247,9 @@ func TestNumber(t *testing.T) {
 
 	for _, test := range tests {
 		errMsg := ""
-		s := New([]byte(test.input), func(pos Pos, msg string) {
+		s := New([]byte(test.input), func(d diag.Diagnostic) {
 			if errMsg == "" {
-				errMsg = msg
+				errMsg = d.Message
 			}
 		})
 
@@ -268,3 +270,41 @@ func TestNumber(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorCode(t *testing.T) {
+	type testCase struct {
+		input string
+		code  diag.Code
+		rng   diag.Range
+	}
+
+	span := func(from, to int) diag.Range {
+		return diag.Range{Start: Pos{Line: 0, Column: from}, End: Pos{Line: 0, Column: to}}
+	}
+
+	tests := []testCase{
+		{"$", diag.IllegalCharacter, span(0, 1)},
+		{"0x", diag.MissingDigits, span(0, 2)},
+		{"0123", diag.LeadingZeros, span(0, 4)},
+		{"1__0", diag.InvalidDigitSeparator, span(0, 4)},
+		{`"a\q"`, diag.UnknownEscape, span(3, 4)},
+		{`"foo`, diag.UnterminatedString, span(0, 4)},
+	}
+
+	for _, test := range tests {
+		var got []diag.Diagnostic
+		s := New([]byte(test.input), func(d diag.Diagnostic) {
+			got = append(got, d)
+		})
+		s.Scan()
+
+		if len(got) != 1 {
+			t.Errorf("%q: got %d diagnostics; want 1", test.input, len(got))
+			continue
+		}
+		if got[0].Code != test.code || got[0].Range != test.rng || got[0].Severity != diag.Error {
+			t.Errorf("%q: got %s %s %v; want error %s %v", test.input,
+				got[0].Severity, got[0].Code, got[0].Range, test.code, test.rng)
+		}
+	}
+}
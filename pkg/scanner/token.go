@@ -1,6 +1,11 @@
 package scanner
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+
+	"larklang.io/lark/pkg/diag"
+)
 
 type TokenKind int
 
@@ -48,9 +53,11 @@ const (
 	FLOAT
 	literal_end
 
+	keyword_beg
 	AS
 	CONST
 	EMBED
+	ENUM
 	FALSE
 	IMPORT
 	INTERFACE
@@ -58,7 +65,9 @@ const (
 	STRUCT
 	TRUE
 	TYPE
+	UNION
 	FUNC
+	keyword_end
 )
 
 var tokens = [...]string{
@@ -105,6 +114,7 @@ var tokens = [...]string{
 	AS:        "as",
 	CONST:     "const",
 	EMBED:     "embed",
+	ENUM:      "enum",
 	FALSE:     "false",
 	IMPORT:    "import",
 	INTERFACE: "interface",
@@ -112,6 +122,7 @@ var tokens = [...]string{
 	STRUCT:    "struct",
 	TRUE:      "true",
 	TYPE:      "type",
+	UNION:     "union",
 	FUNC:      "func",
 }
 
@@ -129,16 +140,36 @@ func (kind TokenKind) IsLiteral() bool {
 	return literal_beg < kind && kind < literal_end
 }
 
-type Pos struct {
-	Line, Column int
+// IsKeyword returns true for kinds corresponding to keywords; it returns
+// false otherwise.
+func (kind TokenKind) IsKeyword() bool {
+	return keyword_beg < kind && kind < keyword_end
 }
 
-func (p Pos) Greater(other Pos) bool {
-	return p.Line > other.Line || p.Line == other.Line && p.Column > other.Column
+// MarshalText encodes kind as its string representation.
+func (kind TokenKind) MarshalText() ([]byte, error) {
+	if 0 <= kind && kind < TokenKind(len(tokens)) && tokens[kind] != "" {
+		return []byte(tokens[kind]), nil
+	}
+	return nil, fmt.Errorf("invalid token kind %d", int(kind))
 }
 
+// UnmarshalText decodes a kind from its string representation.
+func (kind *TokenKind) UnmarshalText(text []byte) error {
+	for k, name := range tokens {
+		if name != "" && name == string(text) {
+			*kind = TokenKind(k)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown token kind %q", text)
+}
+
+type Pos = diag.Pos
+
 type Token struct {
 	Kind  TokenKind
 	Pos   Pos
+	End   Pos // position immediately after the token
 	Value string
 }
@@ -0,0 +1,843 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/diag"
+	"larklang.io/lark/pkg/loader"
+)
+
+// state is the evaluation state of a constant.
+type state int
+
+const (
+	unevaluated state = iota
+	evaluating
+	evaluated
+)
+
+type checker struct {
+	files   map[*loader.File]*File
+	sources map[*File]*loader.File
+	nodes   map[Decl]ast.Node
+	state   map[*Const]state
+}
+
+// Check builds the schema of a loaded program. Problems are added to the
+// diagnostics of the files. The schema is complete only if the program has
+// no errors afterwards; otherwise types and values may be missing.
+func Check(prog *loader.Program) *Schema {
+	c := &checker{
+		files:   map[*loader.File]*File{},
+		sources: map[*File]*loader.File{},
+		nodes:   map[Decl]ast.Node{},
+		state:   map[*Const]state{},
+	}
+
+	s := &Schema{}
+	for _, src := range prog.Files {
+		file := c.declare(src)
+		s.Files = append(s.Files, file)
+	}
+	for _, src := range prog.Roots {
+		s.Roots = append(s.Roots, c.files[src])
+	}
+
+	for _, file := range s.Files {
+		for _, imp := range c.sources[file].Imports {
+			if imp.File != nil {
+				file.Imports = append(file.Imports, &Import{Name: imp.Name, File: c.files[imp.File]})
+			}
+		}
+	}
+	for _, file := range s.Files {
+		c.resolve(file)
+	}
+	for _, file := range s.Files {
+		c.checkAliases(file)
+	}
+	for _, file := range s.Files {
+		c.checkStructs(file)
+	}
+	for _, file := range s.Files {
+		c.checkUnions(file)
+	}
+	return s
+}
+
+func (c *checker) report(file *File, code diag.Code, rng diag.Range, format string, args ...any) {
+	src := c.sources[file]
+	src.Diagnostics = append(src.Diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     code,
+		Range:    rng,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func nameRange(name *ast.Name) diag.Range {
+	return diag.Span(name.Pos(), utf8.RuneCountInString(name.Name))
+}
+
+func qualRange(name *ast.QualName) diag.Range {
+	rng := nameRange(name.Name)
+	rng.Start = name.Pos()
+	return rng
+}
+
+// typeRange returns the range of a named type or the position of an
+// array type.
+func typeRange(node *ast.Type) diag.Range {
+	if node.Name == nil {
+		return diag.At(node.Pos())
+	}
+	return qualRange(node.Name)
+}
+
+// valid reports whether name was parsed without errors.
+func valid(name *ast.Name) bool {
+	return name != nil && name.Name != "@"
+}
+
+func docOf(node ast.Node) *ast.CommentGroup {
+	switch n := node.(type) {
+	case *ast.ImportSpec:
+		return n.Doc
+	case *ast.ConstSpec:
+		return n.Doc
+	case *ast.TypeAlias:
+		return n.Doc
+	case *ast.Struct:
+		return n.Doc
+	case *ast.Enum:
+		return n.Doc
+	case *ast.Union:
+		return n.Doc
+	case *ast.Interface:
+		return n.Doc
+	}
+	return nil
+}
+
+// fileDoc returns the comment that starts a file unless it documents the
+// first declaration.
+func fileDoc(file *ast.File) string {
+	if len(file.Comments) == 0 || file.Comments[0].Trailing {
+		return ""
+	}
+	group := file.Comments[0]
+	if len(file.Nodes) > 0 && (docOf(file.Nodes[0]) == group || file.Nodes[0].Pos().Line < group.Pos().Line) {
+		return ""
+	}
+	return group.Text()
+}
+
+// scope checks that names are declared once.
+type scope map[string]*ast.Name
+
+func (c *checker) declareName(file *File, s scope, name *ast.Name, what string) bool {
+	if !valid(name) {
+		return false
+	}
+	if prev, ok := s[name.Name]; ok {
+		src := c.sources[file]
+		src.Diagnostics = append(src.Diagnostics, diag.Diagnostic{
+			Severity: diag.Error,
+			Code:     diag.Redeclared,
+			Range:    nameRange(name),
+			Message:  fmt.Sprintf("%s %s redeclared", what, name.Name),
+			Related:  []diag.Related{{Range: nameRange(prev), Label: "previous declaration"}},
+		})
+		return false
+	}
+	s[name.Name] = name
+	return true
+}
+
+func info(file *File, name *ast.Name, doc *ast.CommentGroup) Info {
+	return Info{Name: name.Name, Doc: doc.Text(), File: file, Pos: name.Pos()}
+}
+
+// declare creates the file and its declarations without resolving types
+// and values.
+func (c *checker) declare(src *loader.File) *File {
+	file := &File{Path: src.Path, Module: src.Module, Doc: fileDoc(src.File), AST: src.File}
+	c.files[src] = file
+	c.sources[file] = src
+
+	decls := scope{}
+	for _, node := range src.File.Nodes {
+		var decl Decl
+		switch n := node.(type) {
+		case *ast.ConstSpec:
+			if c.declareName(file, decls, n.Name, "constant") {
+				decl = &Const{Info: info(file, n.Name, n.Doc)}
+			}
+		case *ast.TypeAlias:
+			if c.declareName(file, decls, n.Name, "type") {
+				decl = &Alias{Info: info(file, n.Name, n.Doc)}
+			}
+		case *ast.Struct:
+			if c.declareName(file, decls, n.Name, "struct") {
+				decl = c.declareStruct(file, n)
+			}
+		case *ast.Enum:
+			if c.declareName(file, decls, n.Name, "enum") {
+				decl = c.declareEnum(file, n)
+			}
+		case *ast.Union:
+			if c.declareName(file, decls, n.Name, "union") {
+				decl = c.declareUnion(file, n)
+			}
+		case *ast.Interface:
+			if c.declareName(file, decls, n.Name, "interface") {
+				decl = c.declareInterface(file, n)
+			}
+		}
+
+		if decl != nil {
+			c.nodes[decl] = node
+			file.Decls = append(file.Decls, decl)
+		}
+	}
+	return file
+}
+
+func (c *checker) declareStruct(file *File, node *ast.Struct) *Struct {
+	decl := &Struct{Info: info(file, node.Name, node.Doc)}
+	fields := scope{}
+	for _, field := range node.Fields {
+		if c.declareName(file, fields, field.Name, "field") {
+			decl.Fields = append(decl.Fields, &Field{Info: info(file, field.Name, field.Doc), Optional: field.Optional})
+		}
+	}
+	return decl
+}
+
+func (c *checker) declareEnum(file *File, node *ast.Enum) *Enum {
+	decl := &Enum{Info: info(file, node.Name, node.Doc)}
+	members := scope{}
+	for _, member := range node.Members {
+		if c.declareName(file, members, member.Name, "enum member") {
+			decl.Members = append(decl.Members, &EnumMember{Info: info(file, member.Name, member.Doc)})
+		}
+	}
+	return decl
+}
+
+func (c *checker) declareUnion(file *File, node *ast.Union) *Union {
+	decl := &Union{Info: info(file, node.Name, node.Doc)}
+	variants := scope{}
+	for _, variant := range node.Variants {
+		if c.declareName(file, variants, variant.Name, "union variant") {
+			decl.Variants = append(decl.Variants, &Variant{Info: info(file, variant.Name, variant.Doc)})
+		}
+	}
+	return decl
+}
+
+func (c *checker) declareInterface(file *File, node *ast.Interface) *Interface {
+	decl := &Interface{Info: info(file, node.Name, node.Doc)}
+	methods := scope{}
+	for _, method := range node.Methods {
+		if !c.declareName(file, methods, method.Name, "method") {
+			continue
+		}
+		m := &Method{Info: info(file, method.Name, method.Doc)}
+		params := scope{}
+		for _, param := range method.Params {
+			if c.declareName(file, params, param.Name, "parameter") {
+				m.Params = append(m.Params, &Param{Name: param.Name.Name})
+			}
+		}
+		decl.Methods = append(decl.Methods, m)
+	}
+	return decl
+}
+
+// resolve resolves the types, constants and annotations of a file.
+func (c *checker) resolve(file *File) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *Const:
+			node := c.nodes[decl].(*ast.ConstSpec)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			c.constant(decl)
+
+		case *Alias:
+			node := c.nodes[decl].(*ast.TypeAlias)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			decl.Type = c.typ(file, node.Type)
+
+		case *Struct:
+			node := c.nodes[decl].(*ast.Struct)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			for _, field := range decl.Fields {
+				n := structField(node, field.Name)
+				field.Annotations = c.annotations(file, n.Annotations)
+				field.Type = c.typ(file, n.Type)
+			}
+
+		case *Enum:
+			node := c.nodes[decl].(*ast.Enum)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			c.enumValues(file, decl, node)
+
+		case *Union:
+			node := c.nodes[decl].(*ast.Union)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			decl.Tag = DefaultTag
+			if a := decl.Annotations.Lookup("tag"); a != nil {
+				if tag, ok := a.String(0); ok && tag != "" && len(a.Args) == 1 {
+					decl.Tag = tag
+				} else {
+					c.report(file, diag.InvalidUnion, diag.At(a.Pos), "@tag takes one non-empty string")
+				}
+			}
+			for _, variant := range decl.Variants {
+				n := unionVariant(node, variant.Name)
+				variant.Annotations = c.annotations(file, n.Annotations)
+				variant.Type = c.typ(file, n.Type)
+			}
+
+		case *Interface:
+			node := c.nodes[decl].(*ast.Interface)
+			decl.Annotations = c.annotations(file, node.Annotations)
+			for _, method := range decl.Methods {
+				n := interfaceMethod(node, method.Name)
+				method.Annotations = c.annotations(file, n.Annotations)
+				for _, param := range method.Params {
+					p := methodParam(n, param.Name)
+					param.Annotations = c.annotations(file, p.Annotations)
+					param.Type = c.typ(file, p.Type)
+				}
+				if n.Result != nil {
+					method.Result = c.typ(file, n.Result)
+				}
+			}
+		}
+	}
+}
+
+// enumValues evaluates the values of the members of an enum.
+func (c *checker) enumValues(file *File, decl *Enum, node *ast.Enum) {
+	used := map[int64]*EnumMember{}
+	next := int64(0)
+	for _, member := range decl.Members {
+		n := enumMember(node, member.Name)
+		member.Annotations = c.annotations(file, n.Annotations)
+		member.Value = next
+		if n.Value != nil {
+			v := c.eval(file, n.Value)
+			if !v.IsKnown() {
+				next = member.Value + 1
+				continue
+			}
+			i, ok := v.Int64()
+			if v.Kind() != constant.Int || !ok || i < math.MinInt32 || i > math.MaxInt32 {
+				c.report(file, diag.InvalidEnum, diag.At(n.Value.Pos()), "enum value %s is not an int32", v)
+				next = member.Value + 1
+				continue
+			}
+			member.Value = i
+		} else if next > math.MaxInt32 {
+			c.report(file, diag.InvalidEnum, nameRange(n.Name), "enum value %d of %s overflows int32", next, member.Name)
+		}
+
+		if prev, ok := used[member.Value]; ok {
+			c.report(file, diag.InvalidEnum, nameRange(n.Name), "enum value %d of %s is already used by %s", member.Value, member.Name, prev.Name)
+		} else {
+			used[member.Value] = member
+		}
+		next = member.Value + 1
+	}
+}
+
+func enumMember(node *ast.Enum, name string) *ast.EnumMember {
+	for _, member := range node.Members {
+		if member.Name.Name == name {
+			return member
+		}
+	}
+	return nil
+}
+
+func unionVariant(node *ast.Union, name string) *ast.Variant {
+	for _, variant := range node.Variants {
+		if variant.Name.Name == name {
+			return variant
+		}
+	}
+	return nil
+}
+
+func structField(node *ast.Struct, name string) *ast.Field {
+	for _, field := range node.Fields {
+		if field.Name.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func interfaceMethod(node *ast.Interface, name string) *ast.Method {
+	for _, method := range node.Methods {
+		if method.Name.Name == name {
+			return method
+		}
+	}
+	return nil
+}
+
+func methodParam(node *ast.Method, name string) *ast.Param {
+	for _, param := range node.Params {
+		if param.Name.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+// lookup returns the declaration a qualified name refers to. It reports
+// undefined names unless builtin reports that an unqualified name is
+// predeclared.
+func (c *checker) lookup(file *File, name *ast.QualName, builtin func(string) bool) (Decl, bool) {
+	if !valid(name.Name) || name.Module != nil && !valid(name.Module) {
+		return nil, false
+	}
+
+	if name.Module == nil {
+		if decl := file.Lookup(name.Name.Name); decl != nil {
+			return decl, true
+		}
+		if builtin(name.Name.Name) {
+			return nil, true
+		}
+		c.report(file, diag.UndefinedName, qualRange(name), "undefined: %s", name.Name.Name)
+		return nil, false
+	}
+
+	src := c.sources[file].Lookup(name.Module.Name)
+	if src == nil {
+		c.report(file, diag.UndefinedName, nameRange(name.Module), "undefined: %s", name.Module.Name)
+		return nil, false
+	}
+	if src.File == nil {
+		return nil, false // reported by the loader
+	}
+	if decl := c.files[src.File].Lookup(name.Name.Name); decl != nil {
+		return decl, true
+	}
+	c.report(file, diag.UndefinedName, qualRange(name), "undefined: %s.%s", name.Module.Name, name.Name.Name)
+	return nil, false
+}
+
+func isBuiltinType(name string) bool {
+	_, ok := LookupPrimitive(name)
+	return ok || name == "list" || name == "map"
+}
+
+// typ resolves a type. It returns nil after an error.
+func (c *checker) typ(file *File, node *ast.Type) *Type {
+	if node == nil {
+		return nil
+	}
+	if node.Name == nil {
+		elem := c.typ(file, node.Elem)
+		n, ok := c.arrayLen(file, node.Len)
+		if elem == nil || !ok {
+			return nil
+		}
+		return &Type{Kind: ArrayType, Elem: elem, Len: n}
+	}
+	decl, ok := c.lookup(file, node.Name, isBuiltinType)
+	if !ok {
+		return nil
+	}
+
+	name := node.Name.Name.Name
+	args := c.typeArgs(file, node)
+	if decl == nil {
+		switch name {
+		case "list":
+			if !c.arity(file, node, len(args), 1) || args[0] == nil {
+				return nil
+			}
+			return &Type{Kind: ListType, Elem: args[0]}
+		case "map":
+			if !c.arity(file, node, len(args), 2) || args[0] == nil || args[1] == nil {
+				return nil
+			}
+			if !validKey(args[0]) {
+				c.report(file, diag.InvalidTypeArgs, typeRange(node.Args[0].(*ast.Type)), "invalid map key type %s", args[0])
+				return nil
+			}
+			return &Type{Kind: MapType, Key: args[0], Elem: args[1]}
+		}
+		if !c.arity(file, node, len(args), 0) {
+			return nil
+		}
+		p, _ := LookupPrimitive(name)
+		return &Type{Kind: PrimitiveType, Primitive: p}
+	}
+
+	switch decl.(type) {
+	case *Const:
+		c.report(file, diag.NotAType, qualRange(node.Name), "%s is a constant, not a type", name)
+		return nil
+	case *Interface:
+		c.report(file, diag.NotAType, qualRange(node.Name), "interface %s cannot be used as a data type", name)
+		return nil
+	}
+	if !c.arity(file, node, len(args), 0) {
+		return nil
+	}
+	return &Type{Kind: NamedType, Decl: decl}
+}
+
+// arrayLen evaluates the length of an array type.
+func (c *checker) arrayLen(file *File, node ast.Node) (int64, bool) {
+	v := c.eval(file, node)
+	if !v.IsKnown() {
+		return 0, false
+	}
+	n, ok := v.Int64()
+	if v.Kind() != constant.Int || !ok || n < 1 || n > math.MaxInt32 {
+		c.report(file, diag.InvalidArrayLen, diag.At(node.Pos()), "array length %s is not a positive int32", v)
+		return 0, false
+	}
+	return n, true
+}
+
+// validKey reports whether t can be the key type of a map: a primitive type
+// or an enum.
+func validKey(t *Type) bool {
+	u := t.Underlying()
+	if u == nil {
+		return false
+	}
+	if u.Kind == NamedType {
+		_, ok := u.Decl.(*Enum)
+		return ok
+	}
+	return u.Kind == PrimitiveType
+}
+
+func (c *checker) typeArgs(file *File, node *ast.Type) []*Type {
+	var args []*Type
+	for _, arg := range node.Args {
+		if t, ok := arg.(*ast.Type); ok {
+			args = append(args, c.typ(file, t))
+		} else {
+			args = append(args, nil)
+		}
+	}
+	return args
+}
+
+// arity reports an error unless a type has the expected number of type
+// arguments.
+func (c *checker) arity(file *File, node *ast.Type, got, want int) bool {
+	if got == want {
+		return true
+	}
+	name := node.Name.Name.Name
+	switch want {
+	case 0:
+		c.report(file, diag.InvalidTypeArgs, qualRange(node.Name), "%s takes no type arguments", name)
+	case 1:
+		c.report(file, diag.InvalidTypeArgs, qualRange(node.Name), "%s takes 1 type argument, got %d", name, got)
+	default:
+		c.report(file, diag.InvalidTypeArgs, qualRange(node.Name), "%s takes %d type arguments, got %d", name, want, got)
+	}
+	return false
+}
+
+// checkAliases reports aliases that refer to themselves without a list or
+// a map in between and breaks the cycles. Arrays contain their elements,
+// so they do not break cycles. Since imports cannot form cycles, neither
+// can aliases of different files.
+func (c *checker) checkAliases(file *File) {
+	var cyclic []*Alias
+	for _, decl := range file.Decls {
+		alias, ok := decl.(*Alias)
+		if !ok {
+			continue
+		}
+
+		seen := map[*Alias]bool{alias: true}
+		for t := alias.Type; t != nil && (t.Kind == NamedType || t.Kind == ArrayType); {
+			if t.Kind == ArrayType {
+				t = t.Elem
+				continue
+			}
+			next, ok := t.Decl.(*Alias)
+			if !ok {
+				break
+			}
+			if next == alias {
+				node := c.nodes[alias].(*ast.TypeAlias)
+				c.report(file, diag.DeclarationCycle, nameRange(node.Name), "invalid recursive type alias %s", alias.Name)
+				cyclic = append(cyclic, alias)
+				break
+			}
+			if seen[next] {
+				break // a cycle that alias leads into but is not part of
+			}
+			seen[next] = true
+			t = next.Type
+		}
+	}
+	for _, alias := range cyclic {
+		alias.Type = nil
+	}
+}
+
+// checkStructs reports structs that contain themselves through required
+// fields, which no finite value has. Optional fields, lists, maps and
+// unions break cycles; arrays and aliases do not. Like aliases, structs of
+// different files cannot form cycles.
+func (c *checker) checkStructs(file *File) {
+	for _, decl := range file.Decls {
+		s, ok := decl.(*Struct)
+		if !ok {
+			continue
+		}
+		if containsStruct(s, s, map[*Struct]bool{}) {
+			node := c.nodes[s].(*ast.Struct)
+			c.report(file, diag.DeclarationCycle, nameRange(node.Name), "invalid recursive struct %s", s.Name)
+		}
+	}
+}
+
+// containsStruct reports whether a value of s contains a value of target
+// through its required fields.
+func containsStruct(s, target *Struct, seen map[*Struct]bool) bool {
+	if seen[s] {
+		return false
+	}
+	seen[s] = true
+	for _, field := range s.Fields {
+		if field.Optional {
+			continue
+		}
+		t := field.Type.Underlying()
+		for t != nil && t.Kind == ArrayType {
+			t = t.Elem.Underlying()
+		}
+		if t == nil || t.Kind != NamedType {
+			continue
+		}
+		if next, ok := t.Decl.(*Struct); ok && (next == target || containsStruct(next, target, seen)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUnions reports union variants that are not structs or whose
+// structs have a field with the name of the tag. It runs after the types
+// of all aliases are known.
+func (c *checker) checkUnions(file *File) {
+	for _, decl := range file.Decls {
+		union, ok := decl.(*Union)
+		if !ok {
+			continue
+		}
+		node := c.nodes[union].(*ast.Union)
+		for _, variant := range union.Variants {
+			if variant.Type == nil {
+				continue
+			}
+			n := unionVariant(node, variant.Name)
+			u := variant.Type.Underlying()
+			if u == nil {
+				continue // reported with the alias
+			}
+			s, ok := u.Decl.(*Struct)
+			if u.Kind != NamedType || !ok {
+				c.report(file, diag.InvalidUnion, typeRange(n.Type), "variant %s of %s is not a struct: %s", variant.Name, union.Name, variant.Type)
+				continue
+			}
+			for _, field := range s.Fields {
+				if field.JSONName() == union.Tag {
+					c.report(file, diag.InvalidUnion, typeRange(n.Type), "field %s of %s clashes with the tag %q of %s", field.Name, s.Name, union.Tag, union.Name)
+				}
+			}
+		}
+	}
+}
+
+func (c *checker) annotations(file *File, nodes []*ast.Annotation) Annotations {
+	var list Annotations
+	for _, node := range nodes {
+		if !valid(node.Name) {
+			continue
+		}
+		a := &Annotation{Name: node.Name.Name, Pos: node.Pos()}
+		for _, arg := range node.Args {
+			a.Args = append(a.Args, c.eval(file, arg))
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// constant evaluates a constant declaration once.
+func (c *checker) constant(k *Const) constant.Value {
+	if c.state[k] != unevaluated {
+		return k.Value
+	}
+	c.state[k] = evaluating
+
+	node := c.nodes[k].(*ast.ConstSpec)
+	value := c.eval(k.File, node.Expr)
+	if node.Type != nil {
+		k.Type = c.typ(k.File, node.Type)
+		if k.Type != nil && value.IsKnown() {
+			value = c.convert(k.File, node.Expr, value, k.Type)
+		}
+	} else {
+		k.Type = defaultType(value)
+		if _, ok := value.Int64(); value.Kind() == constant.Int && !ok {
+			c.report(k.File, diag.ConstantType, diag.At(node.Expr.Pos()), "constant %s overflows %s", value, k.Type)
+			value = constant.Value{}
+		}
+	}
+
+	k.Value = value
+	c.state[k] = evaluated
+	return value
+}
+
+func defaultType(v constant.Value) *Type {
+	var p Primitive
+	switch v.Kind() {
+	case constant.Bool:
+		p = Bool
+	case constant.Int:
+		p = Int64
+	case constant.Float:
+		p = Float64
+	case constant.String:
+		p = String
+	default:
+		return nil
+	}
+	return &Type{Kind: PrimitiveType, Primitive: p}
+}
+
+// eval evaluates a constant expression. It returns an unknown value after
+// an error and for operands that are unknown because of earlier errors.
+func (c *checker) eval(file *File, node ast.Node) constant.Value {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		v, err := constant.MakeFromLiteral(n)
+		if err != nil {
+			c.report(file, diag.InvalidConstant, diag.Span(n.Pos(), utf8.RuneCountInString(n.Value)), "%v", err)
+		}
+		return v
+
+	case *ast.QualName:
+		decl, ok := c.lookup(file, n, func(string) bool { return false })
+		if !ok {
+			return constant.Value{}
+		}
+		k, ok := decl.(*Const)
+		if !ok {
+			c.report(file, diag.NotAConstant, qualRange(n), "%s is not a constant", n.Name.Name)
+			return constant.Value{}
+		}
+		if c.state[k] == evaluating {
+			c.report(file, diag.DeclarationCycle, qualRange(n), "constant %s refers to itself", k.Name)
+			return constant.Value{}
+		}
+		return c.constant(k)
+
+	case *ast.UnaryExpr:
+		x := c.eval(file, n.Expr)
+		if !x.IsKnown() {
+			return x
+		}
+		v, err := constant.UnaryOp(n.Op, x)
+		if err != nil {
+			c.report(file, diag.InvalidConstant, diag.Span(n.Pos(), utf8.RuneCountInString(n.Op.String())), "invalid operation: %v", err)
+		}
+		return v
+
+	case *ast.BinaryExpr:
+		x, y := c.eval(file, n.Lhs), c.eval(file, n.Rhs)
+		if !x.IsKnown() || !y.IsKnown() {
+			return constant.Value{}
+		}
+		v, err := constant.BinaryOp(x, n.Op, y)
+		if err != nil {
+			c.report(file, diag.InvalidConstant, diag.At(n.Pos()), "invalid operation: %v", err)
+		}
+		return v
+	}
+	return constant.Value{}
+}
+
+var (
+	minInt = map[Primitive]int64{Int8: math.MinInt8, Int16: math.MinInt16, Int32: math.MinInt32, Int64: math.MinInt64}
+	maxInt = map[Primitive]uint64{
+		Int8: math.MaxInt8, Int16: math.MaxInt16, Int32: math.MaxInt32, Int64: math.MaxInt64,
+		Uint8: math.MaxUint8, Uint16: math.MaxUint16, Uint32: math.MaxUint32, Uint64: math.MaxUint64,
+	}
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// convert converts a value to the declared type of a constant.
+func (c *checker) convert(file *File, node ast.Node, v constant.Value, t *Type) constant.Value {
+	u := t.Underlying()
+	if u == nil {
+		return constant.Value{}
+	}
+
+	fail := func(format string, args ...any) constant.Value {
+		c.report(file, diag.ConstantType, diag.At(node.Pos()), format, args...)
+		return constant.Value{}
+	}
+	if u.Kind != PrimitiveType {
+		return fail("cannot use constant %s as %s value", v, t)
+	}
+
+	p := u.Primitive
+	switch {
+	case p.IsInteger() && v.Kind() == constant.Int:
+		i := v.Int()
+		if i.Sign() < 0 && (p.IsUnsigned() || !i.IsInt64() || i.Int64() < minInt[p]) ||
+			i.Sign() >= 0 && (!i.IsUint64() || i.Uint64() > maxInt[p]) {
+			return fail("constant %s overflows %s", v, t)
+		}
+		return v
+	case p.IsFloat() && (v.Kind() == constant.Int || v.Kind() == constant.Float):
+		f := v.Float64()
+		if p == Float32 && math.Abs(f) > math.MaxFloat32 || math.IsInf(f, 0) {
+			return fail("constant %s overflows %s", v, t)
+		}
+		return constant.MakeFloat64(f)
+	case p == Bool && v.Kind() == constant.Bool,
+		(p == String || p == Bytes) && v.Kind() == constant.String:
+		return v
+	case p == Timestamp && v.Kind() == constant.String:
+		if _, err := time.Parse(time.RFC3339Nano, v.StringVal()); err != nil {
+			return fail("constant %s is not an RFC 3339 timestamp", v)
+		}
+		return v
+	case p == UUID && v.Kind() == constant.String:
+		if !uuidPattern.MatchString(v.StringVal()) {
+			return fail("constant %s is not a UUID", v)
+		}
+		return v
+	}
+	return fail("cannot use %s constant %s as %s value", v.Kind(), v, t)
+}
@@ -0,0 +1,246 @@
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"larklang.io/lark/pkg/loader"
+)
+
+// check checks main.lark with the given files available for import.
+func check(t *testing.T, files map[string]string) (*Schema, *loader.Program) {
+	t.Helper()
+	c := &loader.Config{
+		ReadFile: func(name string) ([]byte, error) {
+			if src, ok := files[filepath.ToSlash(name)]; ok {
+				return []byte(src), nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+	prog, err := c.Load(loader.Source{Path: "main.lark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Check(prog), prog
+}
+
+func messages(prog *loader.Program) []string {
+	var list []string
+	for _, file := range prog.Diagnostics() {
+		for _, d := range file.Diagnostics {
+			list = append(list, string(d.Code)+" "+d.Message)
+		}
+	}
+	return list
+}
+
+func TestCheck(t *testing.T) {
+	s, prog := check(t, map[string]string{
+		"common/types.lark": `
+// Max is the limit.
+const Max: int32 = 2 * 8
+
+type Id = uuid
+`,
+		"main.lark": `// Users.
+
+import "common/types"
+
+const Limit = types.Max + 1
+const Scale: float32 = 1
+const Nothing = null
+
+// A user.
+@table("users") @deprecated("use Account")
+struct User {
+    @pk
+    id: types.Id
+    name?: string
+    tags: map[string, list[Tag]]
+}
+
+type Tag = string
+
+// A role.
+enum Role {
+    guest
+    @deprecated
+    user = types.Max
+    admin
+}
+
+struct Grants {
+    by_role: map[Role, list[string]]
+}
+
+// A subject of a grant.
+@tag("type")
+union Subject {
+    user: User
+    @deprecated
+    account: Account
+}
+
+type Account = User
+
+interface Users {
+    @http("GET", "/users/{id}")
+    func get(@path id: types.Id) -> User
+    func ping()
+}
+`,
+	})
+	if prog.HasErrors() {
+		t.Fatalf("unexpected diagnostics %q", messages(prog))
+	}
+
+	if len(s.Files) != 2 || len(s.Roots) != 1 || s.Roots[0] != s.Files[1] {
+		t.Fatalf("got files %v and roots %v", s.Files, s.Roots)
+	}
+	types, main := s.Files[0], s.Files[1]
+	if types.Module != "common/types" || main.Module != "main" || main.Doc != "Users." {
+		t.Errorf("got files %+v and %+v", types, main)
+	}
+	if len(main.Imports) != 1 || main.Imports[0].Name != "types" || main.Imports[0].File != types {
+		t.Errorf("got imports %+v", main.Imports)
+	}
+
+	max := types.Lookup("Max").(*Const)
+	if max.Doc != "Max is the limit." || max.Type.String() != "int32" || max.Value.String() != "16" {
+		t.Errorf("got Max %+v", max)
+	}
+	limit := main.Lookup("Limit").(*Const)
+	if limit.Type.String() != "int64" || limit.Value.String() != "17" {
+		t.Errorf("got Limit = %s of type %s", limit.Value, limit.Type)
+	}
+	if scale := main.Lookup("Scale").(*Const); scale.Value.String() != "1.0" {
+		t.Errorf("got Scale = %s", scale.Value)
+	}
+	if nothing := main.Lookup("Nothing").(*Const); nothing.Type != nil || nothing.Value.String() != "null" {
+		t.Errorf("got Nothing = %s of type %s", nothing.Value, nothing.Type)
+	}
+
+	user := main.Lookup("User").(*Struct)
+	if user.Doc != "A user." || !user.Annotations.Has("table") {
+		t.Errorf("got User %+v", user)
+	}
+	if msg, ok := user.Deprecated(); !ok || msg != "use Account" {
+		t.Errorf("got deprecation %q, %v", msg, ok)
+	}
+	var fields []string
+	for _, field := range user.Fields {
+		fields = append(fields, field.Name+" "+field.Type.String())
+	}
+	if got, want := strings.Join(fields, "; "), "id common/types.Id; name string; tags map[string, list[main.Tag]]"; got != want {
+		t.Errorf("got fields %q; want %q", got, want)
+	}
+	if u := user.Fields[0].Type.Underlying(); u.Kind != PrimitiveType || u.Primitive != UUID {
+		t.Errorf("got underlying type %s", u)
+	}
+	if !user.Fields[1].Optional || !user.Fields[0].Annotations.Has("pk") {
+		t.Errorf("got fields %+v", user.Fields)
+	}
+
+	role := main.Lookup("Role").(*Enum)
+	var members []string
+	for _, m := range role.Members {
+		members = append(members, fmt.Sprintf("%s=%d", m.Name, m.Value))
+	}
+	if got := strings.Join(members, " "); got != "guest=0 user=16 admin=17" || role.Doc != "A role." || !role.Members[1].Annotations.Has("deprecated") {
+		t.Errorf("got enum %s with members %q", role.Name, got)
+	}
+	if key := main.Lookup("Grants").(*Struct).Fields[0].Type.Key; key.Decl != role {
+		t.Errorf("got key type %s", key)
+	}
+
+	subject := main.Lookup("Subject").(*Union)
+	if subject.Tag != "type" || len(subject.Variants) != 2 || subject.Doc != "A subject of a grant." {
+		t.Errorf("got union %+v", subject)
+	}
+	if account := subject.Variants[1]; account.Type.Underlying().Decl != user || !account.Annotations.Has("deprecated") {
+		t.Errorf("got variant %+v", account)
+	}
+
+	get := main.Lookup("Users").(*Interface).Methods[0]
+	if path, ok := get.Annotations.Lookup("http").String(1); !ok || path != "/users/{id}" {
+		t.Errorf("got http path %q", path)
+	}
+	if get.Result.Decl != user || len(get.Params) != 1 || !get.Params[0].Annotations.Has("path") {
+		t.Errorf("got method %+v", get)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"const a = b", []string{"E0300 undefined: b"}},
+		{"struct S { x: T }", []string{"E0300 undefined: T"}},
+		{"import \"m\"\nconst a = m.c", []string{"E0300 undefined: m.c"}},
+		{"const a = 1\nstruct S { x: a }", []string{"E0301 a is a constant, not a type"}},
+		{"interface I {}\nstruct S { x: I }", []string{"E0301 interface I cannot be used as a data type"}},
+		{"struct S { x: list }", []string{"E0302 list takes 1 type argument, got 0"}},
+		{"struct S { x: map[string] }", []string{"E0302 map takes 2 type arguments, got 1"}},
+		{"struct S { x: int[string] }", []string{"E0302 int takes no type arguments"}},
+		{"struct T {}\nstruct S { x: map[T, int] }", []string{"E0302 invalid map key type main.T"}},
+		{"struct S {}\nconst a = S", []string{"E0303 S is not a constant"}},
+		{"const a = 1 / 0", []string{"E0304 invalid operation: division by zero"}},
+		{"const a = \"x\" - 1", []string{"E0304 invalid operation: operator - not defined on string and int"}},
+		{"const a = b\nconst b = a", []string{"E0305 constant a refers to itself"}},
+		{"type A = B\ntype B = A", []string{"E0305 invalid recursive type alias A", "E0305 invalid recursive type alias B"}},
+		{"type A = list[A]", nil},
+		{"struct S { s: S }", []string{"E0305 invalid recursive struct S"}},
+		{"struct S { t: T }\ntype T = [S; 2]", []string{"E0305 invalid recursive struct S"}},
+		{"struct S { t: T }\nstruct T { s: S }", []string{"E0305 invalid recursive struct S", "E0305 invalid recursive struct T"}},
+		{"struct S { s?: S\n l: list[S]\n m: map[string, S] }", nil},
+		{"struct S { u: U }\nunion U { s: S }", nil},
+		{"struct S { x: int }\nconst S = 1", []string{"E0306 constant S redeclared"}},
+		{"struct S { x: int\n x: string }", []string{"E0306 field x redeclared"}},
+		{"const a: int8 = 128", []string{"E0307 constant 128 overflows int8"}},
+		{"const a = 9223372036854775807 + 1", []string{"E0307 constant 9223372036854775808 overflows int64"}},
+		{"const a: uint64 = 9223372036854775807 + 1", nil},
+		{"const a: uint8 = -1", []string{"E0307 constant -1 overflows uint8"}},
+		{"const a: float32 = 1e300", []string{"E0307 constant 1e+300 overflows float32"}},
+		{"const a: string = 1", []string{"E0307 cannot use int constant 1 as string value"}},
+		{"const a: timestamp = \"yesterday\"", []string{"E0307 constant \"yesterday\" is not an RFC 3339 timestamp"}},
+		{"const a: uuid = \"123\"", []string{"E0307 constant \"123\" is not a UUID"}},
+		{"struct S {}\nconst a: S = 1", []string{"E0307 cannot use constant 1 as main.S value"}},
+		{"@min(a)\nstruct S {}", []string{"E0300 undefined: a"}},
+		{"enum E { a\n a }", []string{"E0306 enum member a redeclared"}},
+		{"enum E { a = 1\n b = 0\n c }", []string{"E0308 enum value 1 of c is already used by a"}},
+		{"enum E { a = \"x\" }", []string{`E0308 enum value "x" is not an int32`}},
+		{"enum E { a = 2147483647\n b }", []string{"E0308 enum value 2147483648 of b overflows int32"}},
+		{"enum E { a }\nstruct S { x: map[E, int] }", nil},
+		{"enum E { a }\nconst E = 1", []string{"E0306 constant E redeclared"}},
+		{"struct S {}\nunion U { a: S }", nil},
+		{"struct S {}\nunion U { a: S\n a: S }", []string{"E0306 union variant a redeclared"}},
+		{"union U { a: string }", []string{"E0309 variant a of U is not a struct: string"}},
+		{"type T = list[S]\nstruct S {}\nunion U { a: T }", []string{"E0309 variant a of U is not a struct: main.T"}},
+		{"struct S { kind: string }\nunion U { a: S }", []string{`E0309 field kind of S clashes with the tag "kind" of U`}},
+		{"struct S { @json(\"t\") x: string }\n@tag(\"t\")\nunion U { a: S }", []string{`E0309 field x of S clashes with the tag "t" of U`}},
+		{"struct S { kind: string }\n@tag(\"type\")\nunion U { a: S }", nil},
+		{"@tag(1)\nunion U {}", []string{"E0309 @tag takes one non-empty string"}},
+		{"union U {}\nstruct S { x: map[U, int] }", []string{"E0302 invalid map key type main.U"}},
+		{"const n = 4\nstruct S { x: [[uint8; n * 2]; 3] }", nil},
+		{"struct S { x: [uint8; 0] }", []string{"E0310 array length 0 is not a positive int32"}},
+		{"struct S { x: [uint8; 1.5] }", []string{"E0310 array length 1.5 is not a positive int32"}},
+		{"struct S { x: [uint8; 4294967296] }", []string{"E0310 array length 4294967296 is not a positive int32"}},
+		{"struct S { x: [T; 2] }", []string{"E0300 undefined: T"}},
+		{"type A = [A; 2]", []string{"E0305 invalid recursive type alias A"}},
+		{"type A = list[[A; 2]]", nil},
+		{"struct S {}\nunion U { a: [S; 2] }", []string{"E0309 variant a of U is not a struct: [main.S; 2]"}},
+	}
+
+	for _, test := range tests {
+		_, prog := check(t, map[string]string{"main.lark": test.src, "m.lark": "const b = 1\n"})
+		got := messages(prog)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: got diagnostics %q; want %q", test.src, got, test.want)
+		}
+	}
+}
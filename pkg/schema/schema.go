@@ -0,0 +1,220 @@
+// Package schema checks loaded Lark files and builds the model that code
+// generators work on.
+//
+// In the model, every type refers to its declaration, every constant and
+// annotation argument is evaluated, and doc comments are plain text.
+// [Check] builds the model from a [loader.Program].
+package schema
+
+import (
+	"larklang.io/lark/pkg/ast"
+	"larklang.io/lark/pkg/constant"
+	"larklang.io/lark/pkg/scanner"
+)
+
+// A Schema is a checked set of files that is closed under imports.
+type Schema struct {
+	// Files holds all files, each after the files it imports.
+	Files []*File
+
+	// Roots are the files that code is generated for.
+	Roots []*File
+}
+
+// A File is a checked source file.
+type File struct {
+	Path    string // file system path
+	Module  string // module path, see loader.File
+	Doc     string // text of the comment that starts the file, if not a doc comment
+	Imports []*Import
+	Decls   []Decl // declarations in source order
+
+	AST *ast.File
+}
+
+// An Import is an import of a module.
+type Import struct {
+	Name string // name under which the module is visible in the file
+	File *File
+}
+
+// Lookup returns the declaration of name in the file, or nil.
+func (f *File) Lookup(name string) Decl {
+	for _, decl := range f.Decls {
+		if decl.DeclInfo().Name == name {
+			return decl
+		}
+	}
+	return nil
+}
+
+// ImportOf returns the import of file, or nil if f does not import it.
+func (f *File) ImportOf(file *File) *Import {
+	for _, imp := range f.Imports {
+		if imp.File == file {
+			return imp
+		}
+	}
+	return nil
+}
+
+// A Decl is a declaration of a file: a *Const, *Alias, *Struct, *Enum,
+// *Union or *Interface.
+type Decl interface {
+	DeclInfo() *Info
+}
+
+// Info holds what declarations and their members have in common.
+type Info struct {
+	Name        string
+	Doc         string
+	Annotations Annotations
+	File        *File
+	Pos         scanner.Pos // position of the name
+}
+
+func (c *Info) DeclInfo() *Info { return c }
+
+// Deprecated returns the message of a @deprecated annotation and whether
+// there is one.
+func (c *Info) Deprecated() (string, bool) {
+	a := c.Annotations.Lookup("deprecated")
+	if a == nil {
+		return "", false
+	}
+	msg, _ := a.String(0)
+	return msg, true
+}
+
+// A Const is a constant declaration. Type is the declared type or, if
+// there is none, the type of the value: int64, float64, string or bool.
+// The type of null is nil.
+type Const struct {
+	Info
+	Type  *Type
+	Value constant.Value
+}
+
+// An Alias is a type alias.
+type Alias struct {
+	Info
+	Type *Type
+}
+
+// A Struct is a struct declaration.
+type Struct struct {
+	Info
+	Fields []*Field
+}
+
+// A Field is a field of a struct.
+type Field struct {
+	Info
+	Optional bool
+	Type     *Type
+}
+
+// JSONName returns the name of the field in JSON: the argument of a
+// @json("name") annotation, or the name of the field.
+func (f *Field) JSONName() string {
+	if name, ok := f.Annotations.Lookup("json").String(0); ok {
+		return name
+	}
+	return f.Name
+}
+
+// An Enum is an enumeration of named int32 values. The values of the
+// members are distinct.
+type Enum struct {
+	Info
+	Members []*EnumMember
+}
+
+// An EnumMember is a member of an enum. Members without an explicit value
+// have the value of the previous member plus one, or 0 if they come first.
+type EnumMember struct {
+	Info
+	Value int64
+}
+
+// DefaultTag is the name of the tag member of unions without a @tag
+// annotation.
+const DefaultTag = "kind"
+
+// A Union is a tagged union of structs. In JSON, a value of a union is the
+// object of its variant's struct with one more member, the tag, whose
+// value is the name of the variant.
+type Union struct {
+	Info
+	Tag      string // name of the tag member: the argument of @tag("name"), or DefaultTag
+	Variants []*Variant
+}
+
+// A Variant is a variant of a union. The underlying type of Type is a
+// struct that has no field with the name of the tag.
+type Variant struct {
+	Info
+	Type *Type
+}
+
+// An Interface is an interface declaration.
+type Interface struct {
+	Info
+	Methods []*Method
+}
+
+// A Method is a method of an interface.
+type Method struct {
+	Info
+	Params []*Param
+	Result *Type // or nil
+}
+
+// A Param is a parameter of a method.
+type Param struct {
+	Name        string
+	Annotations Annotations
+	Type        *Type
+}
+
+// An Annotation is an annotation with evaluated arguments.
+type Annotation struct {
+	Name string
+	Args []constant.Value
+	Pos  scanner.Pos
+}
+
+// Annotations is a list of annotations in source order.
+type Annotations []*Annotation
+
+// Lookup returns the first annotation with the given name, or nil.
+func (list Annotations) Lookup(name string) *Annotation {
+	for _, a := range list {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Has reports whether there is an annotation with the given name.
+func (list Annotations) Has(name string) bool {
+	return list.Lookup(name) != nil
+}
+
+// String returns the i'th argument of a if it is a string.
+func (a *Annotation) String(i int) (string, bool) {
+	if a == nil || i >= len(a.Args) || a.Args[i].Kind() != constant.String {
+		return "", false
+	}
+	return a.Args[i].StringVal(), true
+}
+
+// Int returns the i'th argument of a if it is an integer that fits into
+// an int64.
+func (a *Annotation) Int(i int) (int64, bool) {
+	if a == nil || i >= len(a.Args) {
+		return 0, false
+	}
+	return a.Args[i].Int64()
+}
@@ -0,0 +1,157 @@
+package schema
+
+import "strconv"
+
+// A Primitive is a built-in scalar type.
+type Primitive int
+
+const (
+	Bool Primitive = iota + 1
+	Int8
+	Int16
+	Int32
+	Int64
+	Uint8
+	Uint16
+	Uint32
+	Uint64
+	Float32
+	Float64
+	String
+	Bytes
+	Timestamp // instant in time, RFC 3339 in text encodings
+	UUID
+)
+
+var primitives = [...]string{
+	Bool:      "bool",
+	Int8:      "int8",
+	Int16:     "int16",
+	Int32:     "int32",
+	Int64:     "int64",
+	Uint8:     "uint8",
+	Uint16:    "uint16",
+	Uint32:    "uint32",
+	Uint64:    "uint64",
+	Float32:   "float32",
+	Float64:   "float64",
+	String:    "string",
+	Bytes:     "bytes",
+	Timestamp: "timestamp",
+	UUID:      "uuid",
+}
+
+// primitiveNames maps the names of primitive types to types; int, float
+// and byte are alternative names.
+var primitiveNames = map[string]Primitive{
+	"int":   Int64,
+	"float": Float64,
+	"byte":  Uint8,
+}
+
+func init() {
+	for p, name := range primitives {
+		if name != "" {
+			primitiveNames[name] = Primitive(p)
+		}
+	}
+}
+
+// LookupPrimitive returns the primitive type with the given name.
+func LookupPrimitive(name string) (Primitive, bool) {
+	p, ok := primitiveNames[name]
+	return p, ok
+}
+
+func (p Primitive) String() string {
+	if 0 < p && p < Primitive(len(primitives)) {
+		return primitives[p]
+	}
+	return "primitive(" + strconv.Itoa(int(p)) + ")"
+}
+
+// IsInteger reports whether p is a signed or unsigned integer type.
+func (p Primitive) IsInteger() bool {
+	return Int8 <= p && p <= Uint64
+}
+
+// IsUnsigned reports whether p is an unsigned integer type.
+func (p Primitive) IsUnsigned() bool {
+	return Uint8 <= p && p <= Uint64
+}
+
+// IsFloat reports whether p is a floating-point type.
+func (p Primitive) IsFloat() bool {
+	return p == Float32 || p == Float64
+}
+
+// Bits returns the size of integer and floating-point types in bits, and
+// 0 for other types.
+func (p Primitive) Bits() int {
+	switch p {
+	case Int8, Uint8:
+		return 8
+	case Int16, Uint16:
+		return 16
+	case Int32, Uint32, Float32:
+		return 32
+	case Int64, Uint64, Float64:
+		return 64
+	}
+	return 0
+}
+
+// TypeKind is the kind of a type.
+type TypeKind int
+
+const (
+	PrimitiveType TypeKind = iota + 1
+	ListType
+	MapType
+	NamedType
+	ArrayType
+)
+
+// A Type is a reference to a type.
+type Type struct {
+	Kind      TypeKind
+	Primitive Primitive // for PrimitiveType
+	Key       *Type     // for MapType
+	Elem      *Type     // element type for ListType and ArrayType, value type for MapType
+	Len       int64     // for ArrayType
+	Decl      Decl      // for NamedType: *Alias, *Struct, *Enum or *Union
+}
+
+// Underlying returns t with aliases resolved.
+func (t *Type) Underlying() *Type {
+	for t != nil && t.Kind == NamedType {
+		alias, ok := t.Decl.(*Alias)
+		if !ok {
+			break
+		}
+		t = alias.Type
+	}
+	return t
+}
+
+// String returns the type in Lark syntax. Named types are qualified by the
+// module path of their file.
+func (t *Type) String() string {
+	if t == nil {
+		return "<nil>"
+	}
+	switch t.Kind {
+	case PrimitiveType:
+		return t.Primitive.String()
+	case ListType:
+		return "list[" + t.Elem.String() + "]"
+	case MapType:
+		return "map[" + t.Key.String() + ", " + t.Elem.String() + "]"
+	case ArrayType:
+		return "[" + t.Elem.String() + "; " + strconv.FormatInt(t.Len, 10) + "]"
+	case NamedType:
+		c := t.Decl.DeclInfo()
+		return c.File.Module + "." + c.Name
+	}
+	return "<invalid>"
+}